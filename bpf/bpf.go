@@ -0,0 +1,141 @@
+// Seccomp BPF program support for libseccomp Go bindings
+// Decodes classic BPF programs as generated by libseccomp
+
+// Package bpf decodes, disassembles, simulates and loads the classic BPF
+// programs executed by the kernel for seccomp filters, such as those written
+// by seccomp.ScmpFilter.ExportBPF. It does not depend on libseccomp.
+package bpf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+)
+
+// Instruction is a single classic BPF instruction (struct sock_filter).
+//
+// Op: opcode, combining the instruction class, size, mode and source
+// Jt: relative jump offset taken if a conditional jump is true
+// Jf: relative jump offset taken if a conditional jump is false
+// K:  generic multiuse field (constant, offset or return value)
+//
+type Instruction struct {
+	Op uint16
+	Jt uint8
+	Jf uint8
+	K  uint32
+}
+
+// InstructionSize is the size of an encoded Instruction in bytes.
+const InstructionSize = 8
+
+// Instruction classes
+const (
+	ClassLd   = 0x00
+	ClassLdx  = 0x01
+	ClassSt   = 0x02
+	ClassStx  = 0x03
+	ClassAlu  = 0x04
+	ClassJmp  = 0x05
+	ClassRet  = 0x06
+	ClassMisc = 0x07
+)
+
+// Fields of an opcode
+const (
+	// Load size
+	SizeW = 0x00
+	SizeH = 0x08
+	SizeB = 0x10
+	// Load mode
+	ModeImm = 0x00
+	ModeAbs = 0x20
+	ModeInd = 0x40
+	ModeMem = 0x60
+	ModeLen = 0x80
+	ModeMsh = 0xa0
+	// ALU operations
+	AluAdd = 0x00
+	AluSub = 0x10
+	AluMul = 0x20
+	AluDiv = 0x30
+	AluOr  = 0x40
+	AluAnd = 0x50
+	AluLsh = 0x60
+	AluRsh = 0x70
+	AluNeg = 0x80
+	AluMod = 0x90
+	AluXor = 0xa0
+	// Jump operations
+	JmpJa   = 0x00
+	JmpJeq  = 0x10
+	JmpJgt  = 0x20
+	JmpJge  = 0x30
+	JmpJset = 0x40
+	// Operand source
+	SrcK = 0x00
+	SrcX = 0x08
+	SrcA = 0x10
+	// Miscellaneous operations
+	MiscTax = 0x00
+	MiscTxa = 0x80
+)
+
+// Limits enforced by the kernel on seccomp programs
+const (
+	// MaxInstructions is the maximum length of a program
+	MaxInstructions = 4096
+	// MemWords is the number of words of scratch memory
+	MemWords = 16
+)
+
+// Class returns the instruction class of the opcode.
+func (i Instruction) Class() uint16 {
+	return i.Op & 0x07
+}
+
+// Decode decodes a program from its binary representation, as an array of
+// struct sock_filter in host byte order.
+// Returns an error if the length of data is not a multiple of
+// InstructionSize.
+func Decode(data []byte) ([]Instruction, error) {
+	if len(data)%InstructionSize != 0 {
+		return nil, fmt.Errorf("program length %d is not a multiple of %d", len(data), InstructionSize)
+	}
+
+	prog := make([]Instruction, 0, len(data)/InstructionSize)
+	for off := 0; off < len(data); off += InstructionSize {
+		prog = append(prog, Instruction{
+			Op: nativeEndian.Uint16(data[off:]),
+			Jt: data[off+2],
+			Jf: data[off+3],
+			K:  nativeEndian.Uint32(data[off+4:]),
+		})
+	}
+
+	return prog, nil
+}
+
+// Encode encodes a program into its binary representation, the inverse of
+// Decode.
+func Encode(prog []Instruction) []byte {
+	data := make([]byte, len(prog)*InstructionSize)
+	for n, ins := range prog {
+		off := n * InstructionSize
+		nativeEndian.PutUint16(data[off:], ins.Op)
+		data[off+2] = ins.Jt
+		data[off+3] = ins.Jf
+		nativeEndian.PutUint32(data[off+4:], ins.K)
+	}
+
+	return data
+}
+
+// Byte order of the host, used by the kernel for programs and seccomp_data
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
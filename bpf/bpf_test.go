@@ -0,0 +1,97 @@
+// Tests for the seccomp BPF support of libseccomp Go bindings
+
+package bpf
+
+import (
+	"strings"
+	"testing"
+)
+
+// Allows getpid, fails read with EPERM and kills on anything else
+var testProgram = []Instruction{
+	{Op: ClassLd | SizeW | ModeAbs, K: 0},
+	{Op: ClassJmp | JmpJeq | SrcK, Jt: 2, Jf: 0, K: 39},
+	{Op: ClassJmp | JmpJeq | SrcK, Jt: 0, Jf: 2, K: 0},
+	{Op: ClassRet | SrcK, K: RetErrno | 1},
+	{Op: ClassRet | SrcK, K: RetAllow},
+	{Op: ClassRet | SrcK, K: RetKillThread},
+}
+
+func TestEncodeDecode(t *testing.T) {
+	data := Encode(testProgram)
+	if len(data) != len(testProgram)*InstructionSize {
+		t.Fatalf("Unexpected encoded length %d", len(data))
+	}
+
+	prog, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Error decoding program: %s", err)
+	}
+	for i := range prog {
+		if prog[i] != testProgram[i] {
+			t.Errorf("Instruction %d: got %+v, want %+v", i, prog[i], testProgram[i])
+		}
+	}
+
+	if _, err := Decode(data[1:]); err == nil {
+		t.Errorf("Decoding a truncated program should fail")
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, test := range []struct {
+		nr   int32
+		want uint32
+	}{
+		{39, RetAllow},
+		{0, RetErrno | 1},
+		{1, RetKillThread},
+	} {
+		ret, err := Run(testProgram, &Data{Nr: test.nr})
+		if err != nil {
+			t.Fatalf("Error running program: %s", err)
+		}
+		if ret != test.want {
+			t.Errorf("Syscall %d: got %s, want %s", test.nr, ActionString(ret), ActionString(test.want))
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, prog := range [][]Instruction{
+		nil,
+		{{Op: ClassLd | SizeW | ModeAbs, K: 2}, {Op: ClassRet, K: RetAllow}},
+		{{Op: ClassLd | SizeW | ModeAbs, K: DataSize}, {Op: ClassRet, K: RetAllow}},
+		{{Op: ClassJmp | JmpJeq, Jt: 1}, {Op: ClassRet, K: RetAllow}},
+		{{Op: ClassAlu | AluDiv | SrcK, K: 0}, {Op: ClassRet, K: RetAllow}},
+		{{Op: ClassAlu | AluMod | SrcK, K: 2}, {Op: ClassRet, K: RetAllow}},
+		{{Op: ClassSt, K: MemWords}, {Op: ClassRet, K: RetAllow}},
+		{{Op: ClassLd | SizeW | ModeAbs, K: 0}},
+	} {
+		if err := Validate(prog); err == nil {
+			t.Errorf("Program %+v should be rejected", prog)
+		}
+	}
+
+	if err := Validate(testProgram); err != nil {
+		t.Errorf("Error validating program: %s", err)
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	var b strings.Builder
+	if err := Disassemble(&b, testProgram); err != nil {
+		t.Fatalf("Error disassembling program: %s", err)
+	}
+
+	for _, want := range []string{
+		" 0000: 0x20 0x00 0x00 0x00000000   ld  $data[0]\n",
+		" 0001: 0x15 0x02 0x00 0x00000027   jeq 39 true:0004 false:0002\n",
+		" 0003: 0x06 0x00 0x00 0x00050001   ret ERRNO(1)\n",
+		" 0004: 0x06 0x00 0x00 0x7fff0000   ret ALLOW\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Disassembly does not contain %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Seccomp BPF disassembler for libseccomp Go bindings
+// Prints seccomp programs in the format of libseccomp's scmp_bpf_disasm
+
+package bpf
+
+import (
+	"fmt"
+	"io"
+)
+
+// Mnemonics of the ALU and conditional jump operations
+var (
+	aluNames = map[uint16]string{
+		AluAdd: "add", AluSub: "sub", AluMul: "mul", AluDiv: "div",
+		AluOr: "or", AluAnd: "and", AluLsh: "lsh", AluRsh: "rsh",
+		AluMod: "mod", AluXor: "xor",
+	}
+	jumpNames = map[uint16]string{
+		JmpJeq: "jeq", JmpJgt: "jgt", JmpJge: "jge", JmpJset: "jset",
+	}
+)
+
+// Disassemble writes a listing of prog to w, one instruction per line, in
+// the format used by the scmp_bpf_disasm tool shipped with libseccomp.
+// Jump targets are printed as absolute instruction numbers.
+// Returns an error if writing to w failed.
+func Disassemble(w io.Writer, prog []Instruction) error {
+	if _, err := fmt.Fprintf(w, " line  OP   JT   JF   K\n=================================\n"); err != nil {
+		return err
+	}
+
+	for pc, ins := range prog {
+		_, err := fmt.Fprintf(w, " %04d: 0x%02x 0x%02x 0x%02x 0x%08x   %s\n",
+			pc, ins.Op, ins.Jt, ins.Jf, ins.K, decodeInstruction(pc, ins))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ActionString returns a description of a seccomp filter return value,
+// e.g. "ERRNO(1)".
+func ActionString(ret uint32) string {
+	data := ret & RetDataMask
+	switch ret & RetActionMask {
+	case RetKillProcess:
+		return "KILL_PROCESS"
+	case RetKillThread:
+		return "KILL"
+	case RetTrap:
+		return "TRAP"
+	case RetErrno:
+		return fmt.Sprintf("ERRNO(%d)", data)
+	case RetUserNotif:
+		return "NOTIFY"
+	case RetTrace:
+		return fmt.Sprintf("TRACE(%d)", data)
+	case RetLog:
+		return "LOG"
+	case RetAllow:
+		return "ALLOW"
+	default:
+		return fmt.Sprintf("0x%08x", ret)
+	}
+}
+
+// Helper - Describe a single instruction located at pc
+func decodeInstruction(pc int, ins Instruction) string {
+	src := fmt.Sprintf("%d", ins.K)
+	if ins.Op&SrcX != 0 {
+		src = "$x"
+	}
+
+	switch ins.Class() {
+	case ClassLd, ClassLdx:
+		name := "ld"
+		if ins.Class() == ClassLdx {
+			name = "ldx"
+		}
+		switch ins.Op & 0xe0 {
+		case ModeAbs:
+			return fmt.Sprintf("%-3s $data[%d]", name, ins.K)
+		case ModeLen:
+			return fmt.Sprintf("%-3s $len", name)
+		case ModeImm:
+			return fmt.Sprintf("%-3s %d", name, ins.K)
+		case ModeMem:
+			return fmt.Sprintf("%-3s $temp[%d]", name, ins.K)
+		}
+	case ClassSt:
+		return fmt.Sprintf("st  $temp[%d]", ins.K)
+	case ClassStx:
+		return fmt.Sprintf("stx $temp[%d]", ins.K)
+	case ClassAlu:
+		if ins.Op&0xf0 == AluNeg {
+			return "neg"
+		} else if name, ok := aluNames[ins.Op&0xf0]; ok {
+			return fmt.Sprintf("%-3s %s", name, src)
+		}
+	case ClassJmp:
+		next := pc + 1
+		if ins.Op&0xf0 == JmpJa {
+			return fmt.Sprintf("jmp %04d", next+int(ins.K))
+		} else if name, ok := jumpNames[ins.Op&0xf0]; ok {
+			return fmt.Sprintf("%-3s %s true:%04d false:%04d", name, src, next+int(ins.Jt), next+int(ins.Jf))
+		}
+	case ClassRet:
+		if ins.Op&0x18 == SrcA {
+			return "ret $a"
+		}
+		return "ret " + ActionString(ins.K)
+	case ClassMisc:
+		if ins.Op&0xf8 == MiscTxa {
+			return "txa"
+		}
+		return "tax"
+	}
+
+	return "???"
+}
@@ -0,0 +1,83 @@
+// +build linux
+
+// Tests running programs generated by libseccomp
+
+package bpf_test
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	seccomp "github.com/seccomp/libseccomp-golang"
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestRunExportedFilter(t *testing.T) {
+	auditArch := map[string]uint32{
+		"amd64": 0xc000003e, // AUDIT_ARCH_X86_64
+		"arm64": 0xc00000b7, // AUDIT_ARCH_AARCH64
+	}[runtime.GOARCH]
+	if auditArch == 0 {
+		t.Skipf("Skipping test: no audit architecture known for %s", runtime.GOARCH)
+	}
+
+	filter, err := seccomp.NewFilter(seccomp.ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	call, err := seccomp.GetSyscallFromName("getpid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	if err := filter.AddRule(call, seccomp.ActErrno.SetErrno(unix.EPERM)); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+	cond, err := seccomp.MakeCondition(0, seccomp.CompareEqual, 42)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+	call, err = seccomp.GetSyscallFromName("close")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	if err := filter.AddRuleConditional(call, seccomp.ActLog, []seccomp.ScmpCondition{cond}); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	var data bytes.Buffer
+	if err := filter.ExportBPF(&data); err != nil {
+		t.Fatalf("Error exporting filter: %s", err)
+	}
+	prog, err := bpf.Decode(data.Bytes())
+	if err != nil {
+		t.Fatalf("Error decoding exported filter: %s", err)
+	}
+
+	for _, test := range []struct {
+		data bpf.Data
+		want uint32
+	}{
+		{bpf.Data{Nr: unix.SYS_GETPID, Arch: auditArch}, bpf.RetErrno | uint32(unix.EPERM)},
+		{bpf.Data{Nr: unix.SYS_CLOSE, Arch: auditArch, Args: [6]uint64{42}}, bpf.RetLog},
+		{bpf.Data{Nr: unix.SYS_CLOSE, Arch: auditArch, Args: [6]uint64{43}}, bpf.RetAllow},
+		{bpf.Data{Nr: unix.SYS_READ, Arch: auditArch}, bpf.RetAllow},
+	} {
+		ret, err := bpf.Run(prog, &test.data)
+		if err != nil {
+			t.Fatalf("Error running exported filter: %s", err)
+		}
+		if ret != test.want {
+			t.Errorf("Syscall %d: got %s, want %s", test.data.Nr, bpf.ActionString(ret), bpf.ActionString(test.want))
+		}
+	}
+
+	// Other architectures are killed by the filter
+	ret, err := bpf.Run(prog, &bpf.Data{Nr: unix.SYS_GETPID, Arch: auditArch ^ 1})
+	if err != nil || ret&bpf.RetActionMask != bpf.RetKillThread {
+		t.Errorf("Unexpected result for a foreign architecture: %s, %v", bpf.ActionString(ret), err)
+	}
+}
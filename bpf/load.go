@@ -0,0 +1,58 @@
+// +build linux
+
+// Seccomp BPF program loader for libseccomp Go bindings
+// Installs pre-compiled programs without libseccomp
+
+package bpf
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Operation of seccomp(2) installing a filter
+const seccompSetModeFilter = 1
+
+// Load validates and installs the encoded seccomp program prog on the
+// calling thread with the seccomp(2) flags flags (SECCOMP_FILTER_FLAG_*),
+// without using libseccomp, e.g. to deploy programs compiled elsewhere with
+// seccomp.ScmpFilter.ExportBPF. Without flags, kernels lacking seccomp(2)
+// are supported through prctl(PR_SET_SECCOMP).
+// The caller must have set the no new privileges bit, or have CAP_SYS_ADMIN.
+// Returns the non-negative return value of seccomp(2), e.g. the notification
+// fd with SECCOMP_FILTER_FLAG_NEW_LISTENER, or an error if prog is not a
+// valid program or could not be installed. Errors of the kernel are returned
+// as unix.Errno.
+func Load(prog []byte, flags uint) (int, error) {
+	insns, err := Decode(prog)
+	if err != nil {
+		return -1, err
+	}
+	if err := Validate(insns); err != nil {
+		return -1, fmt.Errorf("invalid program: %v", err)
+	}
+
+	fprog := unix.SockFprog{
+		Len:    uint16(len(insns)),
+		Filter: (*unix.SockFilter)(unsafe.Pointer(&prog[0])),
+	}
+	defer runtime.KeepAlive(prog)
+
+	ret, _, errno := unix.Syscall(unix.SYS_SECCOMP, seccompSetModeFilter, uintptr(flags),
+		uintptr(unsafe.Pointer(&fprog)))
+	if errno == unix.ENOSYS && flags == 0 {
+		// Linux before 3.17
+		err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&fprog)), 0, 0)
+		if err != nil {
+			return -1, err
+		}
+		return 0, nil
+	} else if errno != 0 {
+		return -1, errno
+	}
+
+	return int(ret), nil
+}
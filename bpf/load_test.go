@@ -0,0 +1,25 @@
+// +build linux
+
+// Tests for the seccomp BPF loader of libseccomp Go bindings
+
+package bpf
+
+import (
+	"testing"
+)
+
+func TestLoadInvalid(t *testing.T) {
+	// Loading valid programs would confine the test process, see the tests
+	// of seccomp.LoadRawBPF
+	prog := Encode(testProgram)
+	for _, bad := range [][]byte{
+		nil,
+		prog[:len(prog)-1],
+		Encode([]Instruction{{Op: ClassLd | SizeW | ModeAbs, K: 0}}),
+		Encode([]Instruction{{Op: ClassJmp | JmpJa, K: 1}, {Op: ClassRet | SrcK, K: RetAllow}}),
+	} {
+		if _, err := Load(bad, 0); err == nil {
+			t.Errorf("Loading invalid program %x should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Seccomp BPF simulator for libseccomp Go bindings
+// Runs seccomp programs against syscall data without loading them
+
+package bpf
+
+import (
+	"fmt"
+)
+
+// Filter return values (SECCOMP_RET_*), in order of decreasing precedence
+const (
+	RetKillProcess uint32 = 0x80000000
+	RetKillThread  uint32 = 0x00000000
+	RetTrap        uint32 = 0x00030000
+	RetErrno       uint32 = 0x00050000
+	RetUserNotif   uint32 = 0x7fc00000
+	RetTrace       uint32 = 0x7ff00000
+	RetLog         uint32 = 0x7ffc0000
+	RetAllow       uint32 = 0x7fff0000
+
+	// RetActionMask selects the action of a return value
+	RetActionMask uint32 = 0xffff0000
+	// RetDataMask selects the data (e.g., errno) of a return value
+	RetDataMask uint32 = 0x0000ffff
+)
+
+// DataSize is the size of struct seccomp_data, the input of seccomp programs.
+const DataSize = 64
+
+// Data is the syscall context a seccomp program is run against
+// (struct seccomp_data).
+//
+// Nr:                 syscall number, as seen by the architecture
+// Arch:               AUDIT_ARCH_* value of the syscall's architecture
+// InstructionPointer: address of the syscall instruction
+// Args:               syscall arguments
+//
+type Data struct {
+	Nr                 int32
+	Arch               uint32
+	InstructionPointer uint64
+	Args               [6]uint64
+}
+
+// Bytes returns the binary representation of d as seen by the kernel.
+func (d *Data) Bytes() []byte {
+	buf := make([]byte, DataSize)
+	nativeEndian.PutUint32(buf[0:], uint32(d.Nr))
+	nativeEndian.PutUint32(buf[4:], d.Arch)
+	nativeEndian.PutUint64(buf[8:], d.InstructionPointer)
+	for i, arg := range d.Args {
+		nativeEndian.PutUint64(buf[16+8*i:], arg)
+	}
+	return buf
+}
+
+// Validate checks prog against the rules the kernel enforces when a seccomp
+// program is loaded: its length, the instructions it uses, the bounds of its
+// jumps and memory accesses, and that it ends with a return.
+// Returns an error describing the first violation found.
+func Validate(prog []Instruction) error {
+	if len(prog) == 0 || len(prog) > MaxInstructions {
+		return fmt.Errorf("program length %d is outside of [1, %d]", len(prog), MaxInstructions)
+	}
+
+	for pc, ins := range prog {
+		if err := validateInstruction(pc, ins, len(prog)); err != nil {
+			return fmt.Errorf("instruction %d: %v", pc, err)
+		}
+	}
+
+	if prog[len(prog)-1].Class() != ClassRet {
+		return fmt.Errorf("program does not end with a return")
+	}
+
+	return nil
+}
+
+// Run executes prog against data, the way the kernel does for a syscall.
+// Returns the value returned by the program (a RetXXX action combined with
+// its data), or an error if the program is invalid.
+func Run(prog []Instruction, data *Data) (uint32, error) {
+	if err := Validate(prog); err != nil {
+		return 0, err
+	}
+
+	input := data.Bytes()
+	var a, x uint32
+	var mem [MemWords]uint32
+
+	for pc := 0; pc < len(prog); pc++ {
+		ins := prog[pc]
+		switch ins.Class() {
+		case ClassLd:
+			switch ins.Op & 0xe0 {
+			case ModeAbs:
+				a = nativeEndian.Uint32(input[ins.K:])
+			case ModeLen:
+				a = DataSize
+			case ModeImm:
+				a = ins.K
+			case ModeMem:
+				a = mem[ins.K]
+			}
+		case ClassLdx:
+			switch ins.Op & 0xe0 {
+			case ModeLen:
+				x = DataSize
+			case ModeImm:
+				x = ins.K
+			case ModeMem:
+				x = mem[ins.K]
+			}
+		case ClassSt:
+			mem[ins.K] = a
+		case ClassStx:
+			mem[ins.K] = x
+		case ClassAlu:
+			operand := ins.K
+			if ins.Op&SrcX != 0 {
+				operand = x
+			}
+			if operand == 0 && ins.Op&0xf0 == AluDiv {
+				// Division by zero aborts the program with a zero result
+				return 0, nil
+			}
+			a = alu(ins.Op&0xf0, a, operand)
+		case ClassJmp:
+			if ins.Op&0xf0 == JmpJa {
+				pc += int(ins.K)
+				break
+			}
+			operand := ins.K
+			if ins.Op&SrcX != 0 {
+				operand = x
+			}
+			if jump(ins.Op&0xf0, a, operand) {
+				pc += int(ins.Jt)
+			} else {
+				pc += int(ins.Jf)
+			}
+		case ClassRet:
+			switch ins.Op & 0x18 {
+			case SrcA:
+				return a, nil
+			default:
+				return ins.K, nil
+			}
+		case ClassMisc:
+			if ins.Op&0xf8 == MiscTxa {
+				a = x
+			} else {
+				x = a
+			}
+		}
+	}
+
+	// Unreachable for programs accepted by Validate
+	return 0, fmt.Errorf("program ran past its end")
+}
+
+func validateInstruction(pc int, ins Instruction, length int) error {
+	if ins.Op > 0xff {
+		return fmt.Errorf("invalid opcode %#04x", ins.Op)
+	}
+
+	switch ins.Class() {
+	case ClassLd, ClassLdx:
+		mode := ins.Op & 0xe0
+		switch {
+		case ins.Op == ClassLd|SizeW|ModeAbs:
+			if ins.K%4 != 0 || ins.K >= DataSize {
+				return fmt.Errorf("invalid seccomp_data offset %d", ins.K)
+			}
+		case ins.Op&0x18 == SizeW && (mode == ModeLen || mode == ModeImm):
+		case ins.Op&0x18 == SizeW && mode == ModeMem:
+			if ins.K >= MemWords {
+				return fmt.Errorf("invalid scratch memory index %d", ins.K)
+			}
+		default:
+			return fmt.Errorf("unsupported load opcode %#04x", ins.Op)
+		}
+	case ClassSt, ClassStx:
+		if ins.Op&^0x07 != 0 {
+			return fmt.Errorf("unsupported store opcode %#04x", ins.Op)
+		} else if ins.K >= MemWords {
+			return fmt.Errorf("invalid scratch memory index %d", ins.K)
+		}
+	case ClassAlu:
+		// The kernel does not accept modulo in seccomp programs
+		op := ins.Op & 0xf0
+		if op > AluXor || op == AluMod || (op == AluNeg && ins.Op&SrcX != 0) {
+			return fmt.Errorf("unsupported ALU opcode %#04x", ins.Op)
+		}
+		if ins.Op&SrcX == 0 && ins.K == 0 && op == AluDiv {
+			return fmt.Errorf("division by zero")
+		}
+		if ins.Op&SrcX == 0 && ins.K >= 32 && (op == AluLsh || op == AluRsh) {
+			return fmt.Errorf("shift by %d", ins.K)
+		}
+	case ClassJmp:
+		op := ins.Op & 0xf0
+		if op > JmpJset || (op == JmpJa && ins.Op&SrcX != 0) {
+			return fmt.Errorf("unsupported jump opcode %#04x", ins.Op)
+		}
+		if op == JmpJa {
+			if ins.K >= uint32(length-pc-1) {
+				return fmt.Errorf("jump out of program")
+			}
+		} else if int(ins.Jt) >= length-pc-1 || int(ins.Jf) >= length-pc-1 {
+			return fmt.Errorf("jump out of program")
+		}
+	case ClassRet:
+		if src := ins.Op & 0x18; ins.Op&^0x18 != ClassRet || (src != SrcK && src != SrcA) {
+			return fmt.Errorf("unsupported return opcode %#04x", ins.Op)
+		}
+	case ClassMisc:
+		if op := ins.Op & 0xf8; op != MiscTax && op != MiscTxa {
+			return fmt.Errorf("unsupported miscellaneous opcode %#04x", ins.Op)
+		}
+	}
+
+	return nil
+}
+
+func alu(op uint16, a, operand uint32) uint32 {
+	switch op {
+	case AluAdd:
+		return a + operand
+	case AluSub:
+		return a - operand
+	case AluMul:
+		return a * operand
+	case AluDiv:
+		return a / operand
+	case AluOr:
+		return a | operand
+	case AluAnd:
+		return a & operand
+	case AluXor:
+		return a ^ operand
+	case AluLsh:
+		return a << (operand & 31)
+	case AluRsh:
+		return a >> (operand & 31)
+	case AluNeg:
+		return -a
+	}
+	return a
+}
+
+func jump(op uint16, a, operand uint32) bool {
+	switch op {
+	case JmpJeq:
+		return a == operand
+	case JmpJgt:
+		return a > operand
+	case JmpJge:
+		return a >= operand
+	case JmpJset:
+		return a&operand != 0
+	}
+	return false
+}
@@ -1,3 +1,5 @@
 module github.com/seccomp/libseccomp-golang
 
 go 1.14
+
+require golang.org/x/sys v0.0.0-20210510120138-977fb7262007
@@ -0,0 +1,265 @@
+// Syscall kernel version generator for libseccomp Go bindings
+// Builds the syscallVersions table from the syscall tables of Linux releases
+
+// mksyscallversions reads the syscall tables of every Linux release in git
+// trees, e.g. the pre-git history tree and linux.git, and writes the Go table
+// of the release which first implemented each syscall libseccomp knows on any
+// architecture.
+//
+// Usage:
+//
+//	mksyscallversions -names syscalls.csv -kernel 6.13 -o table.go git-dir...
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Release tags, from Linux 2.6.0 on
+	releaseTag = regexp.MustCompile(`^v(2\.6\.\d+|[3-9]\.\d+)$`)
+	// Syscall tables of the releases, .tbl files since Linux 3.3 and unistd
+	// headers before
+	tablePath = regexp.MustCompile(`^(arch/[^/]+/.*/syscalls?/syscall[^/]*\.tbl|scripts/syscall\.tbl|` +
+		`include/asm-[^/]+/unistd[^/]*\.h|arch/[^/]+/include/(uapi/)?asm/unistd[^/]*\.h|` +
+		`include/(uapi/)?asm-generic/unistd\.h)$`)
+	// Syscall numbers of unistd headers
+	defineNR = regexp.MustCompile(`^#define\s+__NR_(\w+)\s`)
+	// Syscall numbers reserved without an implementation
+	niSyscall = regexp.MustCompile(`__SYSCALL\(\s*__NR_(\w+)\s*,\s*sys_ni_syscall\s*\)`)
+)
+
+// A release of Linux and the git tree holding it
+type release struct {
+	version string
+	number  []int
+	tree    string
+}
+
+func main() {
+	names := flag.String("names", "", "libseccomp syscalls.csv, restricting the syscalls of the table")
+	kernel := flag.String("kernel", "", "newest version of Linux to read")
+	out := flag.String("o", "", "output file")
+	flag.Parse()
+	if *names == "" || *kernel == "" || *out == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	known, err := readNames(*names)
+	if err != nil {
+		log.Fatal(err)
+	}
+	releases, err := listReleases(flag.Args(), versionNumber(*kernel))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(releases) == 0 || releases[len(releases)-1].version != *kernel {
+		log.Fatalf("no release of Linux %s in %v", *kernel, flag.Args())
+	}
+
+	introduced := make(map[string]string)
+	for _, r := range releases {
+		implemented, err := readRelease(r)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for name := range implemented {
+			if _, ok := introduced[name]; !ok && known[name] {
+				introduced[name] = r.version
+			}
+		}
+	}
+
+	src, err := generate(introduced, releases)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Helper - Read the syscall names of libseccomp's syscalls.csv
+func readNames(path string) (map[string]bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names[strings.SplitN(line, ",", 2)[0]] = true
+	}
+
+	return names, nil
+}
+
+// Helper - Parse a version of Linux
+func versionNumber(version string) []int {
+	var number []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			log.Fatalf("invalid version of Linux %q", version)
+		}
+		number = append(number, n)
+	}
+
+	return number
+}
+
+// Helper - Compare two versions of Linux
+func versionLess(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+
+	return len(a) < len(b)
+}
+
+// Helper - List the releases of the git trees up to a version, in order,
+// taking each from the first tree holding it
+func listReleases(trees []string, newest []int) ([]release, error) {
+	seen := make(map[string]bool)
+	var releases []release
+	for _, tree := range trees {
+		tags, err := git(tree, "tag", "--list", "v*")
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range strings.Fields(string(tags)) {
+			m := releaseTag.FindStringSubmatch(tag)
+			if m == nil || seen[m[1]] {
+				continue
+			}
+			number := versionNumber(m[1])
+			if versionLess(newest, number) {
+				continue
+			}
+			seen[m[1]] = true
+			releases = append(releases, release{version: m[1], number: number, tree: tree})
+		}
+	}
+	sort.Slice(releases, func(i, j int) bool { return versionLess(releases[i].number, releases[j].number) })
+
+	return releases, nil
+}
+
+// Helper - Get the syscalls a release implements on any architecture
+func readRelease(r release) (map[string]bool, error) {
+	files, err := git(r.tree, "ls-tree", "-r", "--name-only", "v"+r.version)
+	if err != nil {
+		return nil, err
+	}
+
+	implemented := make(map[string]bool)
+	for _, path := range strings.Fields(string(files)) {
+		if !tablePath.MatchString(path) {
+			continue
+		}
+		data, err := git(r.tree, "show", "v"+r.version+":"+path)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(path, ".tbl") {
+			readTable(data, implemented)
+		} else {
+			readHeader(data, implemented)
+		}
+	}
+
+	return implemented, nil
+}
+
+// Helper - Read the syscalls of a .tbl file, whose lines are the number,
+// ABI, name and entry point of a syscall, without an entry point if it is
+// not implemented
+func readTable(data []byte, implemented map[string]bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || strings.HasPrefix(fields[0], "#") || fields[3] == "sys_ni_syscall" {
+			continue
+		}
+		implemented[fields[2]] = true
+	}
+}
+
+// Helper - Read the syscalls of a unistd header, leaving out those it wires
+// to sys_ni_syscall
+func readHeader(data []byte, implemented map[string]bool) {
+	reserved := make(map[string]bool)
+	for _, m := range niSyscall.FindAllSubmatch(data, -1) {
+		reserved[string(m[1])] = true
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		m := defineNR.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m != nil && !reserved[m[1]] {
+			implemented[m[1]] = true
+		}
+	}
+}
+
+// Helper - Write the table of syscall introductions
+func generate(introduced map[string]string, releases []release) ([]byte, error) {
+	byVersion := make(map[string][]string)
+	for name, version := range introduced {
+		byVersion[version] = append(byVersion[version], name)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by mksyscallversions; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "// +build linux\n\n")
+	fmt.Fprintf(&buf, "package seccomp\n\n")
+	fmt.Fprintf(&buf, "// Syscalls by the version of Linux which first implemented them on any\n")
+	fmt.Fprintf(&buf, "// architecture, up to Linux %s, those of Linux %s and before under %q\n",
+		releases[len(releases)-1].version, releases[0].version, releases[0].version)
+	fmt.Fprintf(&buf, "var syscallVersions = map[string][]string{\n")
+	for _, r := range releases {
+		names := byVersion[r.version]
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&buf, "%q: {\n", r.version)
+		for _, name := range names {
+			fmt.Fprintf(&buf, "%q,\n", name)
+		}
+		fmt.Fprintf(&buf, "},\n")
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	return format.Source(buf.Bytes())
+}
+
+// Helper - Run git in a tree
+func git(tree string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", tree}, args...)...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s in %s: %s", strings.Join(args, " "), tree, err)
+	}
+
+	return out, nil
+}
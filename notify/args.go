@@ -0,0 +1,176 @@
+// +build linux
+
+// TOCTOU-safe argument access for libseccomp Go bindings
+// Dereferences syscall arguments in the memory of a notification's target
+
+package notify
+
+import (
+	"fmt"
+	"sync"
+
+	seccomp "github.com/seccomp/libseccomp-golang"
+)
+
+// Args dereferences the pointer arguments of a notification in the memory
+// of its target, through the ReadBytes and ReadString methods of the
+// notification. Every access is bracketed by checks that the notification
+// is still pending: the target's memory is only trusted while its syscall
+// is blocked, and its pid may be reused once it went away. Once the
+// notification is found invalid, all reads fail with ErrTargetGone and
+// cached data is discarded.
+//
+// Note that the target's other threads may still modify the memory after
+// it has been read; handlers must not let the kernel re-read it (e.g. by
+// responding with FlagContinue) after taking a security decision on it.
+// An Args is safe for concurrent use.
+type Args struct {
+	fd  seccomp.ScmpFd
+	req *Request
+
+	lock  sync.Mutex
+	cache map[argRead][]byte
+	gone  bool
+}
+
+type argRead struct {
+	arg    int
+	length int
+	str    bool
+}
+
+// NewArgs creates an argument accessor for the notification req, received
+// on the notification fd fd. It must be closed with Close.
+func NewArgs(fd seccomp.ScmpFd, req *Request) *Args {
+	// Notifications handed over by another process do not know their fd
+	bound := *req
+	bound.SetFd(fd)
+
+	return &Args{fd: fd, req: &bound, cache: make(map[argRead][]byte)}
+}
+
+// Args creates an argument accessor for a notification received by the
+// supervisor. It must be closed with Close.
+func (s *Supervisor) Args(req *Request) *Args {
+	return NewArgs(s.fd, req)
+}
+
+// Value returns the raw value of argument i, or 0 if i is out of range.
+func (a *Args) Value(i int) uint64 {
+	if i < 0 || i >= len(a.req.Data.Args) {
+		return 0
+	}
+	return a.req.Data.Args[i]
+}
+
+// Bytes reads length bytes from the target's memory at the address held by
+// argument i.
+// Returns an error if the memory could not be read, or ErrTargetGone
+// if the notification is no longer valid.
+func (a *Args) Bytes(i int, length int) ([]byte, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("invalid length %d", length)
+	}
+	return a.read(argRead{arg: i, length: length})
+}
+
+// String reads the NUL-terminated string pointed to by argument i from the
+// target's memory, such as a path. Strings longer than max bytes, or than
+// unix.PathMax, are refused.
+// Returns an error if the memory could not be read or the string is longer
+// than max, or ErrTargetGone if the notification is no longer valid.
+func (a *Args) String(i int, max int) (string, error) {
+	if max <= 0 {
+		return "", fmt.Errorf("invalid maximum length %d", max)
+	}
+
+	data, err := a.read(argRead{arg: i, length: max, str: true})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Valid checks that the notification is still valid.
+// Returns ErrTargetGone if it is not.
+func (a *Args) Valid() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.check()
+}
+
+// Close releases the resources held by the accessor.
+func (a *Args) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.cache = make(map[argRead][]byte)
+	return nil
+}
+
+func (a *Args) read(key argRead) ([]byte, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	addr := a.Value(key.arg)
+	if addr == 0 {
+		return nil, fmt.Errorf("argument is a NULL pointer")
+	}
+
+	if err := a.check(); err != nil {
+		return nil, err
+	}
+
+	if data, ok := a.cache[key]; ok {
+		return append([]byte(nil), data...), nil
+	}
+
+	// Both readers check that the notification is valid around the read
+	var data []byte
+	var err error
+	if key.str {
+		var str string
+		str, err = a.req.ReadString(key.arg)
+		data = []byte(str)
+	} else {
+		data, err = a.req.ReadBytes(addr, key.length)
+	}
+	if err == ErrTargetGone {
+		a.invalidate()
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+	if key.str && len(data) > key.length {
+		return nil, fmt.Errorf("string is longer than %d bytes", key.length)
+	}
+
+	a.cache[key] = data
+	return append([]byte(nil), data...), nil
+}
+
+// Helper - Check that the notification is still valid, dropping all cached
+// reads otherwise
+// Requires the accessor lock
+func (a *Args) check() error {
+	if a.gone {
+		return ErrTargetGone
+	}
+
+	if err := IDValid(a.fd, a.req.ID); err == ErrTargetGone {
+		a.invalidate()
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Helper - Mark the notification invalid, dropping all cached reads
+// Requires the accessor lock
+func (a *Args) invalidate() {
+	a.gone = true
+	a.cache = make(map[argRead][]byte)
+}
@@ -0,0 +1,198 @@
+// +build linux
+
+// Supervisor handoff for libseccomp Go bindings
+// Transfers a notification fd and its pending notifications between processes
+
+package notify
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+
+	seccomp "github.com/seccomp/libseccomp-golang"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Version of the handoff protocol
+	handoffVersion = 1
+	// Upper bound on the size of a single handoff message
+	maxHandoffMessage = 64 << 20
+)
+
+// Message exchanged during a handoff. The first message carries the
+// notification fd and the pending notifications; notifications received by
+// the old supervisor after the handoff follow in further messages.
+// Messages are JSON, prefixed with their length as a 32-bit big-endian
+// integer.
+type handoffMessage struct {
+	Version  int        `json:"version"`
+	Requests []*Request `json:"requests,omitempty"`
+}
+
+// Handoff transfers the notifications handled by the supervisor to another
+// supervisor, typically an upgraded binary, which accepts them with
+// AcceptHandoff on the other end of conn. The confined processes are not
+// affected: notifications the supervisor received but did not respond to
+// are passed on and answered by the new supervisor.
+//
+// Handoff waits for responses being sent to complete. Afterwards, the
+// supervisor refuses to respond, and Serve loops return ErrHandedOff.
+// Notifications still received by a blocked Receive call are forwarded over
+// conn, which must thus only be closed once all receive loops returned. The
+// notification fd can be closed then as well.
+// Returns an error if the supervisor was already handed off or the handoff
+// could not be sent; the supervisor cannot be used anymore in the latter
+// case.
+func (s *Supervisor) Handoff(conn *net.UnixConn) error {
+	s.lock.Lock()
+	if s.handoff != nil {
+		s.lock.Unlock()
+		return ErrHandedOff
+	}
+	s.handoff = conn
+	s.wakeUp()
+	s.lock.Unlock()
+
+	s.inflight.Wait()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reqs := make([]*Request, 0, len(s.pending)+len(s.adopted))
+	reqs = append(reqs, s.adopted...)
+	for _, p := range s.pending {
+		reqs = append(reqs, p.req)
+	}
+	s.adopted = nil
+	s.pending = make(map[uint64]*pendingRequest)
+
+	rights := unix.UnixRights(int(s.fd))
+	if err := writeHandoffMessage(conn, reqs, rights); err != nil {
+		return fmt.Errorf("could not send handoff: %v", err)
+	}
+	s.handoffSent = true
+
+	return nil
+}
+
+// AcceptHandoff takes over the notifications of a supervisor handed off with
+// Handoff on the other end of conn. The notifications left pending by the
+// old supervisor are returned first by the new supervisor's Receive, as are
+// those it forwards over conn later on.
+// The notification fd of the returned supervisor is owned by the caller, as
+// is conn, which should be closed once the old supervisor is gone.
+// Returns an error if no handoff could be read from conn.
+func AcceptHandoff(conn *net.UnixConn) (*Supervisor, error) {
+	oob := make([]byte, unix.CmsgSpace(4))
+	var header [4]byte
+	n, oobn, _, _, err := conn.ReadMsgUnix(header[:], oob)
+	if err != nil {
+		return nil, fmt.Errorf("could not receive handoff: %v", err)
+	}
+
+	fd, err := parseHandoffRights(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	unix.CloseOnExec(fd)
+
+	msg, err := readHandoffBody(conn, header, n)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	s := NewSupervisor(seccomp.ScmpFd(fd))
+	s.adopted = msg.Requests
+
+	go func() {
+		for {
+			var header [4]byte
+			msg, err := readHandoffBody(conn, header, 0)
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			s.adopted = append(s.adopted, msg.Requests...)
+			s.wakeUp()
+			s.lock.Unlock()
+		}
+	}()
+
+	return s, nil
+}
+
+func writeHandoffMessage(conn *net.UnixConn, reqs []*Request, rights []byte) error {
+	body, err := json.Marshal(&handoffMessage{Version: handoffVersion, Requests: reqs})
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(data, uint32(len(body)))
+	copy(data[4:], body)
+
+	// Send the header alone with the fd, so the receiver reads it separately
+	if _, _, err := conn.WriteMsgUnix(data[:4], rights, nil); err != nil {
+		return err
+	}
+	_, err = conn.Write(data[4:])
+	return err
+}
+
+// Helper - Read the rest of a handoff message whose first n header bytes
+// have been read already
+func readHandoffBody(conn *net.UnixConn, header [4]byte, n int) (*handoffMessage, error) {
+	if _, err := io.ReadFull(conn, header[n:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxHandoffMessage {
+		return nil, fmt.Errorf("handoff message of %d bytes is too large", size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, fmt.Errorf("could not read handoff message: %v", err)
+	}
+
+	msg := new(handoffMessage)
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, fmt.Errorf("could not parse handoff message: %v", err)
+	} else if msg.Version != handoffVersion {
+		return nil, fmt.Errorf("unsupported handoff protocol version %d", msg.Version)
+	}
+
+	return msg, nil
+}
+
+// Helper - Extract the notification fd from the control message of a handoff
+func parseHandoffRights(oob []byte) (int, error) {
+	cmsgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return -1, fmt.Errorf("could not parse handoff control message: %v", err)
+	}
+
+	var fds []int
+	for _, cmsg := range cmsgs {
+		rights, err := unix.ParseUnixRights(&cmsg)
+		if err != nil {
+			continue
+		}
+		fds = append(fds, rights...)
+	}
+
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+		return -1, fmt.Errorf("handoff carries %d file descriptors, expected 1", len(fds))
+	}
+
+	return fds[0], nil
+}
@@ -0,0 +1,243 @@
+// +build linux
+
+// Notification multiplexer for libseccomp Go bindings
+// Serves the notifications of many notification fds from a single goroutine
+
+package notify
+
+import (
+	"fmt"
+	"sync"
+
+	seccomp "github.com/seccomp/libseccomp-golang"
+	"golang.org/x/sys/unix"
+)
+
+// Maximum number of epoll events retrieved at once by Mux.Serve
+const muxEvents = 64
+
+// ErrMuxClosed is returned by a Mux which has been closed.
+var ErrMuxClosed = fmt.Errorf("notification multiplexer closed")
+
+// MuxHandler decides how to respond to a notification received on the
+// notification fd fd. The ID of the returned response is filled in by the
+// multiplexer.
+type MuxHandler func(fd seccomp.ScmpFd, req *Request) Response
+
+// Mux serves the notifications of a set of notification fds, e.g. one per
+// container, waiting for all of them with epoll on a single goroutine
+// instead of blocking one goroutine per fd.
+// Notification fds may be added and removed while the multiplexer is
+// serving. Fds which hang up, because no process uses their filter anymore,
+// are removed automatically.
+// The multiplexer must be the only one receiving notifications from its fds,
+// as receiving blocks even on non-blocking fds.
+// A Mux is safe for concurrent use.
+type Mux struct {
+	epfd int
+	// eventfd waking up Serve when the multiplexer is closed
+	wakefd int
+
+	lock     sync.Mutex
+	handlers map[seccomp.ScmpFd]MuxHandler
+	serving  bool
+	closed   bool
+}
+
+// NewMux creates a multiplexer without any notification fd.
+// Returns an error if the epoll instance could not be created.
+func NewMux() (*Mux, error) {
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	wakefd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+	if err != nil {
+		unix.Close(epfd)
+		return nil, err
+	}
+
+	event := unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(wakefd)}
+	if err := unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, wakefd, &event); err != nil {
+		unix.Close(wakefd)
+		unix.Close(epfd)
+		return nil, err
+	}
+
+	return &Mux{
+		epfd:     epfd,
+		wakefd:   wakefd,
+		handlers: make(map[seccomp.ScmpFd]MuxHandler),
+	}, nil
+}
+
+// Add starts serving the notifications of the notification fd fd, as
+// returned by ScmpFilter.GetNotifFd(), with handler. The multiplexer does not
+// take ownership of fd.
+// Returns an error if fd is already served or could not be watched.
+func (m *Mux) Add(fd seccomp.ScmpFd, handler MuxHandler) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.closed {
+		return ErrMuxClosed
+	} else if _, ok := m.handlers[fd]; ok {
+		return fmt.Errorf("notification fd %d is already served", fd)
+	}
+
+	event := unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(fd)}
+	if err := unix.EpollCtl(m.epfd, unix.EPOLL_CTL_ADD, int(fd), &event); err != nil {
+		return err
+	}
+	m.handlers[fd] = handler
+
+	return nil
+}
+
+// Remove stops serving the notifications of the notification fd fd.
+// Notifications pending on fd are left for its next supervisor. fd must be
+// removed before being closed.
+// Returns an error if fd is not served.
+func (m *Mux) Remove(fd seccomp.ScmpFd) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.closed {
+		return ErrMuxClosed
+	} else if _, ok := m.handlers[fd]; !ok {
+		return fmt.Errorf("notification fd %d is not served", fd)
+	}
+
+	return m.remove(fd)
+}
+
+// Len returns the number of notification fds served.
+func (m *Mux) Len() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return len(m.handlers)
+}
+
+// Serve receives the notifications of all the notification fds and responds
+// to each of them with the response returned by the handler of its fd, until
+// the multiplexer is closed. Notifications whose target went away before
+// they could be received or answered are skipped.
+// Only one goroutine may serve at a time.
+// Returns ErrMuxClosed once the multiplexer is closed, or an error if
+// waiting for notifications failed, or receiving or responding failed on one
+// of the fds.
+func (m *Mux) Serve() error {
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return ErrMuxClosed
+	} else if m.serving {
+		m.lock.Unlock()
+		return fmt.Errorf("notification multiplexer is already serving")
+	}
+	m.serving = true
+	m.lock.Unlock()
+
+	defer func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+
+		m.serving = false
+		if m.closed {
+			m.release()
+		}
+	}()
+
+	events := make([]unix.EpollEvent, muxEvents)
+	for {
+		n, err := unix.EpollWait(m.epfd, events, -1)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		for _, event := range events[:n] {
+			if int(event.Fd) == m.wakefd {
+				return ErrMuxClosed
+			}
+
+			if err := m.serve(seccomp.ScmpFd(event.Fd), event.Events); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close stops serving all the notification fds, making Serve return. The
+// notification fds are not closed.
+func (m *Mux) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.closed {
+		return ErrMuxClosed
+	}
+	m.closed = true
+	m.handlers = nil
+
+	if !m.serving {
+		m.release()
+		return nil
+	}
+
+	// Serve is waiting on the fds, it releases them once woken up
+	buf := []byte{1, 0, 0, 0, 0, 0, 0, 0}
+	_, err := unix.Write(m.wakefd, buf)
+
+	return err
+}
+
+// Helper - Handle an epoll event of a notification fd
+func (m *Mux) serve(fd seccomp.ScmpFd, events uint32) error {
+	m.lock.Lock()
+	handler, ok := m.handlers[fd]
+	if ok && events&unix.EPOLLIN == 0 && events&(unix.EPOLLHUP|unix.EPOLLERR) != 0 {
+		// No process uses the filter anymore
+		m.remove(fd)
+		ok = false
+	}
+	m.lock.Unlock()
+
+	if !ok {
+		// Hung up, or removed while the event was reported
+		return nil
+	}
+
+	req, err := Receive(fd)
+	if err == ErrTargetGone {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not receive notification on fd %d: %v", fd, err)
+	}
+
+	resp := handler(fd, req)
+	resp.ID = req.ID
+	if err := Respond(fd, &resp); err != nil && err != ErrTargetGone {
+		return fmt.Errorf("could not respond to notification %#x on fd %d: %v", req.ID, fd, err)
+	}
+
+	return nil
+}
+
+// Helper - Close the epoll fd and the eventfd
+// Requires the multiplexer lock
+func (m *Mux) release() {
+	unix.Close(m.epfd)
+	unix.Close(m.wakefd)
+}
+
+// Helper - Stop watching a notification fd
+// Requires the multiplexer lock
+func (m *Mux) remove(fd seccomp.ScmpFd) error {
+	delete(m.handlers, fd)
+
+	return unix.EpollCtl(m.epfd, unix.EPOLL_CTL_DEL, int(fd), nil)
+}
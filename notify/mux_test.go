@@ -0,0 +1,125 @@
+// +build linux
+
+// Tests for the notification multiplexer of libseccomp Go bindings
+
+package notify
+
+import (
+	"runtime"
+	"testing"
+
+	seccomp "github.com/seccomp/libseccomp-golang"
+	"golang.org/x/sys/unix"
+)
+
+func TestMux(t *testing.T) {
+	execInSubprocess(t, subprocessMux)
+}
+func subprocessMux(t *testing.T) {
+	// The kernel only allows one notification fd per thread
+	fd1, chdir1 := confineThread(t)
+	fd2, chdir2 := confineThread(t)
+
+	mux, err := NewMux()
+	if err != nil {
+		t.Fatalf("Error creating multiplexer: %s", err)
+	}
+
+	handler := func(errno unix.Errno) MuxHandler {
+		return func(fd seccomp.ScmpFd, req *Request) Response {
+			var resp Response
+			resp.SetErrno(errno)
+			return resp
+		}
+	}
+	if err := mux.Add(fd1, handler(unix.ENOMEDIUM)); err != nil {
+		t.Fatalf("Error adding notification fd: %s", err)
+	}
+	if err := mux.Add(fd1, handler(unix.ENOMEDIUM)); err == nil {
+		t.Errorf("Adding a notification fd twice should fail")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- mux.Serve()
+	}()
+
+	if err := chdir1(); err != unix.ENOMEDIUM {
+		t.Errorf("Handler response not applied: got %v, want %v", err, unix.ENOMEDIUM)
+	}
+
+	// Fds can be added while serving
+	if err := mux.Add(fd2, handler(unix.EL2HLT)); err != nil {
+		t.Fatalf("Error adding notification fd: %s", err)
+	}
+	if err := chdir2(); err != unix.EL2HLT {
+		t.Errorf("Handler response not applied: got %v, want %v", err, unix.EL2HLT)
+	}
+	if err := chdir1(); err != unix.ENOMEDIUM {
+		t.Errorf("Handler response not applied: got %v, want %v", err, unix.ENOMEDIUM)
+	}
+	if n := mux.Len(); n != 2 {
+		t.Errorf("Multiplexer serves %d fds, want 2", n)
+	}
+
+	if err := mux.Remove(fd2); err != nil {
+		t.Errorf("Error removing notification fd: %s", err)
+	}
+	if err := mux.Remove(fd2); err == nil {
+		t.Errorf("Removing a notification fd twice should fail")
+	}
+
+	if err := mux.Close(); err != nil {
+		t.Errorf("Error closing multiplexer: %s", err)
+	}
+	if err := <-done; err != ErrMuxClosed {
+		t.Errorf("Serve() after Close(): got %v, want %v", err, ErrMuxClosed)
+	}
+	if err := mux.Add(fd2, handler(unix.EL2HLT)); err != ErrMuxClosed {
+		t.Errorf("Add() after Close(): got %v, want %v", err, ErrMuxClosed)
+	}
+}
+
+// Helper - Load a filter notifying on chdir on a thread of its own, returning
+// its notification fd and a function calling chdir("/") on the thread
+func confineThread(t *testing.T) (seccomp.ScmpFd, func() error) {
+	filter, err := seccomp.NewFilter(seccomp.ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	if err := filter.AddRule(seccomp.ScmpSyscall(unix.SYS_CHDIR), seccomp.ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+
+	loaded := make(chan error)
+	calls := make(chan chan error)
+	go func() {
+		// The thread is never unlocked, so the runtime terminates it along
+		// with its filter once the process is done with it
+		runtime.LockOSThread()
+		loaded <- filter.LoadWithFlags(seccomp.LoadFlagNewListener)
+		for result := range calls {
+			result <- unix.Chdir("/")
+		}
+	}()
+	if err := <-loaded; err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	fd, err := filter.GetNotifFd()
+	if err != nil {
+		t.Fatalf("Error getting notification fd: %s", err)
+	}
+
+	return fd, func() error {
+		result := make(chan error)
+		calls <- result
+		return <-result
+	}
+}
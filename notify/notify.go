@@ -0,0 +1,66 @@
+// +build linux
+
+// Seccomp userspace notification support for libseccomp Go bindings
+// Exposes notification primitives and a supervisor for notification fds
+
+// Package notify implements supervisors for seccomp user notifications. A
+// filter returning ActNotify suspends the calling thread until a supervisor
+// holding the filter's notification fd responds to the notification.
+// The primitives of package seccomp are available under shorter names here.
+package notify
+
+import (
+	seccomp "github.com/seccomp/libseccomp-golang"
+)
+
+// Request is a seccomp userspace notification. See seccomp.ScmpNotifReq.
+type Request = seccomp.ScmpNotifReq
+
+// Response is a response to a seccomp userspace notification.
+// See seccomp.ScmpNotifResp.
+type Response = seccomp.ScmpNotifResp
+
+// Data is the syscall context of a notification. See seccomp.ScmpNotifData.
+type Data = seccomp.ScmpNotifData
+
+// ErrTargetGone is returned when a notification is no longer valid.
+// See seccomp.ErrNotifTargetGone.
+var ErrTargetGone = seccomp.ErrNotifTargetGone
+
+// ErrClosed is returned when receiving from a notification fd which was
+// closed with Close. See seccomp.ErrNotifClosed.
+var ErrClosed = seccomp.ErrNotifClosed
+
+// FlagContinue tells the kernel to execute the syscall that triggered the
+// notification. See seccomp.NotifRespFlagContinue.
+const FlagContinue = seccomp.NotifRespFlagContinue
+
+// Receive retrieves a seccomp notification from a notification fd.
+// See seccomp.NotifReceive.
+func Receive(fd seccomp.ScmpFd) (*Request, error) {
+	return seccomp.NotifReceive(fd)
+}
+
+// Close closes a notification fd, stopping the receives waiting on it.
+// See seccomp.NotifClose.
+func Close(fd seccomp.ScmpFd) error {
+	return seccomp.NotifClose(fd)
+}
+
+// Respond responds to a notification retrieved via Receive.
+// See seccomp.NotifRespond.
+func Respond(fd seccomp.ScmpFd, resp *Response) error {
+	return seccomp.NotifRespond(fd, resp)
+}
+
+// IDValid checks if a notification is still valid.
+// See seccomp.NotifIDValid.
+func IDValid(fd seccomp.ScmpFd, id uint64) error {
+	return seccomp.NotifIDValid(fd, id)
+}
+
+// IDsValid checks if several notifications are still valid.
+// See seccomp.NotifIDsValid.
+func IDsValid(fd seccomp.ScmpFd, ids []uint64) ([]bool, error) {
+	return seccomp.NotifIDsValid(fd, ids)
+}
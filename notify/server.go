@@ -0,0 +1,219 @@
+// +build linux
+
+// Notification server for libseccomp Go bindings
+// Dispatches notifications to handlers registered per syscall
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	seccomp "github.com/seccomp/libseccomp-golang"
+	"golang.org/x/sys/unix"
+)
+
+// ErrServerClosed is returned by a Server after Shutdown.
+var ErrServerClosed = fmt.Errorf("notification server closed")
+
+// Server dispatches the notifications of a notification fd to handlers
+// registered per syscall. The server runs the receive loop on a pool of
+// worker goroutines, skips notifications that went away before they could
+// be handled, and sends the responses the handlers return, using a
+// Supervisor to ensure each notification is only answered once.
+// Handlers may be registered while the server is running.
+type Server struct {
+	supervisor *Supervisor
+	workers    int
+
+	lock     sync.RWMutex
+	handlers map[string]Handler
+	fallback Handler
+
+	// Closed on shutdown, stopping the workers from receiving
+	quit    chan struct{}
+	closed  bool
+	serving sync.WaitGroup
+}
+
+// NewServer creates a server for the notification fd fd, as returned by
+// ScmpFilter.GetNotifFd(), handling up to workers notifications at once.
+// The server does not take ownership of fd.
+func NewServer(fd seccomp.ScmpFd, workers int) *Server {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Server{
+		supervisor: NewSupervisor(fd),
+		workers:    workers,
+		handlers:   make(map[string]Handler),
+		quit:       make(chan struct{}),
+	}
+}
+
+// Supervisor returns the supervisor the server receives and responds to
+// notifications with, e.g. for handlers to read syscall arguments with
+// Supervisor.Args.
+func (s *Server) Supervisor() *Supervisor {
+	return s.supervisor
+}
+
+// Handle registers handler for the notifications of the syscall name, on
+// any architecture, replacing any handler registered for it before.
+// Returns an error if the syscall name is unknown.
+func (s *Server) Handle(name string, handler Handler) error {
+	if _, err := seccomp.GetSyscallFromName(name); err != nil {
+		return fmt.Errorf("%v: %q", err, name)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.handlers[name] = handler
+
+	return nil
+}
+
+// HandleDefault registers handler for the notifications of syscalls without
+// a handler of their own. Without a default handler, these syscalls fail
+// with ENOSYS.
+func (s *Server) HandleDefault(handler Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.fallback = handler
+}
+
+// Serve handles notifications until receiving or responding fails on every
+// worker, the supervisor is handed off, or the server is shut down.
+// Returns the first error that stopped a worker; io.EOF once no process uses
+// the filter anymore, ErrHandedOff after a handoff, and ErrServerClosed
+// after Shutdown or once the notification fd is closed with Close.
+func (s *Server) Serve() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return ErrServerClosed
+	}
+	s.serving.Add(s.workers)
+	s.lock.Unlock()
+
+	errs := make(chan error, s.workers)
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			defer s.serving.Done()
+			errs <- s.closedErr(s.work())
+		}()
+	}
+
+	var err error
+	for i := 0; i < s.workers; i++ {
+		if werr := <-errs; err == nil {
+			err = werr
+		}
+	}
+
+	return err
+}
+
+// Shutdown stops the server from receiving notifications, and waits for the
+// notifications being handled to be responded to, or for ctx to be done.
+// Notifications not received yet stay pending on the notification fd, e.g.
+// for another supervisor. Serve returns ErrServerClosed once the server is
+// shut down.
+// Returns the error of ctx if it is done before the workers stopped.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.lock.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.quit)
+	}
+	s.lock.Unlock()
+
+	// Wake up the workers waiting for notifications
+	s.supervisor.lock.Lock()
+	s.supervisor.wakeUp()
+	s.supervisor.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.serving.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Helper - Receive and handle notifications until an error occurs
+func (s *Server) work() error {
+	for {
+		if err := s.supervisor.wait(s.quit); err != nil {
+			return err
+		}
+
+		req, err := s.supervisor.Receive()
+		if err == ErrTargetGone {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		// The target may have gone away while the notification was queued
+		if err := s.supervisor.IDValid(req.ID); err == ErrTargetGone {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		resp := s.handler(req)(req)
+		resp.ID = req.ID
+		if err := s.supervisor.Respond(&resp); err != nil && err != ErrTargetGone {
+			return err
+		}
+	}
+}
+
+// Helper - Report the errors of workers stopped by Shutdown or by closing
+// the notification fd as ErrServerClosed
+func (s *Server) closedErr(err error) error {
+	select {
+	case <-s.quit:
+		return ErrServerClosed
+	default:
+	}
+
+	if err == ErrClosed {
+		return ErrServerClosed
+	}
+	return err
+}
+
+// Helper - Get the handler of a notification
+func (s *Server) handler(req *Request) Handler {
+	name, err := req.SyscallName()
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if handler, ok := s.handlers[name]; ok && err == nil {
+		return handler
+	} else if s.fallback != nil {
+		return s.fallback
+	}
+
+	return unhandled
+}
+
+// Handler for syscalls without a handler
+func unhandled(req *Request) Response {
+	var resp Response
+	resp.SetErrno(unix.ENOSYS)
+	return resp
+}
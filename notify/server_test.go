@@ -0,0 +1,134 @@
+// +build linux
+
+// Tests for the notification server of libseccomp Go bindings
+
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestServer(t *testing.T) {
+	execInSubprocess(t, subprocessServer)
+}
+func subprocessServer(t *testing.T) {
+	fd := loadNotifyFilter(t, "chdir", "fchdir")
+	server := NewServer(fd, 2)
+
+	if err := server.Handle("not_a_syscall", nil); err == nil {
+		t.Errorf("Registering a handler for an unknown syscall should fail")
+	}
+	err := server.Handle("chdir", func(req *Request) Response {
+		args := server.Supervisor().Args(req)
+		defer args.Close()
+
+		path, err := args.String(0, unix.PathMax)
+		var resp Response
+		if err != nil || path != "/" {
+			resp.SetErrno(unix.EINVAL)
+		} else {
+			resp.SetErrno(unix.ENOMEDIUM)
+		}
+		return resp
+	})
+	if err != nil {
+		t.Fatalf("Error registering handler: %s", err)
+	}
+
+	go server.Serve()
+
+	for i := 0; i < 3; i++ {
+		if err := unix.Chdir("/"); err != unix.ENOMEDIUM {
+			t.Errorf("Handler response not applied: got %v, want %v", err, unix.ENOMEDIUM)
+		}
+	}
+	if err := unix.Fchdir(0); err != unix.ENOSYS {
+		t.Errorf("Syscall without handler: got %v, want %v", err, unix.ENOSYS)
+	}
+
+	server.HandleDefault(func(req *Request) Response {
+		return Response{Val: 0}
+	})
+	if err := unix.Fchdir(0); err != nil {
+		t.Errorf("Default handler response not applied: got %v", err)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	execInSubprocess(t, subprocessServerShutdown)
+}
+func subprocessServerShutdown(t *testing.T) {
+	fd := loadNotifyFilter(t, "chdir")
+	server := NewServer(fd, 1)
+
+	handling, release := make(chan struct{}), make(chan struct{})
+	server.HandleDefault(func(req *Request) Response {
+		close(handling)
+		<-release
+		var resp Response
+		resp.SetErrno(unix.ENOMEDIUM)
+		return resp
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve()
+	}()
+
+	target := make(chan error, 1)
+	go func() {
+		target <- unix.Chdir("/")
+	}()
+	<-handling
+
+	// The notification being handled keeps the server from shutting down
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown() while handling: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Errorf("Error in Shutdown(): %s", err)
+	}
+	if err := <-target; err != unix.ENOMEDIUM {
+		t.Errorf("Handler response not applied: got %v, want %v", err, unix.ENOMEDIUM)
+	}
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("Serve() after Shutdown(): got %v, want %v", err, ErrServerClosed)
+	}
+	if err := server.Serve(); err != ErrServerClosed {
+		t.Errorf("Serve() on a shut down server: got %v, want %v", err, ErrServerClosed)
+	}
+}
+
+func TestServerClosedFd(t *testing.T) {
+	execInSubprocess(t, subprocessServerClosedFd)
+}
+func subprocessServerClosedFd(t *testing.T) {
+	fd := loadNotifyFilter(t, "chdir")
+	server := NewServer(fd, 2)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve()
+	}()
+
+	// Closing the fd stops the workers waiting for notifications
+	if err := Close(fd); err != nil {
+		t.Fatalf("Error closing notification fd: %s", err)
+	}
+	select {
+	case err := <-served:
+		if err != ErrServerClosed {
+			t.Errorf("Serve() after Close(): got %v, want %v", err, ErrServerClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Serve() did not return after Close()")
+	}
+}
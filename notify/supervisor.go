@@ -0,0 +1,316 @@
+// +build linux
+
+// Notification supervisor for libseccomp Go bindings
+// Receives notifications from a notification fd and dispatches them
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	seccomp "github.com/seccomp/libseccomp-golang"
+)
+
+// Number of answered notification IDs remembered to detect double responses
+const answeredHistory = 1024
+
+var (
+	// ErrUnknownID is the reason of a ResponseError for a notification that
+	// was not received through the supervisor
+	ErrUnknownID = fmt.Errorf("unknown notification ID")
+	// ErrAlreadyResponded is the reason of a ResponseError for a
+	// notification that has already been answered, or is being answered
+	ErrAlreadyResponded = fmt.Errorf("notification already responded to")
+	// ErrHandedOff is returned by a supervisor whose notifications have been
+	// handed off to another supervisor
+	ErrHandedOff = fmt.Errorf("notifications have been handed off")
+)
+
+// ResponseError denotes a response the supervisor refused to send to the
+// kernel, because it did not match a pending notification.
+//
+// ID:  notification ID of the refused response
+// Err: reason of the refusal, ErrUnknownID or ErrAlreadyResponded
+//
+type ResponseError struct {
+	ID  uint64
+	Err error
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("cannot respond to notification %#x: %v", e.ID, e.Err)
+}
+
+// Unwrap returns the reason of the refusal.
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// Handler decides how to respond to a notification. The ID of the returned
+// response is filled in by the supervisor.
+type Handler func(req *Request) Response
+
+// Supervisor handles the notifications of a single notification fd.
+// The supervisor tracks the notifications it received, and only sends one
+// response to each of them.
+// A Supervisor is safe for concurrent use; Serve may be called from several
+// goroutines to handle notifications in parallel.
+type Supervisor struct {
+	fd seccomp.ScmpFd
+
+	lock     sync.Mutex
+	pending  map[uint64]*pendingRequest
+	answered map[uint64]struct{}
+	history  []uint64
+	// Notifications taken over from another supervisor, not received yet
+	adopted []*Request
+	// Responses being sent to the kernel
+	inflight sync.WaitGroup
+	// Connection the notifications are handed off over, if any
+	handoff     *net.UnixConn
+	handoffSent bool
+	// Cancelled to wake up the receive loops waiting for notifications
+	waiting context.Context
+	wake    context.CancelFunc
+}
+
+type pendingRequest struct {
+	req        *Request
+	responding bool
+}
+
+// NewSupervisor creates a supervisor for the notification fd fd, as returned
+// by ScmpFilter.GetNotifFd(). The supervisor does not take ownership of fd.
+func NewSupervisor(fd seccomp.ScmpFd) *Supervisor {
+	s := &Supervisor{
+		fd:       fd,
+		pending:  make(map[uint64]*pendingRequest),
+		answered: make(map[uint64]struct{}),
+	}
+	s.waiting, s.wake = context.WithCancel(context.Background())
+
+	return s
+}
+
+// Fd returns the notification fd handled by the supervisor.
+func (s *Supervisor) Fd() seccomp.ScmpFd {
+	return s.fd
+}
+
+// Receive retrieves the next notification, blocking until one is available.
+// Notifications taken over from another supervisor are returned first.
+// The notification is pending until it is responded to.
+// Returns ErrHandedOff once the supervisor has been handed off.
+func (s *Supervisor) Receive() (*Request, error) {
+	s.lock.Lock()
+	if len(s.adopted) > 0 {
+		req := s.adopted[0]
+		s.adopted = s.adopted[1:]
+		s.pending[req.ID] = &pendingRequest{req: req}
+		s.lock.Unlock()
+		return req, nil
+	} else if s.handoff != nil {
+		s.lock.Unlock()
+		return nil, ErrHandedOff
+	}
+	s.lock.Unlock()
+
+	req, err := Receive(s.fd)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.handoffSent {
+		// Received after the handoff, forward it to the new supervisor
+		if err := writeHandoffMessage(s.handoff, []*Request{req}, nil); err != nil {
+			return nil, fmt.Errorf("could not forward notification %#x: %v", req.ID, err)
+		}
+		return nil, ErrHandedOff
+	}
+	s.pending[req.ID] = &pendingRequest{req: req}
+
+	return req, nil
+}
+
+// Respond sends resp in reply to the pending notification with ID resp.ID.
+// Returns a *ResponseError if the notification was not received through the
+// supervisor or has already been responded to, ErrHandedOff once the
+// supervisor has been handed off, and ErrTargetGone if the notification is no
+// longer valid, e.g. because the target was killed.
+// A response that failed with any other error may be retried.
+func (s *Supervisor) Respond(resp *Response) error {
+	if err := s.claim(resp.ID); err != nil {
+		return err
+	}
+	defer s.inflight.Done()
+
+	err := Respond(s.fd, resp)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err != nil && err != ErrTargetGone {
+		s.pending[resp.ID].responding = false
+		return err
+	}
+	s.retire(resp.ID)
+
+	return err
+}
+
+// IDValid checks that the notification with ID id is still pending, which
+// must be done after reading any of the target's memory.
+func (s *Supervisor) IDValid(id uint64) error {
+	err := IDValid(s.fd, id)
+	if err == ErrTargetGone {
+		s.lock.Lock()
+		s.drop(id)
+		s.lock.Unlock()
+	}
+
+	return err
+}
+
+// IDsValid checks which of the notifications with the given IDs are still
+// pending, e.g. before committing the side effects of a batch of them.
+// The returned slice holds the validity of each ID.
+func (s *Supervisor) IDsValid(ids []uint64) ([]bool, error) {
+	valid, err := IDsValid(s.fd, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	for i, id := range ids {
+		if !valid[i] {
+			s.drop(id)
+		}
+	}
+	s.lock.Unlock()
+
+	return valid, nil
+}
+
+// Pending returns the number of notifications received but not yet
+// responded to.
+func (s *Supervisor) Pending() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.pending)
+}
+
+// Serve receives notifications and responds to each of them with the
+// response returned by handler, until receiving fails or the supervisor is
+// handed off. Notifications whose target went away before they could be
+// received or answered are skipped.
+// The loop can be stopped by closing the notification fd with Close.
+// Returns the error that stopped the loop; io.EOF once no process uses the
+// filter anymore, ErrClosed once the fd is closed, and ErrHandedOff after a
+// handoff.
+func (s *Supervisor) Serve(handler Handler) error {
+	for {
+		if err := s.wait(nil); err != nil {
+			return err
+		}
+
+		req, err := s.Receive()
+		if err == ErrTargetGone {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		resp := handler(req)
+		resp.ID = req.ID
+		if err := s.Respond(&resp); err != nil && err != ErrTargetGone {
+			return err
+		}
+	}
+}
+
+// Helper - Wait until a notification is ready to be received, the
+// supervisor adopted notifications or has been handed off, or quit is closed
+func (s *Supervisor) wait(quit <-chan struct{}) error {
+	for {
+		s.lock.Lock()
+		adopted, handedOff := len(s.adopted) > 0, s.handoff != nil
+		waiting := s.waiting
+		s.lock.Unlock()
+
+		if adopted {
+			return nil
+		} else if handedOff {
+			return ErrHandedOff
+		}
+
+		select {
+		case <-quit:
+			return ErrServerClosed
+		default:
+		}
+
+		if err := seccomp.NotifWait(waiting, s.fd); err != context.Canceled {
+			return err
+		}
+	}
+}
+
+// Helper - Wake up the receive loops waiting for notifications, to check the
+// state of the supervisor again
+// Requires the supervisor lock
+func (s *Supervisor) wakeUp() {
+	s.wake()
+	s.waiting, s.wake = context.WithCancel(context.Background())
+}
+
+// Helper - Mark a pending notification as being responded to
+func (s *Supervisor) claim(id uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.handoff != nil {
+		return ErrHandedOff
+	}
+
+	p, ok := s.pending[id]
+	if !ok {
+		if _, ok := s.answered[id]; ok {
+			return &ResponseError{ID: id, Err: ErrAlreadyResponded}
+		}
+		return &ResponseError{ID: id, Err: ErrUnknownID}
+	} else if p.responding {
+		return &ResponseError{ID: id, Err: ErrAlreadyResponded}
+	}
+	p.responding = true
+	s.inflight.Add(1)
+
+	return nil
+}
+
+// Helper - Forget a pending notification which is no longer valid
+// Requires the supervisor lock
+func (s *Supervisor) drop(id uint64) {
+	if p, ok := s.pending[id]; ok && !p.responding {
+		delete(s.pending, id)
+	}
+}
+
+// Helper - Move a notification from the pending to the answered set
+// Requires the supervisor lock
+func (s *Supervisor) retire(id uint64) {
+	delete(s.pending, id)
+
+	s.answered[id] = struct{}{}
+	s.history = append(s.history, id)
+	if len(s.history) > answeredHistory {
+		delete(s.answered, s.history[0])
+		s.history = s.history[1:]
+	}
+}
@@ -0,0 +1,261 @@
+// +build linux
+
+// Tests for the notification supervisor of libseccomp Go bindings
+
+package notify
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	seccomp "github.com/seccomp/libseccomp-golang"
+	"golang.org/x/sys/unix"
+)
+
+// execInSubprocess runs the test in a new process, so the filters it loads
+// do not confine the test binary. See the seccomp package tests.
+func execInSubprocess(t *testing.T, f func(t *testing.T)) {
+	const subprocessEnvKey = `GO_SUBPROCESS_KEY`
+	if testIDString, ok := os.LookupEnv(subprocessEnvKey); ok && testIDString == "1" {
+		t.Run(`subprocess`, f)
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run="+t.Name()+"$", "-test.v=true")
+	cmd.Env = []string{subprocessEnvKey + "=1"}
+
+	var b strings.Builder
+	cmd.Stdout = &b
+	cmd.Stderr = &b
+
+	if err := cmd.Run(); err != nil {
+		t.Logf("\n%s", b.String())
+		t.Fatalf("Test failed in sub-process: %v", err)
+	}
+}
+
+// Helper - Load a filter notifying on the syscalls names and return its fd
+func loadNotifyFilter(t *testing.T, names ...string) seccomp.ScmpFd {
+	if api, err := seccomp.GetAPI(); err != nil || api < 6 {
+		if err := seccomp.SetAPI(6); err != nil {
+			t.Skipf("Skipping test: seccomp notification is not supported")
+		}
+	}
+
+	filter, err := seccomp.NewFilter(seccomp.ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	for _, name := range names {
+		call, err := seccomp.GetSyscallFromName(name)
+		if err != nil {
+			t.Fatalf("Error getting syscall number: %s", err)
+		}
+		if err := filter.AddRule(call, seccomp.ActNotify); err != nil {
+			t.Fatalf("Error adding rule: %s", err)
+		}
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	return fd
+}
+
+func TestSupervisorServe(t *testing.T) {
+	execInSubprocess(t, subprocessSupervisorServe)
+}
+func subprocessSupervisorServe(t *testing.T) {
+	fd := loadNotifyFilter(t, "chdir")
+	supervisor := NewSupervisor(fd)
+
+	go supervisor.Serve(func(req *Request) Response {
+		if name, _ := req.Data.Syscall.GetName(); name != "chdir" {
+			t.Errorf("Unexpected notification for syscall %q", name)
+		}
+		var resp Response
+		resp.SetErrno(unix.ENOMEDIUM)
+		return resp
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := unix.Chdir("/"); err != unix.ENOMEDIUM {
+			t.Errorf("Supervisor response not applied: got %v, want %v", err, unix.ENOMEDIUM)
+		}
+	}
+}
+
+func TestSupervisorDoubleResponse(t *testing.T) {
+	execInSubprocess(t, subprocessSupervisorDoubleResponse)
+}
+func subprocessSupervisorDoubleResponse(t *testing.T) {
+	fd := loadNotifyFilter(t, "chdir")
+	supervisor := NewSupervisor(fd)
+
+	done := make(chan error)
+	go func() {
+		done <- unix.Chdir("/")
+	}()
+
+	req, err := supervisor.Receive()
+	if err != nil {
+		t.Fatalf("Error receiving notification: %s", err)
+	}
+	if n := supervisor.Pending(); n != 1 {
+		t.Errorf("Expected 1 pending notification, got %d", n)
+	}
+	if valid, err := supervisor.IDsValid([]uint64{req.ID, req.ID + 1000}); err != nil {
+		t.Errorf("Error checking notification IDs: %s", err)
+	} else if !valid[0] || valid[1] {
+		t.Errorf("Unexpected notification ID validity: %v", valid)
+	}
+
+	resp := Response{ID: req.ID}
+	resp.SetErrno(unix.EPERM)
+	if err := supervisor.Respond(&resp); err != nil {
+		t.Fatalf("Error responding to notification: %s", err)
+	}
+	if err := <-done; err != unix.EPERM {
+		t.Errorf("Supervisor response not applied: got %v, want %v", err, unix.EPERM)
+	}
+
+	err = supervisor.Respond(&resp)
+	if rerr, ok := err.(*ResponseError); !ok || rerr.ID != req.ID || !errors.Is(err, ErrAlreadyResponded) {
+		t.Errorf("Second response should fail with ErrAlreadyResponded, got %v", err)
+	}
+
+	resp.ID = req.ID + 1000
+	if err := supervisor.Respond(&resp); !errors.Is(err, ErrUnknownID) {
+		t.Errorf("Response to an unknown ID should fail with ErrUnknownID, got %v", err)
+	}
+
+	if n := supervisor.Pending(); n != 0 {
+		t.Errorf("Expected no pending notification, got %d", n)
+	}
+}
+
+func TestArgs(t *testing.T) {
+	execInSubprocess(t, subprocessArgs)
+}
+func subprocessArgs(t *testing.T) {
+	fd := loadNotifyFilter(t, "chdir")
+	supervisor := NewSupervisor(fd)
+
+	done := make(chan error)
+	go func() {
+		done <- unix.Chdir("/non-existent-path")
+	}()
+
+	req, err := supervisor.Receive()
+	if err != nil {
+		t.Fatalf("Error receiving notification: %s", err)
+	}
+
+	args := supervisor.Args(req)
+	defer args.Close()
+
+	path, err := args.String(0, unix.PathMax)
+	if err != nil {
+		t.Fatalf("Error reading path argument: %s", err)
+	} else if path != "/non-existent-path" {
+		t.Errorf("Unexpected path argument: %q", path)
+	}
+	if _, err := args.String(0, 4); err == nil {
+		t.Errorf("Reading a string longer than the maximum should fail")
+	}
+	if data, err := args.Bytes(0, 4); err != nil || string(data) != "/non" {
+		t.Errorf("Unexpected bytes argument: %q, %v", data, err)
+	}
+
+	resp := Response{ID: req.ID}
+	resp.SetErrno(unix.ENOMEDIUM)
+	if err := supervisor.Respond(&resp); err != nil {
+		t.Fatalf("Error responding to notification: %s", err)
+	}
+	<-done
+
+	// Cached reads are dropped once the notification has been answered
+	if _, err := args.String(0, unix.PathMax); !errors.Is(err, seccomp.ErrNotifTargetGone) {
+		t.Errorf("Reading arguments of an answered notification should fail, got %v", err)
+	}
+}
+
+func TestHandoff(t *testing.T) {
+	execInSubprocess(t, subprocessHandoff)
+}
+func subprocessHandoff(t *testing.T) {
+	fd := loadNotifyFilter(t, "chdir")
+	old := NewSupervisor(fd)
+
+	pair, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("Error creating socket pair: %s", err)
+	}
+	oldConn, newConn := unixConn(t, pair[0]), unixConn(t, pair[1])
+	defer oldConn.Close()
+	defer newConn.Close()
+
+	done := make(chan error, 2)
+	go func() {
+		done <- unix.Chdir("/pending")
+	}()
+
+	// Leave a notification pending across the handoff
+	req, err := old.Receive()
+	if err != nil {
+		t.Fatalf("Error receiving notification: %s", err)
+	}
+
+	if err := old.Handoff(oldConn); err != nil {
+		t.Fatalf("Error handing off supervisor: %s", err)
+	}
+	if err := old.Handoff(oldConn); err != ErrHandedOff {
+		t.Errorf("Second handoff should fail with ErrHandedOff, got %v", err)
+	}
+
+	supervisor, err := AcceptHandoff(newConn)
+	if err != nil {
+		t.Fatalf("Error accepting handoff: %s", err)
+	}
+	defer unix.Close(int(supervisor.Fd()))
+
+	resp := Response{ID: req.ID}
+	if err := old.Respond(&resp); err != ErrHandedOff {
+		t.Errorf("Old supervisor should refuse to respond, got %v", err)
+	}
+	if err := old.Serve(nil); err != ErrHandedOff {
+		t.Errorf("Old supervisor should refuse to serve, got %v", err)
+	}
+
+	go supervisor.Serve(func(req *Request) Response {
+		var resp Response
+		resp.SetErrno(unix.ENOMEDIUM)
+		return resp
+	})
+
+	if err := <-done; err != unix.ENOMEDIUM {
+		t.Errorf("Pending notification not answered by new supervisor: got %v", err)
+	}
+	if err := unix.Chdir("/"); err != unix.ENOMEDIUM {
+		t.Errorf("New notification not answered by new supervisor: got %v", err)
+	}
+}
+
+// Helper - Wrap one end of a socket pair into a *net.UnixConn
+func unixConn(t *testing.T, fd int) *net.UnixConn {
+	file := os.NewFile(uintptr(fd), "socketpair")
+	defer file.Close()
+
+	conn, err := net.FileConn(file)
+	if err != nil {
+		t.Fatalf("Error wrapping socket: %s", err)
+	}
+	return conn.(*net.UnixConn)
+}
@@ -0,0 +1,368 @@
+// Default container profile for libseccomp Go bindings
+// Provides the default seccomp profile of Docker and containerd
+
+package profile
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+const (
+	// Namespace flags of clone(2) that require CAP_SYS_ADMIN: CLONE_NEWNS,
+	// CLONE_NEWCGROUP, CLONE_NEWUTS, CLONE_NEWIPC, CLONE_NEWUSER,
+	// CLONE_NEWPID and CLONE_NEWNET
+	cloneNamespaceFlags = 0x7e020000
+	// Socket address family of virtual machine sockets
+	afVsock = 40
+	// Error numbers of denied syscalls
+	errnoEPERM  = 1
+	errnoENOSYS = 38
+)
+
+// DefaultCapabilities are the capabilities Docker grants containers by
+// default, which the default profile assumes when no capabilities are given.
+var DefaultCapabilities = []string{
+	"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_FSETID", "CAP_FOWNER", "CAP_MKNOD",
+	"CAP_NET_RAW", "CAP_SETGID", "CAP_SETUID", "CAP_SETFCAP", "CAP_SETPCAP",
+	"CAP_NET_BIND_SERVICE", "CAP_SYS_CHROOT", "CAP_KILL", "CAP_AUDIT_WRITE",
+}
+
+// DefaultOptions selects the conditional rules of the default profile, which
+// Docker and containerd resolve from the container's configuration.
+//
+// Capabilities:  capabilities of the container (e.g., "CAP_SYS_ADMIN"),
+//                DefaultCapabilities if nil
+// Arch:          Go architecture the profile is for, runtime.GOARCH if empty
+// KernelVersion: "major.minor" version of the kernel the profile is for;
+//                rules requiring a newer kernel are left out. All rules are
+//                kept if empty
+//
+type DefaultOptions struct {
+	Capabilities  []string
+	Arch          string
+	KernelVersion string
+}
+
+// Rule of the default profile, which only applies to some containers
+type defaultRule struct {
+	Syscall
+	// Rule applies if the container has any of these capabilities
+	caps []string
+	// Rule applies if the container has none of these capabilities
+	excludeCaps []string
+	// Rule applies on these Go architectures only
+	arches []string
+	// Rule applies from this kernel version on
+	minKernel string
+}
+
+// Architectures of the default profile, by Go architecture
+var defaultArches = map[string][]string{
+	"amd64":    {"SCMP_ARCH_X86_64", "SCMP_ARCH_X86", "SCMP_ARCH_X32"},
+	"arm64":    {"SCMP_ARCH_AARCH64", "SCMP_ARCH_ARM"},
+	"mips64":   {"SCMP_ARCH_MIPS64", "SCMP_ARCH_MIPS64N32", "SCMP_ARCH_MIPS"},
+	"mips64le": {"SCMP_ARCH_MIPSEL64", "SCMP_ARCH_MIPSEL64N32", "SCMP_ARCH_MIPSEL"},
+	"ppc64le":  {"SCMP_ARCH_PPC64LE"},
+	"s390x":    {"SCMP_ARCH_S390X", "SCMP_ARCH_S390"},
+	"loong64":  {"SCMP_ARCH_LOONGARCH64"},
+	"riscv64":  {"SCMP_ARCH_RISCV64"},
+}
+
+// Rules of the default profile, as maintained by moby
+var defaultRules = []defaultRule{
+	{Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{
+		"accept", "accept4", "access", "adjtimex", "alarm", "bind", "brk",
+		"cachestat", "capget", "capset", "chdir", "chmod", "chown", "chown32",
+		"clock_adjtime", "clock_adjtime64", "clock_getres", "clock_getres_time64",
+		"clock_gettime", "clock_gettime64", "clock_nanosleep",
+		"clock_nanosleep_time64", "close", "close_range", "connect",
+		"copy_file_range", "creat", "dup", "dup2", "dup3", "epoll_create",
+		"epoll_create1", "epoll_ctl", "epoll_ctl_old", "epoll_pwait",
+		"epoll_pwait2", "epoll_wait", "epoll_wait_old", "eventfd", "eventfd2",
+		"execve", "execveat", "exit", "exit_group", "faccessat", "faccessat2",
+		"fadvise64", "fadvise64_64", "fallocate", "fanotify_mark", "fchdir",
+		"fchmod", "fchmodat", "fchmodat2", "fchown", "fchown32", "fchownat",
+		"fcntl", "fcntl64", "fdatasync", "fgetxattr", "flistxattr", "flock",
+		"fork", "fremovexattr", "fsetxattr", "fstat", "fstat64", "fstatat64",
+		"fstatfs", "fstatfs64", "fsync", "ftruncate", "ftruncate64", "futex",
+		"futex_requeue", "futex_time64", "futex_wait", "futex_waitv",
+		"futex_wake", "futimesat", "getcpu", "getcwd", "getdents", "getdents64",
+		"getegid", "getegid32", "geteuid", "geteuid32", "getgid", "getgid32",
+		"getgroups", "getgroups32", "getitimer", "getpeername", "getpgid",
+		"getpgrp", "getpid", "getppid", "getpriority", "getrandom", "getresgid",
+		"getresgid32", "getresuid", "getresuid32", "getrlimit",
+		"get_robust_list", "getrusage", "getsid", "getsockname", "getsockopt",
+		"get_thread_area", "gettid", "gettimeofday", "getuid", "getuid32",
+		"getxattr", "inotify_add_watch", "inotify_init", "inotify_init1",
+		"inotify_rm_watch", "io_cancel", "ioctl", "io_destroy", "io_getevents",
+		"io_pgetevents", "io_pgetevents_time64", "ioprio_get", "ioprio_set",
+		"io_setup", "io_submit", "ipc", "kill", "landlock_add_rule",
+		"landlock_create_ruleset", "landlock_restrict_self", "lchown",
+		"lchown32", "lgetxattr", "link", "linkat", "listen", "listxattr",
+		"llistxattr", "_llseek", "lremovexattr", "lseek", "lsetxattr", "lstat",
+		"lstat64", "madvise", "map_shadow_stack", "membarrier", "memfd_create",
+		"memfd_secret", "mincore", "mkdir", "mkdirat", "mknod", "mknodat",
+		"mlock", "mlock2", "mlockall", "mmap", "mmap2", "mprotect",
+		"mq_getsetattr", "mq_notify", "mq_open", "mq_timedreceive",
+		"mq_timedreceive_time64", "mq_timedsend", "mq_timedsend_time64",
+		"mq_unlink", "mremap", "msgctl", "msgget", "msgrcv", "msgsnd", "msync",
+		"munlock", "munlockall", "munmap", "name_to_handle_at", "nanosleep",
+		"newfstatat", "_newselect", "open", "openat", "openat2", "pause",
+		"pidfd_open", "pidfd_send_signal", "pipe", "pipe2", "pkey_alloc",
+		"pkey_free", "pkey_mprotect", "poll", "ppoll", "ppoll_time64", "prctl",
+		"pread64", "preadv", "preadv2", "prlimit64", "process_mrelease",
+		"pselect6", "pselect6_time64", "pwrite64", "pwritev", "pwritev2",
+		"read", "readahead", "readlink", "readlinkat", "readv", "recv",
+		"recvfrom", "recvmmsg", "recvmmsg_time64", "recvmsg",
+		"remap_file_pages", "removexattr", "rename", "renameat", "renameat2",
+		"restart_syscall", "rmdir", "rseq", "rt_sigaction", "rt_sigpending",
+		"rt_sigprocmask", "rt_sigqueueinfo", "rt_sigreturn", "rt_sigsuspend",
+		"rt_sigtimedwait", "rt_sigtimedwait_time64", "rt_tgsigqueueinfo",
+		"sched_getaffinity", "sched_getattr", "sched_getparam",
+		"sched_get_priority_max", "sched_get_priority_min",
+		"sched_getscheduler", "sched_rr_get_interval",
+		"sched_rr_get_interval_time64", "sched_setaffinity", "sched_setattr",
+		"sched_setparam", "sched_setscheduler", "sched_yield", "seccomp",
+		"select", "semctl", "semget", "semop", "semtimedop",
+		"semtimedop_time64", "send", "sendfile", "sendfile64", "sendmmsg",
+		"sendmsg", "sendto", "setfsgid", "setfsgid32", "setfsuid",
+		"setfsuid32", "setgid", "setgid32", "setgroups", "setgroups32",
+		"setitimer", "setpgid", "setpriority", "setregid", "setregid32",
+		"setresgid", "setresgid32", "setresuid", "setresuid32", "setreuid",
+		"setreuid32", "setrlimit", "set_robust_list", "setsid", "setsockopt",
+		"set_thread_area", "set_tid_address", "setuid", "setuid32", "setxattr",
+		"shmat", "shmctl", "shmdt", "shmget", "shutdown", "sigaltstack",
+		"signalfd", "signalfd4", "sigprocmask", "sigreturn", "socketcall",
+		"socketpair", "splice", "stat", "stat64", "statfs", "statfs64", "statx",
+		"symlink", "symlinkat", "sync", "sync_file_range", "syncfs", "sysinfo",
+		"tee", "tgkill", "time", "timer_create", "timer_delete",
+		"timer_getoverrun", "timer_gettime", "timer_gettime64", "timer_settime",
+		"timer_settime64", "timerfd_create", "timerfd_gettime",
+		"timerfd_gettime64", "timerfd_settime", "timerfd_settime64", "times",
+		"tkill", "truncate", "truncate64", "ugetrlimit", "umask", "uname",
+		"unlink", "unlinkat", "utime", "utimensat", "utimensat_time64",
+		"utimes", "vfork", "vmsplice", "wait4", "waitid", "waitpid", "write",
+		"writev",
+	}}},
+	// ptrace could bypass seccomp before Linux 4.8
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW",
+			Names: []string{"process_vm_readv", "process_vm_writev", "ptrace"}},
+		minKernel: "4.8",
+	},
+	// Virtual machine sockets are not namespaced
+	{Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"socket"},
+		Args: []Arg{{Index: 0, Value: afVsock, Op: "SCMP_CMP_NE"}}}},
+	// Execution domains: PER_LINUX, PER_LINUX32, UNAME26, UNAME26|PER_LINUX32
+	// and queries of the current one
+	{Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"personality"},
+		Args: []Arg{{Index: 0, Value: 0x0, Op: "SCMP_CMP_EQ"}}}},
+	{Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"personality"},
+		Args: []Arg{{Index: 0, Value: 0x0008, Op: "SCMP_CMP_EQ"}}}},
+	{Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"personality"},
+		Args: []Arg{{Index: 0, Value: 0x20000, Op: "SCMP_CMP_EQ"}}}},
+	{Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"personality"},
+		Args: []Arg{{Index: 0, Value: 0x20008, Op: "SCMP_CMP_EQ"}}}},
+	{Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"personality"},
+		Args: []Arg{{Index: 0, Value: 0xffffffff, Op: "SCMP_CMP_EQ"}}}},
+	// Architecture specific syscalls
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW",
+			Names: []string{"sync_file_range2", "swapcontext"}},
+		arches: []string{"ppc64le"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"arm_fadvise64_64",
+			"arm_sync_file_range", "sync_file_range2", "breakpoint", "cacheflush", "set_tls"}},
+		arches: []string{"arm", "arm64"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"arch_prctl"}},
+		arches:  []string{"amd64", "x32"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"modify_ldt"}},
+		arches:  []string{"amd64", "x32", "x86"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"s390_pci_mmio_read",
+			"s390_pci_mmio_write", "s390_runtime_instr"}},
+		arches: []string{"s390", "s390x"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"riscv_flush_icache"}},
+		arches:  []string{"riscv64"},
+	},
+	// Capability dependent syscalls
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"open_by_handle_at"}},
+		caps:    []string{"CAP_DAC_READ_SEARCH"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"bpf", "clone", "clone3",
+			"fanotify_init", "fsconfig", "fsmount", "fsopen", "fspick", "lookup_dcookie",
+			"mount", "mount_setattr", "move_mount", "open_tree", "perf_event_open",
+			"quotactl", "quotactl_fd", "setdomainname", "sethostname", "setns", "syslog",
+			"umount", "umount2", "unshare"}},
+		caps: []string{"CAP_SYS_ADMIN"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"clone"},
+			Args: []Arg{{Index: 0, Value: cloneNamespaceFlags, Op: "SCMP_CMP_MASKED_EQ"}}},
+		excludeCaps: []string{"CAP_SYS_ADMIN"},
+		arches: []string{"386", "amd64", "arm", "arm64", "mips", "mipsle", "mips64",
+			"mips64le", "ppc64", "ppc64le", "riscv64"},
+	},
+	// The flags are the second argument of clone on s390
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"clone"},
+			Args: []Arg{{Index: 1, Value: cloneNamespaceFlags, Op: "SCMP_CMP_MASKED_EQ"}}},
+		excludeCaps: []string{"CAP_SYS_ADMIN"},
+		arches:      []string{"s390", "s390x"},
+	},
+	// clone3 cannot be filtered on its flags, make programs fall back to
+	// clone
+	{
+		Syscall:     Syscall{Action: "SCMP_ACT_ERRNO", ErrnoRet: uintPtr(errnoENOSYS), Names: []string{"clone3"}},
+		excludeCaps: []string{"CAP_SYS_ADMIN"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"reboot"}},
+		caps:    []string{"CAP_SYS_BOOT"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"chroot"}},
+		caps:    []string{"CAP_SYS_CHROOT"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"delete_module",
+			"init_module", "finit_module"}},
+		caps: []string{"CAP_SYS_MODULE"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"acct"}},
+		caps:    []string{"CAP_SYS_PACCT"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"kcmp", "pidfd_getfd",
+			"process_madvise", "process_vm_readv", "process_vm_writev", "ptrace"}},
+		caps: []string{"CAP_SYS_PTRACE"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"iopl", "ioperm"}},
+		caps:    []string{"CAP_SYS_RAWIO"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"settimeofday", "stime",
+			"clock_settime", "clock_settime64"}},
+		caps: []string{"CAP_SYS_TIME"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"vhangup"}},
+		caps:    []string{"CAP_SYS_TTY_CONFIG"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"get_mempolicy", "mbind",
+			"set_mempolicy", "set_mempolicy_home_node"}},
+		caps: []string{"CAP_SYS_NICE"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"syslog"}},
+		caps:    []string{"CAP_SYSLOG"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"bpf"}},
+		caps:    []string{"CAP_BPF"},
+	},
+	{
+		Syscall: Syscall{Action: "SCMP_ACT_ALLOW", Names: []string{"perf_event_open"}},
+		caps:    []string{"CAP_PERFMON"},
+	},
+}
+
+// Default returns the default seccomp profile of Docker and containerd,
+// resolved for the container described by opts as they do: rules which
+// depend on capabilities, the architecture or the kernel version are only
+// kept if they apply. Syscalls which are not allowed fail with EPERM.
+// Returns an error if the kernel version is invalid.
+func Default(opts DefaultOptions) (*Seccomp, error) {
+	caps := make(map[string]bool)
+	capabilities := opts.Capabilities
+	if capabilities == nil {
+		capabilities = DefaultCapabilities
+	}
+	for _, name := range capabilities {
+		name = strings.ToUpper(name)
+		if !strings.HasPrefix(name, "CAP_") {
+			name = "CAP_" + name
+		}
+		caps[name] = true
+	}
+
+	arch := opts.Arch
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+
+	p := &Seccomp{
+		DefaultAction:   "SCMP_ACT_ERRNO",
+		DefaultErrnoRet: uintPtr(errnoEPERM),
+		Architectures:   append([]string(nil), defaultArches[arch]...),
+	}
+
+	for _, rule := range defaultRules {
+		if len(rule.caps) > 0 && !anyOf(rule.caps, caps) {
+			continue
+		} else if anyOf(rule.excludeCaps, caps) {
+			continue
+		} else if len(rule.arches) > 0 && !anyOf(rule.arches, map[string]bool{arch: true}) {
+			continue
+		}
+
+		if rule.minKernel != "" && opts.KernelVersion != "" {
+			newer, err := kernelAtLeast(opts.KernelVersion, rule.minKernel)
+			if err != nil {
+				return nil, err
+			} else if !newer {
+				continue
+			}
+		}
+
+		call := rule.Syscall
+		call.Names = append([]string(nil), call.Names...)
+		call.Args = append([]Arg(nil), call.Args...)
+		p.Syscalls = append(p.Syscalls, call)
+	}
+
+	return p, nil
+}
+
+// Helper - Check whether any of the names is in set
+func anyOf(names []string, set map[string]bool) bool {
+	for _, name := range names {
+		if set[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper - Compare "major.minor" kernel versions, ignoring anything after the
+// minor version (e.g., "5.15.0-91-generic")
+func kernelAtLeast(version, min string) (bool, error) {
+	var major, minor, minMajor, minMinor int
+	if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+		return false, fmt.Errorf("invalid kernel version %q", version)
+	}
+	if _, err := fmt.Sscanf(min, "%d.%d", &minMajor, &minMinor); err != nil {
+		return false, fmt.Errorf("invalid kernel version %q", min)
+	}
+
+	return major > minMajor || (major == minMajor && minor >= minMinor), nil
+}
+
+func uintPtr(v uint) *uint {
+	return &v
+}
@@ -0,0 +1,94 @@
+// Tests for the default container profile of libseccomp Go bindings
+
+package profile
+
+import (
+	"testing"
+)
+
+// Helper - Check whether a profile has a rule for a syscall with an action
+func hasRule(p *Seccomp, name, action string) bool {
+	for _, call := range p.Syscalls {
+		if call.Action != action {
+			continue
+		}
+		for _, n := range call.Names {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestDefault(t *testing.T) {
+	p, err := Default(DefaultOptions{Arch: "amd64", KernelVersion: "5.15.0-91-generic"})
+	if err != nil {
+		t.Fatalf("Error resolving default profile: %s", err)
+	}
+
+	if p.DefaultAction != "SCMP_ACT_ERRNO" || p.DefaultErrnoRet == nil || *p.DefaultErrnoRet != 1 {
+		t.Errorf("Default profile should fail syscalls with EPERM")
+	}
+	if len(p.Architectures) != 3 || p.Architectures[0] != "SCMP_ARCH_X86_64" {
+		t.Errorf("Unexpected architectures for amd64: %v", p.Architectures)
+	}
+
+	for _, test := range []struct {
+		name, action string
+		want         bool
+	}{
+		{"read", "SCMP_ACT_ALLOW", true},
+		{"ptrace", "SCMP_ACT_ALLOW", true},
+		{"chroot", "SCMP_ACT_ALLOW", true},
+		{"arch_prctl", "SCMP_ACT_ALLOW", true},
+		{"clone3", "SCMP_ACT_ERRNO", true},
+		{"mount", "SCMP_ACT_ALLOW", false},
+		{"reboot", "SCMP_ACT_ALLOW", false},
+		{"set_tls", "SCMP_ACT_ALLOW", false},
+	} {
+		if got := hasRule(p, test.name, test.action); got != test.want {
+			t.Errorf("Rule %s for %s: got %t, want %t", test.action, test.name, got, test.want)
+		}
+	}
+}
+
+func TestDefaultOptions(t *testing.T) {
+	p, err := Default(DefaultOptions{
+		Capabilities:  []string{"sys_admin", "CAP_SYS_BOOT"},
+		Arch:          "arm64",
+		KernelVersion: "4.4",
+	})
+	if err != nil {
+		t.Fatalf("Error resolving default profile: %s", err)
+	}
+
+	for _, test := range []struct {
+		name, action string
+		want         bool
+	}{
+		{"mount", "SCMP_ACT_ALLOW", true},
+		{"reboot", "SCMP_ACT_ALLOW", true},
+		{"set_tls", "SCMP_ACT_ALLOW", true},
+		{"clone3", "SCMP_ACT_ALLOW", true},
+		{"clone3", "SCMP_ACT_ERRNO", false},
+		{"chroot", "SCMP_ACT_ALLOW", false},
+		{"arch_prctl", "SCMP_ACT_ALLOW", false},
+		// ptrace could bypass seccomp before Linux 4.8
+		{"ptrace", "SCMP_ACT_ALLOW", false},
+	} {
+		if got := hasRule(p, test.name, test.action); got != test.want {
+			t.Errorf("Rule %s for %s: got %t, want %t", test.action, test.name, got, test.want)
+		}
+	}
+
+	if p, err := Default(DefaultOptions{Arch: "ppc64"}); err != nil {
+		t.Errorf("Error resolving default profile: %s", err)
+	} else if p.Architectures != nil || !hasRule(p, "ptrace", "SCMP_ACT_ALLOW") {
+		t.Errorf("Profile without kernel version should only use the native architecture and keep all rules")
+	}
+
+	if _, err := Default(DefaultOptions{KernelVersion: "bogus"}); err == nil {
+		t.Errorf("Resolving with an invalid kernel version should fail")
+	}
+}
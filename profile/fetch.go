@@ -0,0 +1,418 @@
+// Remote profile retrieval for libseccomp Go bindings
+// Fetches OCI seccomp profiles over HTTP(S) or from OCI registries
+
+package profile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Media types accepted when requesting a manifest from an OCI registry
+	ociManifestMediaTypes = "application/vnd.oci.image.manifest.v1+json, " +
+		"application/vnd.oci.artifact.manifest.v1+json, " +
+		"application/vnd.docker.distribution.manifest.v2+json"
+	// Upper bound on the size of a fetched profile or manifest
+	maxFetchSize = 16 << 20
+)
+
+// Fetcher retrieves seccomp profiles from http(s) URLs or OCI
+// registries and caches them by digest, so that a previously fetched profile
+// remains available when the remote location cannot be reached.
+//
+// Locations are either http:// or https:// URLs, or OCI artifact references
+// of the form oci://registry/repository:tag or
+// oci://registry/repository@sha256:digest. The profile is taken from the
+// first layer of the artifact's manifest.
+type Fetcher struct {
+	// CacheDir is the directory holding cached profiles. Caching is
+	// disabled if empty.
+	CacheDir string
+	// Client is the HTTP client used for requests. http.DefaultClient is
+	// used if nil.
+	Client *http.Client
+	// PlainHTTP makes requests to OCI registries over plain HTTP.
+	PlainHTTP bool
+}
+
+// NewFetcher creates a Fetcher caching profiles in cacheDir.
+func NewFetcher(cacheDir string) *Fetcher {
+	return &Fetcher{CacheDir: cacheDir}
+}
+
+// Fetch retrieves the raw profile stored at location.
+// Returns the profile and its digest (in "sha256:<hex>" form). If the
+// location cannot be reached, because of a network failure or a server
+// error, the last copy cached for it is returned instead. Profiles the
+// server refused or no longer has, and profiles failing their digest check,
+// are not replaced by a cached copy. Returns an error if the profile could
+// neither be fetched nor found in the cache.
+func (pf *Fetcher) Fetch(location string) ([]byte, string, error) {
+	// Digest references are immutable, prefer the cache for them. The
+	// digest pins the manifest, the profile is cached by the digest of the
+	// layer holding it
+	if strings.HasPrefix(location, "oci://") && strings.Contains(location, "@sha256:") {
+		if data, digest, err := pf.readCached(location); err == nil {
+			return data, digest, nil
+		}
+	}
+
+	var data []byte
+	var err error
+	switch {
+	case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+		data, err = pf.get(location, "")
+	case strings.HasPrefix(location, "oci://"):
+		data, err = pf.fetchArtifact(strings.TrimPrefix(location, "oci://"))
+	default:
+		return nil, "", fmt.Errorf("unsupported profile location %q", location)
+	}
+
+	if err != nil {
+		if !isUnreachable(err) {
+			return nil, "", fmt.Errorf("could not fetch profile %q: %v", location, err)
+		}
+		cached, digest, cacheErr := pf.readCached(location)
+		if cacheErr != nil {
+			return nil, "", fmt.Errorf("could not fetch profile %q: %v", location, err)
+		}
+		return cached, digest, nil
+	}
+
+	digest := digestOf(data)
+	if err := pf.writeCached(location, digest, data); err != nil {
+		return nil, "", fmt.Errorf("could not cache profile %q: %v", location, err)
+	}
+
+	return data, digest, nil
+}
+
+// FetchProfile retrieves and parses the OCI seccomp profile stored at
+// location. See Fetch for details.
+func (pf *Fetcher) FetchProfile(location string) (*Seccomp, error) {
+	data, _, err := pf.Fetch(location)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := new(Seccomp)
+	if err := json.Unmarshal(data, profile); err != nil {
+		return nil, fmt.Errorf("could not parse profile %q: %v", location, err)
+	}
+
+	return profile, nil
+}
+
+// Helper - Fetch a profile stored as an artifact in an OCI registry
+func (pf *Fetcher) fetchArtifact(ref string) ([]byte, error) {
+	slash := strings.Index(ref, "/")
+	if slash <= 0 {
+		return nil, fmt.Errorf("invalid OCI reference %q", ref)
+	}
+	registry, repo := ref[:slash], ref[slash+1:]
+
+	tag := "latest"
+	if i := strings.Index(repo, "@"); i >= 0 {
+		repo, tag = repo[:i], repo[i+1:]
+	} else if i := strings.LastIndex(repo, ":"); i >= 0 {
+		repo, tag = repo[:i], repo[i+1:]
+	}
+
+	scheme := "https"
+	if pf.PlainHTTP {
+		scheme = "http"
+	}
+	base := fmt.Sprintf("%s://%s/v2/%s", scheme, registry, repo)
+
+	body, err := pf.get(base+"/manifests/"+tag, ociManifestMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(tag, "sha256:") && digestOf(body) != tag {
+		return nil, fmt.Errorf("digest mismatch for the manifest of %q", ref)
+	}
+
+	var manifest struct {
+		Layers []struct {
+			Digest string `json:"digest"`
+		} `json:"layers"`
+		Blobs []struct {
+			Digest string `json:"digest"`
+		} `json:"blobs"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("could not parse manifest of %q: %v", ref, err)
+	}
+
+	var digest string
+	if len(manifest.Layers) > 0 {
+		digest = manifest.Layers[0].Digest
+	} else if len(manifest.Blobs) > 0 {
+		digest = manifest.Blobs[0].Digest
+	} else {
+		return nil, fmt.Errorf("manifest of %q has no layers", ref)
+	}
+
+	data, err := pf.get(base+"/blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if digestOf(data) != digest {
+		return nil, fmt.Errorf("digest mismatch for %q: expected %s", ref, digest)
+	}
+
+	return data, nil
+}
+
+// Helper - Perform a GET request, answering a registry's bearer token
+// challenge once if needed
+func (pf *Fetcher) get(url, accept string) ([]byte, error) {
+	resp, err := pf.request(url, accept, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if challenge := resp.Header.Get("Www-Authenticate"); resp.StatusCode == http.StatusUnauthorized &&
+		strings.HasPrefix(challenge, "Bearer ") {
+		resp.Body.Close()
+		token, err := pf.getToken(challenge)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = pf.request(url, accept, token); err != nil {
+			return nil, err
+		}
+	}
+
+	return readResponse(url, resp)
+}
+
+// Helper - Perform a single GET request, with a bearer token if not empty
+func (pf *Fetcher) request(url, accept, token string) (*http.Response, error) {
+	client := pf.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return client.Do(req)
+}
+
+// statusError denotes a request answered with a status other than 200 OK
+type statusError struct {
+	url    string
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.url, e.status)
+}
+
+// Helper - Check whether an error means that the remote location could not
+// be reached, as opposed to it answering with an error
+func isUnreachable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code >= 500
+	}
+
+	// Failed connections and DNS lookups, timeouts, and connections cut
+	// mid-response. Every *url.Error is a net.Error, so TLS and redirect
+	// failures must not be matched by that interface
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		// TLS alerts are reported as "local error" and "remote error"
+		switch opErr.Op {
+		case "dial", "read", "write", "proxyconnect":
+			return true
+		}
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Helper - Read the body of a successful response and close it
+func readResponse(url string, resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{url: url, code: resp.StatusCode, status: resp.Status}
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFetchSize {
+		return nil, fmt.Errorf("GET %s: response exceeds %d bytes", url, maxFetchSize)
+	}
+
+	return data, nil
+}
+
+// Helper - Obtain an anonymous token from a registry's token service
+func (pf *Fetcher) getToken(challenge string) (string, error) {
+	params := make(map[string]string)
+	for _, kv := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		if i := strings.Index(kv, "="); i > 0 {
+			params[strings.TrimSpace(kv[:i])] = strings.Trim(kv[i+1:], `"`)
+		}
+	}
+
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry authentication challenge has no realm")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, realm, nil)
+	if err != nil {
+		return "", err
+	}
+	query := req.URL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+
+	// The token service is not authenticated against
+	resp, err := pf.request(req.URL.String(), "", "")
+	if err != nil {
+		return "", err
+	}
+	data, err := readResponse(req.URL.String(), resp)
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return "", fmt.Errorf("could not parse registry token: %v", err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+
+	return token.AccessToken, nil
+}
+
+// Cache helpers
+// Blobs are stored by digest, locations map to the digest last fetched
+
+func (pf *Fetcher) blobPath(digest string) string {
+	return filepath.Join(pf.CacheDir, "blobs", strings.Replace(digest, ":", string(filepath.Separator), 1))
+}
+
+func (pf *Fetcher) refPath(location string) string {
+	return filepath.Join(pf.CacheDir, "refs", strings.TrimPrefix(digestOf([]byte(location)), "sha256:"))
+}
+
+func (pf *Fetcher) readBlob(digest string) ([]byte, error) {
+	if pf.CacheDir == "" {
+		return nil, os.ErrNotExist
+	}
+
+	data, err := ioutil.ReadFile(pf.blobPath(digest))
+	if err != nil {
+		return nil, err
+	}
+
+	if digestOf(data) != digest {
+		return nil, fmt.Errorf("cached profile %s is corrupted", digest)
+	}
+
+	return data, nil
+}
+
+func (pf *Fetcher) readCached(location string) ([]byte, string, error) {
+	if pf.CacheDir == "" {
+		return nil, "", os.ErrNotExist
+	}
+
+	ref, err := ioutil.ReadFile(pf.refPath(location))
+	if err != nil {
+		return nil, "", err
+	}
+
+	digest := strings.TrimSpace(string(ref))
+	data, err := pf.readBlob(digest)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, digest, nil
+}
+
+func (pf *Fetcher) writeCached(location, digest string, data []byte) error {
+	if pf.CacheDir == "" {
+		return nil
+	}
+
+	if err := writeFileAtomic(pf.blobPath(digest), data); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(pf.refPath(location), []byte(digest+"\n"))
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
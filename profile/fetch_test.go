@@ -0,0 +1,205 @@
+// Tests for remote profile retrieval of libseccomp Go bindings
+
+package profile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const fetchTestProfile = `{"defaultAction": "SCMP_ACT_ERRNO", "syscalls": [{"names": ["read"], "action": "SCMP_ACT_ALLOW"}]}`
+
+func TestProfileFetcherHTTP(t *testing.T) {
+	cache, err := ioutil.TempDir("", "seccomp-fetch-cache")
+	if err != nil {
+		t.Fatalf("Error creating cache directory: %s", err)
+	}
+	defer os.RemoveAll(cache)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, fetchTestProfile)
+	}))
+
+	fetcher := NewFetcher(cache)
+	profile, err := fetcher.FetchProfile(server.URL + "/profile.json")
+	if err != nil {
+		t.Fatalf("Error fetching profile: %s", err)
+	} else if profile.DefaultAction != "SCMP_ACT_ERRNO" || len(profile.Syscalls) != 1 {
+		t.Errorf("Fetched profile does not match the served one: %+v", profile)
+	}
+
+	// The cached copy must be used once the server is gone
+	server.Close()
+
+	data, digest, err := fetcher.Fetch(server.URL + "/profile.json")
+	if err != nil {
+		t.Fatalf("Error fetching cached profile: %s", err)
+	} else if string(data) != fetchTestProfile {
+		t.Errorf("Cached profile does not match the served one")
+	} else if digest != digestOf([]byte(fetchTestProfile)) {
+		t.Errorf("Unexpected digest %s", digest)
+	}
+
+	_, _, err = fetcher.Fetch(server.URL + "/other.json")
+	if err == nil {
+		t.Errorf("Fetching an uncached profile from an unreachable server should fail")
+	}
+}
+
+func TestProfileFetcherNoFallback(t *testing.T) {
+	cache, err := ioutil.TempDir("", "seccomp-fetch-cache")
+	if err != nil {
+		t.Fatalf("Error creating cache directory: %s", err)
+	}
+	defer os.RemoveAll(cache)
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, fetchTestProfile)
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(cache)
+	location := server.URL + "/profile.json"
+	if _, _, err := fetcher.Fetch(location); err != nil {
+		t.Fatalf("Error fetching profile: %s", err)
+	}
+
+	// Server errors are outages, the cached copy stands in
+	status = http.StatusServiceUnavailable
+	if data, _, err := fetcher.Fetch(location); err != nil || string(data) != fetchTestProfile {
+		t.Errorf("Got %q, %v with the server unavailable, want the cached profile", data, err)
+	}
+
+	// Withdrawn or forbidden profiles are not replaced by the cached copy
+	for _, status = range []int{http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden} {
+		if _, _, err := fetcher.Fetch(location); err == nil {
+			t.Errorf("Fetching a profile answered with %d should fail", status)
+		}
+	}
+}
+
+func TestProfileFetcherTLSError(t *testing.T) {
+	cache, err := ioutil.TempDir("", "seccomp-fetch-cache")
+	if err != nil {
+		t.Fatalf("Error creating cache directory: %s", err)
+	}
+	defer os.RemoveAll(cache)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, fetchTestProfile)
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(cache)
+	fetcher.Client = server.Client()
+	location := server.URL + "/profile.json"
+	if _, _, err := fetcher.Fetch(location); err != nil {
+		t.Fatalf("Error fetching profile: %s", err)
+	}
+
+	// A server failing certificate verification is reachable, so the
+	// cached copy must not stand in for it
+	fetcher.Client = &http.Client{}
+	if data, _, err := fetcher.Fetch(location); err == nil {
+		t.Errorf("Fetching from an untrusted server returned %q, want an error", data)
+	}
+}
+
+func TestProfileFetcherOCI(t *testing.T) {
+	digest := digestOf([]byte(fetchTestProfile))
+	manifest := fmt.Sprintf(`{"schemaVersion": 2, "layers": [{"mediaType": "application/json", "digest": %q}]}`, digest)
+	manifestDigest := digestOf([]byte(manifest))
+	forgedDigest := digestOf([]byte("forged"))
+	tampered := false
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"token": "secret"}`)
+	})
+	// A token service which challenges its clients itself
+	mux.HandleFunc("/v2/locked/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/v2/locked/token"`, r.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="test"`, r.Host))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/profiles/default/manifests/v1", "/v2/profiles/default/manifests/" + manifestDigest,
+			"/v2/profiles/default/manifests/" + forgedDigest:
+			fmt.Fprint(w, manifest)
+		case "/v2/profiles/default/blobs/" + digest:
+			if tampered {
+				fmt.Fprint(w, "tampered")
+				return
+			}
+			fmt.Fprint(w, fetchTestProfile)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cacheDir, err := ioutil.TempDir("", "seccomp-profile-cache")
+	if err != nil {
+		t.Fatalf("Error creating cache directory: %s", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	fetcher := &Fetcher{CacheDir: cacheDir, PlainHTTP: true}
+	registry := "oci://" + strings.TrimPrefix(server.URL, "http://")
+	ref := registry + "/profiles/default:v1"
+
+	data, got, err := fetcher.Fetch(ref)
+	if err != nil {
+		t.Fatalf("Error fetching profile from registry: %s", err)
+	} else if string(data) != fetchTestProfile || got != digest {
+		t.Errorf("Fetched artifact does not match the pushed one")
+	}
+
+	_, _, err = fetcher.Fetch(ref + "-missing")
+	if err == nil {
+		t.Errorf("Fetching a missing tag should fail")
+	}
+
+	// Manifests are checked against the digest pinning them
+	if _, _, err := fetcher.Fetch(registry + "/profiles/default@" + forgedDigest); err == nil {
+		t.Errorf("Fetching a manifest not matching its digest should fail")
+	}
+
+	// Tampered profiles are not replaced by the cached copy
+	tampered = true
+	if _, _, err := fetcher.Fetch(ref); err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("Got %v fetching a tampered profile, want a digest mismatch", err)
+	}
+	tampered = false
+
+	// Authentication is not retried against the token service
+	if _, _, err := fetcher.Fetch(registry + "/locked/default:v1"); err == nil {
+		t.Errorf("Fetching with a challenged token service should fail")
+	}
+
+	// Pinned references are served from the cache, without the registry
+	pinned := registry + "/profiles/default@" + manifestDigest
+	if _, _, err := fetcher.Fetch(pinned); err != nil {
+		t.Fatalf("Error fetching pinned profile from registry: %s", err)
+	}
+	server.Close()
+	data, got, err = fetcher.Fetch(pinned)
+	if err != nil {
+		t.Fatalf("Error fetching pinned profile from cache: %s", err)
+	} else if string(data) != fetchTestProfile || got != digest {
+		t.Errorf("Cached artifact does not match the pushed one")
+	}
+}
@@ -0,0 +1,105 @@
+// +build linux
+
+// Profile directory watches for libseccomp Go bindings
+// Reloads the profiles of a registry's directory on inotify events
+
+package profile
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// Events of the profile directories triggering reloads
+const inotifyWatchEvents = unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// WatchDirInotify loads the profiles in dir as LoadDir does, then reloads
+// them whenever a file under dir changes, as reported by inotify, so that
+// changes are reflected without the delay of WatchDir. Directories created
+// under dir are watched too. Profiles which could not be loaded, initially
+// or later, are reported by Reloads.
+// Returns a function stopping the watch, or an error if the directory could
+// not be read or watched.
+func (r *Registry) WatchDirInotify(dir string) (func(), error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize inotify: %v", err)
+	}
+	// Nonblocking files are read through the runtime poller, so that closing
+	// the file interrupts the reads
+	watch := os.NewFile(uintptr(fd), "inotify")
+
+	// Directories are watched before loading, so that no change is missed
+	if err := watchDirs(fd, dir); err != nil {
+		watch.Close()
+		return nil, err
+	}
+	if err := r.LoadDir(dir); err != nil {
+		if _, ok := err.(*LoadError); !ok {
+			watch.Close()
+			return nil, err
+		}
+	}
+
+	// The lock keeps the inotify fd open while directories are added to it
+	var lock sync.Mutex
+	done := make(chan struct{})
+	go func() {
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+		for {
+			if _, err := watch.Read(buf); err != nil {
+				select {
+				case <-done:
+				default:
+					r.recordReload(fmt.Errorf("could not watch %s: %v", dir, err))
+				}
+				return
+			}
+
+			// New directories must be watched too
+			lock.Lock()
+			select {
+			case <-done:
+				lock.Unlock()
+				return
+			default:
+			}
+			err := watchDirs(fd, dir)
+			lock.Unlock()
+			if err != nil {
+				r.recordReload(err)
+			}
+			r.LoadDir(dir)
+		}
+	}()
+
+	return func() {
+		lock.Lock()
+		defer lock.Unlock()
+
+		select {
+		case <-done:
+		default:
+			close(done)
+			watch.Close()
+		}
+	}, nil
+}
+
+// Helper - Add inotify watches for dir and its subdirectories, which inotify
+// ignores if they are already watched
+func watchDirs(fd int, dir string) error {
+	return walkDir(dir, func(path string, info os.FileInfo) error {
+		if !info.IsDir() {
+			return nil
+		}
+		if _, err := unix.InotifyAddWatch(fd, path, inotifyWatchEvents); err != nil {
+			return fmt.Errorf("could not watch %s: %v", path, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+// +build linux
+
+// Tests for the profile directory watches of libseccomp Go bindings
+
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistryWatchDirInotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seccomp-registry")
+	if err != nil {
+		t.Fatalf("Error creating profile directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("Error creating directory: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Error writing profile: %s", err)
+		}
+	}
+	write("a.json", `{"defaultAction": "SCMP_ACT_ALLOW"}`)
+	write("broken.json", `{not json`)
+
+	reg := NewRegistry()
+	reloaded := make(chan error, 16)
+	reg.OnReload(func(err error) {
+		select {
+		case reloaded <- err:
+		default:
+		}
+	})
+
+	// Broken profiles do not prevent watching
+	stop, err := reg.WatchDirInotify(dir)
+	if err != nil {
+		t.Fatalf("Error watching profile directory: %s", err)
+	}
+	defer stop()
+	if err, ok := (<-reloaded).(*LoadError); !ok || len(err.Errors) != 1 {
+		t.Errorf("Initial load should report the broken profile, got %v", err)
+	}
+
+	// Files of new directories are picked up
+	os.Remove(filepath.Join(dir, "broken.json"))
+	write("new/b.yaml", "defaultAction: SCMP_ACT_LOG\n")
+
+	want := []string{"a.json", "new/b.yaml"}
+	deadline := time.Now().Add(5 * time.Second)
+	for !reflect.DeepEqual(reg.Names(), want) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if names := reg.Names(); !reflect.DeepEqual(names, want) {
+		t.Fatalf("Got profiles %v after changes, want %v", names, want)
+	}
+
+	stop()
+	stop()
+	if _, err := NewRegistry().WatchDirInotify(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("Watching a missing directory should fail")
+	}
+}
@@ -0,0 +1,110 @@
+// Seccomp profile model for libseccomp Go bindings
+// Describes OCI runtime-spec seccomp profiles independently of libseccomp
+
+// Package profile provides the OCI runtime-spec seccomp profile model, along
+// with the machinery to fetch, verify and manage profiles. It does not depend
+// on libseccomp; profiles are compiled into filters by the seccomp package.
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Seccomp represents the "linux.seccomp" section of an OCI runtime-spec
+// config.json.
+// UnknownSyscallsENOSYS is an extension to the runtime-spec: it makes
+// syscalls unknown when the profile is compiled, such as syscalls added by
+// newer kernels, fail with ENOSYS instead of the default action, so that
+// programs fall back to older syscalls rather than being denied or killed.
+type Seccomp struct {
+	DefaultAction         string    `json:"defaultAction"`
+	DefaultErrnoRet       *uint     `json:"defaultErrnoRet,omitempty"`
+	Architectures         []string  `json:"architectures,omitempty"`
+	Flags                 []string  `json:"flags,omitempty"`
+	ListenerPath          string    `json:"listenerPath,omitempty"`
+	ListenerMetadata      string    `json:"listenerMetadata,omitempty"`
+	Syscalls              []Syscall `json:"syscalls,omitempty"`
+	UnknownSyscallsENOSYS bool      `json:"unknownSyscallsEnosys,omitempty"`
+}
+
+// Syscall represents a single entry of the "syscalls" list of an OCI
+// seccomp profile.
+type Syscall struct {
+	Names    []string `json:"names"`
+	Action   string   `json:"action"`
+	ErrnoRet *uint    `json:"errnoRet,omitempty"`
+	Args     []Arg    `json:"args,omitempty"`
+}
+
+// Arg represents an argument condition of an OCI seccomp syscall entry.
+type Arg struct {
+	Index    uint   `json:"index"`
+	Value    uint64 `json:"value"`
+	ValueTwo uint64 `json:"valueTwo,omitempty"`
+	Op       string `json:"op"`
+}
+
+// HookState is the container state an OCI runtime passes to hooks on their
+// standard input.
+type HookState struct {
+	OCIVersion  string            `json:"ociVersion"`
+	ID          string            `json:"id"`
+	Status      string            `json:"status"`
+	Pid         int               `json:"pid,omitempty"`
+	Bundle      string            `json:"bundle"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Parse parses the JSON encoding of an OCI seccomp profile.
+func Parse(data []byte) (*Seccomp, error) {
+	profile := new(Seccomp)
+	if err := json.Unmarshal(data, profile); err != nil {
+		return nil, fmt.Errorf("could not parse profile: %v", err)
+	}
+
+	return profile, nil
+}
+
+// ReadHookState reads the container state passed to an OCI hook from r.
+// Returns an error if the state could not be parsed or names no bundle.
+func ReadHookState(r io.Reader) (*HookState, error) {
+	state := new(HookState)
+	if err := json.NewDecoder(r).Decode(state); err != nil {
+		return nil, fmt.Errorf("could not parse container state: %v", err)
+	}
+
+	if state.Bundle == "" {
+		return nil, fmt.Errorf("container state does not name a bundle")
+	}
+
+	return state, nil
+}
+
+// ReadBundle reads config.json from the OCI bundle directory bundle and
+// returns its "linux.seccomp" section.
+// Returns nil and no error if the bundle does not configure seccomp.
+func ReadBundle(bundle string) (*Seccomp, error) {
+	data, err := ioutil.ReadFile(filepath.Join(bundle, "config.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var config struct {
+		Linux *struct {
+			Seccomp *Seccomp `json:"seccomp"`
+		} `json:"linux"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("could not parse bundle config: %v", err)
+	}
+
+	if config.Linux == nil {
+		return nil, nil
+	}
+
+	return config.Linux.Seccomp, nil
+}
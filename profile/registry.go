@@ -0,0 +1,417 @@
+// Profile registry for libseccomp Go bindings
+// Keeps a named, hot-reloadable set of OCI seccomp profiles
+
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry manages a set of named OCI seccomp profiles. Profiles can be
+// registered directly or loaded from a directory, which can be watched so
+// that changes on disk are picked up automatically. The registry records
+// usage of each profile. A Registry is safe for concurrent use.
+type Registry struct {
+	lock     sync.RWMutex
+	profiles map[string]*registryEntry
+	reloads  uint64
+	lastErr  error
+	onReload func(error)
+	// Serializes directory reloads
+	loadLock sync.Mutex
+}
+
+type registryEntry struct {
+	profile  *Seccomp
+	digest   string
+	source   string
+	loaded   time.Time
+	lookups  uint64
+	lastUsed time.Time
+}
+
+// Stats describes a profile held by a Registry and its usage.
+//
+// Name:     name the profile is registered under
+// Digest:   digest of the profile's JSON encoding
+// Source:   file the profile was loaded from, empty if registered directly
+// Loaded:   time the profile was last (re)loaded
+// Lookups:  number of successful lookups of the profile
+// LastUsed: time of the last successful lookup, zero if never used
+//
+type Stats struct {
+	Name     string    `json:"name"`
+	Digest   string    `json:"digest"`
+	Source   string    `json:"source,omitempty"`
+	Loaded   time.Time `json:"loaded"`
+	Lookups  uint64    `json:"lookups"`
+	LastUsed time.Time `json:"last_used,omitempty"`
+}
+
+// LoadError lists the files of a directory which LoadDir could not load,
+// one error per file. The other profiles of the directory were loaded.
+type LoadError struct {
+	Errors []string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("could not load profiles: %s", strings.Join(e.Errors, "; "))
+}
+
+// NewRegistry creates an empty profile registry.
+func NewRegistry() *Registry {
+	return &Registry{profiles: make(map[string]*registryEntry)}
+}
+
+// Register adds profile to the registry under name, replacing any profile
+// previously registered under that name. The registry keeps a reference to
+// profile, which must not be modified afterwards.
+// Returns an error if the name is empty or the profile is nil.
+func (r *Registry) Register(name string, profile *Seccomp) error {
+	if name == "" {
+		return fmt.Errorf("profile name must not be empty")
+	} else if profile == nil {
+		return fmt.Errorf("profile %q is nil", name)
+	}
+
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.profiles[name] = &registryEntry{
+		profile: profile,
+		digest:  digestOf(data),
+		loaded:  time.Now(),
+	}
+
+	return nil
+}
+
+// Unregister removes the profile registered under name, if any.
+func (r *Registry) Unregister(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.profiles, name)
+}
+
+// Lookup returns the profile registered under name and records its use.
+// The returned profile is shared and must not be modified.
+// Returns false if no profile is registered under that name.
+func (r *Registry) Lookup(name string) (*Seccomp, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry, ok := r.profiles[name]
+	if !ok {
+		return nil, false
+	}
+
+	entry.lookups++
+	entry.lastUsed = time.Now()
+
+	return entry.profile, true
+}
+
+// Profile returns the profile registered under name and the digest of its
+// JSON encoding, without recording its use, e.g. to compile it ahead of
+// lookups. The returned profile is shared and must not be modified.
+// Returns false if no profile is registered under that name.
+func (r *Registry) Profile(name string) (*Seccomp, string, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	entry, ok := r.profiles[name]
+	if !ok {
+		return nil, "", false
+	}
+
+	return entry.profile, entry.digest, true
+}
+
+// Names returns the sorted names of all registered profiles.
+func (r *Registry) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	names := make([]string, 0, len(r.profiles))
+	for name := range r.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Stats returns usage information on all registered profiles, sorted by name.
+func (r *Registry) Stats() []Stats {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	stats := make([]Stats, 0, len(r.profiles))
+	for name, entry := range r.profiles {
+		stats = append(stats, Stats{
+			Name:     name,
+			Digest:   entry.digest,
+			Source:   entry.source,
+			Loaded:   entry.loaded,
+			Lookups:  entry.lookups,
+			LastUsed: entry.lastUsed,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+
+	return stats
+}
+
+// Reloads returns the number of directory reloads performed by LoadDir and
+// the watches of directories, and the error of the last reload that failed,
+// if any.
+func (r *Registry) Reloads() (uint64, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.reloads, r.lastErr
+}
+
+// OnReload sets a function called with the outcome of every later directory
+// reload, once the registry reflects it, e.g. to compile the reloaded
+// profiles. Reloads are serialized, so fn is not called concurrently.
+func (r *Registry) OnReload(fn func(err error)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.onReload = fn
+}
+
+// LoadDir (re)loads the OCI seccomp profiles of dir, laid out like the
+// localhost profiles of the Kubernetes kubelet: each *.json, *.yaml or *.yml
+// file under dir is a profile named after its slash-separated path relative
+// to dir (e.g., "profiles/audit.json"). YAML profiles are read with
+// ReadYAML. Hidden files and directories are ignored, and symbolic links are
+// followed, so that directories mounted from ConfigMaps can be used.
+// Profiles whose content did not change are kept as they are, and profiles
+// whose file has been removed are unregistered. A file that fails to load
+// keeps its previously loaded version, if any.
+// Returns an error if the directory could not be read, or a *LoadError
+// describing every file that could not be loaded.
+func (r *Registry) LoadDir(dir string) error {
+	r.loadLock.Lock()
+	defer r.loadLock.Unlock()
+
+	dir = filepath.Clean(dir)
+	var paths []string
+	err := walkDir(dir, func(path string, info os.FileInfo) error {
+		switch filepath.Ext(path) {
+		case ".json", ".yaml", ".yml":
+			if info.Mode().IsRegular() {
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.recordReload(err)
+		return err
+	}
+
+	r.lock.RLock()
+	current := make(map[string]*registryEntry)
+	for name, entry := range r.profiles {
+		if entry.source != "" && inDir(dir, entry.source) {
+			current[name] = entry
+		}
+	}
+	r.lock.RUnlock()
+
+	var errs []string
+	seen := make(map[string]bool)
+	updated := make(map[string]*registryEntry)
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		name := filepath.ToSlash(rel)
+		seen[name] = true
+
+		// Files are always read, as YAML profiles depend on the files they
+		// include
+		profile, err := readDirProfile(path)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		data, err := json.Marshal(profile)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+
+		digest := digestOf(data)
+		if old, ok := current[name]; ok && old.digest == digest {
+			continue
+		}
+
+		updated[name] = &registryEntry{
+			profile: profile,
+			digest:  digest,
+			source:  path,
+			loaded:  time.Now(),
+		}
+	}
+
+	r.lock.Lock()
+	for name, entry := range updated {
+		if old, ok := r.profiles[name]; ok {
+			entry.lookups = old.lookups
+			entry.lastUsed = old.lastUsed
+		}
+		r.profiles[name] = entry
+	}
+	for name := range current {
+		if !seen[name] {
+			delete(r.profiles, name)
+		}
+	}
+	r.lock.Unlock()
+
+	if len(errs) > 0 {
+		err = &LoadError{Errors: errs}
+	}
+	r.recordReload(err)
+
+	return err
+}
+
+// WatchDir loads the profiles in dir, then reloads them every interval so
+// that added, changed and removed files are reflected in the registry.
+// Errors during later reloads are reported by Reloads.
+// Returns a function stopping the watch, or an error if the interval is not
+// positive or the initial load failed.
+func (r *Registry) WatchDir(dir string, interval time.Duration) (func(), error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid watch interval %v", interval)
+	}
+	if err := r.LoadDir(dir); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.LoadDir(dir)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }, nil
+}
+
+func (r *Registry) recordReload(err error) {
+	r.lock.Lock()
+	r.reloads++
+	if err != nil {
+		r.lastErr = err
+	}
+	onReload := r.onReload
+	r.lock.Unlock()
+
+	if onReload != nil {
+		onReload(err)
+	}
+}
+
+// Helper - Read a profile of a directory, by the format of its extension
+func readDirProfile(path string) (*Seccomp, error) {
+	if filepath.Ext(path) != ".json" {
+		return ReadYAML(path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Helper - Check whether path is under dir
+func inDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Helper - Call fn for dir and every file and directory under it, following
+// symbolic links and skipping hidden files
+func walkDir(dir string, fn func(path string, info os.FileInfo) error) error {
+	var walk func(dir string, depth int) error
+	walk = func(dir string, depth int) error {
+		// Guard against symbolic link loops
+		if depth > 32 {
+			return fmt.Errorf("too many levels of directories at %s", dir)
+		}
+
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if err := fn(dir, info); err != nil {
+			return err
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if strings.HasPrefix(file.Name(), ".") {
+				continue
+			}
+
+			path := filepath.Join(dir, file.Name())
+			info, err := os.Stat(path)
+			if os.IsNotExist(err) {
+				// Dangling symbolic link
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				err = walk(path, depth+1)
+			} else if info.Mode().IsRegular() {
+				err = fn(path, info)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return walk(dir, 0)
+}
@@ -0,0 +1,128 @@
+// Tests for the profile registry of libseccomp Go bindings
+
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistryRegisterLookup(t *testing.T) {
+	reg := NewRegistry()
+
+	if err := reg.Register("", &Seccomp{}); err == nil {
+		t.Errorf("Registering a profile without a name should fail")
+	}
+	if err := reg.Register("nil", nil); err == nil {
+		t.Errorf("Registering a nil profile should fail")
+	}
+
+	profile := &Seccomp{
+		DefaultAction: "SCMP_ACT_ERRNO",
+		Syscalls:      []Syscall{{Names: []string{"read"}, Action: "SCMP_ACT_ALLOW"}},
+	}
+	if err := reg.Register("default", profile); err != nil {
+		t.Fatalf("Error registering profile: %s", err)
+	}
+
+	if _, ok := reg.Lookup("missing"); ok {
+		t.Errorf("Lookup of an unregistered profile should fail")
+	}
+
+	if p, ok := reg.Lookup("default"); !ok || p != profile {
+		t.Fatalf("Lookup did not return the registered profile")
+	}
+
+	stats := reg.Stats()
+	if len(stats) != 1 || stats[0].Name != "default" || stats[0].Lookups != 1 {
+		t.Errorf("Unexpected registry stats: %+v", stats)
+	} else if stats[0].LastUsed.IsZero() || stats[0].Digest == "" {
+		t.Errorf("Registry stats are incomplete: %+v", stats[0])
+	}
+
+	reg.Unregister("default")
+	if len(reg.Names()) != 0 {
+		t.Errorf("Registry should be empty after unregistering its only profile")
+	}
+}
+
+func TestRegistryLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seccomp-registry")
+	if err != nil {
+		t.Fatalf("Error creating profile directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("Error creating directory: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Error writing profile: %s", err)
+		}
+	}
+
+	write("a.json", `{"defaultAction": "SCMP_ACT_ALLOW"}`)
+	write("b.json", `{"defaultAction": "SCMP_ACT_ERRNO"}`)
+	write("nested/c.yaml", "defaultAction: SCMP_ACT_LOG\n")
+	write(".hidden.json", `{"defaultAction": "SCMP_ACT_LOG"}`)
+	write("notes.txt", `ignored`)
+
+	reg := NewRegistry()
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := reg.WatchDir(dir, interval); err == nil {
+			t.Errorf("Watching with interval %v should fail", interval)
+		}
+	}
+	if names := reg.Names(); len(names) != 0 {
+		t.Errorf("Watching with an invalid interval loaded profiles %v", names)
+	}
+
+	stop, err := reg.WatchDir(dir, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error watching profile directory: %s", err)
+	}
+	defer stop()
+
+	if names := reg.Names(); !reflect.DeepEqual(names, []string{"a.json", "b.json", "nested/c.yaml"}) {
+		t.Fatalf("Unexpected profiles loaded: %v", names)
+	}
+	if p, ok := reg.Lookup("nested/c.yaml"); !ok || p.DefaultAction != "SCMP_ACT_LOG" {
+		t.Errorf("YAML profile was not loaded")
+	}
+
+	// A broken file keeps the previous version, a removed one is dropped
+	write("a.json", `{not json`)
+	os.Remove(filepath.Join(dir, "b.json"))
+	err = reg.LoadDir(dir)
+	if loadErr, ok := err.(*LoadError); !ok || len(loadErr.Errors) != 1 {
+		t.Errorf("Loading an invalid profile should report a LoadError, got %v", err)
+	}
+	if _, lastErr := reg.Reloads(); lastErr == nil {
+		t.Errorf("Reload error should be recorded")
+	}
+	if p, ok := reg.Lookup("a.json"); !ok || p.DefaultAction != "SCMP_ACT_ALLOW" {
+		t.Errorf("Previous version of an invalid profile should be kept")
+	}
+	if _, ok := reg.Lookup("b.json"); ok {
+		t.Errorf("Profile of a removed file should be unregistered")
+	}
+
+	// The watcher picks up new files on its own
+	write("d.json", `{"defaultAction": "SCMP_ACT_KILL"}`)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := reg.Lookup("d.json"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Watcher did not load a new profile")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
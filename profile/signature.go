@@ -0,0 +1,142 @@
+// Profile signature verification for libseccomp Go bindings
+// Checks detached signatures over profiles before they are compiled
+
+package profile
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+var (
+	// ErrSignatureInvalid represents an error condition where the
+	// signature over a profile could not be verified against any trusted key
+	ErrSignatureInvalid = fmt.Errorf("profile signature verification failed")
+)
+
+// Verifier verifies a detached signature over the raw bytes of a
+// profile. Implementations return nil if and only if the signature was
+// produced by a trusted publisher.
+type Verifier interface {
+	Verify(profile, signature []byte) error
+}
+
+// Ed25519Verifier verifies raw Ed25519 signatures against a set of trusted
+// public keys. Signatures may be given raw or base64-encoded.
+type Ed25519Verifier struct {
+	Keys []ed25519.PublicKey
+}
+
+// Verify implements Verifier.
+func (v *Ed25519Verifier) Verify(profile, signature []byte) error {
+	sig := decodeSignature(signature)
+	for _, key := range v.Keys {
+		if ed25519.Verify(key, profile, sig) {
+			return nil
+		}
+	}
+
+	return ErrSignatureInvalid
+}
+
+// ECDSAVerifier verifies ASN.1-encoded ECDSA signatures over the SHA-256
+// digest of a profile against a set of trusted public keys, as produced by
+// "cosign sign-blob". Signatures may be given raw or base64-encoded.
+type ECDSAVerifier struct {
+	Keys []*ecdsa.PublicKey
+}
+
+// Verify implements Verifier.
+func (v *ECDSAVerifier) Verify(profile, signature []byte) error {
+	var sig struct {
+		R, S *big.Int
+	}
+	if rest, err := asn1.Unmarshal(decodeSignature(signature), &sig); err != nil || len(rest) != 0 {
+		return ErrSignatureInvalid
+	}
+
+	digest := sha256.Sum256(profile)
+	for _, key := range v.Keys {
+		if ecdsa.Verify(key, digest[:], sig.R, sig.S) {
+			return nil
+		}
+	}
+
+	return ErrSignatureInvalid
+}
+
+// NewVerifierFromPEM creates a Verifier trusting the PEM-encoded PKIX
+// public keys in data. Ed25519 and ECDSA keys are supported; all keys must be
+// of the same type.
+func NewVerifierFromPEM(data []byte) (Verifier, error) {
+	var edKeys []ed25519.PublicKey
+	var ecKeys []*ecdsa.PublicKey
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse public key: %v", err)
+		}
+
+		switch k := key.(type) {
+		case ed25519.PublicKey:
+			edKeys = append(edKeys, k)
+		case *ecdsa.PublicKey:
+			ecKeys = append(ecKeys, k)
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", key)
+		}
+	}
+
+	switch {
+	case len(edKeys) > 0 && len(ecKeys) > 0:
+		return nil, fmt.Errorf("cannot mix Ed25519 and ECDSA keys in one verifier")
+	case len(edKeys) > 0:
+		return &Ed25519Verifier{Keys: edKeys}, nil
+	case len(ecKeys) > 0:
+		return &ECDSAVerifier{Keys: ecKeys}, nil
+	default:
+		return nil, fmt.Errorf("no public keys found")
+	}
+}
+
+// Verify verifies signature over the raw OCI seccomp profile data
+// using verifier, and parses the profile only once it has been verified.
+// Returns the parsed profile, or an error if verification or parsing failed.
+func Verify(data, signature []byte, verifier Verifier) (*Seccomp, error) {
+	if verifier == nil {
+		return nil, fmt.Errorf("no profile verifier given")
+	}
+
+	if err := verifier.Verify(data, signature); err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Helper - Accept both raw and base64-encoded signatures
+func decodeSignature(signature []byte) []byte {
+	trimmed := bytes.TrimSpace(signature)
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(trimmed)))
+	n, err := base64.StdEncoding.Decode(decoded, trimmed)
+	if err != nil {
+		return signature
+	}
+
+	return decoded[:n]
+}
@@ -0,0 +1,100 @@
+// Tests for profile signature verification of libseccomp Go bindings
+
+package profile
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+)
+
+const signatureTestProfile = `{"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"names": ["ptrace"], "action": "SCMP_ACT_ERRNO"}]}`
+
+func TestEd25519Verifier(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+
+	data := []byte(signatureTestProfile)
+	sig := ed25519.Sign(priv, data)
+	verifier := &Ed25519Verifier{Keys: []ed25519.PublicKey{otherPub, pub}}
+
+	profile, err := Verify(data, sig, verifier)
+	if err != nil {
+		t.Fatalf("Error verifying signed profile: %s", err)
+	} else if profile.DefaultAction != "SCMP_ACT_ALLOW" {
+		t.Errorf("Unexpected default action in verified profile: %s", profile.DefaultAction)
+	}
+
+	// Base64-encoded signatures are accepted as well
+	encoded := []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+	if _, err := Verify(data, encoded, verifier); err != nil {
+		t.Errorf("Error verifying base64-encoded signature: %s", err)
+	}
+
+	tampered := []byte(signatureTestProfile + " ")
+	if _, err := Verify(tampered, sig, verifier); err != ErrSignatureInvalid {
+		t.Errorf("Tampered profile should fail verification, got %v", err)
+	}
+
+	untrusted := &Ed25519Verifier{Keys: []ed25519.PublicKey{otherPub}}
+	if _, err := Verify(data, sig, untrusted); err != ErrSignatureInvalid {
+		t.Errorf("Signature from untrusted key should fail verification, got %v", err)
+	}
+}
+
+func TestECDSAVerifierFromPEM(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("Error marshaling public key: %s", err)
+	}
+
+	verifier, err := NewVerifierFromPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("Error creating verifier: %s", err)
+	}
+
+	data := []byte(signatureTestProfile)
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+	if err != nil {
+		t.Fatalf("Error signing profile: %s", err)
+	}
+	sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	if err != nil {
+		t.Fatalf("Error encoding signature: %s", err)
+	}
+
+	profile, err := Verify(data, []byte(base64.StdEncoding.EncodeToString(sig)), verifier)
+	if err != nil {
+		t.Errorf("Error verifying signature: %s", err)
+	} else if profile.DefaultAction != "SCMP_ACT_ALLOW" {
+		t.Errorf("Verified profile was not parsed correctly")
+	}
+
+	if _, err := Verify(data, []byte("garbage"), verifier); err == nil {
+		t.Errorf("Garbage signature should fail verification")
+	}
+
+	if _, err := NewVerifierFromPEM([]byte("no keys here")); err == nil {
+		t.Errorf("Creating a verifier without keys should fail")
+	}
+}
@@ -0,0 +1,607 @@
+// YAML seccomp profiles for libseccomp Go bindings
+// Reads and writes profiles in YAML, with comments and includes
+
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// YAML profiles use the schema of OCI seccomp profiles, e.g.
+//
+//	# Base policy of our services
+//	include: [base.yaml]
+//	defaultAction: SCMP_ACT_ERRNO
+//	syscalls:
+//	  - names: [read, write]
+//	    action: SCMP_ACT_ALLOW
+//	  - names: [personality]
+//	    action: SCMP_ACT_ALLOW
+//	    args:
+//	      - {index: 0, value: 0x8, op: SCMP_CMP_EQ}
+//
+// The profiles listed by the top-level "include" key are merged first, in
+// order, then the profile itself: the last profile setting the default
+// action and other single values wins, while the architectures, flags and
+// syscalls of all the profiles are combined.
+// Only the block and single-line flow styles of YAML are supported, without
+// multi-line scalars, anchors, tags or multiple documents.
+
+// ParseYAML parses a YAML profile, reading the profiles it includes relative
+// to the current directory.
+// Returns an error if the profile or an included profile could not be read
+// or parsed.
+func ParseYAML(data []byte) (*Seccomp, error) {
+	return parseYAMLProfile(data, ".", nil)
+}
+
+// ReadYAML reads the YAML profile at path, reading the profiles it includes
+// relative to its directory.
+// Returns an error if the profile or an included profile could not be read
+// or parsed, or profiles include themselves.
+func ReadYAML(path string) (*Seccomp, error) {
+	return readYAMLProfile(path, nil)
+}
+
+// MarshalYAML encodes a profile in YAML, which ParseYAML reads back.
+func MarshalYAML(p *Seccomp) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "defaultAction: %s\n", yamlString(p.DefaultAction))
+	if p.DefaultErrnoRet != nil {
+		fmt.Fprintf(&b, "defaultErrnoRet: %d\n", *p.DefaultErrnoRet)
+	}
+	writeYAMLList(&b, "architectures", p.Architectures)
+	writeYAMLList(&b, "flags", p.Flags)
+	if p.ListenerPath != "" {
+		fmt.Fprintf(&b, "listenerPath: %s\n", yamlString(p.ListenerPath))
+	}
+	if p.ListenerMetadata != "" {
+		fmt.Fprintf(&b, "listenerMetadata: %s\n", yamlString(p.ListenerMetadata))
+	}
+	if p.UnknownSyscallsENOSYS {
+		fmt.Fprintf(&b, "unknownSyscallsEnosys: true\n")
+	}
+
+	if len(p.Syscalls) > 0 {
+		fmt.Fprintf(&b, "syscalls:\n")
+	}
+	for _, call := range p.Syscalls {
+		names := make([]string, len(call.Names))
+		for i, name := range call.Names {
+			names[i] = yamlString(name)
+		}
+		fmt.Fprintf(&b, "  - names: [%s]\n", strings.Join(names, ", "))
+		fmt.Fprintf(&b, "    action: %s\n", yamlString(call.Action))
+		if call.ErrnoRet != nil {
+			fmt.Fprintf(&b, "    errnoRet: %d\n", *call.ErrnoRet)
+		}
+		if len(call.Args) > 0 {
+			fmt.Fprintf(&b, "    args:\n")
+		}
+		for _, arg := range call.Args {
+			valueTwo := ""
+			if arg.ValueTwo != 0 {
+				valueTwo = fmt.Sprintf(", valueTwo: %#x", arg.ValueTwo)
+			}
+			fmt.Fprintf(&b, "      - {index: %d, value: %#x%s, op: %s}\n",
+				arg.Index, arg.Value, valueTwo, yamlString(arg.Op))
+		}
+	}
+
+	return b.Bytes()
+}
+
+// Helper - Read a YAML profile, with the absolute paths of the profiles
+// including it
+func readYAMLProfile(path string, including []string) (*Seccomp, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range including {
+		if p == abs {
+			return nil, fmt.Errorf("profile %s includes itself", path)
+		}
+	}
+
+	data, err := ioutil.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := parseYAMLProfile(data, filepath.Dir(abs), append(including, abs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return p, nil
+}
+
+// Helper - Parse a YAML profile, reading includes relative to dir
+func parseYAMLProfile(data []byte, dir string, including []string) (*Seccomp, error) {
+	node, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse profile: %v", err)
+	}
+
+	// Decode the document like a JSON profile
+	encoded, err := json.Marshal(node)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse profile: %v", err)
+	}
+	var doc struct {
+		Seccomp
+		Include []string `json:"include"`
+	}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return nil, fmt.Errorf("could not parse profile: %v", err)
+	}
+
+	p := new(Seccomp)
+	for _, path := range doc.Include {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		included, err := readYAMLProfile(path, including)
+		if err != nil {
+			return nil, err
+		}
+		mergeProfile(p, included)
+	}
+	mergeProfile(p, &doc.Seccomp)
+
+	return p, nil
+}
+
+// Helper - Merge a profile into another one
+func mergeProfile(dst, src *Seccomp) {
+	if src.DefaultAction != "" {
+		dst.DefaultAction = src.DefaultAction
+	}
+	if src.DefaultErrnoRet != nil {
+		dst.DefaultErrnoRet = src.DefaultErrnoRet
+	}
+	if src.ListenerPath != "" {
+		dst.ListenerPath = src.ListenerPath
+	}
+	if src.ListenerMetadata != "" {
+		dst.ListenerMetadata = src.ListenerMetadata
+	}
+	dst.UnknownSyscallsENOSYS = dst.UnknownSyscallsENOSYS || src.UnknownSyscallsENOSYS
+
+	dst.Architectures = appendMissing(dst.Architectures, src.Architectures)
+	dst.Flags = appendMissing(dst.Flags, src.Flags)
+	dst.Syscalls = append(dst.Syscalls, src.Syscalls...)
+}
+
+// Helper - Append the strings which are not in a list yet
+func appendMissing(list, more []string) []string {
+	for _, s := range more {
+		found := false
+		for _, t := range list {
+			found = found || s == t
+		}
+		if !found {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// Helper - Write a list of strings as a YAML block sequence
+func writeYAMLList(b *bytes.Buffer, key string, list []string) {
+	if len(list) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "%s:\n", key)
+	for _, s := range list {
+		fmt.Fprintf(b, "  - %s\n", yamlString(s))
+	}
+}
+
+// Helper - Encode a string as a YAML scalar, quoting it unless it reads
+// back as the same string
+func yamlString(s string) string {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '_' || c == '-' || c == '.' || c == '/') {
+			return strconv.Quote(s)
+		}
+	}
+
+	if _, ok := resolveYAMLScalar(s).(string); !ok || s == "" || s[0] == '-' {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// Line of a YAML document, without comments
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// Parser of the block structure of a YAML document
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// Helper - Parse a YAML document into maps, slices and scalars which
+// encoding/json can encode: strings, bools, json.Number and nil
+func parseYAML(data []byte) (interface{}, error) {
+	var lines []yamlLine
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(stripYAMLComment(line), " \t\r")
+		text := strings.TrimLeft(line, " ")
+		if text == "" || text == "---" || text == "..." {
+			continue
+		} else if text[0] == '\t' {
+			return nil, fmt.Errorf("line %d: tabs cannot be used for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{i + 1, len(line) - len(text), text})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	node, err := p.parseBlock(lines[0].indent)
+	if err != nil {
+		return nil, err
+	} else if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+
+	return node, nil
+}
+
+// Helper - Parse the block node starting at the current line
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+
+	return p.parseMapping(indent)
+}
+
+// Helper - Parse a block sequence
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	seq := []interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent >= indent {
+		line := p.lines[p.pos]
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		} else if !isYAMLSequenceItem(line.text) {
+			break
+		}
+
+		rest := strings.TrimLeft(line.text[1:], " ")
+		var item interface{}
+		var err error
+		if rest == "" {
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				item, err = p.parseBlock(p.lines[p.pos].indent)
+			}
+		} else if _, _, ok := splitYAMLEntry(rest); ok || isYAMLSequenceItem(rest) {
+			// Nested block node starting on the line of the item
+			col := indent + len(line.text) - len(rest)
+			p.lines[p.pos] = yamlLine{line.num, col, rest}
+			item, err = p.parseBlock(col)
+		} else {
+			p.pos++
+			item, err = parseYAMLValue(rest)
+		}
+		if err != nil {
+			return nil, annotateYAMLError(line.num, err)
+		}
+		seq = append(seq, item)
+	}
+
+	return seq, nil
+}
+
+// Helper - Parse a block mapping
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) && p.lines[p.pos].indent >= indent {
+		line := p.lines[p.pos]
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		} else if isYAMLSequenceItem(line.text) {
+			// Sequence of the parent mapping's key, at the same indentation
+			break
+		}
+
+		key, rest, ok := splitYAMLEntry(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a mapping entry", line.num)
+		} else if _, ok := m[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+
+		var value interface{}
+		var err error
+		if rest != "" {
+			value, err = parseYAMLValue(rest)
+		} else if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			if next.indent > indent || (next.indent == indent && isYAMLSequenceItem(next.text)) {
+				value, err = p.parseBlock(next.indent)
+			}
+		}
+		if err != nil {
+			return nil, annotateYAMLError(line.num, err)
+		}
+		m[key] = value
+	}
+
+	return m, nil
+}
+
+// Helper - Add the line number to errors which lack one
+func annotateYAMLError(num int, err error) error {
+	if strings.HasPrefix(err.Error(), "line ") {
+		return err
+	}
+	return fmt.Errorf("line %d: %v", num, err)
+}
+
+// Helper - Check whether a line is an item of a block sequence
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// Helper - Split a mapping entry into its key and value
+func splitYAMLEntry(text string) (string, string, bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+
+	var key, rest string
+	if text[0] == '"' || text[0] == '\'' {
+		s := &yamlScanner{s: text}
+		value, err := s.quoted()
+		if err != nil {
+			return "", "", false
+		}
+		key, rest = value, text[s.i:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		rest = rest[1:]
+	} else {
+		i := strings.Index(text, ": ")
+		if i < 0 && strings.HasSuffix(text, ":") {
+			i = len(text) - 1
+		}
+		if i <= 0 {
+			return "", "", false
+		}
+		key, rest = strings.TrimSpace(text[:i]), text[i+1:]
+	}
+
+	if rest != "" && rest[0] != ' ' {
+		return "", "", false
+	}
+
+	return key, strings.TrimSpace(rest), true
+}
+
+// Helper - Remove the comment of a line, if any
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.IndexByte(" \t[{,:-", line[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '#':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// Helper - Parse the value of a sequence item or mapping entry on a single
+// line
+func parseYAMLValue(text string) (interface{}, error) {
+	switch text[0] {
+	case '|', '>':
+		return nil, fmt.Errorf("multi-line scalars are not supported")
+	case '&', '*', '!':
+		return nil, fmt.Errorf("anchors, aliases and tags are not supported")
+	case '[', '{', '"', '\'':
+		s := &yamlScanner{s: text}
+		value, err := s.flowValue()
+		if err != nil {
+			return nil, err
+		}
+		if s.skipSpaces(); s.i < len(s.s) {
+			return nil, fmt.Errorf("unexpected %q after value", s.s[s.i:])
+		}
+		return value, nil
+	}
+
+	return resolveYAMLScalar(text), nil
+}
+
+// Helper - Resolve the type of a plain scalar
+func resolveYAMLScalar(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+
+	if v, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return json.Number(strconv.FormatInt(v, 10))
+	} else if v, err := strconv.ParseUint(s, 0, 64); err == nil {
+		return json.Number(strconv.FormatUint(v, 10))
+	}
+
+	return s
+}
+
+// Scanner of flow nodes and quoted scalars
+type yamlScanner struct {
+	s string
+	i int
+}
+
+func (s *yamlScanner) skipSpaces() {
+	for s.i < len(s.s) && s.s[s.i] == ' ' {
+		s.i++
+	}
+}
+
+// Helper - Scan a flow node
+func (s *yamlScanner) flowValue() (interface{}, error) {
+	s.skipSpaces()
+	if s.i == len(s.s) {
+		return nil, fmt.Errorf("missing value")
+	}
+
+	switch s.s[s.i] {
+	case '[':
+		return s.flowSequence()
+	case '{':
+		return s.flowMapping()
+	case '"', '\'':
+		return s.quoted()
+	}
+
+	start := s.i
+	for s.i < len(s.s) && strings.IndexByte(",]}", s.s[s.i]) < 0 &&
+		!(s.s[s.i] == ':' && (s.i+1 == len(s.s) || s.s[s.i+1] == ' ')) {
+		s.i++
+	}
+	return resolveYAMLScalar(strings.TrimSpace(s.s[start:s.i])), nil
+}
+
+// Helper - Scan a flow sequence, e.g. "[read, write]"
+func (s *yamlScanner) flowSequence() (interface{}, error) {
+	seq := []interface{}{}
+	s.i++
+	for {
+		s.skipSpaces()
+		if s.i < len(s.s) && s.s[s.i] == ']' {
+			s.i++
+			return seq, nil
+		}
+
+		value, err := s.flowValue()
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, value)
+
+		if err := s.flowSeparator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Helper - Scan a flow mapping, e.g. "{index: 0, value: 1}"
+func (s *yamlScanner) flowMapping() (interface{}, error) {
+	m := make(map[string]interface{})
+	s.i++
+	for {
+		s.skipSpaces()
+		if s.i < len(s.s) && s.s[s.i] == '}' {
+			s.i++
+			return m, nil
+		}
+
+		key, err := s.flowValue()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			name = fmt.Sprint(key)
+		}
+		if _, ok := m[name]; ok {
+			return nil, fmt.Errorf("duplicate key %q", name)
+		}
+
+		if s.skipSpaces(); s.i == len(s.s) || s.s[s.i] != ':' {
+			return nil, fmt.Errorf("missing value of key %q", name)
+		}
+		s.i++
+		if m[name], err = s.flowValue(); err != nil {
+			return nil, err
+		}
+
+		if err := s.flowSeparator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Helper - Scan the separator following an element of a flow collection
+func (s *yamlScanner) flowSeparator(end byte) error {
+	s.skipSpaces()
+	switch {
+	case s.i == len(s.s):
+		return fmt.Errorf("unterminated flow collection")
+	case s.s[s.i] == ',':
+		s.i++
+	case s.s[s.i] != end:
+		return fmt.Errorf("expected ',' or '%c'", end)
+	}
+	return nil
+}
+
+// Helper - Scan a single or double-quoted scalar
+func (s *yamlScanner) quoted() (string, error) {
+	quote := s.s[s.i]
+	for end := s.i + 1; end < len(s.s); end++ {
+		switch {
+		case quote == '"' && s.s[end] == '\\':
+			end++
+		case s.s[end] != quote:
+		case quote == '\'' && end+1 < len(s.s) && s.s[end+1] == '\'':
+			// Escaped single quote
+			end++
+		case quote == '\'':
+			value := strings.Replace(s.s[s.i+1:end], "''", "'", -1)
+			s.i = end + 1
+			return value, nil
+		default:
+			value, err := strconv.Unquote(s.s[s.i : end+1])
+			if err != nil {
+				return "", fmt.Errorf("invalid quoted scalar %s", s.s[s.i:end+1])
+			}
+			s.i = end + 1
+			return value, nil
+		}
+	}
+
+	return "", fmt.Errorf("unterminated quoted scalar")
+}
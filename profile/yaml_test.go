@@ -0,0 +1,127 @@
+// Tests for the YAML profiles of libseccomp Go bindings
+
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const yamlTestBase = `# Syscalls every service needs
+architectures:
+  - SCMP_ARCH_X86_64
+syscalls:
+- names:
+    - read
+    - write   # trailing comment
+  action: SCMP_ACT_ALLOW
+`
+
+const yamlTestProfile = `---
+include: [base.yaml]
+defaultAction: SCMP_ACT_ERRNO
+defaultErrnoRet: 1
+architectures: [SCMP_ARCH_X86, "SCMP_ARCH_X86_64"]
+syscalls:
+  - names: [personality]
+    action: 'SCMP_ACT_ALLOW'
+    args:
+      - {index: 0, value: 0x8, op: SCMP_CMP_EQ}
+      - index: 1
+        value: 255
+        valueTwo: 3
+        op: SCMP_CMP_MASKED_EQ
+  - names: ["open#at"]
+    action: SCMP_ACT_NOTIFY
+unknownSyscallsEnosys: true
+`
+
+func writeYAMLProfiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "seccomp-yaml")
+	if err != nil {
+		t.Fatalf("Error creating directory: %s", err)
+	}
+
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("Error writing profile: %s", err)
+		}
+	}
+
+	return dir
+}
+
+func TestReadYAML(t *testing.T) {
+	dir := writeYAMLProfiles(t, map[string]string{
+		"base.yaml":    yamlTestBase,
+		"profile.yaml": yamlTestProfile,
+	})
+	defer os.RemoveAll(dir)
+
+	p, err := ReadYAML(filepath.Join(dir, "profile.yaml"))
+	if err != nil {
+		t.Fatalf("Error reading profile: %s", err)
+	}
+
+	errnoRet := uint(1)
+	want := &Seccomp{
+		DefaultAction:   "SCMP_ACT_ERRNO",
+		DefaultErrnoRet: &errnoRet,
+		Architectures:   []string{"SCMP_ARCH_X86_64", "SCMP_ARCH_X86"},
+		Syscalls: []Syscall{
+			{Names: []string{"read", "write"}, Action: "SCMP_ACT_ALLOW"},
+			{Names: []string{"personality"}, Action: "SCMP_ACT_ALLOW", Args: []Arg{
+				{Index: 0, Value: 8, Op: "SCMP_CMP_EQ"},
+				{Index: 1, Value: 255, ValueTwo: 3, Op: "SCMP_CMP_MASKED_EQ"},
+			}},
+			{Names: []string{"open#at"}, Action: "SCMP_ACT_NOTIFY"},
+		},
+		UnknownSyscallsENOSYS: true,
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Fatalf("Got profile %+v, want %+v", p, want)
+	}
+
+	again, err := ParseYAML(MarshalYAML(p))
+	if err != nil {
+		t.Fatalf("Error parsing marshaled profile: %s\n%s", err, MarshalYAML(p))
+	}
+	if !reflect.DeepEqual(again, want) {
+		t.Errorf("Marshaled profile differs:\n%s", MarshalYAML(p))
+	}
+}
+
+func TestReadYAMLInvalid(t *testing.T) {
+	dir := writeYAMLProfiles(t, map[string]string{
+		"a.yaml": "include: [b.yaml]\n",
+		"b.yaml": "include: [a.yaml]\n",
+	})
+	defer os.RemoveAll(dir)
+
+	if _, err := ReadYAML(filepath.Join(dir, "a.yaml")); err == nil {
+		t.Errorf("Reading profiles including each other should fail")
+	}
+	if _, err := ReadYAML(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("Reading a missing profile should fail")
+	}
+
+	for _, data := range []string{
+		"defaultAction: SCMP_ACT_ALLOW\n  syscalls: []\n",
+		"defaultAction: SCMP_ACT_ALLOW\ndefaultAction: SCMP_ACT_LOG\n",
+		"syscalls:\n  - names: [read\n",
+		"syscalls:\n  - names: \"read\n",
+		"syscalls: &calls []\n",
+		"defaultAction: |\n  SCMP_ACT_ALLOW\n",
+		"defaultErrnoRet: one\n",
+		"- SCMP_ACT_ALLOW\n",
+		"\tdefaultAction: SCMP_ACT_ALLOW\n",
+	} {
+		if _, err := ParseYAML([]byte(data)); err == nil {
+			t.Errorf("Parsing invalid profile should fail:\n%s", data)
+		}
+	}
+}
@@ -9,13 +9,16 @@
 package seccomp
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
 	"sync"
-	"syscall"
 	"unsafe"
+
+	"golang.org/x/sys/unix"
 )
 
 // C wrapping code
@@ -45,7 +48,7 @@ func init() {
 	// This forces the cgo libseccomp to initialize its internal API support state,
 	// which is necessary on older versions of libseccomp in order to work
 	// correctly.
-	GetAPI()
+	hostAPI, _ = GetAPI()
 }
 
 func (e VersionError) Error() string {
@@ -62,6 +65,45 @@ func (e VersionError) Error() string {
 	return fmt.Sprintf("Libseccomp version too low: %sminimum supported is %s: detected %d.%d.%d", messageStr, minimumStr, verMajor, verMinor, verMicro)
 }
 
+// TsyncError denotes a failure to synchronize a filter to all the threads of
+// the process, which left the filter unloaded.
+//
+// ThreadID: ID of a thread the filter could not be synchronized to, 0 if unknown
+//
+type TsyncError struct {
+	ThreadID int
+}
+
+func (e *TsyncError) Error() string {
+	if e.ThreadID == 0 {
+		return "could not synchronize filter to all threads"
+	}
+	return fmt.Sprintf("could not synchronize filter to thread %d", e.ThreadID)
+}
+
+// Unwrap returns unix.ESRCH, which libseccomp reports synchronization
+// failures with.
+func (e *TsyncError) Unwrap() error {
+	return unix.ESRCH
+}
+
+// MergeError denotes filters which could not be merged because they conflict.
+//
+// Attr: attribute whose values differ between the filters, or AttrInvalid
+// Arch: architecture present in both filters, or ArchInvalid
+//
+type MergeError struct {
+	Attr ScmpFilterAttr
+	Arch ScmpArch
+}
+
+func (e *MergeError) Error() string {
+	if e.Arch != ArchInvalid {
+		return fmt.Sprintf("filters could not be merged: architecture %s is present in both", e.Arch)
+	}
+	return fmt.Sprintf("filters could not be merged: mismatch in attribute %s", e.Attr)
+}
+
 // ScmpArch represents a CPU architecture. Seccomp can restrict syscalls on a
 // per-architecture basis.
 type ScmpArch uint
@@ -74,6 +116,13 @@ type ScmpAction uint
 // rule
 type ScmpCompareOp uint
 
+// ScmpFilterAttr represents an attribute of a filter, numbered as in
+// libseccomp's enum scmp_filter_attr
+type ScmpFilterAttr uint
+
+// LoadFlag represents a flag of the seccomp(2) system call loading a filter
+type LoadFlag uint
+
 // ScmpCondition represents a rule in a libseccomp filter context
 type ScmpCondition struct {
 	Argument uint          `json:"argument,omitempty"`
@@ -111,12 +160,18 @@ type ScmpNotifData struct {
 // Pid:   process that triggered the notification event
 // Flags: filter flags (see seccomp(2))
 // Data:  system call context that triggered the notification
+// Extra: trailing fields of the kernel's notification structure which are
+//        unknown to the bindings, if the kernel uses a larger one
 //
 type ScmpNotifReq struct {
 	ID    uint64        `json:"id,omitempty"`
 	Pid   uint32        `json:"pid,omitempty"`
 	Flags uint32        `json:"flags,omitempty"`
 	Data  ScmpNotifData `json:"data,omitempty"`
+	Extra []byte        `json:"extra,omitempty"`
+	// Notification fd the notification was received on, if known
+	fd    ScmpFd
+	hasFd bool
 }
 
 // ScmpNotifResp represents a seccomp userspace notification response. See NotifRespond()
@@ -124,17 +179,59 @@ type ScmpNotifReq struct {
 //
 // ID:    notification ID (must match the corresponding ScmpNotifReq ID)
 // Error: must be 0 if no error occurred, or an error constant from package
-//        syscall (e.g., syscall.EPERM, etc). In the latter case, it's used
-//        as an error return from the syscall that created the notification.
+//        unix (e.g., unix.EPERM, etc). In the latter case, it's used as an
+//        error return from the syscall that created the notification.
+//        See SetErrno().
 // Val:   return value for the syscall that created the notification. Only
 //        relevant if Error is 0.
 // Flags: userspace notification response flag (e.g., NotifRespFlagContinue)
+// Extra: trailing fields of the kernel's response structure which are
+//        unknown to the bindings, sent as is; zeroed if shorter than the
+//        kernel's structure
+// AllowedFlags: response flags unknown to the bindings which Validate()
+//        accepts in Flags for this response, to use flags introduced by
+//        newer kernels. The kernel remains the judge of their validity. Not
+//        sent to the kernel.
 //
 type ScmpNotifResp struct {
-	ID    uint64 `json:"id,omitempty"`
-	Error int32  `json:"error,omitempty"`
-	Val   uint64 `json:"val,omitempty"`
-	Flags uint32 `json:"flags,omitempty"`
+	ID           uint64 `json:"id,omitempty"`
+	Error        int32  `json:"error,omitempty"`
+	Val          uint64 `json:"val,omitempty"`
+	Flags        uint32 `json:"flags,omitempty"`
+	Extra        []byte `json:"extra,omitempty"`
+	AllowedFlags uint32 `json:"allowedFlags,omitempty"`
+}
+
+// ScmpNotifAddFd describes a file descriptor to install in the process which
+// triggered a notification. See NotifAddFd() for info on how to install it.
+//
+// ID:         notification ID (must match the corresponding ScmpNotifReq ID)
+// Flags:      file descriptor installation flags (e.g., NotifAddFdFlagSetFd)
+// SrcFd:      file descriptor of the supervisor to install in the target
+// NewFd:      file descriptor number to use in the target, only used with
+//             NotifAddFdFlagSetFd
+// NewFdFlags: flags of the new file descriptor, only O_CLOEXEC is supported
+//
+type ScmpNotifAddFd struct {
+	ID         uint64 `json:"id,omitempty"`
+	Flags      uint32 `json:"flags,omitempty"`
+	SrcFd      uint32 `json:"src_fd,omitempty"`
+	NewFd      uint32 `json:"new_fd,omitempty"`
+	NewFdFlags uint32 `json:"new_fd_flags,omitempty"`
+}
+
+// ScmpNotifSizes holds the sizes in bytes of the structures the kernel uses
+// for seccomp userspace notifications. The kernel may grow these structures,
+// so the sizes can differ from those the bindings were built with.
+//
+// Notif:     size of the notification request (struct seccomp_notif)
+// NotifResp: size of the notification response (struct seccomp_notif_resp)
+// Data:      size of the syscall data of a request (struct seccomp_data)
+//
+type ScmpNotifSizes struct {
+	Notif     uint16 `json:"notif,omitempty"`
+	NotifResp uint16 `json:"notif_resp,omitempty"`
+	Data      uint16 `json:"data,omitempty"`
 }
 
 // Exported Constants
@@ -186,6 +283,18 @@ const (
 	ArchPARISC ScmpArch = iota
 	// ArchPARISC64 represents 64-bit PA-RISC
 	ArchPARISC64 ScmpArch = iota
+	// ArchLOONGARCH64 represents 64-bit LoongArch syscalls
+	ArchLOONGARCH64 ScmpArch = iota
+	// ArchRISCV64 represents 64-bit RISC-V syscalls
+	ArchRISCV64 ScmpArch = iota
+	// ArchRISCV32 represents 32-bit RISC-V syscalls
+	ArchRISCV32 ScmpArch = iota
+	// ArchM68K represents Motorola 68000 syscalls
+	ArchM68K ScmpArch = iota
+	// ArchSH represents little-endian SuperH syscalls
+	ArchSH ScmpArch = iota
+	// ArchSHEB represents big-endian SuperH syscalls
+	ArchSHEB ScmpArch = iota
 )
 
 const (
@@ -224,6 +333,17 @@ const (
 	ActKillProcess ScmpAction = iota
 )
 
+const (
+	// Preset errno actions
+
+	// ActEPerm causes the syscall to return EPERM, the error commonly used
+	// to deny syscalls
+	ActEPerm = ActErrno | ScmpAction(unix.EPERM)<<16
+	// ActENoSys causes the syscall to return ENOSYS, as if the kernel did
+	// not implement it
+	ActENoSys = ActErrno | ScmpAction(unix.ENOSYS)<<16
+)
+
 const (
 	// These are comparison operators used in conditional seccomp rules
 	// They are used to compare the value of a single argument of a syscall
@@ -253,10 +373,80 @@ const (
 	CompareMaskedEqual ScmpCompareOp = iota
 )
 
+const (
+	// Filter attributes, with the values libseccomp uses. Attributes added
+	// to libseccomp after these can be used by their value.
+
+	// AttrInvalid is a placeholder to ensure uninitialized ScmpFilterAttr
+	// variables are invalid
+	AttrInvalid ScmpFilterAttr = 0
+	// AttrActDefault is the default action of the filter, in libseccomp's
+	// representation. It is read-only.
+	AttrActDefault ScmpFilterAttr = 1
+	// AttrActBadArch is the action taken on syscalls of architectures not
+	// in the filter, in libseccomp's representation
+	AttrActBadArch ScmpFilterAttr = 2
+	// AttrNNP is the No New Privileges bit, see SetNoNewPrivsBit
+	AttrNNP ScmpFilterAttr = 3
+	// AttrTsync is the TSYNC bit, see SetTsync
+	AttrTsync ScmpFilterAttr = 4
+	// AttrTSkip allows rules on the syscall number -1, see SetTSkip
+	AttrTSkip ScmpFilterAttr = 5
+	// AttrLog is the Log bit, see SetLogBit
+	AttrLog ScmpFilterAttr = 6
+	// AttrSSB is the SSB bit, see SetSSB
+	AttrSSB ScmpFilterAttr = 7
+	// AttrOptimize is the optimization level, see SetOptimizeLevel
+	AttrOptimize ScmpFilterAttr = 8
+	// AttrRawRC enables raw return codes, see SetRawRC
+	AttrRawRC ScmpFilterAttr = 9
+	// AttrWaitKill enables wait killable notifications, see SetWaitKill
+	AttrWaitKill ScmpFilterAttr = 10
+)
+
+const (
+	// Flags of the seccomp(2) system call loading a filter, see
+	// LoadWithFlags
+
+	// LoadFlagTsync synchronizes the filter to all the threads of the
+	// process
+	LoadFlagTsync LoadFlag = 1 << 0
+	// LoadFlagLog logs all actions taken by the filter, except ActAllow
+	LoadFlagLog LoadFlag = 1 << 1
+	// LoadFlagSpecAllow disables the Speculative Store Bypass mitigation
+	LoadFlagSpecAllow LoadFlag = 1 << 2
+	// LoadFlagNewListener creates a notification fd for the filter
+	LoadFlagNewListener LoadFlag = 1 << 3
+	// LoadFlagTsyncESRCH makes synchronization failures return ESRCH
+	// instead of the ID of the failing thread, allowing LoadFlagTsync to be
+	// combined with LoadFlagNewListener
+	LoadFlagTsyncESRCH LoadFlag = 1 << 4
+	// LoadFlagWaitKillableRecv makes targets of notifications wait for a
+	// response in a killable state once the notification was received
+	LoadFlagWaitKillableRecv LoadFlag = 1 << 5
+)
+
 var (
 	// ErrSyscallDoesNotExist represents an error condition where
 	// libseccomp is unable to resolve the syscall
 	ErrSyscallDoesNotExist = fmt.Errorf("could not resolve syscall name")
+	// ErrInvalidFilter is returned by the methods of a filter which was
+	// closed, released or merged into another filter, or which was not
+	// created by NewFilter
+	ErrInvalidFilter = fmt.Errorf("filter is invalid or uninitialized")
+	// ErrNotifTargetGone is returned by the notification functions when the
+	// notification is no longer valid, usually because the process which
+	// triggered it was killed or its syscall was interrupted. This is a
+	// normal occurrence, upon which supervisors should skip the
+	// notification. It is unix.ENOENT, which the kernel reports it with, so
+	// that callers comparing errors against unix.ENOENT keep working.
+	ErrNotifTargetGone error = unix.ENOENT
+	// ErrNotifClosed is returned by the notification receive functions when
+	// the notification fd was closed, e.g. by another goroutine with
+	// NotifClose to stop a receive loop. It is unix.EBADF, which the kernel
+	// reports closed fds with, so that callers comparing errors against
+	// unix.EBADF keep working.
+	ErrNotifClosed error = unix.EBADF
 )
 
 const (
@@ -266,6 +456,34 @@ const (
 	// call that triggered the notification. Must only be used when the notication
 	// response's error is 0.
 	NotifRespFlagContinue uint32 = 1
+
+	// NotifRespFlagsKnown is the set of notification response flags known
+	// to the bindings. See ScmpNotifResp.AllowedFlags for using others.
+	NotifRespFlagsKnown = NotifRespFlagContinue
+)
+
+const (
+	// Userspace notification file descriptor installation flags
+
+	// NotifAddFdFlagSetFd installs the file descriptor at the number given
+	// by the request's NewFd, replacing any file descriptor open there,
+	// instead of the lowest available number.
+	NotifAddFdFlagSetFd uint32 = 1
+	// NotifAddFdFlagSend responds to the notification along with installing
+	// the file descriptor, making the syscall which triggered it return the
+	// file descriptor number. Kernels before Linux 5.14 lack it, in which
+	// case NotifAddFd() falls back to responding separately.
+	NotifAddFdFlagSend uint32 = 1 << 1
+)
+
+const (
+	// Userspace notification fd flags
+
+	// NotifFdFlagSyncWakeUp makes the kernel wake up the supervisor waiting
+	// for a notification on the same CPU the target runs on, which speeds
+	// up supervisors handling notifications synchronously. Requires Linux
+	// 6.6 or later.
+	NotifFdFlagSyncWakeUp uint64 = 1
 )
 
 // Helpers for types
@@ -314,6 +532,18 @@ func GetArchFromString(arch string) (ScmpArch, error) {
 		return ArchPARISC, nil
 	case "parisc64":
 		return ArchPARISC64, nil
+	case "loongarch64", "loong64":
+		return ArchLOONGARCH64, nil
+	case "riscv64":
+		return ArchRISCV64, nil
+	case "riscv32":
+		return ArchRISCV32, nil
+	case "m68k":
+		return ArchM68K, nil
+	case "sh":
+		return ArchSH, nil
+	case "sheb":
+		return ArchSHEB, nil
 	default:
 		return ArchInvalid, fmt.Errorf("cannot convert unrecognized string %q", arch)
 	}
@@ -358,6 +588,18 @@ func (a ScmpArch) String() string {
 		return "parisc"
 	case ArchPARISC64:
 		return "parisc64"
+	case ArchLOONGARCH64:
+		return "loongarch64"
+	case ArchRISCV64:
+		return "riscv64"
+	case ArchRISCV32:
+		return "riscv32"
+	case ArchM68K:
+		return "m68k"
+	case ArchSH:
+		return "sh"
+	case ArchSHEB:
+		return "sheb"
 	case ArchNative:
 		return "native"
 	case ArchInvalid:
@@ -391,6 +633,36 @@ func (a ScmpCompareOp) String() string {
 	}
 }
 
+// String returns a string representation of a filter attribute constant
+func (a ScmpFilterAttr) String() string {
+	switch a {
+	case AttrActDefault:
+		return "Default action"
+	case AttrActBadArch:
+		return "Bad architecture action"
+	case AttrNNP:
+		return "No New Privileges bit"
+	case AttrTsync:
+		return "TSYNC bit"
+	case AttrTSkip:
+		return "Syscall -1 rules"
+	case AttrLog:
+		return "Log bit"
+	case AttrSSB:
+		return "SSB bit"
+	case AttrOptimize:
+		return "Optimization level"
+	case AttrRawRC:
+		return "Raw return codes"
+	case AttrWaitKill:
+		return "Wait killable notifications"
+	case AttrInvalid:
+		return "Invalid filter attribute"
+	default:
+		return fmt.Sprintf("Unknown filter attribute %#x", uint(a))
+	}
+}
+
 // String returns a string representation of a seccomp match action
 func (a ScmpAction) String() string {
 	switch a & 0xFFFF {
@@ -433,6 +705,51 @@ func (a ScmpAction) GetReturnCode() int16 {
 	return int16(a >> 16)
 }
 
+// SetErrno is SetReturnCode taking an error number from package unix
+// (e.g., unix.EPERM), for use with ActErrno.
+func (a ScmpAction) SetErrno(errno unix.Errno) ScmpAction {
+	return a.SetReturnCode(int16(errno))
+}
+
+// GetErrno returns the return code of an ScmpAction as an error number.
+func (a ScmpAction) GetErrno() unix.Errno {
+	return unix.Errno(uint16(a.GetReturnCode()))
+}
+
+// SetErrno sets the error returned by the syscall that created the
+// notification. A zero errno makes the syscall succeed with r.Val instead.
+func (r *ScmpNotifResp) SetErrno(errno unix.Errno) {
+	r.Error = int32(errno)
+}
+
+// GetErrno returns the error set in a notification response, or 0 if the
+// syscall is made to succeed.
+func (r ScmpNotifResp) GetErrno() unix.Errno {
+	return unix.Errno(r.Error)
+}
+
+// Validate checks the response against the rules the kernel applies to
+// notification responses: only known flags may be set, a response with
+// NotifRespFlagContinue must not set an error or return value, and the
+// error must be a valid error number. NotifRespond() validates responses
+// before sending them.
+// Returns an error describing the first violation found.
+func (r ScmpNotifResp) Validate() error {
+	if unknown := r.Flags &^ (NotifRespFlagsKnown | r.AllowedFlags); unknown != 0 {
+		return fmt.Errorf("unknown notification response flags %#x", unknown)
+	}
+
+	if r.Flags&NotifRespFlagContinue != 0 && (r.Error != 0 || r.Val != 0) {
+		return fmt.Errorf("notification response with NotifRespFlagContinue must not set an error or value")
+	}
+
+	if r.Error < 0 || r.Error > maxErrno {
+		return fmt.Errorf("invalid notification response error %d", r.Error)
+	}
+
+	return nil
+}
+
 // General utility functions
 
 // GetLibraryVersion returns the version of the library the bindings are built
@@ -461,10 +778,52 @@ func SetAPI(api uint) error {
 	return setAPI(api)
 }
 
+// SetAutoAPI sets whether the API level is raised automatically to the one
+// required by the features the filter uses: ActNotify rules and notification
+// fds require level 6, the SSB bit level 4, and the log bit, ActLog and
+// ActKillProcess level 3. The level is raised when a feature is first used
+// and again by Load, as the API level is shared by the whole process. It is
+// never lowered.
+// Defaults to off, leaving the API level to GetAPI and SetAPI.
+// Returns an error if the filter is invalid, or if the host does not support
+// the API level required by the features used so far; the error lists all
+// such features.
+func (f *ScmpFilter) SetAutoAPI(state bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return ErrInvalidFilter
+	}
+
+	f.autoAPI = state
+	if !state {
+		return nil
+	}
+
+	return raiseAPI(f.apiFeatures())
+}
+
+// GetRequiredAPI returns the lowest API level supporting all the features the
+// filter currently uses, from its attributes and the actions of its rules, so
+// that e.g. clearing the log bit lowers it, or an error if the filter is
+// invalid.
+func (f *ScmpFilter) GetRequiredAPI() (uint, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return 0, ErrInvalidFilter
+	}
+
+	return requiredAPI(f.apiFeatures()), nil
+}
+
 // Syscall functions
 
 // GetName retrieves the name of a syscall from its number.
-// Acts on any syscall number.
+// Acts on any syscall number, falling back to the mappings registered with
+// RegisterSyscall.
 // Returns either a string containing the name of the syscall, or an error.
 func (s ScmpSyscall) GetName() (string, error) {
 	return s.GetNameByArch(ArchNative)
@@ -472,17 +831,43 @@ func (s ScmpSyscall) GetName() (string, error) {
 
 // GetNameByArch retrieves the name of a syscall from its number for a given
 // architecture.
-// Acts on any syscall number.
+// Acts on any syscall number, falling back to the mappings registered with
+// RegisterSyscall.
 // Accepts a valid architecture constant.
 // Returns either a string containing the name of the syscall, or an error.
 // if the syscall is unrecognized or an issue occurred.
 func (s ScmpSyscall) GetNameByArch(arch ScmpArch) (string, error) {
+	return cachedSyscallName(arch, s, func() (string, error) {
+		return s.getNameByArch(arch)
+	})
+}
+
+// ExistsOnArch checks whether the syscall number is the number of a syscall
+// on a given architecture, either known to libseccomp or registered with
+// RegisterSyscall. Pseudo-syscalls of syscalls multiplexed on the
+// architecture exist, while those libseccomp resolves the syscalls missing
+// on the architecture to do not. ArchNative is the native architecture of
+// the kernel.
+func (s ScmpSyscall) ExistsOnArch(arch ScmpArch) bool {
+	if s < pseudoMissingBase {
+		return false
+	}
+
+	_, err := s.GetNameByArch(arch)
+	return err == nil
+}
+
+// Helper - Resolve the name of a syscall, without the cache
+func (s ScmpSyscall) getNameByArch(arch ScmpArch) (string, error) {
 	if err := sanitizeArch(arch); err != nil {
 		return "", err
 	}
 
 	cString := C.seccomp_syscall_resolve_num_arch(arch.toNative(), C.int(s))
 	if cString == nil {
+		if name, ok := supplementalSyscallName(arch, s); ok {
+			return name, nil
+		}
 		return "", ErrSyscallDoesNotExist
 	}
 	defer C.free(unsafe.Pointer(cString))
@@ -492,11 +877,20 @@ func (s ScmpSyscall) GetNameByArch(arch ScmpArch) (string, error) {
 }
 
 // GetSyscallFromName returns the number of a syscall by name on the kernel's
-// native architecture.
+// native architecture, falling back to the mappings registered with
+// RegisterSyscall.
 // Accepts a string containing the name of a syscall.
 // Returns the number of the syscall, or an error if no syscall with that name
 // was found.
 func GetSyscallFromName(name string) (ScmpSyscall, error) {
+	return cachedSyscall(ArchNative, name, func() (ScmpSyscall, error) {
+		return getSyscallFromName(name)
+	})
+}
+
+// Helper - Resolve the number of a syscall on the native architecture,
+// without the cache
+func getSyscallFromName(name string) (ScmpSyscall, error) {
 	if err := ensureSupportedVersion(); err != nil {
 		return 0, err
 	}
@@ -506,6 +900,9 @@ func GetSyscallFromName(name string) (ScmpSyscall, error) {
 
 	result := C.seccomp_syscall_resolve_name(cString)
 	if result == scmpError {
+		if call, ok := supplementalSyscall(ArchNative, name); ok {
+			return call, nil
+		}
 		return 0, ErrSyscallDoesNotExist
 	}
 
@@ -513,11 +910,19 @@ func GetSyscallFromName(name string) (ScmpSyscall, error) {
 }
 
 // GetSyscallFromNameByArch returns the number of a syscall by name for a given
-// architecture's ABI.
+// architecture's ABI, falling back to the mappings registered with
+// RegisterSyscall.
 // Accepts the name of a syscall and an architecture constant.
 // Returns the number of the syscall, or an error if an invalid architecture is
 // passed or a syscall with that name was not found.
 func GetSyscallFromNameByArch(name string, arch ScmpArch) (ScmpSyscall, error) {
+	return cachedSyscall(arch, name, func() (ScmpSyscall, error) {
+		return getSyscallFromNameByArch(name, arch)
+	})
+}
+
+// Helper - Resolve the number of a syscall, without the cache
+func getSyscallFromNameByArch(name string, arch ScmpArch) (ScmpSyscall, error) {
 	if err := ensureSupportedVersion(); err != nil {
 		return 0, err
 	}
@@ -530,12 +935,87 @@ func GetSyscallFromNameByArch(name string, arch ScmpArch) (ScmpSyscall, error) {
 
 	result := C.seccomp_syscall_resolve_name_arch(arch.toNative(), cString)
 	if result == scmpError {
+		if call, ok := supplementalSyscall(arch, name); ok {
+			return call, nil
+		}
+		return 0, ErrSyscallDoesNotExist
+	}
+
+	return ScmpSyscall(result), nil
+}
+
+// GetSyscallFromNameRewrite returns the number of a syscall by name for a
+// given architecture's ABI, as GetSyscallFromNameByArch does, but rewrites
+// the pseudo-syscalls of multiplexed syscalls into the number of their
+// multiplexer, as libseccomp does when generating filters and
+// scmp_sys_resolver -t does, e.g. socket into socketcall on ArchX86.
+// Accepts the name of a syscall and an architecture constant.
+// Returns the number of the syscall, or an error if an invalid architecture is
+// passed or a syscall with that name was not found.
+func GetSyscallFromNameRewrite(name string, arch ScmpArch) (ScmpSyscall, error) {
+	if err := ensureSupportedVersion(); err != nil {
+		return 0, err
+	}
+	if err := sanitizeArch(arch); err != nil {
+		return 0, err
+	}
+
+	cString := C.CString(name)
+	defer C.free(unsafe.Pointer(cString))
+
+	result := C.seccomp_syscall_resolve_name_rewrite(arch.toNative(), cString)
+	if result == scmpError {
+		if call, ok := supplementalSyscall(arch, name); ok {
+			return call, nil
+		}
 		return 0, ErrSyscallDoesNotExist
 	}
 
 	return ScmpSyscall(result), nil
 }
 
+// TranslateSyscall translates the number of a syscall on architecture from
+// into its number on architecture to, by resolving its name, e.g. to convert
+// numeric profiles captured on one architecture to other architectures.
+// ArchNative is resolved to the native architecture of the kernel, and
+// syscall numbers are returned unchanged between identical architectures.
+// Syscalls multiplexed on architecture to, such as socket on ArchX86, are
+// translated to their pseudo-syscall numbers (see IsPseudo).
+// Returns the number of the syscall on architecture to, or an error if an
+// architecture is invalid, the syscall is unknown on architecture from, or
+// it does not exist on architecture to.
+func TranslateSyscall(call ScmpSyscall, from, to ScmpArch) (ScmpSyscall, error) {
+	from, err := resolveNativeArch(from)
+	if err != nil {
+		return 0, err
+	}
+	to, err = resolveNativeArch(to)
+	if err != nil {
+		return 0, err
+	}
+	if err := sanitizeArch(to); err != nil {
+		return 0, err
+	}
+	if from == to {
+		return call, nil
+	}
+
+	name, err := call.GetNameByArch(from)
+	if err != nil {
+		return 0, err
+	}
+
+	toCall, err := GetSyscallFromNameByArch(name, to)
+	if err != nil {
+		return 0, err
+	} else if toCall < pseudoMissingBase {
+		// Pseudo-syscall number of a syscall missing on the architecture
+		return 0, ErrSyscallDoesNotExist
+	}
+
+	return toCall, nil
+}
+
 // MakeCondition creates and returns a new condition to attach to a filter rule.
 // Associated rules will only match if this condition is true.
 // Accepts the number the argument we are checking, and a comparison operator
@@ -548,6 +1028,8 @@ func GetSyscallFromNameByArch(name string, arch ScmpArch) (ScmpSyscall, error) {
 // For example, in the less than or equal case, if the syscall argument was
 // 0 and the value provided was 1, the condition would match, as 0 is less
 // than or equal to 1.
+// Values exceeding 32 bits are refused when rules are added to filters with
+// 32-bit architectures, see SetOperandTruncation.
 // Return either an error on bad argument or a valid ScmpCondition struct.
 func MakeCondition(arg uint, comparison ScmpCompareOp, values ...uint64) (ScmpCondition, error) {
 	var condStruct ScmpCondition
@@ -601,6 +1083,20 @@ type ScmpFilter struct {
 	filterCtx C.scmp_filter_ctx
 	valid     bool
 	lock      sync.Mutex
+	// Whether raw rules may use pseudo-syscall numbers
+	rawPseudo bool
+	// Whether operands are truncated on 32-bit architectures
+	truncateOperands bool
+	// Whether the API level is raised to the one the filter requires
+	autoAPI bool
+	// Rules and syscall priorities of the filter, to rebuild it
+	rules      []ScmpRule
+	priorities map[ScmpSyscall]uint8
+	// Active transactions, innermost last
+	transactions []*ScmpTransaction
+	// Notification fd created by LoadWithFlags, if any, owned by the caller
+	listenerFd  ScmpFd
+	hasListener bool
 }
 
 // NewFilter creates and returns a new filter context.  Accepts a default action to be
@@ -624,11 +1120,12 @@ func NewFilter(defaultAction ScmpAction) (*ScmpFilter, error) {
 	filter := new(ScmpFilter)
 	filter.filterCtx = fPtr
 	filter.valid = true
+	filter.priorities = make(map[ScmpSyscall]uint8)
 	runtime.SetFinalizer(filter, filterFinalizer)
 
 	// Enable TSync so all goroutines will receive the same rules.
 	// If the kernel does not support TSYNC, allow us to continue without error.
-	if err := filter.setFilterAttr(filterAttrTsync, 0x1); err != nil && err != syscall.ENOTSUP {
+	if err := filter.setFilterAttr(filterAttrTsync, 0x1); err != nil && err != unix.ENOTSUP {
 		filter.Release()
 		return nil, fmt.Errorf("could not create filter - error setting tsync bit: %v", err)
 	}
@@ -636,6 +1133,49 @@ func NewFilter(defaultAction ScmpAction) (*ScmpFilter, error) {
 	return filter, nil
 }
 
+// NewFilterWithArches creates and returns a new filter context for exactly
+// the given architectures. Unlike NewFilter, the native architecture is only
+// part of the filter if it is listed. Accepts a default action to be taken for
+// syscalls which match no rules in the filter.
+// Returns a reference to a valid filter context, or nil and an error if no
+// architecture is given or the filter could not be created with all of them.
+func NewFilterWithArches(defaultAction ScmpAction, arches ...ScmpArch) (*ScmpFilter, error) {
+	if len(arches) == 0 {
+		return nil, fmt.Errorf("could not create filter - no architectures given")
+	}
+
+	native, err := GetNativeArch()
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := NewFilter(defaultAction)
+	if err != nil {
+		return nil, err
+	}
+
+	keepNative := false
+	for _, arch := range arches {
+		if arch == native || arch == ArchNative {
+			keepNative = true
+			continue
+		}
+		if err := filter.AddArch(arch); err != nil {
+			filter.Release()
+			return nil, fmt.Errorf("could not create filter - error adding architecture %s: %v", arch, err)
+		}
+	}
+
+	if !keepNative {
+		if err := filter.RemoveArch(ArchNative); err != nil {
+			filter.Release()
+			return nil, fmt.Errorf("could not create filter - error removing native architecture: %v", err)
+		}
+	}
+
+	return filter, nil
+}
+
 // IsValid determines whether a filter context is valid to use.
 // Some operations (Release and Merge) render filter contexts invalid and
 // consequently prevent further use.
@@ -656,11 +1196,50 @@ func (f *ScmpFilter) Reset(defaultAction ScmpAction) error {
 	if err := sanitizeAction(defaultAction); err != nil {
 		return err
 	} else if !f.valid {
-		return errBadFilter
+		return ErrInvalidFilter
 	}
 
-	if retCode := C.seccomp_reset(f.filterCtx, defaultAction.toNative()); retCode != 0 {
-		return errRc(retCode)
+	return f.reset(defaultAction)
+}
+
+// ResetKeepArches resets a filter context like Reset, but keeps the
+// architectures of the filter, instead of reverting to the native
+// architecture only.
+// Accepts a new default action to be taken for syscalls which do not match.
+// Returns an error if the filter or action provided are invalid, or the
+// architectures could not be restored.
+func (f *ScmpFilter) ResetKeepArches(defaultAction ScmpAction) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := sanitizeAction(defaultAction); err != nil {
+		return err
+	} else if !f.valid {
+		return ErrInvalidFilter
+	}
+
+	native, err := GetNativeArch()
+	if err != nil {
+		return err
+	}
+
+	arches := f.filterArches()
+	if err := f.reset(defaultAction); err != nil {
+		return err
+	}
+
+	keepNative := false
+	for _, arch := range arches {
+		if arch == native {
+			keepNative = true
+		} else if retCode := C.seccomp_arch_add(f.filterCtx, arch.toNative()); retCode != 0 {
+			return fmt.Errorf("could not restore architecture %s: %v", arch, errRc(retCode))
+		}
+	}
+	if !keepNative {
+		if retCode := C.seccomp_arch_remove(f.filterCtx, native.toNative()); retCode != 0 {
+			return fmt.Errorf("could not remove native architecture: %v", errRc(retCode))
+		}
 	}
 
 	return nil
@@ -672,6 +1251,9 @@ func (f *ScmpFilter) Reset(defaultAction ScmpAction) error {
 // be used.
 // Release() will be invoked automatically when a filter context is garbage
 // collected, but can also be called manually to free memory.
+// The notification fd of a loaded filter is owned by the caller and is not
+// closed, so that notifications can still be handled once the filter is
+// released; close it with NotifClose when done.
 func (f *ScmpFilter) Release() {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -682,6 +1264,24 @@ func (f *ScmpFilter) Release() {
 
 	f.valid = false
 	C.seccomp_release(f.filterCtx)
+	runtime.SetFinalizer(f, nil)
+}
+
+// Close releases a filter context like Release, implementing io.Closer.
+// After closing, the methods of the filter return ErrInvalidFilter.
+// Closing a filter more than once has no effect.
+func (f *ScmpFilter) Close() error {
+	f.Release()
+
+	return nil
+}
+
+// DisableFinalizer stops the filter context from being released when it is
+// garbage collected, for callers which release their filters
+// deterministically with Close or Release, and want to avoid the cost of
+// finalizers. Filters not released explicitly leak their memory afterwards.
+func (f *ScmpFilter) DisableFinalizer() {
+	runtime.SetFinalizer(f, nil)
 }
 
 // Merge merges two filter contexts.
@@ -693,7 +1293,9 @@ func (f *ScmpFilter) Release() {
 // The filter src will be merged into the filter this is called on.
 // The architectures of the src filter not present in the destination, and all
 // associated rules, will be added to the destination.
-// Returns an error if merging the filters failed.
+// Returns a *MergeError naming the conflict if the filters share an
+// architecture or their attributes do not match, or an error if merging the
+// filters failed.
 func (f *ScmpFilter) Merge(src *ScmpFilter) error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -702,23 +1304,67 @@ func (f *ScmpFilter) Merge(src *ScmpFilter) error {
 	defer src.lock.Unlock()
 
 	if !src.valid || !f.valid {
-		return fmt.Errorf("one or more of the filter contexts is invalid or uninitialized")
+		return ErrInvalidFilter
+	}
+
+	// The rules of src only apply to its own architectures
+	srcRules := restrictRules(src.rules, src.filterArches())
+
+	if err := f.mergeConflict(src); err != nil {
+		return err
 	}
 
 	// Merge the filters
 	if retCode := C.seccomp_merge(f.filterCtx, src.filterCtx); retCode != 0 {
 		e := errRc(retCode)
-		if e == syscall.EINVAL {
+		if e == unix.EINVAL {
 			return fmt.Errorf("filters could not be merged due to a mismatch in attributes or invalid filter")
 		}
 		return e
 	}
 
 	src.valid = false
+	f.rules = append(f.rules, srcRules...)
 
 	return nil
 }
 
+// Clone creates a new filter identical to the filter, with the same default
+// action, attributes, architectures, rules and syscall priorities, e.g. to
+// stamp out filters from a template. The new filter is independent from the
+// filter; it does not take part in its active transactions.
+// As libseccomp cannot copy filters, the new filter is built from the rules
+// tracked by the bindings, see AddRuleForArch.
+// Returns an error if the filter is invalid or could not be copied.
+func (f *ScmpFilter) Clone() (*ScmpFilter, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return nil, ErrInvalidFilter
+	}
+
+	ctx, err := f.build(f.filterArches())
+	if err != nil {
+		return nil, fmt.Errorf("could not clone filter: %v", err)
+	}
+
+	clone := new(ScmpFilter)
+	clone.filterCtx = ctx
+	clone.valid = true
+	clone.rawPseudo = f.rawPseudo
+	clone.truncateOperands = f.truncateOperands
+	clone.autoAPI = f.autoAPI
+	clone.rules = append([]ScmpRule(nil), f.rules...)
+	clone.priorities = make(map[ScmpSyscall]uint8, len(f.priorities))
+	for call, priority := range f.priorities {
+		clone.priorities[call] = priority
+	}
+	runtime.SetFinalizer(clone, filterFinalizer)
+
+	return clone, nil
+}
+
 // IsArchPresent checks if an architecture is present in a filter.
 // If a filter contains an architecture, it uses its default action for
 // syscalls which do not match rules in it, and its rules can match syscalls
@@ -737,12 +1383,12 @@ func (f *ScmpFilter) IsArchPresent(arch ScmpArch) (bool, error) {
 	if err := sanitizeArch(arch); err != nil {
 		return false, err
 	} else if !f.valid {
-		return false, errBadFilter
+		return false, ErrInvalidFilter
 	}
 
 	if retCode := C.seccomp_arch_exist(f.filterCtx, arch.toNative()); retCode != 0 {
 		e := errRc(retCode)
-		if e == syscall.EEXIST {
+		if e == unix.EEXIST {
 			// -EEXIST is "arch not present"
 			return false, nil
 		}
@@ -754,8 +1400,9 @@ func (f *ScmpFilter) IsArchPresent(arch ScmpArch) (bool, error) {
 
 // AddArch adds an architecture to the filter.
 // Accepts an architecture constant.
-// Returns an error on invalid filter context or architecture token, or an
-// issue with the call to libseccomp.
+// Returns an error on invalid filter context or architecture token, if rules
+// of the filter have operands a 32-bit architecture would truncate (see
+// SetOperandTruncation), or an issue with the call to libseccomp.
 func (f *ScmpFilter) AddArch(arch ScmpArch) error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -763,14 +1410,23 @@ func (f *ScmpFilter) AddArch(arch ScmpArch) error {
 	if err := sanitizeArch(arch); err != nil {
 		return err
 	} else if !f.valid {
-		return errBadFilter
+		return ErrInvalidFilter
+	}
+
+	// Libseccomp applies the rules of the filter to the architecture
+	for _, rule := range f.rules {
+		if rule.Arch == ArchInvalid {
+			if err := f.checkOperands([]ScmpArch{arch}, rule.Conditions); err != nil {
+				return err
+			}
+		}
 	}
 
 	// Libseccomp returns -EEXIST if the specified architecture is already
 	// present. Succeed silently in this case, as it's not fatal, and the
 	// architecture is present already.
 	if retCode := C.seccomp_arch_add(f.filterCtx, arch.toNative()); retCode != 0 {
-		if e := errRc(retCode); e != syscall.EEXIST {
+		if e := errRc(retCode); e != unix.EEXIST {
 			return e
 		}
 	}
@@ -789,36 +1445,108 @@ func (f *ScmpFilter) RemoveArch(arch ScmpArch) error {
 	if err := sanitizeArch(arch); err != nil {
 		return err
 	} else if !f.valid {
-		return errBadFilter
+		return ErrInvalidFilter
 	}
 
 	// Similar to AddArch, -EEXIST is returned if the arch is not present
 	// Succeed silently in that case, this is not fatal and the architecture
 	// is not present in the filter after RemoveArch
 	if retCode := C.seccomp_arch_remove(f.filterCtx, arch.toNative()); retCode != 0 {
-		if e := errRc(retCode); e != syscall.EEXIST {
+		if e := errRc(retCode); e != unix.EEXIST {
 			return e
 		}
 	}
 
+	if removed, err := resolveNativeArch(arch); err == nil {
+		rules := f.rules[:0]
+		for _, rule := range f.rules {
+			if rule.Arch != removed {
+				rules = append(rules, rule)
+			}
+		}
+		f.rules = rules
+	}
+
 	return nil
 }
 
+// Precompute generates the BPF program of a filter context ahead of time, so
+// that a subsequent Load only has to pass it to the kernel, e.g. in a latency
+// critical window right before exec. Changing the filter afterwards discards
+// the precomputed program.
+// Precompute requires libseccomp v2.6.0 or newer.
+// Returns an error if the filter context is invalid or the program could not
+// be generated.
+func (f *ScmpFilter) Precompute() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return ErrInvalidFilter
+	}
+
+	return f.precompute()
+}
+
 // Load loads a filter context into the kernel.
-// Returns an error if the filter context is invalid or the syscall failed.
+// Returns an error if the filter context is invalid or the syscall failed, and
+// a *TsyncError if TSYNC is enabled and a thread of the process could not be
+// synchronized, e.g. because it installed a filter of its own. As libseccomp
+// does not report the thread, the load is retried once to identify it.
 func (f *ScmpFilter) Load() error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
+	return f.load()
+}
+
+// LoadOnCurrentThread loads a filter context into the kernel for the calling
+// thread only, regardless of the TSYNC attribute, e.g. to confine a single
+// worker goroutine.
+// The calling goroutine is locked to its OS thread first, see
+// runtime.LockOSThread, and stays locked once the filter is loaded, so the
+// filter keeps applying to it and no other goroutine runs on the confined
+// thread. The thread is terminated when the goroutine exits.
+// Returns an error if the filter context is invalid or the syscall failed, in
+// which case the goroutine is unlocked again.
+func (f *ScmpFilter) LoadOnCurrentThread() error {
+	runtime.LockOSThread()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := f.loadOnCurrentThread(); err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+
+	return nil
+}
+
+// LoadWithFlags loads a filter context into the kernel with exactly the given
+// seccomp(2) flags, instead of the flags libseccomp derives from the filter
+// attributes. Only the No New Privileges bit is taken from the attributes.
+// With LoadFlagNewListener, the notification fd created by the kernel is
+// returned by GetNotifFd afterwards. The caller owns the fd, which Release
+// does not close.
+// Like Load, LoadWithFlags only applies the filter to the calling thread,
+// unless LoadFlagTsync is set.
+// Returns an error if the filter context is invalid or the syscall failed.
+func (f *ScmpFilter) LoadWithFlags(flags LoadFlag) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
 	if !f.valid {
-		return errBadFilter
+		return ErrInvalidFilter
 	}
 
-	if retCode := C.seccomp_load(f.filterCtx); retCode != 0 {
-		return errRc(retCode)
+	if f.autoAPI {
+		if err := raiseAPI(f.apiFeatures()); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return f.loadWithFlags(flags)
 }
 
 // GetDefaultAction returns the default action taken on a syscall which does not
@@ -914,6 +1642,116 @@ func (f *ScmpFilter) GetSSB() (bool, error) {
 	return true, nil
 }
 
+// GetTsync returns whether the filter will be synchronized to all the threads
+// of the process when loaded, or an error if an issue was encountered
+// retrieving the value.
+// Filters are created with TSYNC enabled if the kernel supports it.
+func (f *ScmpFilter) GetTsync() (bool, error) {
+	tsync, err := f.getFilterAttr(filterAttrTsync)
+	if err != nil {
+		return false, err
+	}
+
+	if tsync == 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// GetOptimizeLevel returns the optimization level libseccomp generates the
+// filter program with, or an error if an issue was encountered retrieving
+// the value. See SetOptimizeLevel for the levels.
+// Optimization levels are only supported by libseccomp v2.5.0 and newer.
+func (f *ScmpFilter) GetOptimizeLevel() (uint, error) {
+	if !checkVersionAbove(2, 5, 0) {
+		return 0, VersionError{
+			message: "optimization levels are not supported",
+			minimum: "2.5.0",
+		}
+	}
+
+	level, err := f.getFilterAttr(filterAttrOptimize)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint(level), nil
+}
+
+// GetRawRC returns whether libseccomp passes the return codes of the system
+// calls it makes on unchanged, or an error if an issue was encountered
+// retrieving the value. See SetRawRC.
+// Raw return codes are only supported by libseccomp v2.5.0 and newer.
+func (f *ScmpFilter) GetRawRC() (bool, error) {
+	if !checkVersionAbove(2, 5, 0) {
+		return false, VersionError{
+			message: "raw return codes are not supported",
+			minimum: "2.5.0",
+		}
+	}
+
+	rawrc, err := f.getFilterAttr(filterAttrRawRC)
+	if err != nil {
+		return false, err
+	}
+
+	if rawrc == 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// GetTSkip returns whether rules on the syscall number -1 are allowed, or an
+// error if an issue was encountered retrieving the value. See SetTSkip.
+// Rules on the syscall number -1 are only supported by libseccomp v2.3.0 and
+// newer.
+func (f *ScmpFilter) GetTSkip() (bool, error) {
+	if !checkVersionAbove(2, 3, 0) {
+		return false, VersionError{
+			message: "rules on the syscall number -1 are not supported",
+			minimum: "2.3.0",
+		}
+	}
+
+	tskip, err := f.getFilterAttr(filterAttrTSkip)
+	if err != nil {
+		return false, err
+	}
+
+	if tskip == 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// GetWaitKill returns whether targets of notifications wait for a response
+// in a killable state once the supervisor received the notification, or an
+// error if an issue was encountered retrieving the value. See SetWaitKill.
+// Wait killable notifications are only supported by libseccomp v2.6.0 and
+// newer.
+func (f *ScmpFilter) GetWaitKill() (bool, error) {
+	if !checkVersionAbove(2, 6, 0) {
+		return false, VersionError{
+			message: "wait killable notifications are not supported",
+			minimum: "2.6.0",
+		}
+	}
+
+	waitKill, err := f.getFilterAttr(filterAttrWaitKill)
+	if err != nil {
+		return false, err
+	}
+
+	if waitKill == 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
 // SetBadArchAction sets the default action taken on a syscall for an
 // architecture not in the filter, or an error if an issue was encountered
 // setting the value.
@@ -922,6 +1760,12 @@ func (f *ScmpFilter) SetBadArchAction(action ScmpAction) error {
 		return err
 	}
 
+	if feature, level := actionAPILevel(action); level != 0 {
+		if err := f.requireAPI(feature, level); err != nil {
+			return err
+		}
+	}
+
 	return f.setFilterAttr(filterAttrActBadArch, action.toNative())
 }
 
@@ -949,6 +1793,9 @@ func (f *ScmpFilter) SetLogBit(state bool) error {
 
 	if state {
 		toSet = 0x1
+		if err := f.requireAPI("log bit", 3); err != nil {
+			return err
+		}
 	}
 
 	err := f.setFilterAttr(filterAttrLog, toSet)
@@ -972,6 +1819,9 @@ func (f *ScmpFilter) SetSSB(state bool) error {
 
 	if state {
 		toSet = 0x1
+		if err := f.requireAPI("SSB bit", 4); err != nil {
+			return err
+		}
 	}
 
 	err := f.setFilterAttr(filterAttrSSB, toSet)
@@ -985,30 +1835,203 @@ func (f *ScmpFilter) SetSSB(state bool) error {
 	return err
 }
 
+// SetTsync sets whether the filter is synchronized to all the threads of the
+// process when loaded, or returns an error if an issue was encountered setting
+// the value. With TSYNC enabled, Load fails unless all the threads could be
+// synchronized; as the Go runtime starts threads of its own, filters meant to
+// confine the whole process should require it.
+// Returns an error if TSYNC is enabled and the kernel does not support it.
+func (f *ScmpFilter) SetTsync(state bool) error {
+	var toSet C.uint32_t = 0x0
+
+	if state {
+		toSet = 0x1
+	}
+
+	err := f.setFilterAttr(filterAttrTsync, toSet)
+	if err == unix.EOPNOTSUPP {
+		if !state {
+			// Filters are never synchronized without kernel support
+			return nil
+		}
+		return fmt.Errorf("TSYNC is not supported by the running kernel")
+	}
+
+	return err
+}
+
+// SetOptimizeLevel sets the optimization level libseccomp generates the
+// filter program with, or returns an error if an issue was encountered
+// setting the value.
+// Level 1, the default, checks the syscalls one after the other, ordered by
+// priority, see SetSyscallPriority. Level 2 looks the syscalls up in a binary
+// tree, which is faster for filters with many rules.
+// Optimization levels are only supported by libseccomp v2.5.0 and newer.
+func (f *ScmpFilter) SetOptimizeLevel(level uint) error {
+	if !checkVersionAbove(2, 5, 0) {
+		return VersionError{
+			message: "optimization levels are not supported",
+			minimum: "2.5.0",
+		}
+	}
+
+	if level < 1 || level > 2 {
+		return fmt.Errorf("invalid optimization level %d", level)
+	}
+
+	return f.setFilterAttr(filterAttrOptimize, C.uint32_t(level))
+}
+
+// SetRawRC sets whether libseccomp passes the return codes of the system
+// calls it makes on unchanged, or returns an error if an issue was
+// encountered setting the value.
+// By default, libseccomp turns the errors of system calls into a small set of
+// documented return codes, e.g. ECANCELED for any failure to load a filter.
+// With raw return codes enabled, the errors returned by Load carry the errno
+// set by the kernel instead, such as EACCES or EPERM.
+// Raw return codes are only supported by libseccomp v2.5.0 and newer.
+func (f *ScmpFilter) SetRawRC(state bool) error {
+	if !checkVersionAbove(2, 5, 0) {
+		return VersionError{
+			message: "raw return codes are not supported",
+			minimum: "2.5.0",
+		}
+	}
+
+	var toSet C.uint32_t = 0x0
+
+	if state {
+		toSet = 0x1
+	}
+
+	return f.setFilterAttr(filterAttrRawRC, toSet)
+}
+
+// SetTSkip sets whether rules on the syscall number -1 are allowed, or
+// returns an error if an issue was encountered setting the value.
+// Tracers skip a syscall by changing its number to -1, which the kernel then
+// runs through seccomp filters again. Filters of processes which may be
+// traced this way, e.g. by debuggers or test tooling, can allow the skipped
+// syscalls with a rule on -1, such as AddRule(-1, ActAllow).
+// Defaults to off.
+// Rules on the syscall number -1 are only supported by libseccomp v2.3.0 and
+// newer.
+func (f *ScmpFilter) SetTSkip(state bool) error {
+	if !checkVersionAbove(2, 3, 0) {
+		return VersionError{
+			message: "rules on the syscall number -1 are not supported",
+			minimum: "2.3.0",
+		}
+	}
+
+	var toSet C.uint32_t = 0x0
+
+	if state {
+		toSet = 0x1
+	}
+
+	return f.setFilterAttr(filterAttrTSkip, toSet)
+}
+
+// SetWaitKill sets whether targets of notifications wait for a response in a
+// killable state once the supervisor received the notification, or returns
+// an error if an issue was encountered setting the value.
+// By default, signals interrupt the wait of the target, which is then
+// restarted, while the supervisor may still be acting on the notification.
+// With wait killable notifications, only fatal signals end the wait, so the
+// target can still be killed while a supervisor is slow to respond.
+// Wait killable notifications are only supported by libseccomp v2.6.0 and
+// newer, with API level 7 or higher.
+func (f *ScmpFilter) SetWaitKill(state bool) error {
+	if !checkVersionAbove(2, 6, 0) {
+		return VersionError{
+			message: "wait killable notifications are not supported",
+			minimum: "2.6.0",
+		}
+	}
+
+	var toSet C.uint32_t = 0x0
+
+	if state {
+		toSet = 0x1
+		if err := f.requireAPI("wait killable notifications", 7); err != nil {
+			return err
+		}
+	}
+
+	return f.setFilterAttr(filterAttrWaitKill, toSet)
+}
+
+// GetAttr returns the raw value of a filter attribute, or an error if an
+// issue was encountered retrieving it. Unlike the dedicated getters, GetAttr
+// accepts attributes the bindings have no constant for, by their libseccomp
+// value.
+// Returns an error if the attribute is invalid or unknown to libseccomp.
+func (f *ScmpFilter) GetAttr(attr ScmpFilterAttr) (uint, error) {
+	if attr == AttrInvalid {
+		return 0, fmt.Errorf("invalid filter attribute")
+	}
+
+	value, err := f.getNativeFilterAttr(uint32(attr))
+	if err != nil {
+		return 0, err
+	}
+
+	return uint(value), nil
+}
+
+// SetAttr sets the raw value of a filter attribute, or returns an error if an
+// issue was encountered setting it. Unlike the dedicated setters, SetAttr
+// accepts attributes the bindings have no constant for, by their libseccomp
+// value. The API levels the attributes known to the bindings require are
+// tracked as with the dedicated setters, see SetAutoAPI.
+// Returns an error if the attribute is invalid, read-only or unknown to
+// libseccomp, or if the value is invalid.
+func (f *ScmpFilter) SetAttr(attr ScmpFilterAttr, value uint) error {
+	if attr == AttrInvalid {
+		return fmt.Errorf("invalid filter attribute")
+	}
+	if uint(C.uint32_t(value)) != value {
+		return fmt.Errorf("value %#x of %s does not fit in 32 bits", value, attr)
+	}
+
+	if feature, level := attrAPILevel(attr, value); level != 0 {
+		if err := f.requireAPI(feature, level); err != nil {
+			return err
+		}
+	}
+
+	return f.setNativeFilterAttr(uint32(attr), C.uint32_t(value))
+}
+
 // SetSyscallPriority sets a syscall's priority.
 // This provides a hint to the filter generator in libseccomp about the
 // importance of this syscall. High-priority syscalls are placed
 // first in the filter code, and incur less overhead (at the expense of
-// lower-priority syscalls).
+// lower-priority syscalls). Priorities range from 0 to 255, and are kept when
+// the filter is rebuilt, see AddRuleForArch.
 func (f *ScmpFilter) SetSyscallPriority(call ScmpSyscall, priority uint8) error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
 	if !f.valid {
-		return errBadFilter
+		return ErrInvalidFilter
 	}
 
 	if retCode := C.seccomp_syscall_priority(f.filterCtx, C.int(call),
 		C.uint8_t(priority)); retCode != 0 {
 		return errRc(retCode)
 	}
+	f.priorities[call] = priority
 
 	return nil
 }
 
 // AddRule adds a single rule for an unconditional action on a syscall.
 // Accepts the number of the syscall and the action to be taken on the call
-// being made.
+// being made. Numbers are those of the native architecture, or pseudo-syscall
+// numbers such as PseudoSocket when the native architecture
+// multiplexes the syscall (see IsPseudo).
 // Returns an error if an issue was encountered adding the rule.
 func (f *ScmpFilter) AddRule(call ScmpSyscall, action ScmpAction) error {
 	return f.addRuleGeneric(call, action, false, nil)
@@ -1050,46 +2073,116 @@ func (f *ScmpFilter) AddRuleConditionalExact(call ScmpSyscall, action ScmpAction
 	return f.addRuleGeneric(call, action, true, conds)
 }
 
-// ExportPFC output PFC-formatted, human-readable dump of a filter context's
-// rules to a file.
-// Accepts file to write to (must be open for writing).
-// Returns an error if writing to the file fails.
-func (f *ScmpFilter) ExportPFC(file *os.File) error {
+// AddRuleRaw adds a single rule for an unconditional action on a raw syscall
+// number of architecture arch, as produced by kernel-side tracing.
+// Unlike AddRule, the number is not resolved through the syscall's name nor
+// rewritten in any way, so syscalls unknown to libseccomp can be filtered.
+// As libseccomp only takes syscall numbers of the native architecture, and
+// translates them to the other architectures by name, arch must be the
+// native architecture. The rule only applies to it, as with AddRuleForArch:
+// the other architectures of the filter are not affected, and the filter is
+// rebuilt from its tracked rules.
+// Negative numbers denote libseccomp pseudo-syscalls rather than raw syscall
+// numbers, and are refused unless allowed with SetRawPseudoSyscalls.
+// Returns an error if an issue was encountered adding the rule.
+func (f *ScmpFilter) AddRuleRaw(arch ScmpArch, call ScmpSyscall, action ScmpAction) error {
+	return f.addRuleRaw(arch, call, action, nil)
+}
+
+// AddRuleConditionalRaw adds a single rule for a conditional action on a raw
+// syscall number of architecture arch. See AddRuleRaw for the handling of
+// the number.
+// All conditions must match for the rule to match.
+// Returns an error if an issue was encountered adding the rule.
+func (f *ScmpFilter) AddRuleConditionalRaw(arch ScmpArch, call ScmpSyscall, action ScmpAction, conds []ScmpCondition) error {
+	return f.addRuleRaw(arch, call, action, conds)
+}
+
+// SetRawPseudoSyscalls sets whether AddRuleRaw and AddRuleConditionalRaw
+// accept negative syscall numbers, which libseccomp treats as
+// pseudo-syscalls and may rewrite for the architecture, e.g. to socketcall()
+// on x86.
+// Defaults to off.
+// Returns an error if the filter is invalid.
+func (f *ScmpFilter) SetRawPseudoSyscalls(state bool) error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	fd := file.Fd()
+	if !f.valid {
+		return ErrInvalidFilter
+	}
+	f.rawPseudo = state
+
+	return nil
+}
+
+// ExportPFC output PFC-formatted, human-readable dump of a filter context's
+// rules to a writer, without loading the filter. The comments of rules added
+// with AddRuleWithComment are written before the code of their syscall.
+// Accepts the writer to write to; an *os.File must be open for writing, and
+// is written to directly.
+// Returns an error if writing to the writer fails.
+func (f *ScmpFilter) ExportPFC(w io.Writer) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 
 	if !f.valid {
-		return errBadFilter
+		return ErrInvalidFilter
 	}
 
-	if retCode := C.seccomp_export_pfc(f.filterCtx, C.int(fd)); retCode != 0 {
-		return errRc(retCode)
+	export := func(fd C.int) C.int {
+		return C.seccomp_export_pfc(f.filterCtx, fd)
+	}
+	if !hasComments(f.rules) {
+		return exportTo(w, export)
 	}
 
-	return nil
+	native, err := GetNativeArch()
+	if err != nil {
+		return err
+	}
+	var pfc bytes.Buffer
+	if err := exportTo(&pfc, export); err != nil {
+		return err
+	}
+	_, err = w.Write(annotatePFC(pfc.Bytes(), f.rules, native))
+	return err
 }
 
 // ExportBPF outputs Berkeley Packet Filter-formatted, kernel-readable dump of a
-// filter context's rules to a file.
-// Accepts file to write to (must be open for writing).
-// Returns an error if writing to the file fails.
-func (f *ScmpFilter) ExportBPF(file *os.File) error {
+// filter context's rules to a writer, as a sequence of struct sock_filter in
+// native byte order. The program can be loaded by another process, or
+// inspected with the bpf package.
+// Accepts the writer to write to; an *os.File must be open for writing, and
+// is written to directly.
+// Returns an error if writing to the writer fails.
+func (f *ScmpFilter) ExportBPF(w io.Writer) error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	fd := file.Fd()
-
 	if !f.valid {
-		return errBadFilter
+		return ErrInvalidFilter
 	}
 
-	if retCode := C.seccomp_export_bpf(f.filterCtx, C.int(fd)); retCode != 0 {
-		return errRc(retCode)
+	return exportTo(w, func(fd C.int) C.int {
+		return C.seccomp_export_bpf(f.filterCtx, fd)
+	})
+}
+
+// ExportBPFMem returns the Berkeley Packet Filter program of a filter
+// context, in the format written by ExportBPF.
+// With libseccomp versions below v2.6.0, the program is captured from
+// ExportBPF instead.
+// Returns an error if the program could not be generated.
+func (f *ScmpFilter) ExportBPFMem() ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return nil, ErrInvalidFilter
 	}
 
-	return nil
+	return f.exportBPFMem()
 }
 
 // Userspace Notification API
@@ -1098,29 +2191,198 @@ func (f *ScmpFilter) ExportBPF(file *os.File) error {
 // filter context. Such a file descriptor is only valid after the filter has been loaded
 // and only when the filter uses the ActNotify action. The file descriptor can be used to
 // retrieve and respond to notifications associated with the filter (see NotifReceive(),
-// NotifRespond(), and NotifIDValid()).
+// NotifRespond(), and NotifIDValid()). The caller owns the file descriptor,
+// which remains valid after the filter is released, and must close it with
+// NotifClose().
 func (f *ScmpFilter) GetNotifFd() (ScmpFd, error) {
 	return f.getNotifFd()
 }
 
+// GetNotifFile returns the userspace notification file descriptor of the
+// filter, see GetNotifFd(), as an *os.File. The file holds a close-on-exec
+// duplicate of the file descriptor, owned by the caller: it can be closed,
+// or passed to a child process with exec.Cmd.ExtraFiles, independently of
+// the filter.
+// Returns an error if the filter context is invalid, the API level does not
+// support notifications, the filter has no notification fd, or it could not
+// be duplicated.
+func (f *ScmpFilter) GetNotifFile() (*os.File, error) {
+	fd, err := f.getNotifFd()
+	if err != nil {
+		return nil, err
+	} else if fd < 0 {
+		return nil, fmt.Errorf("filter has no notification fd")
+	}
+
+	dup, err := unix.FcntlInt(uintptr(fd), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not duplicate notification fd: %v", err)
+	}
+
+	return os.NewFile(uintptr(dup), "seccomp-notify"), nil
+}
+
+// LoadAndGetNotifFd loads a filter context using ActNotify into the kernel,
+// and returns the userspace notification file descriptor created along with
+// it, see GetNotifFd. The notification fd is created by the same seccomp(2)
+// call as the filter is loaded with, so no notification can be triggered
+// before it exists. As with GetNotifFd, the caller owns the file descriptor.
+// Returns an error if the filter context is invalid, the API level does not
+// support notifications, loading failed, or the filter has no notification
+// fd, e.g. because it has no ActNotify rule.
+func (f *ScmpFilter) LoadAndGetNotifFd() (ScmpFd, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return -1, ErrInvalidFilter
+	}
+
+	if err := f.checkNotifAPI(); err != nil {
+		return -1, err
+	}
+
+	if err := f.load(); err != nil {
+		return -1, err
+	}
+
+	fd := f.notifFd()
+	if fd < 0 {
+		return -1, fmt.Errorf("loaded filter has no notification fd")
+	}
+
+	return fd, nil
+}
+
 // NotifReceive retrieves a seccomp userspace notification from a filter whose ActNotify
 // action has triggered. The caller is expected to process the notification and return a
 // response via NotifRespond(). Each invocation of this function returns one
 // notification. As multiple notifications may be pending at any time, this function is
-// normally called within a polling loop.
+// normally called within a polling loop. ErrNotifTargetGone is returned if the
+// notification went away while it was being retrieved.
+// A receive loop can be stopped by closing fd with NotifClose, upon which the
+// waiting call returns ErrNotifClosed. io.EOF is returned once no process
+// uses the filter anymore.
+// The notification is retrieved with the structure size of the running
+// kernel (see GetNotifSizes()), keeping the fields the bindings do not know
+// in the Extra field.
 func NotifReceive(fd ScmpFd) (*ScmpNotifReq, error) {
 	return notifReceive(fd)
 }
 
+// NotifReceiveInto retrieves a seccomp userspace notification as
+// NotifReceive() does, storing it into req instead of allocating a new one.
+// The slices of req are reused, and the buffers used to retrieve
+// notifications are shared between calls, so that supervisors handling many
+// notifications do not allocate for each of them. req must therefore not be
+// in use anymore, including its Data.Args and Extra slices.
+// Returns the same errors as NotifReceive(); req is left in an unspecified
+// state on error.
+func NotifReceiveInto(fd ScmpFd, req *ScmpNotifReq) error {
+	return notifReceiveInto(fd, req)
+}
+
+// NotifReceiveBatch retrieves up to max seccomp userspace notifications in a
+// single call into libseccomp, draining the notifications pending on fd.
+// It waits for the first notification as NotifReceive() does, but not for
+// the following ones. Notifications whose target went away before they could
+// be retrieved are skipped.
+// As retrieving a notification blocks even on non-blocking fds, the caller
+// must be the only one retrieving notifications from fd, or the call may
+// block after the first notification.
+// Returns an error if no notification could be retrieved.
+func NotifReceiveBatch(fd ScmpFd, max int) ([]*ScmpNotifReq, error) {
+	return notifReceiveBatch(fd, max)
+}
+
 // NotifRespond responds to a notification retrieved via NotifReceive(). The response Id
 // must match that of the corresponding notification retrieved via NotifReceive().
+// The response is sent with the structure size of the running kernel,
+// including the Extra field. The kernel hands the response to the target
+// without waiting for it, so responding does not block, even if the target
+// is slow to resume or died.
+// ErrNotifTargetGone is returned if the notification is no longer valid.
 func NotifRespond(fd ScmpFd, scmpResp *ScmpNotifResp) error {
 	return notifRespond(fd, scmpResp)
 }
 
+// NotifRespondErrno responds to a notification retrieved via NotifReceive(),
+// making the syscall that triggered it fail with the error errno.
+// Returns an error if errno is 0 or not a valid error number, or the
+// response could not be sent.
+func NotifRespondErrno(fd ScmpFd, id uint64, errno unix.Errno) error {
+	if errno == 0 {
+		return fmt.Errorf("notification response error must not be 0; see NotifRespondSuccess()")
+	}
+
+	resp := &ScmpNotifResp{ID: id}
+	resp.SetErrno(errno)
+	return notifRespond(fd, resp)
+}
+
+// NotifRespondSuccess responds to a notification retrieved via NotifReceive(),
+// making the syscall that triggered it succeed with the return value val,
+// without executing it.
+// Returns an error if the response could not be sent.
+func NotifRespondSuccess(fd ScmpFd, id uint64, val uint64) error {
+	return notifRespond(fd, &ScmpNotifResp{ID: id, Val: val})
+}
+
+// NotifRespondContinue responds to a notification retrieved via
+// NotifReceive(), letting the kernel execute the syscall that triggered it.
+// See NotifRespFlagContinue for the caveats of continuing syscalls.
+// Returns an error if the response could not be sent.
+func NotifRespondContinue(fd ScmpFd, id uint64) error {
+	return notifRespond(fd, &ScmpNotifResp{ID: id, Flags: NotifRespFlagContinue})
+}
+
 // NotifIDValid checks if a notification is still valid. An return value of nil means the
-// notification is still valid. Otherwise the notification is not valid. This can be used
+// notification is still valid. Otherwise the notification is not valid, and
+// ErrNotifTargetGone is returned unless checking failed. This can be used
 // to mitigate time-of-check-time-of-use (TOCTOU) attacks as described in seccomp_notify_id_valid(2).
 func NotifIDValid(fd ScmpFd, id uint64) error {
 	return notifIDValid(fd, id)
 }
+
+// NotifIDsValid checks if several notifications are still valid, in a single
+// call into libseccomp. The returned slice holds, for each of the given IDs,
+// whether the notification is still valid. Supervisors can use it to check a
+// whole batch of pending notifications before committing their side effects.
+// Returns an error if the validity of the IDs could not be determined.
+func NotifIDsValid(fd ScmpFd, ids []uint64) ([]bool, error) {
+	return notifIDsValid(fd, ids)
+}
+
+// NotifAddFd installs a file descriptor of the supervisor in the process which
+// triggered a notification retrieved via NotifReceive(), e.g. to emulate a
+// syscall opening a file. The notification must then be responded to via
+// NotifRespond(), usually with the returned file descriptor number as value,
+// unless NotifAddFdFlagSend is set: the notification is then answered by the
+// kernel atomically, or by NotifAddFd() right after installing the file
+// descriptor on kernels which do not support it.
+// Returns the number of the file descriptor in the target process, or an
+// error if it could not be installed; ErrNotifTargetGone if the notification
+// is no longer valid. Requires Linux 5.9 or later.
+func NotifAddFd(fd ScmpFd, req *ScmpNotifAddFd) (int, error) {
+	return notifAddFd(fd, req)
+}
+
+// NotifSetFlags sets flags changing how the kernel delivers notifications on
+// the notification fd fd (e.g., NotifFdFlagSyncWakeUp). Unlike the filter's
+// LoadFlagWaitKillableRecv, these flags can be changed at any time.
+// Returns an error if the flags could not be set; ENOTTY if the kernel does
+// not support setting flags, and EINVAL if it does not support the flags.
+func NotifSetFlags(fd ScmpFd, flags uint64) error {
+	return notifSetFlags(fd, flags)
+}
+
+// GetNotifSizes returns the sizes of the structures the running kernel uses
+// for seccomp userspace notifications, so that callers exchanging raw
+// notifications, e.g. with processes built against other kernel headers, can
+// check that they are compatible.
+// Returns an error if the sizes could not be retrieved; EINVAL if the kernel
+// does not support seccomp userspace notifications. Requires Linux 5.0 or
+// later.
+func GetNotifSizes() (*ScmpNotifSizes, error) {
+	return notifGetSizes()
+}
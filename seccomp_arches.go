@@ -0,0 +1,251 @@
+// +build linux
+
+// Architecture helpers for libseccomp Go bindings
+// Lists the architectures and relates them to other naming schemes
+
+package seccomp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"runtime"
+)
+
+// Go architectures (GOARCH values) of the Linux ports of Go, and the
+// architecture of their syscalls
+var goarches = []struct {
+	goarch string
+	arch   ScmpArch
+}{
+	{"386", ArchX86},
+	{"amd64", ArchAMD64},
+	{"arm", ArchARM},
+	{"arm64", ArchARM64},
+	{"mips", ArchMIPS},
+	{"mipsle", ArchMIPSEL},
+	{"mips64", ArchMIPS64},
+	{"mips64le", ArchMIPSEL64},
+	{"ppc64", ArchPPC64},
+	{"ppc64le", ArchPPC64LE},
+	{"s390x", ArchS390X},
+	{"loong64", ArchLOONGARCH64},
+	{"riscv64", ArchRISCV64},
+}
+
+// GetArchFromGOARCH returns the architecture of the syscalls of programs
+// built for a Go architecture, as named by GOARCH (e.g., "386" for ArchX86),
+// so that tools building for other architectures can pick the architectures
+// of their filters. An empty goarch stands for runtime.GOARCH.
+// Returns an error if Go does not support Linux on the architecture, or its
+// syscalls have no seccomp architecture.
+func GetArchFromGOARCH(goarch string) (ScmpArch, error) {
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+
+	for _, a := range goarches {
+		if a.goarch == goarch {
+			return a.arch, nil
+		}
+	}
+
+	return ArchInvalid, fmt.Errorf("no seccomp architecture for GOARCH %q", goarch)
+}
+
+// GOARCH returns the Go architecture, as named by GOARCH, of programs making
+// syscalls of the architecture. ArchNative is resolved to the native
+// architecture of the kernel.
+// Returns an error if Go has no Linux port for the architecture, such as for
+// ArchX32.
+func (a ScmpArch) GOARCH() (string, error) {
+	arch, err := resolveNativeArch(a)
+	if err != nil {
+		return "", err
+	}
+
+	for _, g := range goarches {
+		if g.arch == arch {
+			return g.goarch, nil
+		}
+	}
+
+	return "", fmt.Errorf("no GOARCH for architecture %s", arch)
+}
+
+// Compat architectures of 64-bit architectures, whose syscalls programs can
+// make on kernels of the 64-bit architecture
+var compatArches = map[ScmpArch][]ScmpArch{
+	ArchAMD64:    {ArchX86, ArchX32},
+	ArchARM64:    {ArchARM},
+	ArchMIPS64:   {ArchMIPS64N32, ArchMIPS},
+	ArchMIPSEL64: {ArchMIPSEL64N32, ArchMIPSEL},
+	ArchPPC64:    {ArchPPC},
+	ArchS390X:    {ArchS390},
+	ArchPARISC64: {ArchPARISC},
+}
+
+// Properties of the architectures, as libseccomp defines them, and the
+// AUDIT_ARCH_* values the kernel reports for their syscalls
+var archProperties = map[ScmpArch]struct {
+	bits      int
+	bigEndian bool
+	audit     uint32
+}{
+	ArchX86:         {32, false, 0x40000003},
+	ArchAMD64:       {64, false, 0xc000003e},
+	ArchX32:         {32, false, 0xc000003e},
+	ArchARM:         {32, false, 0x40000028},
+	ArchARM64:       {64, false, 0xc00000b7},
+	ArchMIPS:        {32, true, 0x00000008},
+	ArchMIPS64:      {64, true, 0x80000008},
+	ArchMIPS64N32:   {32, true, 0xa0000008},
+	ArchMIPSEL:      {32, false, 0x40000008},
+	ArchMIPSEL64:    {64, false, 0xc0000008},
+	ArchMIPSEL64N32: {32, false, 0xe0000008},
+	ArchPPC:         {32, true, 0x00000014},
+	ArchPPC64:       {64, true, 0x80000015},
+	ArchPPC64LE:     {64, false, 0xc0000015},
+	ArchS390:        {32, true, 0x00000016},
+	ArchS390X:       {64, true, 0x80000016},
+	ArchPARISC:      {32, true, 0x0000000f},
+	ArchPARISC64:    {64, true, 0x8000000f},
+	ArchLOONGARCH64: {64, false, 0xc0000102},
+	ArchRISCV64:     {64, false, 0xc00000f3},
+	ArchRISCV32:     {32, false, 0x400000f3},
+	ArchM68K:        {32, true, 0x00000004},
+	ArchSH:          {32, false, 0x4000002a},
+	ArchSHEB:        {32, true, 0x0000002a},
+}
+
+// BitWidth returns the width in bits of the architecture, 32 or 64. As for
+// libseccomp, the ABIs with 32-bit pointers of 64-bit architectures, ArchX32
+// and the N32 ABIs of MIPS64, are 32-bit, as is 31-bit ArchS390. Syscall
+// arguments are 64-bit on every architecture, but only their lower 32 bits
+// are meaningful on 32-bit architectures. ArchNative is resolved to the
+// native architecture of the kernel.
+// Returns an error if the architecture is invalid.
+func (a ScmpArch) BitWidth() (int, error) {
+	arch, err := resolveNativeArch(a)
+	if err != nil {
+		return 0, err
+	}
+
+	props, ok := archProperties[arch]
+	if !ok {
+		return 0, fmt.Errorf("invalid architecture %s", a)
+	}
+
+	return props.bits, nil
+}
+
+// Endianness returns the byte order of the architecture, binary.LittleEndian
+// or binary.BigEndian. A filter can only have architectures of the same
+// endianness. ArchNative is resolved to the native architecture of the
+// kernel.
+// Returns an error if the architecture is invalid.
+func (a ScmpArch) Endianness() (binary.ByteOrder, error) {
+	arch, err := resolveNativeArch(a)
+	if err != nil {
+		return nil, err
+	}
+
+	props, ok := archProperties[arch]
+	if !ok {
+		return nil, fmt.Errorf("invalid architecture %s", a)
+	} else if props.bigEndian {
+		return binary.BigEndian, nil
+	}
+
+	return binary.LittleEndian, nil
+}
+
+// AuditToken returns the AUDIT_ARCH_* value the kernel reports in the arch
+// field of seccomp_data for syscalls of the architecture, as seen in audit
+// logs, ptrace events and seccomp notifications. The kernel reports ArchX32
+// syscalls as AUDIT_ARCH_X86_64, telling them apart from ArchAMD64 syscalls
+// by the __X32_SYSCALL_BIT of their number. ArchNative is resolved to the
+// native architecture of the kernel.
+// Returns 0 if the architecture is invalid.
+func (a ScmpArch) AuditToken() uint32 {
+	arch, err := resolveNativeArch(a)
+	if err != nil {
+		return 0
+	}
+
+	return archProperties[arch].audit
+}
+
+// ArchFromAuditToken returns the architecture of syscalls reported by the
+// kernel with an AUDIT_ARCH_* value, such as the arch field of seccomp_data.
+// AUDIT_ARCH_X86_64 is ArchAMD64, see AuditToken.
+// Returns an error if no architecture has the value.
+func ArchFromAuditToken(token uint32) (ScmpArch, error) {
+	for _, arch := range AllArches() {
+		if archProperties[arch].audit == token {
+			return arch, nil
+		}
+	}
+
+	return ArchInvalid, fmt.Errorf("unknown audit architecture %#x", token)
+}
+
+// AllArches returns all the architectures known to the bindings, without
+// ArchNative, in the order of their constants. Some may not be supported by
+// the linked libseccomp, see IsSupported and SupportedArches.
+func AllArches() []ScmpArch {
+	arches := make([]ScmpArch, 0, archEnd-archStart)
+	for a := archStart + 1; a <= archEnd; a++ {
+		arches = append(arches, a)
+	}
+
+	return arches
+}
+
+// SupportedArches returns the architectures of AllArches which the linked
+// libseccomp supports. Note that libseccomp cannot combine architectures of
+// different endianness in a filter.
+func SupportedArches() []ScmpArch {
+	var arches []ScmpArch
+	for _, a := range AllArches() {
+		if a.IsSupported() {
+			arches = append(arches, a)
+		}
+	}
+
+	return arches
+}
+
+// IsSupported checks whether the linked libseccomp supports the
+// architecture. ArchNative is always supported.
+func (a ScmpArch) IsSupported() bool {
+	return sanitizeArch(a) == nil
+}
+
+// AddArchFamily adds an architecture to the filter along with its compat
+// architectures, whose syscalls programs can also make on kernels of the
+// architecture: ArchX86 and ArchX32 for ArchAMD64, ArchARM for ArchARM64,
+// the 32-bit and N32 ABIs for the MIPS64 architectures, ArchPPC for
+// ArchPPC64, ArchS390 for ArchS390X and ArchPARISC for ArchPARISC64. Leaving
+// compat architectures out of a filter which otherwise allows their syscalls
+// makes it bypassable with 32-bit syscalls, unless the bad arch action
+// denies them. Other architectures are added alone. ArchNative is resolved
+// to the native architecture of the kernel.
+// Returns an error on invalid filter context or architecture token, or an
+// issue with the call to libseccomp.
+func (f *ScmpFilter) AddArchFamily(arch ScmpArch) error {
+	if err := sanitizeArch(arch); err != nil {
+		return err
+	}
+	arch, err := resolveNativeArch(arch)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range append([]ScmpArch{arch}, compatArches[arch]...) {
+		if err := f.AddArch(a); err != nil {
+			return fmt.Errorf("could not add architecture %s: %v", a, err)
+		}
+	}
+
+	return nil
+}
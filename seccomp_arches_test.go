@@ -0,0 +1,346 @@
+// +build linux
+
+// Tests for the architecture helpers of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"encoding/binary"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGOARCH(t *testing.T) {
+	for _, test := range []struct {
+		goarch string
+		arch   ScmpArch
+	}{
+		{"386", ArchX86},
+		{"amd64", ArchAMD64},
+		{"arm", ArchARM},
+		{"arm64", ArchARM64},
+		{"mipsle", ArchMIPSEL},
+		{"mips64", ArchMIPS64},
+		{"ppc64le", ArchPPC64LE},
+		{"s390x", ArchS390X},
+	} {
+		arch, err := GetArchFromGOARCH(test.goarch)
+		if err != nil || arch != test.arch {
+			t.Errorf("GOARCH %s: got %s, %v, want %s", test.goarch, arch, err, test.arch)
+		}
+		goarch, err := test.arch.GOARCH()
+		if err != nil || goarch != test.goarch {
+			t.Errorf("Architecture %s: got GOARCH %q, %v, want %q", test.arch, goarch, err, test.goarch)
+		}
+	}
+
+	for _, goarch := range []string{"wasm", "x86", "amd64p32"} {
+		if _, err := GetArchFromGOARCH(goarch); err == nil {
+			t.Errorf("GOARCH %s should have no seccomp architecture", goarch)
+		}
+	}
+	for _, arch := range []ScmpArch{ArchX32, ArchMIPS64N32, ArchInvalid} {
+		if _, err := arch.GOARCH(); err == nil {
+			t.Errorf("Architecture %s should have no GOARCH", arch)
+		}
+	}
+
+	arch, err := GetArchFromGOARCH("")
+	if want, _ := GetArchFromGOARCH(runtime.GOARCH); err != nil || arch != want {
+		t.Errorf("Empty GOARCH should stand for %s: got %s, %v", runtime.GOARCH, arch, err)
+	}
+}
+
+func TestAllArches(t *testing.T) {
+	arches := AllArches()
+	if len(arches) == 0 || arches[0] != ArchX86 {
+		t.Fatalf("Got architectures %v", arches)
+	}
+	seen := make(map[ScmpArch]bool)
+	for _, arch := range arches {
+		if arch == ArchNative || arch == ArchInvalid || seen[arch] {
+			t.Errorf("Unexpected architecture %s in %v", arch, arches)
+		}
+		seen[arch] = true
+
+		if _, err := GetArchFromString(arch.String()); err != nil {
+			t.Errorf("Architecture %s should be parsable: %s", arch, err)
+		}
+	}
+
+	native, err := GetNativeArch()
+	if err != nil {
+		t.Fatalf("Error getting native architecture: %s", err)
+	}
+	supported := SupportedArches()
+	found := false
+	for _, arch := range supported {
+		if !seen[arch] || !arch.IsSupported() {
+			t.Errorf("Supported architecture %s should be in AllArches and supported", arch)
+		}
+		found = found || arch == native
+
+		if _, err := GetSyscallFromNameByArch("exit", arch); err != nil {
+			t.Errorf("Error resolving syscall on supported architecture %s: %s", arch, err)
+		}
+	}
+	if !found {
+		t.Errorf("Native architecture %s should be supported", native)
+	}
+
+	if !ArchNative.IsSupported() || ArchInvalid.IsSupported() || ScmpArch(0xff).IsSupported() {
+		t.Errorf("Got unexpected support of native, invalid or unknown architectures")
+	}
+}
+
+func TestAddArchFamily(t *testing.T) {
+	native, err := GetNativeArch()
+	if err != nil {
+		t.Fatalf("Error getting native architecture: %s", err)
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.AddArchFamily(ArchNative); err != nil {
+		t.Fatalf("Error adding native architecture family: %s", err)
+	}
+	for _, arch := range append([]ScmpArch{native}, compatArches[native]...) {
+		if present, err := filter.IsArchPresent(arch); err != nil || !present {
+			t.Errorf("Architecture %s should be present", arch)
+		}
+	}
+
+	// Adding the family again is not an error
+	if err := filter.AddArchFamily(native); err != nil {
+		t.Errorf("Error adding native architecture family again: %s", err)
+	}
+	if err := filter.AddArchFamily(ArchInvalid); err == nil {
+		t.Errorf("Adding an invalid architecture family should fail")
+	}
+
+	if native != ArchAMD64 {
+		return
+	}
+	for _, arch := range []ScmpArch{ArchX86, ArchX32} {
+		if present, _ := filter.IsArchPresent(arch); !present {
+			t.Errorf("Compat architecture %s of amd64 should be present", arch)
+		}
+	}
+}
+
+func TestArchLOONGARCH64(t *testing.T) {
+	for _, name := range []string{"loongarch64", "loong64", "LOONGARCH64"} {
+		if arch, err := GetArchFromString(name); err != nil || arch != ArchLOONGARCH64 {
+			t.Errorf("Architecture %q: got %s, %v", name, arch, err)
+		}
+	}
+	if s := ArchLOONGARCH64.String(); s != "loongarch64" {
+		t.Errorf("Got architecture string %q", s)
+	}
+	if arch, err := GetArchFromGOARCH("loong64"); err != nil || arch != ArchLOONGARCH64 {
+		t.Errorf("GOARCH loong64: got %s, %v", arch, err)
+	}
+	if name, err := ociArchToString(ArchLOONGARCH64); err != nil || name != "SCMP_ARCH_LOONGARCH64" {
+		t.Errorf("Got OCI architecture %q, %v", name, err)
+	}
+
+	if !ArchLOONGARCH64.IsSupported() {
+		t.Skipf("Skipping test: LoongArch is not supported by the library")
+	}
+	// LoongArch uses the generic syscall table
+	if call, err := GetSyscallFromNameByArch("openat", ArchLOONGARCH64); err != nil || call != 56 {
+		t.Errorf("Got openat syscall number %d, %v on loongarch64", call, err)
+	}
+}
+
+func TestArchRISCV(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		arch ScmpArch
+	}{
+		{"riscv64", ArchRISCV64},
+		{"RISCV64", ArchRISCV64},
+		{"riscv32", ArchRISCV32},
+	} {
+		if arch, err := GetArchFromString(test.name); err != nil || arch != test.arch {
+			t.Errorf("Architecture %q: got %s, %v", test.name, arch, err)
+		}
+		if s := test.arch.String(); s != strings.ToLower(test.name) {
+			t.Errorf("Got architecture string %q for %q", s, test.name)
+		}
+	}
+	if arch, err := GetArchFromGOARCH("riscv64"); err != nil || arch != ArchRISCV64 {
+		t.Errorf("GOARCH riscv64: got %s, %v", arch, err)
+	}
+	if _, err := ArchRISCV32.GOARCH(); err == nil {
+		t.Errorf("Go has no port for riscv32")
+	}
+	if name, err := ociArchToString(ArchRISCV64); err != nil || name != "SCMP_ARCH_RISCV64" {
+		t.Errorf("Got OCI architecture %q, %v", name, err)
+	}
+
+	// RISC-V uses the generic syscall table
+	for _, arch := range []ScmpArch{ArchRISCV64, ArchRISCV32} {
+		if !arch.IsSupported() {
+			t.Logf("Architecture %s is not supported by the library", arch)
+			continue
+		}
+		if call, err := GetSyscallFromNameByArch("openat", arch); err != nil || call != 56 {
+			t.Errorf("Got openat syscall number %d, %v on %s", call, err, arch)
+		}
+	}
+}
+
+func TestArchM68K(t *testing.T) {
+	if arch, err := GetArchFromString("m68k"); err != nil || arch != ArchM68K {
+		t.Errorf("Architecture m68k: got %s, %v", arch, err)
+	}
+	if s := ArchM68K.String(); s != "m68k" {
+		t.Errorf("Got architecture string %q", s)
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if !ArchM68K.IsSupported() {
+		if err := filter.AddArch(ArchM68K); err == nil {
+			t.Errorf("Adding an unsupported architecture should fail")
+		}
+		t.Skipf("Skipping test: m68k is not supported by the library")
+	}
+	if call, err := GetSyscallFromNameByArch("exit", ArchM68K); err != nil || call != 1 {
+		t.Errorf("Got exit syscall number %d, %v on m68k", call, err)
+	}
+}
+
+func TestArchSH(t *testing.T) {
+	for _, arch := range []ScmpArch{ArchSH, ArchSHEB} {
+		if a, err := GetArchFromString(arch.String()); err != nil || a != arch {
+			t.Errorf("Architecture %q: got %s, %v", arch.String(), a, err)
+		}
+	}
+	if ArchSH.String() != "sh" || ArchSHEB.String() != "sheb" {
+		t.Errorf("Got architecture strings %q and %q", ArchSH, ArchSHEB)
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if !ArchSH.IsSupported() {
+		if err := filter.AddArch(ArchSH); err == nil {
+			t.Errorf("Adding an unsupported architecture should fail")
+		}
+		t.Skipf("Skipping test: SuperH is not supported by the library")
+	}
+	if call, err := GetSyscallFromNameByArch("exit", ArchSH); err != nil || call != 1 {
+		t.Errorf("Got exit syscall number %d, %v on sh", call, err)
+	}
+
+	// Little-endian SuperH can be combined with the native architecture of
+	// little-endian hosts
+	if runtime.GOARCH != "amd64" {
+		return
+	}
+	if err := filter.AddArch(ArchSH); err != nil {
+		t.Errorf("Error adding architecture: %s", err)
+	} else if present, err := filter.IsArchPresent(ArchSH); err != nil || !present {
+		t.Errorf("Architecture should be present: %t, %v", present, err)
+	} else if err := filter.RemoveArch(ArchSH); err != nil {
+		t.Errorf("Error removing architecture: %s", err)
+	}
+}
+
+func TestArchProperties(t *testing.T) {
+	for _, arch := range AllArches() {
+		if bits, err := arch.BitWidth(); err != nil || (bits != 32 && bits != 64) {
+			t.Errorf("Got bit width %d, %v for %s", bits, err, arch)
+		}
+		if order, err := arch.Endianness(); err != nil || order == nil {
+			t.Errorf("Got endianness %v, %v for %s", order, err, arch)
+		}
+	}
+
+	for _, test := range []struct {
+		arch  ScmpArch
+		bits  int
+		order binary.ByteOrder
+	}{
+		{ArchAMD64, 64, binary.LittleEndian},
+		{ArchX86, 32, binary.LittleEndian},
+		{ArchX32, 32, binary.LittleEndian},
+		{ArchMIPS64N32, 32, binary.BigEndian},
+		{ArchPPC64, 64, binary.BigEndian},
+		{ArchPPC64LE, 64, binary.LittleEndian},
+		{ArchS390, 32, binary.BigEndian},
+		{ArchSHEB, 32, binary.BigEndian},
+	} {
+		bits, _ := test.arch.BitWidth()
+		order, _ := test.arch.Endianness()
+		if bits != test.bits || order != test.order {
+			t.Errorf("Got %d-bit %v for %s, want %d-bit %v", bits, order, test.arch, test.bits, test.order)
+		}
+	}
+
+	if _, err := ArchInvalid.BitWidth(); err == nil {
+		t.Errorf("Invalid architecture should have no bit width")
+	}
+	if _, err := ArchInvalid.Endianness(); err == nil {
+		t.Errorf("Invalid architecture should have no endianness")
+	}
+
+	if runtime.GOARCH == "amd64" {
+		if bits, err := ArchNative.BitWidth(); err != nil || bits != 64 {
+			t.Errorf("Got native bit width %d, %v", bits, err)
+		}
+	}
+}
+
+func TestAuditToken(t *testing.T) {
+	for _, arch := range AllArches() {
+		token := arch.AuditToken()
+		if token == 0 {
+			t.Errorf("Got no audit token for %s", arch)
+			continue
+		}
+
+		got, err := ArchFromAuditToken(token)
+		if err != nil {
+			t.Errorf("Error converting audit token %#x of %s: %s", token, arch, err)
+		} else if got != arch && arch != ArchX32 {
+			t.Errorf("Audit token %#x of %s converts to %s", token, arch, got)
+		}
+
+		// Check the tokens against those libseccomp uses, which differ for
+		// ArchX32
+		if arch != ArchX32 && arch.IsSupported() {
+			if got, err := archFromAudit(token); err != nil || got != arch {
+				t.Errorf("libseccomp converts audit token %#x of %s to %s, %v", token, arch, got, err)
+			}
+		}
+	}
+
+	const auditArchX8664 = 0xc000003e
+	if arch, err := ArchFromAuditToken(auditArchX8664); err != nil || arch != ArchAMD64 {
+		t.Errorf("Got %s, %v for AUDIT_ARCH_X86_64", arch, err)
+	}
+	if arch, err := ArchFromAuditToken(0xc0000102); err != nil || arch != ArchLOONGARCH64 {
+		t.Errorf("Got %s, %v for AUDIT_ARCH_LOONGARCH64", arch, err)
+	}
+	if _, err := ArchFromAuditToken(0xdeadbeef); err == nil {
+		t.Errorf("Unknown audit token should fail")
+	}
+	if token := ArchInvalid.AuditToken(); token != 0 {
+		t.Errorf("Got audit token %#x for invalid architecture", token)
+	}
+}
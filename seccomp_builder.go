@@ -0,0 +1,180 @@
+// +build linux
+
+// Profile builder for libseccomp Go bindings
+// Describes filters with chained calls instead of individual rules
+
+package seccomp
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// ProfileBuilder describes a filter with chained calls, e.g.
+//
+//	filter, err := seccomp.Profile().
+//		DefaultErrno(unix.EPERM).
+//		Allow("read", "write", "futex").
+//		Deny("ptrace").
+//		NotifyOn("openat").
+//		Build()
+//
+// Errors, such as unknown syscall names, are reported by Build. Rules taking
+// the default action are skipped, as libseccomp rejects them. A
+// ProfileBuilder is not safe for concurrent use.
+type ProfileBuilder struct {
+	defaultAction ScmpAction
+	badArchAction ScmpAction
+	arches        []ScmpArch
+	noNewPrivs    bool
+	rules         []profileRule
+}
+
+// Rule of a ProfileBuilder, on a syscall by name
+type profileRule struct {
+	name   string
+	action ScmpAction
+	conds  []ScmpCondition
+}
+
+// Profile returns a builder for a filter of the native architecture which
+// denies all syscalls with EPERM, until rules are added.
+func Profile() *ProfileBuilder {
+	return &ProfileBuilder{
+		defaultAction: ActEPerm,
+		badArchAction: ActKillThread,
+		noNewPrivs:    true,
+	}
+}
+
+// DefaultAction sets the action taken on syscalls which match no rule.
+func (b *ProfileBuilder) DefaultAction(action ScmpAction) *ProfileBuilder {
+	b.defaultAction = action
+	return b
+}
+
+// DefaultErrno makes syscalls which match no rule fail with errno.
+func (b *ProfileBuilder) DefaultErrno(errno unix.Errno) *ProfileBuilder {
+	return b.DefaultAction(ActErrno.SetErrno(errno))
+}
+
+// BadArchAction sets the action taken on syscalls of architectures not in
+// the filter, ActKillThread by default.
+func (b *ProfileBuilder) BadArchAction(action ScmpAction) *ProfileBuilder {
+	b.badArchAction = action
+	return b
+}
+
+// Arches sets the architectures of the filter, replacing the native one.
+func (b *ProfileBuilder) Arches(arches ...ScmpArch) *ProfileBuilder {
+	b.arches = append([]ScmpArch(nil), arches...)
+	return b
+}
+
+// NoNewPrivs sets whether the no new privileges bit is set when the filter
+// is loaded, which is the default.
+func (b *ProfileBuilder) NoNewPrivs(state bool) *ProfileBuilder {
+	b.noNewPrivs = state
+	return b
+}
+
+// Allow allows the syscalls names.
+func (b *ProfileBuilder) Allow(names ...string) *ProfileBuilder {
+	return b.On(ActAllow, names...)
+}
+
+// Deny makes the syscalls names fail with EPERM.
+func (b *ProfileBuilder) Deny(names ...string) *ProfileBuilder {
+	return b.On(ActEPerm, names...)
+}
+
+// Errno makes the syscalls names fail with errno.
+func (b *ProfileBuilder) Errno(errno unix.Errno, names ...string) *ProfileBuilder {
+	return b.On(ActErrno.SetErrno(errno), names...)
+}
+
+// Log allows and logs the syscalls names.
+func (b *ProfileBuilder) Log(names ...string) *ProfileBuilder {
+	return b.On(ActLog, names...)
+}
+
+// Kill kills the process on the syscalls names.
+func (b *ProfileBuilder) Kill(names ...string) *ProfileBuilder {
+	return b.On(ActKillProcess, names...)
+}
+
+// NotifyOn sends a userspace notification for the syscalls names.
+func (b *ProfileBuilder) NotifyOn(names ...string) *ProfileBuilder {
+	return b.On(ActNotify, names...)
+}
+
+// On takes action on the syscalls names.
+func (b *ProfileBuilder) On(action ScmpAction, names ...string) *ProfileBuilder {
+	for _, name := range names {
+		b.rules = append(b.rules, profileRule{name: name, action: action})
+	}
+	return b
+}
+
+// OnIf takes action on the syscall name when all the conditions match, e.g.
+// to allow a syscall for some arguments only.
+func (b *ProfileBuilder) OnIf(action ScmpAction, name string, conds ...ScmpCondition) *ProfileBuilder {
+	b.rules = append(b.rules, profileRule{
+		name:   name,
+		action: action,
+		conds:  append([]ScmpCondition(nil), conds...),
+	})
+	return b
+}
+
+// Build creates a new filter from the description.
+// Returns the filter, which is not loaded, or an error if a syscall name is
+// unknown, or the filter could not be created.
+func (b *ProfileBuilder) Build() (*ScmpFilter, error) {
+	var filter *ScmpFilter
+	var err error
+	if len(b.arches) == 0 {
+		filter, err = NewFilter(b.defaultAction)
+	} else {
+		filter, err = NewFilterWithArches(b.defaultAction, b.arches...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.apply(filter); err != nil {
+		filter.Release()
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// Helper - Apply the attributes and rules of the description to a filter
+func (b *ProfileBuilder) apply(filter *ScmpFilter) error {
+	if err := filter.SetBadArchAction(b.badArchAction); err != nil {
+		return fmt.Errorf("could not set bad arch action: %v", err)
+	}
+	if err := filter.SetNoNewPrivsBit(b.noNewPrivs); err != nil {
+		return fmt.Errorf("could not set no new privileges bit: %v", err)
+	}
+
+	for _, rule := range b.rules {
+		call, err := GetSyscallFromName(rule.name)
+		if err != nil {
+			return syscallNameError(err, rule.name, ArchNative)
+		}
+
+		// libseccomp refuses rules matching the default action
+		if rule.action == b.defaultAction {
+			continue
+		}
+
+		if err := filter.AddRuleConditional(call, rule.action, rule.conds); err != nil {
+			return fmt.Errorf("could not add rule for %q: %v", rule.name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+// +build linux
+
+// Tests for the profile builder of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestProfileBuilder(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: filter program is checked for amd64")
+	}
+	const auditArchX8664 = 0xc000003e
+
+	personality, err := MakeCondition(0, CompareEqual, 0)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+
+	filter, err := Profile().
+		DefaultErrno(unix.EPERM).
+		Allow("read", "write", "futex").
+		Deny("ptrace").
+		NotifyOn("openat").
+		Errno(unix.EACCES, "chdir").
+		OnIf(ActAllow, "personality", personality).
+		BadArchAction(ActKillProcess).
+		Build()
+	if err != nil {
+		t.Fatalf("Error building profile: %s", err)
+	}
+	defer filter.Release()
+
+	if action, err := filter.GetBadArchAction(); err != nil || action != ActKillProcess {
+		t.Errorf("Got bad arch action %s, %v", action, err)
+	}
+	if nnp, err := filter.GetNoNewPrivsBit(); err != nil || !nnp {
+		t.Errorf("No new privileges bit should be set")
+	}
+
+	prog := exportProgram(t, filter)
+	for _, test := range []struct {
+		nr   int32
+		arg  uint64
+		want uint32
+	}{
+		{unix.SYS_READ, 0, bpf.RetAllow},
+		{unix.SYS_FUTEX, 0, bpf.RetAllow},
+		{unix.SYS_PTRACE, 0, bpf.RetErrno | uint32(unix.EPERM)},
+		{unix.SYS_OPENAT, 0, bpf.RetUserNotif},
+		{unix.SYS_CHDIR, 0, bpf.RetErrno | uint32(unix.EACCES)},
+		{unix.SYS_PERSONALITY, 0, bpf.RetAllow},
+		{unix.SYS_PERSONALITY, 1, bpf.RetErrno | uint32(unix.EPERM)},
+		{unix.SYS_GETPID, 0, bpf.RetErrno | uint32(unix.EPERM)},
+	} {
+		ret, err := bpf.Run(prog, &bpf.Data{Nr: test.nr, Arch: auditArchX8664, Args: [6]uint64{test.arg}})
+		if err != nil {
+			t.Fatalf("Error running exported filter: %s", err)
+		}
+		if ret != test.want {
+			t.Errorf("Syscall %d(%d): got %s, want %s", test.nr, test.arg,
+				bpf.ActionString(ret), bpf.ActionString(test.want))
+		}
+	}
+}
+
+func TestProfileBuilderOptions(t *testing.T) {
+	filter, err := Profile().
+		DefaultAction(ActAllow).
+		Arches(ArchNative, ArchX86).
+		NoNewPrivs(false).
+		Kill("kexec_load").
+		Log("getpid").
+		Build()
+	if err != nil {
+		t.Fatalf("Error building profile: %s", err)
+	}
+	defer filter.Release()
+
+	if present, err := filter.IsArchPresent(ArchX86); err != nil || !present {
+		t.Errorf("Architecture %s should be present", ArchX86)
+	}
+	if nnp, err := filter.GetNoNewPrivsBit(); err != nil || nnp {
+		t.Errorf("No new privileges bit should not be set")
+	}
+	if rules := filter.ListRules(); len(rules) != 2 {
+		t.Errorf("Got %d rules, want 2", len(rules))
+	}
+
+	if filter, err := Profile().Allow("not_a_real_syscall").Build(); err == nil {
+		filter.Release()
+		t.Errorf("Building a profile with an unknown syscall should fail")
+	}
+}
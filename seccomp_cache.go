@@ -0,0 +1,141 @@
+// +build linux
+
+// Syscall lookup cache for libseccomp Go bindings
+// Remembers syscall name and number lookups to spare calls into libseccomp
+
+package seccomp
+
+import (
+	"sync"
+)
+
+// Cached lookups, by architecture as passed to the lookup functions
+var syscallCache = struct {
+	lock    sync.RWMutex
+	enabled bool
+	// Incremented when the cache is cleared, so that lookups racing with
+	// RegisterSyscall are not cached
+	generation uint64
+	byName     map[syscallNameKey]syscallNameEntry
+	byNum      map[syscallNumKey]syscallNumEntry
+}{}
+
+// Key of a lookup by name
+type syscallNameKey struct {
+	arch ScmpArch
+	name string
+}
+
+// Key of a lookup by number
+type syscallNumKey struct {
+	arch ScmpArch
+	call ScmpSyscall
+}
+
+// Result of a lookup by name, ok being false for unknown syscalls
+type syscallNameEntry struct {
+	call ScmpSyscall
+	ok   bool
+}
+
+// Result of a lookup by number, ok being false for unknown syscalls
+type syscallNumEntry struct {
+	name string
+	ok   bool
+}
+
+// SetSyscallCache sets whether the lookups of GetSyscallFromName,
+// GetSyscallFromNameByArch, GetName and GetNameByArch are cached in the
+// process, sparing a call into libseccomp for repeated lookups, e.g. when
+// naming the syscalls of notifications. The tables of libseccomp do not
+// change while it is loaded, and registering supplemental syscalls with
+// RegisterSyscall clears the cache, so cached lookups always return what
+// libseccomp would. Disabling the cache empties it.
+// Defaults to off.
+func SetSyscallCache(enabled bool) {
+	syscallCache.lock.Lock()
+	defer syscallCache.lock.Unlock()
+
+	syscallCache.enabled = enabled
+	syscallCache.generation++
+	syscallCache.byName = nil
+	syscallCache.byNum = nil
+	if enabled {
+		syscallCache.byName = make(map[syscallNameKey]syscallNameEntry)
+		syscallCache.byNum = make(map[syscallNumKey]syscallNumEntry)
+	}
+}
+
+// Helper - Clear the cached lookups, e.g. when supplemental syscalls change
+func clearSyscallCache() {
+	syscallCache.lock.Lock()
+	defer syscallCache.lock.Unlock()
+
+	syscallCache.generation++
+	if syscallCache.enabled {
+		syscallCache.byName = make(map[syscallNameKey]syscallNameEntry)
+		syscallCache.byNum = make(map[syscallNumKey]syscallNumEntry)
+	}
+}
+
+// Helper - Look a syscall number up by name through the cache, calling
+// resolve on misses
+// Only successful lookups and unknown syscalls are cached
+func cachedSyscall(arch ScmpArch, name string, resolve func() (ScmpSyscall, error)) (ScmpSyscall, error) {
+	key := syscallNameKey{arch: arch, name: name}
+
+	syscallCache.lock.RLock()
+	enabled := syscallCache.enabled
+	generation := syscallCache.generation
+	entry, found := syscallCache.byName[key]
+	syscallCache.lock.RUnlock()
+
+	if found {
+		if !entry.ok {
+			return 0, ErrSyscallDoesNotExist
+		}
+		return entry.call, nil
+	}
+
+	call, err := resolve()
+	if enabled && (err == nil || err == ErrSyscallDoesNotExist) {
+		syscallCache.lock.Lock()
+		if syscallCache.generation == generation {
+			syscallCache.byName[key] = syscallNameEntry{call: call, ok: err == nil}
+		}
+		syscallCache.lock.Unlock()
+	}
+
+	return call, err
+}
+
+// Helper - Look a syscall name up by number through the cache, calling
+// resolve on misses
+// Only successful lookups and unknown syscalls are cached
+func cachedSyscallName(arch ScmpArch, call ScmpSyscall, resolve func() (string, error)) (string, error) {
+	key := syscallNumKey{arch: arch, call: call}
+
+	syscallCache.lock.RLock()
+	enabled := syscallCache.enabled
+	generation := syscallCache.generation
+	entry, found := syscallCache.byNum[key]
+	syscallCache.lock.RUnlock()
+
+	if found {
+		if !entry.ok {
+			return "", ErrSyscallDoesNotExist
+		}
+		return entry.name, nil
+	}
+
+	name, err := resolve()
+	if enabled && (err == nil || err == ErrSyscallDoesNotExist) {
+		syscallCache.lock.Lock()
+		if syscallCache.generation == generation {
+			syscallCache.byNum[key] = syscallNumEntry{name: name, ok: err == nil}
+		}
+		syscallCache.lock.Unlock()
+	}
+
+	return name, err
+}
@@ -0,0 +1,61 @@
+// +build linux
+
+// Tests for the syscall lookup cache of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"testing"
+)
+
+func TestSyscallCache(t *testing.T) {
+	SetSyscallCache(true)
+	defer SetSyscallCache(false)
+
+	for i := 0; i < 2; i++ {
+		if call, err := GetSyscallFromNameByArch("write", ArchAMD64); err != nil || call != 1 {
+			t.Errorf("Got write as %d, %v on amd64", call, err)
+		}
+		if name, err := ScmpSyscall(1).GetNameByArch(ArchAMD64); err != nil || name != "write" {
+			t.Errorf("Got syscall 1 as %q, %v on amd64", name, err)
+		}
+		if _, err := GetSyscallFromName("NOTASYSCALL"); err != ErrSyscallDoesNotExist {
+			t.Errorf("Got %v for an unknown syscall", err)
+		}
+		if _, err := GetSyscallFromNameByArch("write", ArchInvalid); err == nil {
+			t.Errorf("Getting valid syscall for invalid arch should error")
+		}
+	}
+	if len(syscallCache.byName) != 2 || len(syscallCache.byNum) != 1 {
+		t.Errorf("Got %d lookups by name and %d by number cached, want 2 and 1",
+			len(syscallCache.byName), len(syscallCache.byNum))
+	}
+
+	// Registering syscalls invalidates unknown syscalls
+	const call = ScmpSyscall(4002)
+	const name = "cached_test_syscall"
+	if _, err := GetSyscallFromName(name); err == nil {
+		t.Fatalf("Syscall %s should be unknown", name)
+	}
+	if _, err := call.GetName(); err == nil {
+		t.Fatalf("Syscall %d should be unknown", call)
+	}
+	if err := RegisterSyscall(ArchNative, name, call); err != nil {
+		t.Fatalf("Error registering syscall: %s", err)
+	}
+	defer unregisterSyscall(ArchNative, name)
+	if got, err := GetSyscallFromName(name); err != nil || got != call {
+		t.Errorf("Got %d, %v for registered syscall, want %d", got, err, call)
+	}
+	if got, err := call.GetName(); err != nil || got != name {
+		t.Errorf("Got %q, %v for registered syscall, want %q", got, err, name)
+	}
+
+	SetSyscallCache(false)
+	if syscallCache.byName != nil || syscallCache.byNum != nil {
+		t.Errorf("Disabling the cache should empty it")
+	}
+	if call, err := GetSyscallFromNameByArch("write", ArchAMD64); err != nil || call != 1 {
+		t.Errorf("Got write as %d, %v on amd64 without cache", call, err)
+	}
+}
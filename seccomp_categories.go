@@ -0,0 +1,158 @@
+// +build linux
+
+// Syscall categories for libseccomp Go bindings
+// Groups syscalls by function, for policies written per category
+
+package seccomp
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SyscallCategoriesKernel is the version of Linux whose syscalls the
+// categories are up to date with. Syscalls added in later versions are in
+// no category.
+const SyscallCategoriesKernel = "6.6"
+
+// SyscallCategory is a functional category of syscalls, such as the syscalls
+// reading files. Unlike syscall sets, categories do not include each other,
+// but a syscall may be in several categories (e.g., openat both reads and
+// writes files).
+type SyscallCategory string
+
+const (
+	// CategoryFileRead are the syscalls opening, reading and inspecting files
+	CategoryFileRead SyscallCategory = "file-read"
+	// CategoryFileWrite are the syscalls opening, writing, creating and
+	// changing files
+	CategoryFileWrite SyscallCategory = "file-write"
+	// CategoryNetwork are the syscalls of sockets
+	CategoryNetwork SyscallCategory = "network"
+	// CategoryProcess are the syscalls creating, executing, inspecting and
+	// waiting for processes
+	CategoryProcess SyscallCategory = "process"
+	// CategorySignals are the syscalls sending and handling signals
+	CategorySignals SyscallCategory = "signals"
+	// CategoryTimers are the syscalls reading clocks, sleeping and managing
+	// timers
+	CategoryTimers SyscallCategory = "timers"
+	// CategoryIOUring are the syscalls of io_uring
+	CategoryIOUring SyscallCategory = "io_uring"
+	// CategoryMemory are the syscalls mapping and managing memory
+	CategoryMemory SyscallCategory = "memory"
+)
+
+// Syscalls of the categories, sorted by name
+var syscallCategories = map[SyscallCategory][]string{
+	CategoryFileRead: {
+		"_llseek", "access", "arm_fadvise64_64", "close", "close_range",
+		"faccessat", "faccessat2", "fadvise64", "fadvise64_64", "fcntl",
+		"fcntl64", "fgetxattr", "flistxattr", "flock", "fstat", "fstat64",
+		"fstatat64", "fstatfs", "fstatfs64", "getcwd", "getdents",
+		"getdents64", "getxattr", "lgetxattr", "listxattr", "llistxattr",
+		"lseek", "lstat", "lstat64", "name_to_handle_at", "newfstatat",
+		"oldfstat", "oldlstat", "oldstat", "open", "openat", "openat2",
+		"pread64", "preadv", "preadv2", "read", "readahead", "readdir",
+		"readlink", "readlinkat", "readv", "stat", "stat64", "statfs",
+		"statfs64", "statx",
+	},
+	CategoryFileWrite: {
+		"chmod", "chown", "chown32", "close", "close_range", "copy_file_range",
+		"creat", "fallocate", "fchmod", "fchmodat", "fchmodat2", "fchown",
+		"fchown32", "fchownat", "fdatasync", "fremovexattr", "fsetxattr",
+		"fsync", "ftruncate", "ftruncate64", "futimesat", "lchown", "lchown32",
+		"link", "linkat", "lremovexattr", "lsetxattr", "mkdir", "mkdirat",
+		"mknod", "mknodat", "open", "openat", "openat2", "pwrite64", "pwritev",
+		"pwritev2", "removexattr", "rename", "renameat", "renameat2", "rmdir",
+		"setxattr", "symlink", "symlinkat", "sync", "sync_file_range",
+		"sync_file_range2", "syncfs", "truncate", "truncate64", "umask",
+		"unlink", "unlinkat", "utime", "utimensat", "utimensat_time64",
+		"utimes", "write", "writev",
+	},
+	CategoryNetwork: {
+		"accept", "accept4", "bind", "connect", "getpeername", "getsockname",
+		"getsockopt", "listen", "recv", "recvfrom", "recvmmsg",
+		"recvmmsg_time64", "recvmsg", "send", "sendmmsg", "sendmsg", "sendto",
+		"setsockopt", "shutdown", "socket", "socketcall", "socketpair",
+	},
+	CategoryProcess: {
+		"arch_prctl", "capget", "clone", "clone3", "execve", "execveat", "exit",
+		"exit_group", "fork", "getegid", "getegid32", "geteuid", "geteuid32",
+		"getgid", "getgid32", "getgroups", "getgroups32", "getpgid", "getpgrp",
+		"getpid", "getppid", "getpriority", "getresgid", "getresgid32",
+		"getresuid", "getresuid32", "getrlimit", "getrusage", "getsid",
+		"gettid", "getuid", "getuid32", "personality", "pidfd_getfd",
+		"pidfd_open", "prctl", "prlimit64", "sched_getaffinity",
+		"sched_yield", "set_tid_address", "setpgid", "setpriority",
+		"setrlimit", "setsid", "ugetrlimit", "vfork", "wait4", "waitid",
+		"waitpid",
+	},
+	CategorySignals: {
+		"kill", "pause", "pidfd_send_signal", "rt_sigaction", "rt_sigpending",
+		"rt_sigprocmask", "rt_sigqueueinfo", "rt_sigreturn", "rt_sigsuspend",
+		"rt_sigtimedwait", "rt_sigtimedwait_time64", "rt_tgsigqueueinfo",
+		"sigaction", "sigaltstack", "signal", "signalfd", "signalfd4",
+		"sigpending", "sigprocmask", "sigreturn", "sigsuspend", "tgkill",
+		"tkill",
+	},
+	CategoryTimers: {
+		"alarm", "clock_getres", "clock_getres_time64", "clock_gettime",
+		"clock_gettime64", "clock_nanosleep", "clock_nanosleep_time64",
+		"getitimer", "gettimeofday", "nanosleep", "setitimer", "time",
+		"timer_create", "timer_delete", "timer_getoverrun", "timer_gettime",
+		"timer_gettime64", "timer_settime", "timer_settime64",
+		"timerfd_create", "timerfd_gettime", "timerfd_gettime64",
+		"timerfd_settime", "timerfd_settime64", "times",
+	},
+	CategoryIOUring: {
+		"io_uring_enter", "io_uring_register", "io_uring_setup",
+	},
+	CategoryMemory: {
+		"brk", "get_mempolicy", "madvise", "map_shadow_stack", "mbind",
+		"membarrier", "memfd_create", "memfd_secret", "migrate_pages",
+		"mincore", "mlock", "mlock2", "mlockall", "mmap", "mmap2", "move_pages",
+		"mprotect", "mremap", "msync", "munlock", "munlockall", "munmap",
+		"pkey_alloc", "pkey_free", "pkey_mprotect", "process_madvise",
+		"remap_file_pages", "set_mempolicy", "set_mempolicy_home_node",
+		"userfaultfd",
+	},
+}
+
+// SyscallCategories returns all the known syscall categories, sorted by
+// name.
+func SyscallCategories() []SyscallCategory {
+	cats := make([]SyscallCategory, 0, len(syscallCategories))
+	for cat := range syscallCategories {
+		cats = append(cats, cat)
+	}
+	sort.Slice(cats, func(i, j int) bool { return cats[i] < cats[j] })
+
+	return cats
+}
+
+// SyscallsInCategory returns the names of the syscalls in the category,
+// sorted. Names may include syscalls which do not exist on every
+// architecture, or are unknown to libseccomp.
+// Returns an error if the category is unknown.
+func SyscallsInCategory(cat SyscallCategory) ([]string, error) {
+	names, ok := syscallCategories[cat]
+	if !ok {
+		return nil, fmt.Errorf("unknown syscall category %q", string(cat))
+	}
+
+	return append([]string(nil), names...), nil
+}
+
+// AddRuleCategory adds a single rule taking action on each syscall of the
+// category, as AddRule does. Syscalls of the category which are unknown to
+// libseccomp are skipped.
+// Returns an error if the category is unknown, or a rule could not be added.
+func (f *ScmpFilter) AddRuleCategory(cat SyscallCategory, action ScmpAction) error {
+	names, err := SyscallsInCategory(cat)
+	if err != nil {
+		return err
+	}
+
+	return f.addRuleNames(names, action, "category "+string(cat))
+}
@@ -0,0 +1,92 @@
+// +build linux
+
+// Tests for the syscall categories of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestSyscallCategories(t *testing.T) {
+	cats := SyscallCategories()
+	if len(cats) != 8 || !sort.SliceIsSorted(cats, func(i, j int) bool { return cats[i] < cats[j] }) {
+		t.Fatalf("Syscall categories should be listed sorted: %v", cats)
+	}
+
+	for _, cat := range cats {
+		names, err := SyscallsInCategory(cat)
+		if err != nil {
+			t.Fatalf("Error listing %s: %s", cat, err)
+		}
+		if len(names) == 0 || !sort.StringsAreSorted(names) {
+			t.Errorf("Syscalls of %s should be listed sorted", cat)
+		}
+		for i := 1; i < len(names); i++ {
+			if names[i] == names[i-1] {
+				t.Errorf("Syscalls of %s list %s twice", cat, names[i])
+			}
+		}
+
+		// The accessor returns copies
+		names[0] = "bogus"
+		if again, _ := SyscallsInCategory(cat); again[0] == "bogus" {
+			t.Errorf("Syscalls of %s should not be modifiable", cat)
+		}
+	}
+
+	if _, err := SyscallsInCategory("bogus"); err == nil {
+		t.Errorf("Listing an unknown category should fail")
+	}
+}
+
+func TestAddRuleCategory(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: filter program is checked for amd64")
+	}
+	const auditArchX8664 = 0xc000003e
+
+	filter, err := NewFilter(ActEPerm)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	for _, cat := range []SyscallCategory{CategoryFileRead, CategoryMemory, CategoryProcess} {
+		if err := filter.AddRuleCategory(cat, ActAllow); err != nil {
+			t.Fatalf("Error adding rules for %s: %s", cat, err)
+		}
+	}
+	if err := filter.AddRuleCategory(CategoryIOUring, ActENoSys); err != nil {
+		t.Fatalf("Error adding rules for %s: %s", CategoryIOUring, err)
+	}
+	if err := filter.AddRuleCategory("bogus", ActAllow); err == nil {
+		t.Errorf("Adding rules for an unknown category should fail")
+	}
+
+	prog := exportProgram(t, filter)
+	for _, test := range []struct {
+		nr   int32
+		want uint32
+	}{
+		{unix.SYS_READ, bpf.RetAllow},
+		{unix.SYS_MMAP, bpf.RetAllow},
+		{unix.SYS_EXECVE, bpf.RetAllow},
+		{unix.SYS_WRITE, bpf.RetErrno | uint32(unix.EPERM)},
+		{unix.SYS_SOCKET, bpf.RetErrno | uint32(unix.EPERM)},
+		{unix.SYS_IO_URING_SETUP, bpf.RetErrno | uint32(unix.ENOSYS)},
+	} {
+		ret, err := bpf.Run(prog, &bpf.Data{Nr: test.nr, Arch: auditArchX8664})
+		if err != nil {
+			t.Fatalf("Error running exported filter: %s", err)
+		}
+		if ret != test.want {
+			t.Errorf("Syscall %d: got %s, want %s", test.nr, bpf.ActionString(ret), bpf.ActionString(test.want))
+		}
+	}
+}
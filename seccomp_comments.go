@@ -0,0 +1,104 @@
+// +build linux
+
+// Rule comments for libseccomp Go bindings
+// Labels rules so exported filters can be traced back to their policy
+
+package seccomp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// AddRuleWithComment adds a single rule for a conditional action on a
+// syscall, as AddRuleConditional does, labelled with a comment such as the
+// policy line the rule was generated from. Comments are tracked with the
+// rule, see ListRules, written before the code of their syscall by ExportPFC
+// and reported by Report.
+// Returns an error if an issue was encountered adding the rule.
+func (f *ScmpFilter) AddRuleWithComment(call ScmpSyscall, action ScmpAction, conds []ScmpCondition, comment string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return ErrInvalidFilter
+	}
+
+	if feature, level := actionAPILevel(action); level != 0 {
+		if err := f.useAPI(feature, level); err != nil {
+			return err
+		}
+	}
+
+	if err := f.addRuleConds(call, action, false, conds); err != nil {
+		return err
+	}
+	f.trackRule(call, action, false, conds)
+	f.rules[len(f.rules)-1].Comment = comment
+
+	return nil
+}
+
+// Helper - Check whether any of the rules has a comment
+func hasComments(rules []ScmpRule) bool {
+	for _, rule := range rules {
+		if rule.Comment != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper - Insert the comments of the rules into PFC output, after the line
+// introducing the code of their syscall on each architecture
+func annotatePFC(pfc []byte, rules []ScmpRule, native ScmpArch) []byte {
+	var out bytes.Buffer
+	arch := ArchInvalid
+
+	scanner := bufio.NewScanner(bytes.NewReader(pfc))
+	for scanner.Scan() {
+		line := scanner.Text()
+		out.WriteString(line + "\n")
+
+		text := strings.TrimLeft(line, " ")
+		indent := line[:len(line)-len(text)]
+
+		var archName string
+		var audit uint32
+		if _, err := fmt.Sscanf(text, "# filter for arch %s (%d)", &archName, &audit); err == nil {
+			if arch, err = archFromAudit(audit); err != nil {
+				arch = ArchInvalid
+			}
+			continue
+		}
+
+		var name string
+		var call int32
+		if _, err := fmt.Sscanf(text, "# filter for syscall %q (%d)", &name, &call); err != nil || arch == ArchInvalid {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for _, rule := range rules {
+			if rule.Comment == "" || seen[rule.Comment] {
+				continue
+			}
+			if rule.Arch == ArchInvalid {
+				if ruleName, err := rule.Syscall.GetNameByArch(native); err != nil || ruleName != name {
+					continue
+				}
+			} else if rule.Arch != arch || int32(rule.Syscall) != call {
+				continue
+			}
+
+			seen[rule.Comment] = true
+			for _, commentLine := range strings.Split(rule.Comment, "\n") {
+				out.WriteString(indent + "# " + commentLine + "\n")
+			}
+		}
+	}
+
+	return out.Bytes()
+}
@@ -0,0 +1,90 @@
+// +build linux
+
+// Tests for the rule comments of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAddRuleWithComment(t *testing.T) {
+	filter, err := NewFilterWithArches(ActEPerm, ArchNative, ArchX86)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	native, err := GetNativeArch()
+	if err != nil {
+		t.Fatalf("Error getting native architecture: %s", err)
+	}
+	if native == ArchX86 {
+		t.Skipf("Skipping test: needs an architecture other than x86")
+	}
+
+	read, err := GetSyscallFromName("read")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	write, err := GetSyscallFromName("write")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	cond, err := MakeCondition(0, CompareEqual, 1)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+
+	if err := filter.AddRuleWithComment(read, ActAllow, nil, "policy.yaml:3 allow read"); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+	if err := filter.AddRuleWithComment(write, ActLog, []ScmpCondition{cond}, "policy.yaml:4 log stdout"); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	rules := filter.ListRules()
+	if len(rules) != 2 || rules[0].Comment != "policy.yaml:3 allow read" || rules[1].Comment != "policy.yaml:4 log stdout" {
+		t.Errorf("Comments should be tracked with the rules, got %+v", rules)
+	}
+
+	var pfc bytes.Buffer
+	if err := filter.ExportPFC(&pfc); err != nil {
+		t.Fatalf("Error exporting filter: %s", err)
+	}
+	for _, want := range []string{
+		"  # filter for syscall \"read\" (3) [priority: 65535]\n  # policy.yaml:3 allow read\n",
+		"\n  # policy.yaml:4 log stdout\n  if ($syscall == 4)\n",
+	} {
+		if !strings.Contains(pfc.String(), want) {
+			t.Errorf("PFC should contain %q:\n%s", want, pfc.String())
+		}
+	}
+	if n := strings.Count(pfc.String(), "# policy.yaml"); n != 4 {
+		t.Errorf("Comments should be written once per architecture, got %d:\n%s", n, pfc.String())
+	}
+
+	parsed, err := ParsePFC(&pfc)
+	if err != nil {
+		t.Fatalf("Error parsing annotated PFC: %s", err)
+	}
+	if len(parsed.Rules) == 0 {
+		t.Errorf("Annotated PFC should parse to rules")
+	}
+
+	r, err := filter.Report()
+	if err != nil {
+		t.Fatalf("Error reporting filter: %s", err)
+	}
+	comments := make(map[string]bool)
+	for _, group := range r.Groups {
+		for _, rule := range group.Rules {
+			comments[rule.Comment] = true
+		}
+	}
+	if !comments["policy.yaml:3 allow read"] || !comments["policy.yaml:4 log stdout"] {
+		t.Errorf("Report should carry the comments, got %+v", r.Groups)
+	}
+}
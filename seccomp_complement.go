@@ -0,0 +1,318 @@
+// +build linux
+
+// Condition complements for libseccomp Go bindings
+// Computes the conditions matching the arguments a set of rules does not match
+
+package seccomp
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Values of an argument whose bits under mask equal val
+type valueCube struct {
+	mask, val uint64
+}
+
+// Values of an argument, as disjoint cubes
+type valueSet []valueCube
+
+// Helper - Get all the values of an argument, up to limit
+func universeSet(limit uint64) valueSet {
+	return valueSet{{mask: ^limit}}
+}
+
+// Helper - Intersect two cubes
+// Returns false if the intersection is empty
+func (c valueCube) intersect(o valueCube) (valueCube, bool) {
+	if (c.val^o.val)&c.mask&o.mask != 0 {
+		return valueCube{}, false
+	}
+
+	return valueCube{mask: c.mask | o.mask, val: c.val | o.val}, true
+}
+
+// Helper - Subtract a cube from another, as disjoint cubes
+func (c valueCube) subtract(o valueCube) valueSet {
+	if _, ok := c.intersect(o); !ok {
+		return valueSet{c}
+	}
+
+	// One piece per bit fixed by o but not by c, differing from o in that bit
+	// and matching it in the higher bits, so that pieces of ranges are ranges
+	var pieces valueSet
+	fixed := c
+	for free := o.mask &^ c.mask; free != 0; {
+		bit := uint64(1) << (bits.Len64(free) - 1)
+		free &^= bit
+		pieces = append(pieces, valueCube{mask: fixed.mask | bit, val: fixed.val | ^o.val&bit})
+		fixed = valueCube{mask: fixed.mask | bit, val: fixed.val | o.val&bit}
+	}
+
+	return pieces
+}
+
+// Helper - Intersect two sets of values
+func (s valueSet) intersect(o valueSet) valueSet {
+	var result valueSet
+	for _, a := range s {
+		for _, b := range o {
+			if c, ok := a.intersect(b); ok {
+				result = append(result, c)
+			}
+		}
+	}
+
+	return result
+}
+
+// Helper - Subtract a set of values from another
+func (s valueSet) subtract(o valueSet) valueSet {
+	result := s
+	for _, b := range o {
+		var next valueSet
+		for _, a := range result {
+			next = append(next, a.subtract(b)...)
+		}
+		result = next
+	}
+
+	return result
+}
+
+// Helper - Decompose the values from lo to hi into aligned blocks
+func intervalSet(lo, hi uint64) valueSet {
+	var s valueSet
+	for {
+		// Grow the block at lo as long as it stays aligned and below hi
+		size := uint(0)
+		for size < 64 {
+			span := uint64(1)<<(size+1) - 1
+			if lo&span != 0 || hi-lo < span {
+				break
+			}
+			size++
+		}
+
+		span := uint64(1)<<size - 1
+		s = append(s, valueCube{mask: ^span, val: lo})
+		if hi-lo == span {
+			return s
+		}
+		lo += span + 1
+	}
+}
+
+// Helper - Get the values of an argument up to limit matching a condition,
+// whose operands are truncated to limit
+func conditionSet(cond ScmpCondition, limit uint64) valueSet {
+	v := cond.Operand1 & limit
+	switch cond.Op {
+	case CompareEqual:
+		return valueSet{{mask: math.MaxUint64, val: v}}
+	case CompareNotEqual:
+		return universeSet(limit).subtract(valueSet{{mask: math.MaxUint64, val: v}})
+	case CompareLess:
+		if v == 0 {
+			return nil
+		}
+		return intervalSet(0, v-1)
+	case CompareLessOrEqual:
+		return intervalSet(0, v)
+	case CompareGreater:
+		if v == limit {
+			return nil
+		}
+		return intervalSet(v+1, limit)
+	case CompareGreaterEqual:
+		return intervalSet(v, limit)
+	case CompareMaskedEqual:
+		// Operand1 is the mask, Operand2 the value
+		val := cond.Operand2 & limit
+		if val&^v != 0 {
+			return nil
+		}
+		return universeSet(limit).intersect(valueSet{{mask: v, val: val}})
+	default:
+		return nil
+	}
+}
+
+// complementConditions returns the rules, as lists of conditions, matching
+// exactly the arguments which match none of the alternatives, each of which
+// is a list of conditions that must all match, e.g. to apply a default
+// action to the arguments conditional rules leave out. Arguments go up to
+// limit, math.MaxUint32 on 32-bit architectures, whose comparisons libseccomp
+// truncates to 32 bits. Rules have at most one condition per argument, as
+// libseccomp requires, and operands up to limit.
+func complementConditions(alternatives [][]ScmpCondition, limit uint64) [][]ScmpCondition {
+	alts := make([]map[uint]valueSet, 0, len(alternatives))
+	for _, conds := range alternatives {
+		alt := make(map[uint]valueSet)
+		for _, cond := range conds {
+			set := conditionSet(cond, limit)
+			if prev, ok := alt[cond.Argument]; ok {
+				set = prev.intersect(set)
+			}
+			alt[cond.Argument] = set
+		}
+		alts = append(alts, alt)
+	}
+
+	var boxes []map[uint]valueSet
+	complementBoxes(alts, map[uint]valueSet{}, limit, &boxes)
+
+	var rules [][]ScmpCondition
+	for _, box := range boxes {
+		rules = append(rules, boxConditions(box, limit)...)
+	}
+
+	return rules
+}
+
+// Helper - Split a box of argument values, unconstrained arguments taking
+// any value, until each part is either inside an alternative, and dropped,
+// or outside all of them, and appended to out
+func complementBoxes(alts []map[uint]valueSet, box map[uint]valueSet, limit uint64, out *[]map[uint]valueSet) {
+	var live []map[uint]valueSet
+	for _, alt := range alts {
+		remaining := make(map[uint]valueSet)
+		dead := false
+		for arg, set := range alt {
+			current, ok := box[arg]
+			if !ok {
+				current = universeSet(limit)
+			}
+			if len(current.intersect(set)) == 0 {
+				dead = true
+				break
+			}
+			if len(current.subtract(set)) != 0 {
+				remaining[arg] = set
+			}
+		}
+
+		if dead {
+			continue
+		} else if len(remaining) == 0 {
+			// The whole box matches the alternative
+			return
+		}
+		live = append(live, remaining)
+	}
+
+	if len(live) == 0 {
+		*out = append(*out, box)
+		return
+	}
+
+	// Split on the lowest argument the first alternative still depends on
+	var arg uint = math.MaxUint32
+	for a := range live[0] {
+		if a < arg {
+			arg = a
+		}
+	}
+	current, ok := box[arg]
+	if !ok {
+		current = universeSet(limit)
+	}
+
+	for _, part := range []valueSet{current.intersect(live[0][arg]), current.subtract(live[0][arg])} {
+		split := make(map[uint]valueSet, len(box)+1)
+		for a, set := range box {
+			split[a] = set
+		}
+		split[arg] = part
+		complementBoxes(live, split, limit, out)
+	}
+}
+
+// Helper - Express a box as rules, one for each combination of the
+// conditions the values of its arguments decompose into
+func boxConditions(box map[uint]valueSet, limit uint64) [][]ScmpCondition {
+	args := make([]uint, 0, len(box))
+	for arg := range box {
+		args = append(args, arg)
+	}
+	sort.Slice(args, func(i, j int) bool { return args[i] < args[j] })
+
+	rules := [][]ScmpCondition{nil}
+	for _, arg := range args {
+		conds := setConditions(arg, box[arg], limit)
+		if conds == nil {
+			// Any value
+			continue
+		}
+
+		var next [][]ScmpCondition
+		for _, rule := range rules {
+			for _, cond := range conds {
+				next = append(next, append(append([]ScmpCondition(nil), rule...), cond))
+			}
+		}
+		rules = next
+	}
+
+	return rules
+}
+
+// Helper - Express a set of values up to limit as conditions, any of which
+// matches
+// Returns nil if the set holds all values
+func setConditions(arg uint, s valueSet, limit uint64) []ScmpCondition {
+	// Blocks of consecutive values are merged into ranges
+	type valueRange struct {
+		lo, hi uint64
+	}
+	var ranges []valueRange
+	var conds []ScmpCondition
+	for _, c := range s {
+		if span := ^c.mask; span&(span+1) == 0 {
+			ranges = append(ranges, valueRange{c.val, c.val | span})
+		} else {
+			conds = append(conds, ScmpCondition{Argument: arg, Op: CompareMaskedEqual, Operand1: c.mask & limit, Operand2: c.val})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].lo < ranges[j].lo })
+
+	var merged []valueRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && merged[n-1].hi != limit && merged[n-1].hi+1 == r.lo {
+			merged[n-1].hi = r.hi
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	// All values but one
+	if len(merged) == 2 && len(conds) == 0 && merged[0].lo == 0 && merged[1].hi == limit &&
+		merged[0].hi+2 == merged[1].lo {
+		return []ScmpCondition{{Argument: arg, Op: CompareNotEqual, Operand1: merged[0].hi + 1}}
+	}
+
+	for _, r := range merged {
+		switch {
+		case r.lo == 0 && r.hi == limit:
+			return nil
+		case r.lo == r.hi:
+			conds = append(conds, ScmpCondition{Argument: arg, Op: CompareEqual, Operand1: r.lo})
+		case r.lo == 0:
+			conds = append(conds, ScmpCondition{Argument: arg, Op: CompareLessOrEqual, Operand1: r.hi})
+		case r.hi == limit:
+			conds = append(conds, ScmpCondition{Argument: arg, Op: CompareGreaterEqual, Operand1: r.lo})
+		default:
+			for _, c := range intervalSet(r.lo, r.hi) {
+				if c.mask == math.MaxUint64 {
+					conds = append(conds, ScmpCondition{Argument: arg, Op: CompareEqual, Operand1: c.val})
+				} else {
+					conds = append(conds, ScmpCondition{Argument: arg, Op: CompareMaskedEqual, Operand1: c.mask & limit, Operand2: c.val})
+				}
+			}
+		}
+	}
+
+	return conds
+}
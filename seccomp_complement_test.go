@@ -0,0 +1,130 @@
+// +build linux
+
+// Tests for the condition complements of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Helper - Check whether arguments match all the conditions of a rule
+func matchConditions(conds []ScmpCondition, args [6]uint64) bool {
+	for _, cond := range conds {
+		v := args[cond.Argument]
+		var match bool
+		switch cond.Op {
+		case CompareEqual:
+			match = v == cond.Operand1
+		case CompareNotEqual:
+			match = v != cond.Operand1
+		case CompareLess:
+			match = v < cond.Operand1
+		case CompareLessOrEqual:
+			match = v <= cond.Operand1
+		case CompareGreater:
+			match = v > cond.Operand1
+		case CompareGreaterEqual:
+			match = v >= cond.Operand1
+		case CompareMaskedEqual:
+			match = v&cond.Operand1 == cond.Operand2
+		}
+		if !match {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestComplementConditions(t *testing.T) {
+	eq := func(arg uint, v uint64) ScmpCondition {
+		return ScmpCondition{Argument: arg, Op: CompareEqual, Operand1: v}
+	}
+
+	for i, alternatives := range [][][]ScmpCondition{
+		// personality() in the moby default profile
+		{{eq(0, 0)}, {eq(0, 8)}, {eq(0, 0x20000)}, {eq(0, 0x20008)}, {eq(0, 0xffffffff)}},
+		// clone() without namespace flags
+		{{{Argument: 0, Op: CompareMaskedEqual, Operand1: 0x7e020000, Operand2: 0}}},
+		{{{Argument: 0, Op: CompareNotEqual, Operand1: 40}}},
+		{{eq(0, 1), eq(1, 2)}, {{Argument: 1, Op: CompareGreater, Operand1: 5}}},
+		{{{Argument: 2, Op: CompareLess, Operand1: 0x100}, {Argument: 3, Op: CompareGreaterEqual, Operand1: 7}},
+			{{Argument: 2, Op: CompareMaskedEqual, Operand1: 0xf0, Operand2: 0x30}}},
+		{{{Argument: 0, Op: CompareLessOrEqual, Operand1: math.MaxUint64}}},
+		{{{Argument: 0, Op: CompareMaskedEqual, Operand1: 0xf, Operand2: 0x10}}},
+	} {
+		for _, limit := range []uint64{math.MaxUint64, math.MaxUint32} {
+			checkComplement(t, i, alternatives, limit)
+		}
+	}
+
+	// A single excluded value is expressed directly
+	rules := complementConditions([][]ScmpCondition{{eq(0, 40)}}, math.MaxUint64)
+	if len(rules) != 1 || len(rules[0]) != 1 || rules[0][0] != (ScmpCondition{Argument: 0, Op: CompareNotEqual, Operand1: 40}) {
+		t.Errorf("Got complement %+v of arg0 == 40", rules)
+	}
+}
+
+// Helper - Check that the complement of alternatives matches exactly the
+// arguments up to limit which none of them match, with operands truncated
+// as on 32-bit architectures
+func checkComplement(t *testing.T, i int, alternatives [][]ScmpCondition, limit uint64) {
+	rules := complementConditions(alternatives, limit)
+
+	truncated := make([][]ScmpCondition, len(alternatives))
+	for j, alt := range alternatives {
+		for _, cond := range alt {
+			cond.Operand1 &= limit
+			cond.Operand2 &= limit
+			truncated[j] = append(truncated[j], cond)
+		}
+	}
+
+	var samples []uint64
+	for _, alt := range truncated {
+		for _, cond := range alt {
+			for _, v := range []uint64{cond.Operand1, cond.Operand2} {
+				samples = append(samples, v-1, v, v+1)
+			}
+		}
+	}
+	samples = append(samples, 0, math.MaxUint64, 0x100000000)
+	rng := rand.New(rand.NewSource(int64(i)))
+	for j := 0; j < 64; j++ {
+		samples = append(samples, rng.Uint64(), uint64(rng.Uint32()))
+	}
+
+	for _, rule := range rules {
+		seen := make(map[uint]bool)
+		for _, cond := range rule {
+			if seen[cond.Argument] {
+				t.Fatalf("Case %d: rule %+v compares an argument twice", i, rule)
+			} else if cond.Operand1 > limit || cond.Operand2 > limit {
+				t.Fatalf("Case %d: rule %+v has operands above %#x", i, rule, limit)
+			}
+			seen[cond.Argument] = true
+		}
+	}
+
+	for j := 0; j < 2000; j++ {
+		var args [6]uint64
+		for k := range args {
+			args[k] = samples[rng.Intn(len(samples))] & limit
+		}
+
+		inAlt, inRule := false, false
+		for _, alt := range truncated {
+			inAlt = inAlt || matchConditions(alt, args)
+		}
+		for _, rule := range rules {
+			inRule = inRule || matchConditions(rule, args)
+		}
+		if inAlt == inRule {
+			t.Fatalf("Case %d: arguments %#x match the alternatives %t and the complement %t up to %#x",
+				i, args, inAlt, inRule, limit)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// +build linux
+
+// Condition parser for libseccomp Go bindings
+// Reads argument conditions from comparison expressions
+
+package seccomp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Comparison operators of condition expressions
+var conditionOps = map[string]ScmpCompareOp{
+	"!=": CompareNotEqual,
+	"<":  CompareLess,
+	"<=": CompareLessOrEqual,
+	"==": CompareEqual,
+	">=": CompareGreaterEqual,
+	">":  CompareGreater,
+}
+
+// ParseCondition parses a condition on a syscall argument from an expression
+// comparing the argument, as "arg" or "a" followed by its index, to a value,
+// e.g. "arg0 == 42" or "a2 >= 0x10". The operators are ==, !=, <, <=, > and
+// >=, and masked equality is written with the mask, e.g. "arg1 & 0x3 == 0x1".
+// Values are decimal, or hexadecimal or octal with a 0x or 0 prefix, and may
+// be negative, e.g. "arg0 == -1" compares against all bits set.
+// Returns the condition, or an error if the expression is invalid.
+func ParseCondition(text string) (ScmpCondition, error) {
+	tokens, err := tokenizeCondition(text)
+	if err != nil {
+		return ScmpCondition{}, err
+	}
+
+	return parseConditionTokens(text, tokens)
+}
+
+// ParseConditions parses the conditions of a rule from condition expressions,
+// as read by ParseCondition, joined by &&, e.g. "arg0 == 42 && arg2 != 0".
+// Returns the conditions in the order of the expression, or an error if any
+// of them is invalid.
+func ParseConditions(text string) ([]ScmpCondition, error) {
+	tokens, err := tokenizeCondition(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var conds []ScmpCondition
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && tokens[i] != "&&" {
+			continue
+		}
+
+		cond, err := parseConditionTokens(strings.Join(tokens[start:i], " "), tokens[start:i])
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+		start = i + 1
+	}
+
+	return conds, nil
+}
+
+// Helper - Split a condition expression into operators and operands
+func tokenizeCondition(text string) ([]string, error) {
+	var tokens []string
+
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case strings.IndexByte("&=!<>", c) >= 0:
+			op := text[i : i+1]
+			if i+1 < len(text) {
+				switch two := text[i : i+2]; two {
+				case "&&", "==", "!=", "<=", ">=":
+					op = two
+				}
+			}
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf("invalid operator %q in condition %q", op, text)
+			}
+			tokens = append(tokens, op)
+			i += len(op)
+		default:
+			end := i
+			for end < len(text) && strings.IndexByte(" \t\n&=!<>", text[end]) < 0 {
+				end++
+			}
+			tokens = append(tokens, text[i:end])
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+// Helper - Make a condition from the tokens of a single comparison, either
+// "argN op value" or "argN & mask == value"
+func parseConditionTokens(text string, tokens []string) (ScmpCondition, error) {
+	var mask *uint64
+	if len(tokens) == 5 && tokens[1] == "&" && tokens[3] == "==" {
+		value, err := parseConditionValue(tokens[2])
+		if err != nil {
+			return ScmpCondition{}, fmt.Errorf("invalid mask in condition %q: %v", text, err)
+		}
+		mask = &value
+		tokens = []string{tokens[0], tokens[3], tokens[4]}
+	}
+	if len(tokens) != 3 {
+		return ScmpCondition{}, fmt.Errorf("invalid condition %q, expected e.g. \"arg0 == 42\"", text)
+	}
+
+	name := tokens[0]
+	switch {
+	case strings.HasPrefix(name, "arg"):
+		name = name[len("arg"):]
+	case strings.HasPrefix(name, "a"):
+		name = name[len("a"):]
+	default:
+		return ScmpCondition{}, fmt.Errorf("invalid argument %q in condition %q", tokens[0], text)
+	}
+	arg, err := strconv.ParseUint(name, 10, 0)
+	if err != nil {
+		return ScmpCondition{}, fmt.Errorf("invalid argument %q in condition %q", tokens[0], text)
+	}
+
+	op, ok := conditionOps[tokens[1]]
+	if !ok {
+		return ScmpCondition{}, fmt.Errorf("invalid operator %q in condition %q", tokens[1], text)
+	}
+
+	value, err := parseConditionValue(tokens[2])
+	if err != nil {
+		return ScmpCondition{}, fmt.Errorf("invalid value in condition %q: %v", text, err)
+	}
+
+	if mask != nil {
+		return MakeCondition(uint(arg), CompareMaskedEqual, *mask, value)
+	}
+	return MakeCondition(uint(arg), op, value)
+}
+
+// Helper - Parse an operand, negative values being taken as two's complement
+func parseConditionValue(s string) (uint64, error) {
+	if strings.HasPrefix(s, "-") {
+		value, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a 64-bit integer", s)
+		}
+		return uint64(value), nil
+	}
+
+	value, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a 64-bit integer", s)
+	}
+	return value, nil
+}
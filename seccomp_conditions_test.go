@@ -0,0 +1,74 @@
+// +build linux
+
+// Tests for the condition parser of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	for text, expected := range map[string]ScmpCondition{
+		"arg0 == 42":          {Argument: 0, Op: CompareEqual, Operand1: 42},
+		"a2 >= 0x10":          {Argument: 2, Op: CompareGreaterEqual, Operand1: 0x10},
+		"arg5<010":            {Argument: 5, Op: CompareLess, Operand1: 8},
+		"arg3 <= 7":           {Argument: 3, Op: CompareLessOrEqual, Operand1: 7},
+		"arg4 > 1":            {Argument: 4, Op: CompareGreater, Operand1: 1},
+		"arg1 != 0":           {Argument: 1, Op: CompareNotEqual, Operand1: 0},
+		"arg0 == -1":          {Argument: 0, Op: CompareEqual, Operand1: 0xffffffffffffffff},
+		"arg1 & 0x3 == 0x1":   {Argument: 1, Op: CompareMaskedEqual, Operand1: 0x3, Operand2: 0x1},
+		"\targ1&0xff==0x100 ": {Argument: 1, Op: CompareMaskedEqual, Operand1: 0xff, Operand2: 0x100},
+	} {
+		cond, err := ParseCondition(text)
+		if err != nil {
+			t.Errorf("Error parsing %q: %s", text, err)
+		} else if cond != expected {
+			t.Errorf("Parsed %q as %+v, expected %+v", text, cond, expected)
+		}
+	}
+
+	for _, text := range []string{
+		"", "arg0", "arg0 ==", "arg6 == 1", "argx == 1", "fd == 1",
+		"arg0 = 1", "arg0 ! 1", "arg0 == 1 2", "arg0 & 1 != 1",
+		"arg0 == 0x1ffffffffffffffff", "arg0 == -0x8000000000000001",
+		"arg0 == 1 && arg1 == 2",
+	} {
+		if _, err := ParseCondition(text); err == nil {
+			t.Errorf("Expected an error parsing %q", text)
+		}
+	}
+}
+
+func TestParseConditions(t *testing.T) {
+	conds, err := ParseConditions("arg0 == 42 && arg2 != 0&&arg1 & 0x3 == 0x1")
+	if err != nil {
+		t.Fatalf("Error parsing conditions: %s", err)
+	}
+	expected := []ScmpCondition{
+		{Argument: 0, Op: CompareEqual, Operand1: 42},
+		{Argument: 2, Op: CompareNotEqual, Operand1: 0},
+		{Argument: 1, Op: CompareMaskedEqual, Operand1: 0x3, Operand2: 0x1},
+	}
+	if !reflect.DeepEqual(conds, expected) {
+		t.Errorf("Got conditions %+v, expected %+v", conds, expected)
+	}
+
+	for _, text := range []string{"", "arg0 == 1 &&", "&& arg0 == 1", "arg0 == 1 && && arg1 == 2"} {
+		if _, err := ParseConditions(text); err == nil {
+			t.Errorf("Expected an error parsing %q", text)
+		}
+	}
+}
+
+func TestParseConditionRoundTrip(t *testing.T) {
+	cond, err := MakeCondition(1, CompareMaskedEqual, 0xff, 3)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+	parsed, err := ParseCondition(conditionString(cond))
+	if err != nil || parsed != cond {
+		t.Errorf("Got %+v, %v parsing %q", parsed, err, conditionString(cond))
+	}
+}
@@ -0,0 +1,119 @@
+// +build linux
+
+// Self-confinement helper for libseccomp Go bindings
+// Applies the prerequisites of loading a filter in the right order
+
+package seccomp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Field of /proc/thread-self/status holding the seccomp mode
+const procStatusSeccompLine = "Seccomp:"
+
+// ConfineOptions configures ConfineSelf.
+//
+// Filter:           filter to load, which must have been built by the caller
+// ClearAmbientCaps: clear the ambient capability set of the calling thread
+//                   before loading
+// AllowStacking:    permit loading on top of an already installed filter
+//
+type ConfineOptions struct {
+	Filter           *ScmpFilter
+	ClearAmbientCaps bool
+	AllowStacking    bool
+}
+
+// ConfineSelf confines the calling process with opts.Filter, performing the
+// steps that must precede the load in the correct order, on the calling
+// thread:
+//
+// 1. refuse to proceed if the thread runs in strict seccomp mode, or already
+//    has a filter installed and opts.AllowStacking is not set
+// 2. clear the ambient capability set of the thread, if
+//    opts.ClearAmbientCaps is set
+// 3. set the No New Privileges bit
+// 4. load the filter with thread synchronization, so every thread of the
+//    process is confined
+//
+// The No New Privileges bit and the filter are propagated to all threads by
+// the synchronized load, but the ambient capability set is per-thread and
+// cannot be cleared on other threads: other threads of the process, such as
+// those the Go runtime started before, keep their ambient capabilities, and
+// may pass them to programs they execute. Processes which must not keep
+// ambient capabilities should clear them before starting, e.g. through the
+// AmbientCaps of the syscall.SysProcAttr which starts them.
+// Returns an error if any step failed; the process may have been partially
+// confined in that case.
+func ConfineSelf(opts ConfineOptions) error {
+	if opts.Filter == nil {
+		return fmt.Errorf("no filter given")
+	}
+
+	tsync, err := opts.Filter.getFilterAttr(filterAttrTsync)
+	if err != nil {
+		return err
+	} else if tsync == 0 {
+		return fmt.Errorf("filter must have thread synchronization enabled")
+	}
+
+	// The checks, all prctl calls and the load must happen on the same thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	mode, err := currentSeccompMode()
+	if err != nil {
+		return err
+	}
+	switch {
+	case mode == unix.SECCOMP_MODE_STRICT:
+		return fmt.Errorf("process is running in strict seccomp mode")
+	case mode != unix.SECCOMP_MODE_DISABLED && !opts.AllowStacking:
+		return fmt.Errorf("process is already confined by a seccomp filter")
+	}
+
+	if opts.ClearAmbientCaps {
+		if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+			return fmt.Errorf("could not clear ambient capabilities: %v", err)
+		}
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("could not set no_new_privs: %v", err)
+	}
+
+	return opts.Filter.Load()
+}
+
+// Helper - Read the seccomp mode of the calling thread from procfs, which
+// must be locked to its goroutine
+func currentSeccompMode() (int, error) {
+	// /proc/self/status describes the thread group leader
+	file, err := os.Open("/proc/thread-self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, procStatusSeccompLine) {
+			return strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, procStatusSeccompLine)))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	// Kernel built without seccomp support
+	return 0, fmt.Errorf("kernel does not report a seccomp mode")
+}
@@ -0,0 +1,325 @@
+// +build linux
+
+// Syscall list import for libseccomp Go bindings
+// Parses the syscall lists of firejail and bubblewrap based sandboxes
+
+package seccomp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ParseAllowlist parses a comma-separated list of syscalls in the format of
+// firejail's seccomp.keep profile command, also used by sandboxes passing
+// filters to bubblewrap's --seccomp option, e.g. "@default,read,!ptrace".
+// Entries are syscall names, syscall groups by their firejail name (e.g.,
+// "@default", the syscalls firejail denies by default, or "@default-keep",
+// those it keeps for seccomp.keep), or names and groups prefixed with "!"
+// which are left out of the list however else they are included. Syscalls
+// of groups which are unknown to libseccomp are skipped, like AddRuleSet
+// does. Firejail's groups differ from the systemd sets of SyscallSet
+// sharing their names.
+// Returns an allowlist of the syscalls with the defaults of NewAllowlist, or
+// an error if the list is invalid.
+func ParseAllowlist(list string) (*Allowlist, error) {
+	names, actions, err := parseSyscallList(list)
+	if err != nil {
+		return nil, err
+	}
+	if len(actions) > 0 {
+		return nil, fmt.Errorf("allowlists cannot set the errno of syscalls")
+	}
+
+	return NewAllowlist(names...), nil
+}
+
+// ParseDenylist parses a comma-separated list of syscalls in the format of
+// firejail's seccomp and seccomp.drop profile commands, e.g.
+// "@obsolete,mount:EACCES,!sysfs". Entries are those of ParseAllowlist,
+// optionally followed by ":" and the name or number of the errno the
+// syscalls fail with instead of EPERM.
+// Returns a denylist of the syscalls with the defaults of NewDenylist, or an
+// error if the list is invalid.
+func ParseDenylist(list string) (*Denylist, error) {
+	names, actions, err := parseSyscallList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	l := NewDenylist(names...)
+	if len(actions) > 0 {
+		l.Actions = actions
+	}
+
+	return l, nil
+}
+
+// Helper - Parse a syscall list into the names of its syscalls, in order of
+// appearance, and the actions set for some of them
+func parseSyscallList(list string) ([]string, map[string]ScmpAction, error) {
+	var names []string
+	included := make(map[string]bool)
+	excluded := make(map[string]bool)
+	actions := make(map[string]ScmpAction)
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		exclude := strings.HasPrefix(entry, "!")
+		entry = strings.TrimPrefix(entry, "!")
+
+		var action ScmpAction
+		hasAction := false
+		if i := strings.Index(entry, ":"); i >= 0 {
+			if exclude {
+				return nil, nil, fmt.Errorf("excluded syscalls cannot set an errno: %q", entry)
+			}
+			errno, err := parseErrno(entry[i+1:])
+			if err != nil {
+				return nil, nil, err
+			}
+			action = ActErrno.SetErrno(errno)
+			hasAction = true
+			entry = entry[:i]
+		}
+
+		members := []string{entry}
+		if strings.HasPrefix(entry, "@") {
+			setNames, err := firejailGroupSyscalls(entry)
+			if err != nil {
+				return nil, nil, err
+			}
+			members = members[:0]
+			for _, name := range setNames {
+				if _, err := GetSyscallFromName(name); err == nil {
+					members = append(members, name)
+				}
+			}
+		} else if entry == "" {
+			return nil, nil, fmt.Errorf("empty syscall name in list")
+		}
+
+		for _, name := range members {
+			if exclude {
+				excluded[name] = true
+				continue
+			}
+			if hasAction {
+				actions[name] = action
+			}
+			if !included[name] {
+				included[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	var result []string
+	for _, name := range names {
+		if excluded[name] {
+			delete(actions, name)
+			continue
+		}
+		result = append(result, name)
+	}
+
+	return result, actions, nil
+}
+
+// Members of the syscall groups of firejail, including other groups by their
+// name, as defined by firejail
+var firejailGroups = map[string][]string{
+	"@aio": {
+		"io_cancel", "io_destroy", "io_getevents", "io_pgetevents", "io_setup",
+		"io_submit",
+	},
+	"@basic-io": {
+		"_llseek", "close", "dup", "dup2", "dup3", "lseek", "pread64", "preadv",
+		"preadv2", "pwrite64", "pwritev", "pwritev2", "read", "readv", "write",
+		"writev",
+	},
+	"@chown": {
+		"chown", "chown32", "fchown", "fchown32", "fchownat", "lchown", "lchown32",
+	},
+	"@clock": {
+		"adjtimex", "clock_adjtime", "clock_settime", "settimeofday", "stime",
+	},
+	"@cpu-emulation": {
+		"modify_ldt", "subpage_prot", "switch_endian", "vm86", "vm86old",
+	},
+	"@debug": {
+		"lookup_dcookie", "perf_event_open", "process_vm_writev", "rtas",
+		"s390_runtime_instr", "sys_debug_setcontext",
+	},
+	// Denied by the seccomp command when debuggers are allowed
+	"@default": {
+		"@clock", "@cpu-emulation", "@debug", "@module", "@mount", "@obsolete",
+		"@raw-io", "@reboot", "@swap", "acct", "add_key", "bpf", "fanotify_init",
+		"get_mempolicy", "io_cancel", "io_destroy", "io_getevents", "io_setup",
+		"io_submit", "ioprio_set", "kcmp", "keyctl", "mbind", "migrate_pages",
+		"move_pages", "name_to_handle_at", "nfsservctl", "open_by_handle_at",
+		"remap_file_pages", "request_key", "set_mempolicy", "setdomainname",
+		"sethostname", "syslog", "userfaultfd", "vhangup", "vmsplice",
+	},
+	// Denied by the seccomp command otherwise
+	"@default-nodebuggers": {
+		"@default", "personality", "process_vm_readv", "ptrace",
+	},
+	// Kept by the seccomp.keep command to start the sandboxed program
+	"@default-keep": {
+		"execve", "execveat", "prctl",
+	},
+	"@file-system": {
+		"access", "chdir", "chmod", "close", "creat", "faccessat", "fallocate",
+		"fchdir", "fchmod", "fchmodat", "fcntl", "fcntl64", "fgetxattr",
+		"flistxattr", "fremovexattr", "fsetxattr", "fstat", "fstat64",
+		"fstatat64", "fstatfs", "fstatfs64", "ftruncate", "ftruncate64",
+		"futimesat", "getcwd", "getdents", "getdents64", "getxattr",
+		"inotify_add_watch", "inotify_init", "inotify_init1", "inotify_rm_watch",
+		"lgetxattr", "link", "linkat", "listxattr", "llistxattr", "lremovexattr",
+		"lsetxattr", "lstat", "lstat64", "mkdir", "mkdirat", "mknod", "mknodat",
+		"mmap", "mmap2", "munmap", "newfstatat", "open", "openat", "readlink",
+		"readlinkat", "removexattr", "rename", "renameat", "renameat2", "rmdir",
+		"setxattr", "stat", "stat64", "statfs", "statfs64", "statx", "symlink",
+		"symlinkat", "truncate", "truncate64", "unlink", "unlinkat", "utime",
+		"utimensat", "utimes",
+	},
+	"@io-event": {
+		"_newselect", "epoll_create", "epoll_create1", "epoll_ctl",
+		"epoll_ctl_old", "epoll_pwait", "epoll_wait", "epoll_wait_old", "eventfd",
+		"eventfd2", "poll", "ppoll", "pselect6", "select",
+	},
+	"@ipc": {
+		"ipc", "memfd_create", "mq_getsetattr", "mq_notify", "mq_open",
+		"mq_timedreceive", "mq_timedsend", "mq_unlink", "msgctl", "msgget",
+		"msgrcv", "msgsnd", "pipe", "pipe2", "process_vm_readv",
+		"process_vm_writev", "semctl", "semget", "semop", "semtimedop", "shmat",
+		"shmctl", "shmdt", "shmget",
+	},
+	"@keyring": {
+		"add_key", "keyctl", "request_key",
+	},
+	"@memlock": {
+		"mlock", "mlock2", "mlockall", "munlock", "munlockall",
+	},
+	"@module": {
+		"delete_module", "finit_module", "init_module",
+	},
+	"@mount": {
+		"mount", "pivot_root", "umount", "umount2",
+	},
+	"@network-io": {
+		"accept", "accept4", "bind", "connect", "getpeername", "getsockname",
+		"getsockopt", "listen", "recv", "recvfrom", "recvmmsg", "recvmsg",
+		"send", "sendmmsg", "sendmsg", "sendto", "setsockopt", "shutdown",
+		"socket", "socketcall", "socketpair",
+	},
+	"@obsolete": {
+		"_sysctl", "afs_syscall", "bdflush", "break", "create_module", "ftime",
+		"get_kernel_syms", "getpmsg", "gtty", "lock", "mpx", "prof", "profil",
+		"putpmsg", "query_module", "security", "sgetmask", "ssetmask", "stty",
+		"sysfs", "tuxcall", "ulimit", "uselib", "ustat", "vserver",
+	},
+	"@privileged": {
+		"@chown", "@clock", "@module", "@raw-io", "@reboot", "@swap", "_sysctl",
+		"acct", "bpf", "capset", "chroot", "fanotify_init", "mount",
+		"nfsservctl", "open_by_handle_at", "pivot_root", "quotactl",
+		"setdomainname", "setfsuid", "setfsuid32", "setgroups", "setgroups32",
+		"sethostname", "setresuid", "setresuid32", "setreuid", "setreuid32",
+		"setuid", "setuid32", "umount2", "vhangup",
+	},
+	"@process": {
+		"arch_prctl", "capget", "clone", "execveat", "fork", "getrusage", "kill",
+		"pidfd_open", "pidfd_send_signal", "prctl", "rt_sigqueueinfo",
+		"rt_tgsigqueueinfo", "setns", "swapcontext", "tgkill", "times", "tkill",
+		"unshare", "vfork", "wait4", "waitid", "waitpid",
+	},
+	"@raw-io": {
+		"ioperm", "iopl", "pciconfig_iobase", "pciconfig_read",
+		"pciconfig_write", "s390_mmio_read", "s390_mmio_write",
+	},
+	"@reboot": {
+		"kexec_file_load", "kexec_load", "reboot",
+	},
+	"@resources": {
+		"ioprio_set", "mbind", "migrate_pages", "move_pages", "nice",
+		"sched_setaffinity", "sched_setattr", "sched_setparam",
+		"sched_setscheduler", "set_mempolicy", "setpriority", "setrlimit",
+	},
+	"@setuid": {
+		"setgid", "setgid32", "setgroups", "setgroups32", "setregid",
+		"setregid32", "setresgid", "setresgid32", "setresuid", "setresuid32",
+		"setreuid", "setreuid32", "setuid", "setuid32",
+	},
+	"@signal": {
+		"rt_sigaction", "rt_sigpending", "rt_sigprocmask", "rt_sigsuspend",
+		"rt_sigtimedwait", "sigaction", "sigaltstack", "signal", "signalfd",
+		"signalfd4", "sigpending", "sigprocmask", "sigsuspend",
+	},
+	"@swap": {
+		"swapoff", "swapon",
+	},
+	"@sync": {
+		"fdatasync", "fsync", "msync", "sync", "sync_file_range",
+		"sync_file_range2", "syncfs",
+	},
+	"@timer": {
+		"alarm", "getitimer", "setitimer", "timer_create", "timer_delete",
+		"timer_getoverrun", "timer_gettime", "timer_settime", "timerfd_create",
+		"timerfd_gettime", "timerfd_settime", "times",
+	},
+}
+
+// Helper - Get the syscall names of a firejail group and its included groups
+func firejailGroupSyscalls(group string) ([]string, error) {
+	seen := make(map[string]bool)
+	var expand func(group string) error
+	expand = func(group string) error {
+		members, ok := firejailGroups[group]
+		if !ok {
+			return fmt.Errorf("unknown syscall group %q", group)
+		}
+		for _, member := range members {
+			if !strings.HasPrefix(member, "@") {
+				seen[member] = true
+			} else if err := expand(member); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := expand(group); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Helper - Parse an errno by name (e.g., "EPERM") or number
+func parseErrno(s string) (unix.Errno, error) {
+	if n, err := strconv.ParseUint(s, 10, 16); err == nil {
+		return unix.Errno(n), nil
+	}
+
+	for errno := unix.Errno(1); errno < 4096; errno++ {
+		if unix.ErrnoName(errno) == s {
+			return errno, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown errno %q", s)
+}
@@ -0,0 +1,120 @@
+// +build linux
+
+// Tests for the syscall list import of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseSyscallLists(t *testing.T) {
+	allow, err := ParseAllowlist("read, write,@clock,!clock_adjtime,!@timer,times")
+	if err != nil {
+		t.Fatalf("Error parsing allowlist: %s", err)
+	}
+	if len(allow.Syscalls) < 4 || allow.Syscalls[0] != "read" || allow.Syscalls[1] != "write" {
+		t.Errorf("Got allowlist %v, want syscalls in order of appearance", allow.Syscalls)
+	}
+	listed := make(map[string]bool)
+	for _, name := range allow.Syscalls {
+		listed[name] = true
+	}
+	if !listed["settimeofday"] || listed["clock_adjtime"] || listed["times"] {
+		t.Errorf("Got allowlist %v, want @clock without clock_adjtime and @timer", allow.Syscalls)
+	}
+	if allow.DenyAction != ActEPerm {
+		t.Errorf("Allowlist should deny with EPERM, got %s", allow.DenyAction)
+	}
+
+	deny, err := ParseDenylist("mount:EACCES,umount2:22,ptrace,,!umount2,ptrace")
+	if err != nil {
+		t.Fatalf("Error parsing denylist: %s", err)
+	}
+	if !reflect.DeepEqual(deny.Syscalls, []string{"mount", "ptrace"}) {
+		t.Errorf("Got denylist %v", deny.Syscalls)
+	}
+	if len(deny.Actions) != 1 || deny.Actions["mount"] != ActErrno.SetErrno(unix.EACCES) {
+		t.Errorf("Got denylist actions %v", deny.Actions)
+	}
+
+	// Groups are firejail's, not systemd's
+	for _, test := range []struct {
+		list    string
+		in, out []string
+	}{
+		{"@default", []string{"mount", "kexec_load", "syslog", "swapon", "bpf"}, []string{"ptrace", "execve", "read"}},
+		{"@default-nodebuggers", []string{"mount", "ptrace", "process_vm_readv"}, []string{"execve"}},
+		{"@default-keep", []string{"execve", "execveat", "prctl"}, []string{"mount"}},
+	} {
+		l, err := ParseDenylist(test.list)
+		if err != nil {
+			t.Fatalf("Error parsing denylist %q: %s", test.list, err)
+		}
+		listed := make(map[string]bool)
+		for _, name := range l.Syscalls {
+			listed[name] = true
+		}
+		for _, name := range test.in {
+			if !listed[name] {
+				t.Errorf("Denylist %q should hold %s", test.list, name)
+			}
+		}
+		for _, name := range test.out {
+			if listed[name] {
+				t.Errorf("Denylist %q should not hold %s", test.list, name)
+			}
+		}
+	}
+
+	for _, list := range []string{"@nope", "@clone", "mount:EWHAT", "!mount:EPERM", ":EPERM"} {
+		if _, err := ParseDenylist(list); err == nil {
+			t.Errorf("Parsing denylist %q should fail", list)
+		}
+	}
+	if _, err := ParseAllowlist("read:EPERM"); err == nil {
+		t.Errorf("Allowlists should not set errnos")
+	}
+}
+
+func TestParseDenylistFilter(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: filter program is checked for amd64")
+	}
+	const auditArchX8664 = 0xc000003e
+
+	deny, err := ParseDenylist("@reboot,mount:EACCES,!kexec_file_load")
+	if err != nil {
+		t.Fatalf("Error parsing denylist: %s", err)
+	}
+	filter, err := deny.Build()
+	if err != nil {
+		t.Fatalf("Error building denylist: %s", err)
+	}
+	defer filter.Release()
+
+	prog := exportProgram(t, filter)
+	for _, test := range []struct {
+		nr   int32
+		want uint32
+	}{
+		{unix.SYS_REBOOT, bpf.RetErrno | uint32(unix.EPERM)},
+		{unix.SYS_KEXEC_LOAD, bpf.RetErrno | uint32(unix.EPERM)},
+		{unix.SYS_KEXEC_FILE_LOAD, bpf.RetAllow},
+		{unix.SYS_MOUNT, bpf.RetErrno | uint32(unix.EACCES)},
+		{unix.SYS_GETPID, bpf.RetAllow},
+	} {
+		ret, err := bpf.Run(prog, &bpf.Data{Nr: test.nr, Arch: auditArchX8664})
+		if err != nil {
+			t.Fatalf("Error running exported filter: %s", err)
+		}
+		if ret != test.want {
+			t.Errorf("Syscall %d: got %s, want %s", test.nr, bpf.ActionString(ret), bpf.ActionString(test.want))
+		}
+	}
+}
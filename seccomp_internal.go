@@ -6,8 +6,19 @@
 package seccomp
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"syscall"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
 )
 
 // Unexported C wrapping code - provides the C-Golang interface
@@ -24,7 +35,12 @@ import (
 // #cgo pkg-config: libseccomp
 /*
 #include <errno.h>
+#include <poll.h>
 #include <stdlib.h>
+#include <string.h>
+#include <sys/ioctl.h>
+#include <sys/syscall.h>
+#include <unistd.h>
 #include <seccomp.h>
 
 #if SCMP_VER_MAJOR < 2
@@ -65,6 +81,30 @@ const uint32_t C_ARCH_BAD = ARCH_BAD;
 #define SCMP_ARCH_PARISC64 ARCH_BAD
 #endif
 
+#ifndef SCMP_ARCH_LOONGARCH64
+#define SCMP_ARCH_LOONGARCH64 ARCH_BAD
+#endif
+
+#ifndef SCMP_ARCH_RISCV64
+#define SCMP_ARCH_RISCV64 ARCH_BAD
+#endif
+
+#ifndef SCMP_ARCH_RISCV32
+#define SCMP_ARCH_RISCV32 ARCH_BAD
+#endif
+
+#ifndef SCMP_ARCH_M68K
+#define SCMP_ARCH_M68K ARCH_BAD
+#endif
+
+#ifndef SCMP_ARCH_SH
+#define SCMP_ARCH_SH ARCH_BAD
+#endif
+
+#ifndef SCMP_ARCH_SHEB
+#define SCMP_ARCH_SHEB ARCH_BAD
+#endif
+
 const uint32_t C_ARCH_NATIVE       = SCMP_ARCH_NATIVE;
 const uint32_t C_ARCH_X86          = SCMP_ARCH_X86;
 const uint32_t C_ARCH_X86_64       = SCMP_ARCH_X86_64;
@@ -84,6 +124,12 @@ const uint32_t C_ARCH_S390         = SCMP_ARCH_S390;
 const uint32_t C_ARCH_S390X        = SCMP_ARCH_S390X;
 const uint32_t C_ARCH_PARISC       = SCMP_ARCH_PARISC;
 const uint32_t C_ARCH_PARISC64     = SCMP_ARCH_PARISC64;
+const uint32_t C_ARCH_LOONGARCH64  = SCMP_ARCH_LOONGARCH64;
+const uint32_t C_ARCH_RISCV64      = SCMP_ARCH_RISCV64;
+const uint32_t C_ARCH_RISCV32      = SCMP_ARCH_RISCV32;
+const uint32_t C_ARCH_M68K         = SCMP_ARCH_M68K;
+const uint32_t C_ARCH_SH           = SCMP_ARCH_SH;
+const uint32_t C_ARCH_SHEB         = SCMP_ARCH_SHEB;
 
 #ifndef SCMP_ACT_LOG
 #define SCMP_ACT_LOG 0x7ffc0000U
@@ -111,6 +157,12 @@ const uint32_t C_ACT_LOG           = SCMP_ACT_LOG;
 const uint32_t C_ACT_ALLOW         = SCMP_ACT_ALLOW;
 const uint32_t C_ACT_NOTIFY        = SCMP_ACT_NOTIFY;
 
+// The libseccomp SCMP_FLTATR_API_TSKIP member of the scmp_filter_attr enum
+// was added in v2.3.0
+#if SCMP_VER_MAJOR == 2 && SCMP_VER_MINOR < 3
+#define SCMP_FLTATR_API_TSKIP _SCMP_FLTATR_MIN
+#endif
+
 // The libseccomp SCMP_FLTATR_CTL_LOG member of the scmp_filter_attr enum was
 // added in v2.4.0
 #if (SCMP_VER_MAJOR < 2) || \
@@ -119,14 +171,23 @@ const uint32_t C_ACT_NOTIFY        = SCMP_ACT_NOTIFY;
 #endif
 #if SCMP_VER_MAJOR == 2 && SCMP_VER_MINOR < 5
 #define SCMP_FLTATR_CTL_SSB _SCMP_FLTATR_MIN
+#define SCMP_FLTATR_CTL_OPTIMIZE _SCMP_FLTATR_MIN
+#define SCMP_FLTATR_API_SYSRAWRC _SCMP_FLTATR_MIN
+#endif
+#if SCMP_VER_MAJOR == 2 && SCMP_VER_MINOR < 6
+#define SCMP_FLTATR_CTL_WAITKILL _SCMP_FLTATR_MIN
 #endif
 
 const uint32_t C_ATTRIBUTE_DEFAULT = (uint32_t)SCMP_FLTATR_ACT_DEFAULT;
 const uint32_t C_ATTRIBUTE_BADARCH = (uint32_t)SCMP_FLTATR_ACT_BADARCH;
 const uint32_t C_ATTRIBUTE_NNP     = (uint32_t)SCMP_FLTATR_CTL_NNP;
 const uint32_t C_ATTRIBUTE_TSYNC   = (uint32_t)SCMP_FLTATR_CTL_TSYNC;
+const uint32_t C_ATTRIBUTE_TSKIP   = (uint32_t)SCMP_FLTATR_API_TSKIP;
 const uint32_t C_ATTRIBUTE_LOG     = (uint32_t)SCMP_FLTATR_CTL_LOG;
 const uint32_t C_ATTRIBUTE_SSB     = (uint32_t)SCMP_FLTATR_CTL_SSB;
+const uint32_t C_ATTRIBUTE_OPTIMIZE = (uint32_t)SCMP_FLTATR_CTL_OPTIMIZE;
+const uint32_t C_ATTRIBUTE_SYSRAWRC = (uint32_t)SCMP_FLTATR_API_SYSRAWRC;
+const uint32_t C_ATTRIBUTE_WAITKILL = (uint32_t)SCMP_FLTATR_CTL_WAITKILL;
 
 const int      C_CMP_NE            = (int)SCMP_CMP_NE;
 const int      C_CMP_LT            = (int)SCMP_CMP_LT;
@@ -260,6 +321,182 @@ int seccomp_notify_respond(int fd, struct seccomp_notif_resp *resp) {
 }
 
 #endif
+
+// The in-memory export, precompute and transaction functions were added in
+// v2.6.0
+#if (SCMP_VER_MAJOR < 2) || \
+    (SCMP_VER_MAJOR == 2 && SCMP_VER_MINOR < 6)
+int seccomp_export_bpf_mem(const scmp_filter_ctx ctx, void *buf, size_t *len) {
+	return -EOPNOTSUPP;
+}
+int seccomp_precompute(const scmp_filter_ctx ctx) {
+	return -EOPNOTSUPP;
+}
+int seccomp_transaction_start(const scmp_filter_ctx ctx) {
+	return -EOPNOTSUPP;
+}
+void seccomp_transaction_reject(const scmp_filter_ctx ctx) {
+}
+int seccomp_transaction_commit(const scmp_filter_ctx ctx) {
+	return -EOPNOTSUPP;
+}
+#endif
+
+// The SECCOMP_IOCTL_NOTIF_ADDFD ioctl was added in Linux 5.9
+#ifndef SECCOMP_IOCTL_NOTIF_ADDFD
+struct seccomp_notif_addfd {
+	__u64 id;
+	__u32 flags;
+	__u32 srcfd;
+	__u32 newfd;
+	__u32 newfd_flags;
+};
+
+#define SECCOMP_IOCTL_NOTIF_ADDFD _IOW('!', 3, struct seccomp_notif_addfd)
+#endif
+
+// Install a file descriptor in the target of a notification
+// Returns the new file descriptor number, or a negative errno on failure
+int notify_addfd(int fd, uint64_t id, uint32_t flags, uint32_t srcfd, uint32_t newfd, uint32_t newfd_flags)
+{
+	struct seccomp_notif_addfd addfd = {
+		.id = id,
+		.flags = flags,
+		.srcfd = srcfd,
+		.newfd = newfd,
+		.newfd_flags = newfd_flags,
+	};
+	int rc;
+
+	rc = ioctl(fd, SECCOMP_IOCTL_NOTIF_ADDFD, &addfd);
+	if (rc < 0)
+		return -errno;
+
+	return rc;
+}
+
+// The SECCOMP_IOCTL_NOTIF_SET_FLAGS ioctl was added in Linux 6.6
+#ifndef SECCOMP_IOCTL_NOTIF_SET_FLAGS
+#define SECCOMP_IOCTL_NOTIF_SET_FLAGS _IOW('!', 4, __u64)
+#endif
+
+// Set the flags of a notification fd
+// Returns 0 on success, or a negative errno on failure
+int notify_set_flags(int fd, uint64_t flags)
+{
+	if (ioctl(fd, SECCOMP_IOCTL_NOTIF_SET_FLAGS, flags) < 0)
+		return -errno;
+
+	return 0;
+}
+
+// The SECCOMP_GET_NOTIF_SIZES operation was added in Linux 5.0
+#ifndef SECCOMP_GET_NOTIF_SIZES
+struct seccomp_notif_sizes {
+	__u16 seccomp_notif;
+	__u16 seccomp_notif_resp;
+	__u16 seccomp_data;
+};
+
+#define SECCOMP_GET_NOTIF_SIZES 3
+#endif
+
+// Get the sizes of the notification structures used by the kernel
+// Returns 0 on success, or a negative errno on failure
+int notify_get_sizes(uint16_t *notif, uint16_t *resp, uint16_t *data)
+{
+	struct seccomp_notif_sizes sizes;
+
+	if (syscall(__NR_seccomp, SECCOMP_GET_NOTIF_SIZES, 0, &sizes) < 0)
+		return -errno;
+
+	*notif = sizes.seccomp_notif;
+	*resp = sizes.seccomp_notif_resp;
+	*data = sizes.seccomp_data;
+	return 0;
+}
+
+// Receive up to max notifications, only waiting for the first one, into the
+// array reqs of buffers of size bytes each
+// Returns the number of notifications received, or a negative errno if none
+// could be
+int notify_receive_batch(int fd, void *reqs, size_t size, unsigned int max)
+{
+	struct pollfd pfd = { .fd = fd, .events = POLLIN };
+	struct seccomp_notif *req;
+	unsigned int n = 0;
+	int rc;
+
+	while (n < max) {
+		// Receiving blocks even on non-blocking fds
+		if (n > 0) {
+			rc = poll(&pfd, 1, 0);
+			if (rc < 0 && errno == EINTR)
+				continue;
+			if (rc <= 0 || !(pfd.revents & POLLIN))
+				break;
+		}
+
+		// The kernel rejects requests which are not zeroed
+		req = (struct seccomp_notif *)((char *)reqs + n * size);
+		memset(req, 0, size);
+		errno = 0;
+		rc = seccomp_notify_receive(fd, req);
+		if (rc != 0) {
+			// Skip notifications whose target went away
+			if (errno == EINTR || errno == ENOENT)
+				continue;
+			if (n > 0)
+				break;
+			// libseccomp reports all failures as -ECANCELED
+			return errno ? -errno : rc;
+		}
+		n++;
+	}
+
+	return n;
+}
+
+#ifndef SECCOMP_IOCTL_NOTIF_SEND
+#define SECCOMP_IOCTL_NOTIF_SEND _IOWR('!', 1, struct seccomp_notif_resp)
+#endif
+
+// Send a response with the continue flag to a notification which does not
+// exist
+// Returns a negative errno, -ENOENT if the kernel accepted the flag
+int notify_probe_continue(int fd)
+{
+	struct seccomp_notif_resp resp = {
+		.id = ~0ULL,
+		.flags = 1,
+	};
+
+	if (ioctl(fd, SECCOMP_IOCTL_NOTIF_SEND, &resp) < 0)
+		return -errno;
+
+	return 0;
+}
+
+// Check the validity of several notification IDs in a single call, storing
+// 1 for valid and 0 for invalid IDs in valid
+int notify_ids_valid(int fd, const uint64_t *ids, unsigned int count, unsigned char *valid)
+{
+	unsigned int i;
+	int rc;
+
+	for (i = 0; i < count; i++) {
+		do {
+			errno = 0;
+			rc = seccomp_notify_id_valid(fd, ids[i]);
+		} while (rc == -ENOENT && errno == EINTR);
+
+		if (rc != 0 && rc != -ENOENT)
+			return rc;
+		valid[i] = (rc == 0);
+	}
+
+	return 0;
+}
 */
 import "C"
 
@@ -273,8 +510,12 @@ const (
 	filterAttrActBadArch scmpFilterAttr = iota
 	filterAttrNNP        scmpFilterAttr = iota
 	filterAttrTsync      scmpFilterAttr = iota
+	filterAttrTSkip      scmpFilterAttr = iota
 	filterAttrLog        scmpFilterAttr = iota
 	filterAttrSSB        scmpFilterAttr = iota
+	filterAttrOptimize   scmpFilterAttr = iota
+	filterAttrRawRC      scmpFilterAttr = iota
+	filterAttrWaitKill   scmpFilterAttr = iota
 )
 
 const (
@@ -282,24 +523,40 @@ const (
 	scmpError C.int = -1
 	// Comparison boundaries to check for architecture validity
 	archStart ScmpArch = ArchNative
-	archEnd   ScmpArch = ArchPARISC64
+	archEnd   ScmpArch = ArchSHEB
 	// Comparison boundaries to check for action validity
 	actionStart ScmpAction = ActKill
 	actionEnd   ScmpAction = ActKillProcess
 	// Comparison boundaries to check for comparison operator validity
 	compareOpStart ScmpCompareOp = CompareNotEqual
 	compareOpEnd   ScmpCompareOp = CompareMaskedEqual
+	// Largest error number the kernel accepts in a notification response
+	maxErrno = 4095
 )
 
 var (
-	// Error thrown on bad filter context
-	errBadFilter = fmt.Errorf("filter is invalid or uninitialized")
+	// API level detected by libseccomp at startup, the highest the host
+	// supports
+	hostAPI uint
 	// Constants representing library major, minor, and micro versions
 	verMajor = uint(C.get_major_version())
 	verMinor = uint(C.get_minor_version())
 	verMicro = uint(C.get_micro_version())
+	// Sizes of the notification request and response buffers, determined
+	// once
+	notifBufSizesOnce sync.Once
+	notifReqSize      int
+	notifRespSize     int
+	// Notification request buffers, reused across receives
+	notifReqBufs sync.Pool
 )
 
+// Notification request buffer allocated in C memory
+type notifReqBuf struct {
+	req  *C.struct_seccomp_notif
+	size int
+}
+
 // Nonexported functions
 
 // Check if library version is greater than or equal to the given one
@@ -330,7 +587,7 @@ func getAPI() (uint, error) {
 // Set the API level
 func setAPI(api uint) error {
 	if retCode := C.seccomp_api_set(C.uint(api)); retCode != 0 {
-		if errRc(retCode) == syscall.EOPNOTSUPP {
+		if errRc(retCode) == unix.EOPNOTSUPP {
 			return fmt.Errorf("API level operations are not supported")
 		}
 
@@ -340,6 +597,147 @@ func setAPI(api uint) error {
 	return nil
 }
 
+// Helper - Compute the API level required by a set of features
+func requiredAPI(features map[string]uint) uint {
+	var level uint
+	for _, l := range features {
+		if l > level {
+			level = l
+		}
+	}
+
+	return level
+}
+
+// Helper - Raise the API level to the one required by a set of features
+// Returns an error listing the features the host does not support
+func raiseAPI(features map[string]uint) error {
+	level := requiredAPI(features)
+	if level == 0 {
+		return nil
+	}
+
+	current, err := getAPI()
+	if err != nil {
+		return VersionError{
+			message: "automatic API level negotiation is not supported",
+			minimum: "2.4.0",
+		}
+	}
+
+	supported := hostAPI
+	if current > supported {
+		// Forced by the caller, who knows better
+		supported = current
+	}
+
+	var unsupported []string
+	for feature, l := range features {
+		if l > supported {
+			unsupported = append(unsupported, fmt.Sprintf("%s (%d)", feature, l))
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return fmt.Errorf("host supports API level %d, but the filter requires higher levels for: %s",
+			supported, strings.Join(unsupported, ", "))
+	}
+
+	if current < level {
+		return setAPI(level)
+	}
+
+	return nil
+}
+
+// Helper - Get the feature an action belongs to and the API level it
+// requires, or 0 if it does not require any particular level
+func actionAPILevel(action ScmpAction) (string, uint) {
+	switch action & 0xFFFF {
+	case ActNotify:
+		return "notify action", 6
+	case ActLog:
+		return "log action", 3
+	case ActKillProcess:
+		return "kill process action", 3
+	}
+
+	return "", 0
+}
+
+// Helper - Get the feature and API level a filter attribute value requires,
+// or a zero level if it requires none
+func attrAPILevel(attr ScmpFilterAttr, value uint) (string, uint) {
+	if value == 0 {
+		return "", 0
+	}
+
+	switch attr {
+	case AttrActDefault, AttrActBadArch:
+		action, err := actionFromNative(C.uint32_t(value))
+		if err != nil {
+			return "", 0
+		}
+		return actionAPILevel(action)
+	case AttrLog:
+		return "log bit", 3
+	case AttrSSB:
+		return "SSB bit", 4
+	case AttrWaitKill:
+		return "wait killable notifications", 7
+	}
+
+	return "", 0
+}
+
+// Helper - Get the API levels required by the features the filter uses,
+// from its current attributes and the actions of its rules
+// Requires the filter lock
+func (f *ScmpFilter) apiFeatures() map[string]uint {
+	features := make(map[string]uint)
+	use := func(feature string, level uint) {
+		if level != 0 && features[feature] < level {
+			features[feature] = level
+		}
+	}
+
+	for _, attr := range []ScmpFilterAttr{AttrActDefault, AttrActBadArch, AttrLog, AttrSSB, AttrWaitKill} {
+		var value C.uint32_t
+		if C.seccomp_attr_get(f.filterCtx, uint32(attr), &value) == 0 {
+			use(attrAPILevel(attr, uint(value)))
+		}
+	}
+	for _, rule := range f.rules {
+		use(actionAPILevel(rule.Action))
+	}
+
+	return features
+}
+
+// Helper - Raise the API level in auto mode for a feature the filter is
+// about to use; the filter only requires it once libseccomp accepted it
+// Requires the filter lock
+func (f *ScmpFilter) useAPI(feature string, level uint) error {
+	if !f.autoAPI {
+		return nil
+	}
+
+	return raiseAPI(map[string]uint{feature: level})
+}
+
+// Helper - Lock the filter and raise the API level in auto mode for a
+// feature the filter is about to use
+func (f *ScmpFilter) requireAPI(feature string, level uint) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return ErrInvalidFilter
+	}
+
+	return f.useAPI(feature, level)
+}
+
 // Filter helpers
 
 // Filter finalizer - ensure that kernel context for filters is freed
@@ -348,21 +746,31 @@ func filterFinalizer(f *ScmpFilter) {
 }
 
 func errRc(rc C.int) error {
-	return syscall.Errno(-1 * rc)
+	return unix.Errno(-1 * rc)
 }
 
 // Get a raw filter attribute
 func (f *ScmpFilter) getFilterAttr(attr scmpFilterAttr) (C.uint32_t, error) {
+	return f.getNativeFilterAttr(attr.toNative())
+}
+
+// Set a raw filter attribute
+func (f *ScmpFilter) setFilterAttr(attr scmpFilterAttr, value C.uint32_t) error {
+	return f.setNativeFilterAttr(attr.toNative(), value)
+}
+
+// Get a raw filter attribute by its libseccomp value
+func (f *ScmpFilter) getNativeFilterAttr(attr uint32) (C.uint32_t, error) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
 	if !f.valid {
-		return 0x0, errBadFilter
+		return 0x0, ErrInvalidFilter
 	}
 
 	var attribute C.uint32_t
 
-	retCode := C.seccomp_attr_get(f.filterCtx, attr.toNative(), &attribute)
+	retCode := C.seccomp_attr_get(f.filterCtx, attr, &attribute)
 	if retCode != 0 {
 		return 0x0, errRc(retCode)
 	}
@@ -370,16 +778,16 @@ func (f *ScmpFilter) getFilterAttr(attr scmpFilterAttr) (C.uint32_t, error) {
 	return attribute, nil
 }
 
-// Set a raw filter attribute
-func (f *ScmpFilter) setFilterAttr(attr scmpFilterAttr, value C.uint32_t) error {
+// Set a raw filter attribute by its libseccomp value
+func (f *ScmpFilter) setNativeFilterAttr(attr uint32, value C.uint32_t) error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
 	if !f.valid {
-		return errBadFilter
+		return ErrInvalidFilter
 	}
 
-	retCode := C.seccomp_attr_set(f.filterCtx, attr.toNative(), value)
+	retCode := C.seccomp_attr_set(f.filterCtx, attr, value)
 	if retCode != 0 {
 		return errRc(retCode)
 	}
@@ -404,11 +812,11 @@ func (f *ScmpFilter) addRuleWrapper(call ScmpSyscall, action ScmpAction, exact b
 
 	if retCode != 0 {
 		switch e := errRc(retCode); e {
-		case syscall.EFAULT:
+		case unix.EFAULT:
 			return fmt.Errorf("unrecognized syscall %#x", int32(call))
-		case syscall.EPERM:
+		case unix.EPERM:
 			return fmt.Errorf("requested action matches default action of filter")
-		case syscall.EINVAL:
+		case unix.EINVAL:
 			return fmt.Errorf("two checks on same syscall argument")
 		default:
 			return e
@@ -424,7 +832,36 @@ func (f *ScmpFilter) addRuleGeneric(call ScmpSyscall, action ScmpAction, exact b
 	defer f.lock.Unlock()
 
 	if !f.valid {
-		return errBadFilter
+		return ErrInvalidFilter
+	}
+
+	if feature, level := actionAPILevel(action); level != 0 {
+		if err := f.useAPI(feature, level); err != nil {
+			return err
+		}
+	}
+
+	if err := f.addRuleConds(call, action, exact, conds); err != nil {
+		return err
+	}
+	f.trackRule(call, action, exact, conds)
+
+	return nil
+}
+
+// DOES NOT LOCK OR CHECK VALIDITY
+// Assumes caller has already done this
+// Helper - Build the conditions array and add the rule
+func (f *ScmpFilter) addRuleConds(call ScmpSyscall, action ScmpAction, exact bool, conds []ScmpCondition) error {
+	var tskip C.uint32_t
+	if call == -1 {
+		C.seccomp_attr_get(f.filterCtx, filterAttrTSkip.toNative(), &tskip)
+	}
+	if err := checkRuleSyscall(call, tskip != 0); err != nil {
+		return err
+	}
+	if err := f.checkOperands(f.filterArches(), conds); err != nil {
+		return err
 	}
 
 	if len(conds) == 0 {
@@ -460,6 +897,496 @@ func (f *ScmpFilter) addRuleGeneric(call ScmpSyscall, action ScmpAction, exact b
 	return nil
 }
 
+// Add function for rules on raw syscall numbers
+func (f *ScmpFilter) addRuleRaw(arch ScmpArch, call ScmpSyscall, action ScmpAction, conds []ScmpCondition) error {
+	if err := sanitizeArch(arch); err != nil {
+		return err
+	}
+
+	native, err := GetNativeArch()
+	if err != nil {
+		return err
+	}
+	// libseccomp takes the syscall numbers of rules on the native
+	// architecture, and translates them to the others by name
+	if arch != ArchNative && arch != native {
+		return fmt.Errorf("raw syscall numbers are only supported for the native architecture %s, not %s", native, arch)
+	}
+
+	f.lock.Lock()
+	rawPseudo := f.rawPseudo
+	f.lock.Unlock()
+
+	if int32(call) < 0 && !rawPseudo {
+		return fmt.Errorf("syscall number %d is a pseudo-syscall, not a raw syscall number", int32(call))
+	}
+
+	// The rule is restricted to the native architecture, as the other
+	// architectures of the filter would get it translated by syscall name.
+	// Pseudo-syscalls need rewriting, which exact rules refuse
+	return f.addArchRules([]ScmpRule{{
+		Arch:       native,
+		Syscall:    call,
+		Action:     action,
+		Conditions: append([]ScmpCondition(nil), conds...),
+		Exact:      int32(call) >= 0,
+	}})
+}
+
+// Helper - Run a libseccomp export function writing to a file descriptor,
+// copying its output to w through a pipe unless w is a file
+func exportTo(w io.Writer, export func(fd C.int) C.int) error {
+	if file, ok := w.(*os.File); ok {
+		if retCode := export(C.int(file.Fd())); retCode != 0 {
+			return errRc(retCode)
+		}
+		return nil
+	}
+
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	copied := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(w, r)
+		if err != nil {
+			// Keep draining the pipe so libseccomp does not block
+			io.Copy(ioutil.Discard, r)
+		}
+		copied <- err
+	}()
+
+	retCode := export(C.int(pw.Fd()))
+	pw.Close()
+	err = <-copied
+
+	if retCode != 0 {
+		return errRc(retCode)
+	}
+	return err
+}
+
+// Helper - Export the BPF program of the filter into memory
+// Requires the filter lock
+func (f *ScmpFilter) exportBPFMem() ([]byte, error) {
+	if !checkVersionAbove(2, 6, 0) {
+		var prog bytes.Buffer
+		err := exportTo(&prog, func(fd C.int) C.int {
+			return C.seccomp_export_bpf(f.filterCtx, fd)
+		})
+		return prog.Bytes(), err
+	}
+
+	// Query the size of the program first
+	var length C.size_t
+	if retCode := C.seccomp_export_bpf_mem(f.filterCtx, nil, &length); retCode != 0 {
+		return nil, errRc(retCode)
+	}
+	if length == 0 {
+		return []byte{}, nil
+	}
+
+	prog := make([]byte, length)
+	if retCode := C.seccomp_export_bpf_mem(f.filterCtx, unsafe.Pointer(&prog[0]), &length); retCode != 0 {
+		return nil, errRc(retCode)
+	}
+
+	return prog[:length], nil
+}
+
+// Helper - Load the BPF program of the filter with the seccomp(2) system
+// call, passing the given flags
+// Requires the filter lock
+func (f *ScmpFilter) loadWithFlags(flags LoadFlag) error {
+	const seccompSetModeFilter = 1
+
+	prog, err := f.exportBPFMem()
+	if err != nil {
+		return fmt.Errorf("could not generate filter program: %v", err)
+	} else if len(prog) == 0 {
+		return fmt.Errorf("filter program is empty")
+	}
+
+	var nnp C.uint32_t
+	if C.seccomp_attr_get(f.filterCtx, filterAttrNNP.toNative(), &nnp) == 0 && nnp != 0 {
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("could not set no new privileges bit: %v", err)
+		}
+	}
+
+	fprog := unix.SockFprog{
+		Len:    uint16(len(prog) / unix.SizeofSockFilter),
+		Filter: (*unix.SockFilter)(unsafe.Pointer(&prog[0])),
+	}
+	ret, _, errno := unix.Syscall(unix.SYS_SECCOMP, seccompSetModeFilter, uintptr(flags),
+		uintptr(unsafe.Pointer(&fprog)))
+	runtime.KeepAlive(prog)
+
+	if flags&LoadFlagTsync != 0 && errno == unix.ESRCH {
+		return &TsyncError{}
+	} else if errno != 0 {
+		return errno
+	}
+
+	if flags&LoadFlagNewListener != 0 {
+		f.listenerFd = ScmpFd(ret)
+		f.hasListener = true
+	} else if flags&LoadFlagTsync != 0 && flags&LoadFlagTsyncESRCH == 0 && ret != 0 {
+		return &TsyncError{ThreadID: int(ret)}
+	}
+
+	return nil
+}
+
+// Helper - Load the filter into the kernel
+// Requires the filter lock
+func (f *ScmpFilter) load() error {
+	if !f.valid {
+		return ErrInvalidFilter
+	}
+
+	if f.autoAPI {
+		if err := raiseAPI(f.apiFeatures()); err != nil {
+			return err
+		}
+	}
+
+	if retCode := C.seccomp_load(f.filterCtx); retCode != 0 {
+		err := errRc(retCode)
+		if err == unix.ESRCH || err == unix.ECANCELED {
+			return f.retryTsync(err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Helper - Load the filter without synchronizing it to the other threads
+// Requires the filter lock
+func (f *ScmpFilter) loadOnCurrentThread() error {
+	if !f.valid {
+		return ErrInvalidFilter
+	}
+
+	var tsync C.uint32_t
+	if C.seccomp_attr_get(f.filterCtx, filterAttrTsync.toNative(), &tsync) == 0 && tsync != 0 {
+		if retCode := C.seccomp_attr_set(f.filterCtx, filterAttrTsync.toNative(), 0); retCode != 0 {
+			return fmt.Errorf("could not disable TSYNC: %v", errRc(retCode))
+		}
+		defer C.seccomp_attr_set(f.filterCtx, filterAttrTsync.toNative(), tsync)
+	}
+
+	return f.load()
+}
+
+// Helper - Retry loading a filter libseccomp failed to load with err, in case
+// it could not be synchronized to all threads, to find out which thread
+// Returns err if the failure is not known to be a synchronization failure
+// Requires the filter lock
+func (f *ScmpFilter) retryTsync(err error) error {
+	attrs := make(map[scmpFilterAttr]C.uint32_t)
+	for _, attr := range []scmpFilterAttr{filterAttrTsync, filterAttrLog, filterAttrSSB} {
+		var value C.uint32_t
+		if C.seccomp_attr_get(f.filterCtx, attr.toNative(), &value) == 0 {
+			attrs[attr] = value
+		}
+	}
+	if attrs[filterAttrTsync] == 0 {
+		return err
+	}
+
+	// The kernel only reports the thread when no notification fd is created
+	for _, rule := range f.rules {
+		if rule.Action&0xFFFF == ActNotify {
+			return err
+		}
+	}
+
+	flags := LoadFlagTsync
+	if attrs[filterAttrLog] != 0 {
+		flags |= LoadFlagLog
+	}
+	if attrs[filterAttrSSB] != 0 {
+		flags |= LoadFlagSpecAllow
+	}
+
+	retryErr := f.loadWithFlags(flags)
+	if _, ok := retryErr.(*TsyncError); ok || retryErr == nil {
+		return retryErr
+	}
+
+	return err
+}
+
+// Helper - Generate the BPF program of the filter ahead of time
+// Requires the filter lock
+func (f *ScmpFilter) precompute() error {
+	if !checkVersionAbove(2, 6, 0) {
+		return VersionError{
+			message: "precomputing filters is not supported",
+			minimum: "2.6.0",
+		}
+	}
+
+	if retCode := C.seccomp_precompute(f.filterCtx); retCode != 0 {
+		return errRc(retCode)
+	}
+
+	return nil
+}
+
+// Helper - Start a libseccomp transaction, if supported
+// Returns whether a transaction was started
+// Requires the filter lock
+func (f *ScmpFilter) transactionStart() (bool, error) {
+	if !checkVersionAbove(2, 6, 0) {
+		return false, nil
+	}
+
+	if retCode := C.seccomp_transaction_start(f.filterCtx); retCode != 0 {
+		return false, errRc(retCode)
+	}
+
+	return true, nil
+}
+
+// Helper - Commit the innermost libseccomp transaction
+// Requires the filter lock
+func (f *ScmpFilter) transactionCommit() error {
+	if retCode := C.seccomp_transaction_commit(f.filterCtx); retCode != 0 {
+		return errRc(retCode)
+	}
+
+	return nil
+}
+
+// Helper - Reject the innermost libseccomp transaction
+// Requires the filter lock
+func (f *ScmpFilter) transactionReject() {
+	C.seccomp_transaction_reject(f.filterCtx)
+}
+
+// Helper - Reset the filter and its tracked state
+// Requires the filter lock
+func (f *ScmpFilter) reset(defaultAction ScmpAction) error {
+	if retCode := C.seccomp_reset(f.filterCtx, defaultAction.toNative()); retCode != 0 {
+		return errRc(retCode)
+	}
+
+	f.rules = nil
+	f.priorities = make(map[ScmpSyscall]uint8)
+	f.transactions = nil
+
+	return nil
+}
+
+// Helper - Find the conflict preventing the source filter from being merged
+// into the filter, if any
+// Requires the locks of both filters
+func (f *ScmpFilter) mergeConflict(src *ScmpFilter) error {
+	for _, attr := range []scmpFilterAttr{filterAttrActDefault, filterAttrNNP, filterAttrTsync} {
+		var dstValue, srcValue C.uint32_t
+		if C.seccomp_attr_get(f.filterCtx, attr.toNative(), &dstValue) != 0 ||
+			C.seccomp_attr_get(src.filterCtx, attr.toNative(), &srcValue) != 0 {
+			continue
+		}
+		if dstValue != srcValue {
+			return &MergeError{Attr: ScmpFilterAttr(attr.toNative())}
+		}
+	}
+
+	for _, arch := range src.filterArches() {
+		if f.archPresent(arch) {
+			return &MergeError{Arch: arch}
+		}
+	}
+
+	return nil
+}
+
+// Helper - Check whether an architecture is present in the filter
+// Requires the filter lock
+func (f *ScmpFilter) archPresent(arch ScmpArch) bool {
+	return C.seccomp_arch_exist(f.filterCtx, arch.toNative()) == 0
+}
+
+// Helper - List the architectures present in the filter
+// Requires the filter lock
+func (f *ScmpFilter) filterArches() []ScmpArch {
+	var arches []ScmpArch
+	for a := archStart + 1; a <= archEnd; a++ {
+		if a.toNative() != C.C_ARCH_BAD && f.archPresent(a) {
+			arches = append(arches, a)
+		}
+	}
+
+	return arches
+}
+
+// Helper - Rebuild the libseccomp filter for the given architectures from the
+// tracked rules
+// Requires the filter lock
+func (f *ScmpFilter) rebuild(arches []ScmpArch) error {
+	ctx, err := f.build(arches)
+	if err != nil {
+		return err
+	}
+
+	C.seccomp_release(f.filterCtx)
+	f.filterCtx = ctx
+
+	// The libseccomp transactions went away with the old filter, active
+	// transactions are rejected by rebuilding from the tracked rules instead
+	for _, tx := range f.transactions {
+		tx.native = false
+	}
+
+	return nil
+}
+
+// Helper - Build a new libseccomp filter for the given architectures, with
+// the attributes of the filter and its tracked rules, one architecture at a
+// time so rules restricted to an architecture only apply to it, and merge
+// the results
+// Requires the filter lock
+func (f *ScmpFilter) build(arches []ScmpArch) (C.scmp_filter_ctx, error) {
+	if len(arches) == 0 {
+		return nil, fmt.Errorf("filter has no architectures")
+	}
+
+	native, err := GetNativeArch()
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[scmpFilterAttr]C.uint32_t)
+	for _, attr := range []scmpFilterAttr{filterAttrActDefault, filterAttrActBadArch,
+		filterAttrNNP, filterAttrTsync, filterAttrTSkip, filterAttrLog, filterAttrSSB,
+		filterAttrOptimize, filterAttrRawRC, filterAttrWaitKill} {
+		var value C.uint32_t
+		if C.seccomp_attr_get(f.filterCtx, attr.toNative(), &value) == 0 {
+			attrs[attr] = value
+		}
+	}
+
+	var ctxs []C.scmp_filter_ctx
+	release := func() {
+		for _, ctx := range ctxs {
+			if ctx != nil {
+				C.seccomp_release(ctx)
+			}
+		}
+	}
+
+	// The filter reporting notifications must hold the notify rules
+	dst := 0
+	for _, arch := range arches {
+		ctx := C.seccomp_init(attrs[filterAttrActDefault])
+		if ctx == nil {
+			release()
+			return nil, fmt.Errorf("could not create filter")
+		}
+		ctxs = append(ctxs, ctx)
+
+		if arch != native {
+			if retCode := C.seccomp_arch_add(ctx, arch.toNative()); retCode != 0 {
+				release()
+				return nil, errRc(retCode)
+			}
+			if retCode := C.seccomp_arch_remove(ctx, native.toNative()); retCode != 0 {
+				release()
+				return nil, errRc(retCode)
+			}
+		}
+
+		for attr, value := range attrs {
+			if attr == filterAttrActDefault {
+				continue
+			}
+			if retCode := C.seccomp_attr_set(ctx, attr.toNative(), value); retCode != 0 && value != 0 {
+				release()
+				return nil, fmt.Errorf("could not set filter attribute: %v", errRc(retCode))
+			}
+		}
+
+		notify, err := f.replayRules(ctx, arch, native)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		if notify && dst == 0 {
+			dst = len(ctxs) - 1
+		}
+	}
+
+	merged := ctxs[dst]
+	for i, ctx := range ctxs {
+		if i == dst {
+			continue
+		}
+		if retCode := C.seccomp_merge(merged, ctx); retCode != 0 {
+			release()
+			return nil, fmt.Errorf("could not merge architecture filters: %v", errRc(retCode))
+		}
+		// Released by libseccomp
+		ctxs[i] = nil
+	}
+
+	return merged, nil
+}
+
+// Helper - Add the tracked rules and priorities applying to an architecture
+// to a libseccomp filter holding only that architecture
+// Returns whether a rule notifies userspace
+// Requires the filter lock
+func (f *ScmpFilter) replayRules(ctx C.scmp_filter_ctx, arch, native ScmpArch) (bool, error) {
+	filter := &ScmpFilter{filterCtx: ctx, valid: true, truncateOperands: f.truncateOperands}
+	notify := false
+
+	for _, rule := range f.rules {
+		call := rule.Syscall
+		if rule.Arch != ArchInvalid && rule.Arch != arch {
+			continue
+		} else if rule.Arch != ArchInvalid && arch != native {
+			// libseccomp translates syscalls from the native architecture
+			name, err := call.GetNameByArch(arch)
+			if err != nil {
+				return false, fmt.Errorf("could not resolve syscall %d on %s: %v", int32(call), arch, err)
+			}
+			if call, err = GetSyscallFromName(name); err != nil {
+				return false, fmt.Errorf("could not resolve syscall %q: %v", name, err)
+			}
+		}
+
+		if err := filter.addRuleConds(call, rule.Action, rule.Exact, rule.Conditions); err != nil {
+			return false, err
+		}
+		if rule.Action&0xFFFF == ActNotify {
+			notify = true
+		}
+	}
+
+	for call, priority := range f.priorities {
+		// Syscalls missing on the architecture have no priority there
+		C.seccomp_syscall_priority(ctx, C.int(call), C.uint8_t(priority))
+	}
+
+	return notify, nil
+}
+
+// Helper - Check whether libseccomp itself knows a syscall name on the native
+// architecture, ignoring the supplemental tables
+func libseccompKnowsSyscall(name string) bool {
+	cString := C.CString(name)
+	defer C.free(unsafe.Pointer(cString))
+
+	return C.seccomp_syscall_resolve_name(cString) != scmpError
+}
+
 // Generic Helpers
 
 // Helper - Sanitize Arch token input
@@ -536,11 +1463,29 @@ func archFromNative(a C.uint32_t) (ScmpArch, error) {
 		return ArchPARISC, nil
 	case C.C_ARCH_PARISC64:
 		return ArchPARISC64, nil
+	case C.C_ARCH_LOONGARCH64:
+		return ArchLOONGARCH64, nil
+	case C.C_ARCH_RISCV64:
+		return ArchRISCV64, nil
+	case C.C_ARCH_RISCV32:
+		return ArchRISCV32, nil
+	case C.C_ARCH_M68K:
+		return ArchM68K, nil
+	case C.C_ARCH_SH:
+		return ArchSH, nil
+	case C.C_ARCH_SHEB:
+		return ArchSHEB, nil
 	default:
 		return 0x0, fmt.Errorf("unrecognized architecture %#x", uint32(a))
 	}
 }
 
+// Helper - Convert an AUDIT_ARCH_* token, as found in seccomp_data, into an
+// architecture
+func archFromAudit(token uint32) (ScmpArch, error) {
+	return archFromNative(C.uint32_t(token))
+}
+
 // Only use with sanitized arches, no error handling
 func (a ScmpArch) toNative() C.uint32_t {
 	switch a {
@@ -580,6 +1525,18 @@ func (a ScmpArch) toNative() C.uint32_t {
 		return C.C_ARCH_PARISC
 	case ArchPARISC64:
 		return C.C_ARCH_PARISC64
+	case ArchLOONGARCH64:
+		return C.C_ARCH_LOONGARCH64
+	case ArchRISCV64:
+		return C.C_ARCH_RISCV64
+	case ArchRISCV32:
+		return C.C_ARCH_RISCV32
+	case ArchM68K:
+		return C.C_ARCH_M68K
+	case ArchSH:
+		return C.C_ARCH_SH
+	case ArchSHEB:
+		return C.C_ARCH_SHEB
 	case ArchNative:
 		return C.C_ARCH_NATIVE
 	default:
@@ -635,6 +1592,16 @@ func actionFromNative(a C.uint32_t) (ScmpAction, error) {
 	}
 }
 
+// Helper - Convert a filter return value (SECCOMP_RET_*) into an action
+func actionFromRet(ret uint32) (ScmpAction, error) {
+	return actionFromNative(C.uint32_t(ret))
+}
+
+// Helper - Convert a sanitized action into a filter return value
+func actionToRet(a ScmpAction) uint32 {
+	return uint32(a.toNative())
+}
+
 // Only use with sanitized actions, no error handling
 func (a ScmpAction) toNative() C.uint32_t {
 	switch a & 0xFFFF {
@@ -672,10 +1639,18 @@ func (a scmpFilterAttr) toNative() uint32 {
 		return uint32(C.C_ATTRIBUTE_NNP)
 	case filterAttrTsync:
 		return uint32(C.C_ATTRIBUTE_TSYNC)
+	case filterAttrTSkip:
+		return uint32(C.C_ATTRIBUTE_TSKIP)
 	case filterAttrLog:
 		return uint32(C.C_ATTRIBUTE_LOG)
 	case filterAttrSSB:
 		return uint32(C.C_ATTRIBUTE_SSB)
+	case filterAttrOptimize:
+		return uint32(C.C_ATTRIBUTE_OPTIMIZE)
+	case filterAttrRawRC:
+		return uint32(C.C_ATTRIBUTE_SYSRAWRC)
+	case filterAttrWaitKill:
+		return uint32(C.C_ATTRIBUTE_WAITKILL)
 	default:
 		return 0x0
 	}
@@ -689,39 +1664,93 @@ func syscallFromNative(a C.int) ScmpSyscall {
 	return ScmpSyscall(a)
 }
 
-func notifReqFromNative(req *C.struct_seccomp_notif) (*ScmpNotifReq, error) {
-	scmpArgs := make([]uint64, 6)
-	for i := 0; i < len(scmpArgs); i++ {
-		scmpArgs[i] = uint64(req.data.args[i])
+func notifReqFromNative(req *C.struct_seccomp_notif, size int) (*ScmpNotifReq, error) {
+	scmpReq := new(ScmpNotifReq)
+	if err := scmpReq.fromNative(req, size); err != nil {
+		return nil, err
 	}
 
+	return scmpReq, nil
+}
+
+// Helper - Fill in a notification from its native representation, reusing
+// the slices of the notification
+func (r *ScmpNotifReq) fromNative(req *C.struct_seccomp_notif, size int) error {
 	arch, err := archFromNative(req.data.arch)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	scmpArgs := r.Data.Args
+	if cap(scmpArgs) < 6 {
+		scmpArgs = make([]uint64, 6)
+	}
+	scmpArgs = scmpArgs[:6]
+	for i := 0; i < len(scmpArgs); i++ {
+		scmpArgs[i] = uint64(req.data.args[i])
 	}
 
-	scmpData := ScmpNotifData{
+	r.ID = uint64(req.id)
+	r.Pid = uint32(req.pid)
+	r.Flags = uint32(req.flags)
+	r.Data = ScmpNotifData{
 		Syscall:      syscallFromNative(req.data.nr),
 		Arch:         arch,
 		InstrPointer: uint64(req.data.instruction_pointer),
 		Args:         scmpArgs,
 	}
 
-	scmpReq := &ScmpNotifReq{
-		ID:    uint64(req.id),
-		Pid:   uint32(req.pid),
-		Flags: uint32(req.flags),
-		Data:  scmpData,
+	// Preserve the fields of newer kernels
+	r.Extra = r.Extra[:0]
+	if known := int(C.sizeof_struct_seccomp_notif); size > known {
+		extra := (*[1 << 16]byte)(unsafe.Pointer(uintptr(unsafe.Pointer(req)) + uintptr(known)))
+		r.Extra = append(r.Extra, extra[:size-known]...)
 	}
 
-	return scmpReq, nil
+	return nil
 }
 
-func (scmpResp *ScmpNotifResp) toNative(resp *C.struct_seccomp_notif_resp) {
+func (scmpResp *ScmpNotifResp) toNative(resp *C.struct_seccomp_notif_resp, size int) error {
+	known := int(C.sizeof_struct_seccomp_notif_resp)
+	if len(scmpResp.Extra) > size-known {
+		return fmt.Errorf("response has %d bytes of extra fields, kernel supports %d", len(scmpResp.Extra), size-known)
+	}
+
 	resp.id = C.__u64(scmpResp.ID)
 	resp.val = C.__s64(scmpResp.Val)
 	resp.error = (C.__s32(scmpResp.Error) * -1) // kernel requires a negated value
 	resp.flags = C.__u32(scmpResp.Flags)
+
+	if len(scmpResp.Extra) > 0 {
+		extra := (*[1 << 16]byte)(unsafe.Pointer(uintptr(unsafe.Pointer(resp)) + uintptr(known)))
+		copy(extra[:size-known], scmpResp.Extra)
+	}
+
+	return nil
+}
+
+// Helper - Get the sizes of the notification request and response buffers,
+// large enough for the structures of both the kernel and the bindings
+func notifBufSizes() (int, int) {
+	notifBufSizesOnce.Do(func() {
+		notifReqSize = int(C.sizeof_struct_seccomp_notif)
+		notifRespSize = int(C.sizeof_struct_seccomp_notif_resp)
+
+		// The kernel may use larger structures than the bindings were
+		// built with
+		sizes, err := notifGetSizes()
+		if err != nil {
+			return
+		}
+		if int(sizes.Notif) > notifReqSize {
+			notifReqSize = int(sizes.Notif)
+		}
+		if int(sizes.NotifResp) > notifRespSize {
+			notifRespSize = int(sizes.NotifResp)
+		}
+	})
+
+	return notifReqSize, notifRespSize
 }
 
 // Userspace Notification API
@@ -732,79 +1761,195 @@ func (f *ScmpFilter) getNotifFd() (ScmpFd, error) {
 	defer f.lock.Unlock()
 
 	if !f.valid {
-		return -1, errBadFilter
+		return -1, ErrInvalidFilter
+	}
+
+	if err := f.checkNotifAPI(); err != nil {
+		return -1, err
+	}
+
+	return f.notifFd(), nil
+}
+
+// Helper - Check that the API level supports notifications
+// Requires the filter lock
+func (f *ScmpFilter) checkNotifAPI() error {
+	if err := f.useAPI("notification fd", 6); err != nil {
+		return err
 	}
 
 	// Ignore error, if not supported returns apiLevel == 0
 	apiLevel, _ := GetAPI()
 	if apiLevel < 6 {
-		return -1, fmt.Errorf("seccomp notification requires API level >= 6; current level = %d", apiLevel)
+		return fmt.Errorf("seccomp notification requires API level >= 6; current level = %d", apiLevel)
+	}
+
+	return nil
+}
+
+// Helper - Get the notification fd of a loaded filter, -1 if there is none
+// Requires the filter lock
+func (f *ScmpFilter) notifFd() ScmpFd {
+	if f.hasListener {
+		return f.listenerFd
+	}
+
+	return ScmpFd(C.seccomp_notify_fd(f.filterCtx))
+}
+
+// Helper - Get a notification request buffer of the size used by the kernel
+// from the pool of buffers, freed once they are no longer referenced
+func getNotifReqBuf() *notifReqBuf {
+	if buf, ok := notifReqBufs.Get().(*notifReqBuf); ok {
+		return buf
 	}
 
-	fd := C.seccomp_notify_fd(f.filterCtx)
+	size, _ := notifBufSizes()
+	req := (*C.struct_seccomp_notif)(C.calloc(1, C.size_t(size)))
+	if req == nil {
+		return nil
+	}
+
+	buf := &notifReqBuf{req: req, size: size}
+	runtime.SetFinalizer(buf, func(buf *notifReqBuf) {
+		C.free(unsafe.Pointer(buf.req))
+	})
 
-	return ScmpFd(fd), nil
+	return buf
 }
 
 func notifReceive(fd ScmpFd) (*ScmpNotifReq, error) {
-	var req *C.struct_seccomp_notif
-	var resp *C.struct_seccomp_notif_resp
+	scmpReq := new(ScmpNotifReq)
+	if err := notifReceiveInto(fd, scmpReq); err != nil {
+		return nil, err
+	}
 
+	return scmpReq, nil
+}
+
+func notifReceiveInto(fd ScmpFd, scmpReq *ScmpNotifReq) error {
 	// Ignore error, if not supported returns apiLevel == 0
 	apiLevel, _ := GetAPI()
 	if apiLevel < 6 {
-		return nil, fmt.Errorf("seccomp notification requires API level >= 6; current level = %d", apiLevel)
+		return fmt.Errorf("seccomp notification requires API level >= 6; current level = %d", apiLevel)
 	}
 
-	// we only use the request here; the response is unused
-	if retCode := C.seccomp_notify_alloc(&req, &resp); retCode != 0 {
-		return nil, errRc(retCode)
+	w, err := notifAcquire(fd)
+	if err != nil {
+		return err
+	}
+	defer notifRelease(fd, w)
+	if err := w.wait(context.Background(), fd); err != nil {
+		return err
 	}
 
-	defer func() {
-		C.seccomp_notify_free(req, resp)
-	}()
+	buf := getNotifReqBuf()
+	if buf == nil {
+		return unix.ENOMEM
+	}
+	defer notifReqBufs.Put(buf)
 
 	for {
-		retCode, errno := C.seccomp_notify_receive(C.int(fd), req)
+		// The kernel rejects requests which are not zeroed
+		C.memset(unsafe.Pointer(buf.req), 0, C.size_t(buf.size))
+
+		retCode, errno := C.seccomp_notify_receive(C.int(fd), buf.req)
 		if retCode == 0 {
 			break
 		}
 
-		if errno == syscall.EINTR {
+		if errno == unix.EINTR {
 			continue
 		}
 
-		if errno == syscall.ENOENT {
-			return nil, errno
+		if errno == unix.ENOENT {
+			return ErrNotifTargetGone
+		} else if errno == unix.EBADF {
+			return ErrNotifClosed
 		}
 
+		return errRc(retCode)
+	}
+
+	if err := scmpReq.fromNative(buf.req, buf.size); err != nil {
+		return err
+	}
+	scmpReq.fd, scmpReq.hasFd = fd, true
+
+	return nil
+}
+
+func notifReceiveBatch(fd ScmpFd, max int) ([]*ScmpNotifReq, error) {
+	// Ignore error, if not supported returns apiLevel == 0
+	apiLevel, _ := GetAPI()
+	if apiLevel < 6 {
+		return nil, fmt.Errorf("seccomp notification requires API level >= 6; current level = %d", apiLevel)
+	}
+
+	if max <= 0 {
+		return nil, fmt.Errorf("invalid maximum number of notifications %d", max)
+	}
+
+	w, err := notifAcquire(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer notifRelease(fd, w)
+	if err := w.wait(context.Background(), fd); err != nil {
+		return nil, err
+	}
+
+	size, _ := notifBufSizes()
+	buf := C.calloc(C.size_t(max), C.size_t(size))
+	if buf == nil {
+		return nil, unix.ENOMEM
+	}
+	defer C.free(buf)
+
+	retCode := C.notify_receive_batch(C.int(fd), buf, C.size_t(size), C.uint(max))
+	if retCode < 0 {
+		if errRc(retCode) == unix.EBADF {
+			return nil, ErrNotifClosed
+		}
 		return nil, errRc(retCode)
 	}
 
-	return notifReqFromNative(req)
+	reqs := make([]*ScmpNotifReq, 0, int(retCode))
+	for i := 0; i < int(retCode); i++ {
+		req := (*C.struct_seccomp_notif)(unsafe.Pointer(uintptr(buf) + uintptr(i*size)))
+		scmpReq, err := notifReqFromNative(req, size)
+		if err != nil {
+			return nil, err
+		}
+		scmpReq.fd, scmpReq.hasFd = fd, true
+
+		reqs = append(reqs, scmpReq)
+	}
+
+	return reqs, nil
 }
 
 func notifRespond(fd ScmpFd, scmpResp *ScmpNotifResp) error {
-	var req *C.struct_seccomp_notif
-	var resp *C.struct_seccomp_notif_resp
-
 	// Ignore error, if not supported returns apiLevel == 0
 	apiLevel, _ := GetAPI()
 	if apiLevel < 6 {
 		return fmt.Errorf("seccomp notification requires API level >= 6; current level = %d", apiLevel)
 	}
 
-	// we only use the reponse here; the request is discarded
-	if retCode := C.seccomp_notify_alloc(&req, &resp); retCode != 0 {
-		return errRc(retCode)
+	if err := scmpResp.Validate(); err != nil {
+		return err
 	}
 
-	defer func() {
-		C.seccomp_notify_free(req, resp)
-	}()
+	_, size := notifBufSizes()
+	resp := (*C.struct_seccomp_notif_resp)(C.calloc(1, C.size_t(size)))
+	if resp == nil {
+		return unix.ENOMEM
+	}
+	defer C.free(unsafe.Pointer(resp))
 
-	scmpResp.toNative(resp)
+	if err := scmpResp.toNative(resp, size); err != nil {
+		return err
+	}
 
 	for {
 		retCode, errno := C.seccomp_notify_respond(C.int(fd), resp)
@@ -812,20 +1957,133 @@ func notifRespond(fd ScmpFd, scmpResp *ScmpNotifResp) error {
 			break
 		}
 
-		if errno == syscall.EINTR {
+		if errno == unix.EINTR {
 			continue
 		}
 
-		if errno == syscall.ENOENT {
-			return errno
+		if errno == unix.ENOENT {
+			return ErrNotifTargetGone
+		}
+
+		return errRc(retCode)
+	}
+
+	return nil
+}
+
+func notifIDsValid(fd ScmpFd, ids []uint64) ([]bool, error) {
+	// Ignore error, if not supported returns apiLevel == 0
+	apiLevel, _ := GetAPI()
+	if apiLevel < 6 {
+		return nil, fmt.Errorf("seccomp notification requires API level >= 6; current level = %d", apiLevel)
+	}
+
+	valid := make([]bool, len(ids))
+	if len(ids) == 0 {
+		return valid, nil
+	}
+
+	// Neither slice holds Go pointers, so both can be handed to C directly
+	cValid := make([]C.uchar, len(ids))
+	retCode := C.notify_ids_valid(C.int(fd), (*C.uint64_t)(unsafe.Pointer(&ids[0])),
+		C.uint(len(ids)), &cValid[0])
+	if retCode != 0 {
+		return nil, errRc(retCode)
+	}
+
+	for i := range valid {
+		valid[i] = cValid[i] != 0
+	}
+
+	return valid, nil
+}
+
+func notifAddFd(fd ScmpFd, req *ScmpNotifAddFd) (int, error) {
+	newFd, err := notifAddFdRaw(fd, req)
+	if err == unix.EINVAL && req.Flags&NotifAddFdFlagSend != 0 {
+		// Kernels older than 5.14 reject the unknown flag
+		return notifAddFdAndRespond(fd, req)
+	}
+
+	return newFd, err
+}
+
+// Helper - Install a file descriptor and respond to the notification in two
+// steps, for kernels without SECCOMP_ADDFD_FLAG_SEND
+func notifAddFdAndRespond(fd ScmpFd, req *ScmpNotifAddFd) (int, error) {
+	addFd := *req
+	addFd.Flags &^= NotifAddFdFlagSend
+
+	newFd, err := notifAddFdRaw(fd, &addFd)
+	if err != nil {
+		return -1, err
+	}
+
+	if err := notifRespond(fd, &ScmpNotifResp{ID: req.ID, Val: uint64(newFd)}); err != nil {
+		return -1, err
+	}
+
+	return newFd, nil
+}
+
+// Helper - Install a file descriptor with the SECCOMP_IOCTL_NOTIF_ADDFD ioctl
+func notifAddFdRaw(fd ScmpFd, req *ScmpNotifAddFd) (int, error) {
+	for {
+		retCode := C.notify_addfd(C.int(fd), C.uint64_t(req.ID), C.uint32_t(req.Flags),
+			C.uint32_t(req.SrcFd), C.uint32_t(req.NewFd), C.uint32_t(req.NewFdFlags))
+		if retCode >= 0 {
+			return int(retCode), nil
+		}
+
+		if errRc(retCode) == unix.EINTR {
+			continue
+		}
+
+		if errRc(retCode) == unix.ENOENT {
+			return -1, ErrNotifTargetGone
 		}
 
+		return -1, errRc(retCode)
+	}
+}
+
+func notifProbeContinue(fd ScmpFd) error {
+	if retCode := C.notify_probe_continue(C.int(fd)); retCode != 0 {
 		return errRc(retCode)
 	}
 
 	return nil
 }
 
+func notifSetFlags(fd ScmpFd, flags uint64) error {
+	for {
+		retCode := C.notify_set_flags(C.int(fd), C.uint64_t(flags))
+		if retCode == 0 {
+			return nil
+		}
+
+		if errRc(retCode) == unix.EINTR {
+			continue
+		}
+
+		return errRc(retCode)
+	}
+}
+
+func notifGetSizes() (*ScmpNotifSizes, error) {
+	var notif, resp, data C.uint16_t
+
+	if retCode := C.notify_get_sizes(&notif, &resp, &data); retCode != 0 {
+		return nil, errRc(retCode)
+	}
+
+	return &ScmpNotifSizes{
+		Notif:     uint16(notif),
+		NotifResp: uint16(resp),
+		Data:      uint16(data),
+	}, nil
+}
+
 func notifIDValid(fd ScmpFd, id uint64) error {
 	// Ignore error, if not supported returns apiLevel == 0
 	apiLevel, _ := GetAPI()
@@ -839,11 +2097,14 @@ func notifIDValid(fd ScmpFd, id uint64) error {
 			break
 		}
 
-		if errno == syscall.EINTR {
+		if errno == unix.EINTR {
 			continue
 		}
 
-		if errno == syscall.ENOENT {
+		if errno == unix.ENOENT {
+			return ErrNotifTargetGone
+		} else if errno != nil {
+			// libseccomp reports all failures as -ENOENT
 			return errno
 		}
 
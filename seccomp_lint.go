@@ -0,0 +1,204 @@
+// +build linux
+
+// Filter linting for libseccomp Go bindings
+// Detects rules which do not take effect as intended
+
+package seccomp
+
+import (
+	"fmt"
+	"math"
+)
+
+// LintKind is the kind of problem found by Lint.
+type LintKind uint
+
+const (
+	// LintDuplicateRule is a rule identical to an earlier rule
+	LintDuplicateRule LintKind = iota + 1
+	// LintDefaultAction is a rule taking the default action of the filter,
+	// which has no effect
+	LintDefaultAction
+	// LintShadowedRule is a conditional rule on a syscall which also has an
+	// unconditional rule, which libseccomp gives precedence to
+	LintShadowedRule
+	// LintNeverMatches is a rule with conditions which can never all match
+	LintNeverMatches
+	// LintUnknownSyscall is a rule on a syscall which does not exist on some
+	// architecture of the filter
+	LintUnknownSyscall
+)
+
+// String returns a string representation of a lint kind.
+func (k LintKind) String() string {
+	switch k {
+	case LintDuplicateRule:
+		return "duplicate rule"
+	case LintDefaultAction:
+		return "default action"
+	case LintShadowedRule:
+		return "shadowed rule"
+	case LintNeverMatches:
+		return "never matches"
+	case LintUnknownSyscall:
+		return "unknown syscall"
+	default:
+		return fmt.Sprintf("Unknown lint kind %d", uint(k))
+	}
+}
+
+// LintIssue is a problem found in the rules of a filter by Lint.
+//
+// Kind:    kind of problem
+// Rule:    rule with the problem, as returned by ListRules
+// Arch:    architecture the syscall does not exist on, for LintUnknownSyscall
+// Message: description of the problem
+//
+type LintIssue struct {
+	Kind    LintKind
+	Rule    ScmpRule
+	Arch    ScmpArch
+	Message string
+}
+
+// String returns the description of the issue.
+func (i LintIssue) String() string {
+	return i.Message
+}
+
+// Lint checks the rules tracked by the bindings for mistakes which silently
+// leave holes in the policy: duplicate rules, rules taking the default
+// action, conditional rules shadowed by unconditional ones, conditions which
+// can never match and syscalls which do not exist on some architectures of
+// the filter. Running it when building a filter catches these before the
+// filter is loaded.
+// Returns the issues found in the order of the rules, or nil if there are
+// none or the filter is invalid.
+func (f *ScmpFilter) Lint() []LintIssue {
+	state, err := f.MarshalState()
+	if err != nil {
+		return nil
+	}
+
+	var issues []LintIssue
+	add := func(kind LintKind, rule ScmpRule, arch ScmpArch, format string, a ...interface{}) {
+		issues = append(issues, LintIssue{
+			Kind:    kind,
+			Rule:    rule,
+			Arch:    arch,
+			Message: lintRuleString(rule, state.NativeArch) + ": " + fmt.Sprintf(format, a...),
+		})
+	}
+
+	for i, rule := range state.Rules {
+		duplicate := false
+		for _, prev := range state.Rules[:i] {
+			if prev.Arch == rule.Arch && prev.Syscall == rule.Syscall &&
+				prev.Action == rule.Action && sameConditions(prev.Conditions, rule.Conditions) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			add(LintDuplicateRule, rule, ArchInvalid, "duplicates an earlier rule")
+			continue
+		}
+
+		if rule.Action == state.DefaultAction {
+			add(LintDefaultAction, rule, ArchInvalid, "takes the default action %s", rule.Action)
+		}
+
+		if len(rule.Conditions) > 0 {
+			for _, other := range state.Rules {
+				if other.Syscall == rule.Syscall && len(other.Conditions) == 0 &&
+					(other.Arch == ArchInvalid || other.Arch == rule.Arch) {
+					add(LintShadowedRule, rule, ArchInvalid, "shadowed by the unconditional rule taking action %s", other.Action)
+					break
+				}
+			}
+		}
+
+		if reason := neverMatches(rule.Conditions); reason != "" {
+			add(LintNeverMatches, rule, ArchInvalid, "never matches, %s", reason)
+		}
+
+		for _, arch := range lintMissingArches(rule, state.NativeArch, state.Arches) {
+			add(LintUnknownSyscall, rule, arch, "syscall does not exist on %s", arch)
+		}
+	}
+
+	return issues
+}
+
+// Helper - Describe a rule by its syscall name, e.g. "socket[x86](a0 != 0x28)"
+func lintRuleString(rule ScmpRule, native ScmpArch) string {
+	arch := rule.Arch
+	if arch == ArchInvalid {
+		arch = native
+	}
+	name, err := rule.Syscall.GetNameByArch(arch)
+	if err != nil {
+		name = fmt.Sprintf("%d", int32(rule.Syscall))
+	}
+
+	return ScmpReportRule{Syscall: name, Arch: rule.Arch, Conditions: rule.Conditions}.String()
+}
+
+// Helper - Explain why conditions can never all match, or return an empty
+// string if they can
+func neverMatches(conds []ScmpCondition) string {
+	for i, cond := range conds {
+		switch {
+		case cond.Op == CompareMaskedEqual && cond.Operand2&^cond.Operand1 != 0:
+			return fmt.Sprintf("%s compares bits outside of the mask", conditionString(cond))
+		case cond.Op == CompareLess && cond.Operand1 == 0:
+			return fmt.Sprintf("no argument is less than 0 in %s", conditionString(cond))
+		case cond.Op == CompareGreater && cond.Operand1 == math.MaxUint64:
+			return fmt.Sprintf("no argument is greater than %#x in %s", cond.Operand1, conditionString(cond))
+		}
+
+		for _, other := range conds[i+1:] {
+			if other.Argument != cond.Argument {
+				continue
+			}
+			if (cond.Op == CompareEqual && other.Op == CompareEqual && cond.Operand1 != other.Operand1) ||
+				(cond.Op == CompareEqual && other.Op == CompareNotEqual && cond.Operand1 == other.Operand1) ||
+				(cond.Op == CompareNotEqual && other.Op == CompareEqual && cond.Operand1 == other.Operand1) {
+				return fmt.Sprintf("%s contradicts %s", conditionString(cond), conditionString(other))
+			}
+		}
+	}
+
+	return ""
+}
+
+// Helper - List the architectures a rule applies to on which its syscall
+// does not exist
+func lintMissingArches(rule ScmpRule, native ScmpArch, arches []ScmpArch) []ScmpArch {
+	if rule.Arch != ArchInvalid {
+		if _, err := rule.Syscall.GetNameByArch(rule.Arch); err != nil {
+			return []ScmpArch{rule.Arch}
+		}
+		return nil
+	}
+
+	// Rules on syscalls unknown to libseccomp only apply to the native
+	// architecture
+	name, err := rule.Syscall.GetNameByArch(native)
+	if err != nil || !libseccompKnowsSyscall(name) {
+		return nil
+	}
+
+	var missing []ScmpArch
+	for _, arch := range arches {
+		call, err := GetSyscallFromNameByArch(name, arch)
+		// libseccomp resolves syscalls absent from an architecture to
+		// pseudo-syscall numbers of -10000 and below; other negative numbers
+		// are multiplexed syscalls, such as socket through socketcall
+		if err != nil || int32(call) <= -10000 {
+			missing = append(missing, arch)
+		}
+	}
+
+	return missing
+}
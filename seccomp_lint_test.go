@@ -0,0 +1,89 @@
+// +build linux
+
+// Tests for the filter linting of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: syscalls are checked for amd64")
+	}
+
+	filter, err := NewFilterWithArches(ActEPerm, ArchAMD64, ArchARM)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	addRule := func(name string, action ScmpAction, conds ...ScmpCondition) {
+		call, err := GetSyscallFromName(name)
+		if err != nil {
+			t.Fatalf("Error getting syscall number of %q: %s", name, err)
+		}
+		if err := filter.AddRuleConditional(call, action, conds); err != nil {
+			t.Fatalf("Error adding rule for %q: %s", name, err)
+		}
+	}
+	makeCondition := func(arg uint, op ScmpCompareOp, values ...uint64) ScmpCondition {
+		cond, err := MakeCondition(arg, op, values...)
+		if err != nil {
+			t.Fatalf("Error making condition: %s", err)
+		}
+		return cond
+	}
+
+	if issues := filter.Lint(); len(issues) != 0 {
+		t.Errorf("Empty filter should have no issues, got %v", issues)
+	}
+
+	addRule("read", ActAllow)
+	addRule("socket", ActAllow)
+	addRule("read", ActAllow)
+	addRule("write", ActAllow)
+	addRule("write", ActLog, makeCondition(0, CompareEqual, 2))
+	addRule("ioctl", ActAllow, makeCondition(1, CompareMaskedEqual, 0xff, 0x100))
+	addRule("personality", ActAllow, makeCondition(0, CompareLess, 0))
+	addRule("arch_prctl", ActAllow)
+
+	issues := filter.Lint()
+	want := []struct {
+		kind    LintKind
+		arch    ScmpArch
+		message string
+	}{
+		{LintDuplicateRule, ArchInvalid, "read: duplicates"},
+		{LintShadowedRule, ArchInvalid, "write(a0 == 0x2): shadowed"},
+		{LintNeverMatches, ArchInvalid, "ioctl(a1 & 0xff == 0x100): never matches"},
+		{LintNeverMatches, ArchInvalid, "personality(a0 < 0x0): never matches"},
+		{LintUnknownSyscall, ArchARM, "arch_prctl: syscall does not exist on arm"},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("Got %d issues, want %d: %v", len(issues), len(want), issues)
+	}
+	for i, issue := range issues {
+		if issue.Kind != want[i].kind || issue.Arch != want[i].arch ||
+			!strings.HasPrefix(issue.String(), want[i].message) {
+			t.Errorf("Issue %d: got %s %q on %s, want %s %q on %s", i, issue.Kind, issue,
+				issue.Arch, want[i].kind, want[i].message, want[i].arch)
+		}
+	}
+
+	if reason := neverMatches([]ScmpCondition{
+		makeCondition(0, CompareEqual, 1),
+		makeCondition(0, CompareNotEqual, 1),
+	}); reason == "" {
+		t.Errorf("Contradicting conditions should never match")
+	}
+	if reason := neverMatches([]ScmpCondition{
+		makeCondition(0, CompareEqual, 1),
+		makeCondition(1, CompareNotEqual, 1),
+	}); reason != "" {
+		t.Errorf("Conditions on different arguments should match, got %q", reason)
+	}
+}
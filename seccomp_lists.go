@@ -0,0 +1,117 @@
+// +build linux
+
+// Allowlist and denylist builders for libseccomp Go bindings
+// Builds the common filters listing the syscalls to allow or to deny
+
+package seccomp
+
+import (
+	"fmt"
+)
+
+// Allowlist builds filters which allow a list of syscalls and deny all the
+// others.
+//
+// Syscalls:      names of the syscalls allowed
+// Arches:        architectures of the filter, the native one if empty
+// DenyAction:    action taken on the syscalls not listed
+// BadArchAction: action taken on syscalls of architectures not in Arches
+//
+type Allowlist struct {
+	Syscalls      []string
+	Arches        []ScmpArch
+	DenyAction    ScmpAction
+	BadArchAction ScmpAction
+}
+
+// Denylist builds filters which deny a list of syscalls and allow all the
+// others.
+//
+// Syscalls:      names of the syscalls denied
+// Arches:        architectures of the filter, the native one if empty
+// DenyAction:    action taken on the syscalls listed
+// Actions:       actions taken on some of the syscalls listed instead of
+//                DenyAction, by name
+// BadArchAction: action taken on syscalls of architectures not in Arches
+//
+type Denylist struct {
+	Syscalls      []string
+	Arches        []ScmpArch
+	DenyAction    ScmpAction
+	Actions       map[string]ScmpAction
+	BadArchAction ScmpAction
+}
+
+// NewAllowlist returns an allowlist of the given syscalls, which denies the
+// other syscalls with EPERM, and syscalls of other architectures with ENOSYS.
+func NewAllowlist(syscalls ...string) *Allowlist {
+	return &Allowlist{
+		Syscalls:      syscalls,
+		DenyAction:    ActEPerm,
+		BadArchAction: ActENoSys,
+	}
+}
+
+// NewDenylist returns a denylist of the given syscalls, which denies them
+// with EPERM, and syscalls of other architectures with ENOSYS.
+func NewDenylist(syscalls ...string) *Denylist {
+	return &Denylist{
+		Syscalls:      syscalls,
+		DenyAction:    ActEPerm,
+		BadArchAction: ActENoSys,
+	}
+}
+
+// Build creates a filter from the allowlist.
+// Returns an error if a syscall name is unknown, or the filter could not be
+// created.
+func (l *Allowlist) Build() (*ScmpFilter, error) {
+	return buildSyscallList(l.Syscalls, l.Arches, l.DenyAction, ActAllow, nil, l.BadArchAction)
+}
+
+// Build creates a filter from the denylist.
+// Returns an error if a syscall name is unknown, or the filter could not be
+// created.
+func (l *Denylist) Build() (*ScmpFilter, error) {
+	return buildSyscallList(l.Syscalls, l.Arches, ActAllow, l.DenyAction, l.Actions, l.BadArchAction)
+}
+
+// Helper - Build a filter taking action, or the action given in actions, on
+// a list of syscalls, and defaultAction on the others
+func buildSyscallList(names []string, arches []ScmpArch, defaultAction, action ScmpAction,
+	actions map[string]ScmpAction, badArchAction ScmpAction) (*ScmpFilter, error) {
+	if action == defaultAction {
+		return nil, fmt.Errorf("listed syscalls must be handled differently than the others")
+	}
+	if len(arches) == 0 {
+		arches = []ScmpArch{ArchNative}
+	}
+
+	filter, err := NewFilterWithArches(defaultAction, arches...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := filter.SetBadArchAction(badArchAction); err != nil {
+		filter.Release()
+		return nil, fmt.Errorf("could not set bad arch action: %v", err)
+	}
+
+	for _, name := range names {
+		call, err := GetSyscallFromName(name)
+		if err != nil {
+			filter.Release()
+			return nil, syscallNameError(err, name, ArchNative)
+		}
+		nameAction, ok := actions[name]
+		if !ok {
+			nameAction = action
+		}
+		if err := filter.AddRule(call, nameAction); err != nil {
+			filter.Release()
+			return nil, fmt.Errorf("could not add rule for %q: %v", name, err)
+		}
+	}
+
+	return filter, nil
+}
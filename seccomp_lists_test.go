@@ -0,0 +1,68 @@
+// +build linux
+
+// Tests for the allowlist and denylist builders of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestSyscallLists(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: filter program is checked for amd64")
+	}
+	const auditArchI386 = 0x40000003
+
+	eperm := bpf.RetErrno | uint32(unix.EPERM)
+	enosys := bpf.RetErrno | uint32(unix.ENOSYS)
+
+	allow, err := NewAllowlist("getpid", "getppid").Build()
+	if err != nil {
+		t.Fatalf("Error building allowlist: %s", err)
+	}
+	defer allow.Release()
+
+	deny, err := NewDenylist("getpid").Build()
+	if err != nil {
+		t.Fatalf("Error building denylist: %s", err)
+	}
+	defer deny.Release()
+
+	for _, test := range []struct {
+		filter *ScmpFilter
+		call   ScmpSyscall
+		want   uint32
+	}{
+		{allow, unix.SYS_GETPID, bpf.RetAllow},
+		{allow, unix.SYS_GETPPID, bpf.RetAllow},
+		{allow, unix.SYS_GETUID, eperm},
+		{deny, unix.SYS_GETPID, eperm},
+		{deny, unix.SYS_GETUID, bpf.RetAllow},
+	} {
+		if ret := runAMD64(t, test.filter, test.call); ret != test.want {
+			t.Errorf("Syscall %d: got %s, want %s", test.call, bpf.ActionString(ret), bpf.ActionString(test.want))
+		}
+	}
+
+	ret, err := bpf.Run(exportProgram(t, allow), &bpf.Data{Nr: 20, Arch: auditArchI386})
+	if err != nil {
+		t.Fatalf("Error running exported filter: %s", err)
+	} else if ret != enosys {
+		t.Errorf("Syscall of another architecture: got %s, want %s", bpf.ActionString(ret), bpf.ActionString(enosys))
+	}
+
+	if _, err := NewAllowlist("not_a_syscall").Build(); err == nil {
+		t.Errorf("Building an allowlist with an unknown syscall should fail")
+	}
+
+	list := NewDenylist("getpid")
+	list.DenyAction = ActAllow
+	if _, err := list.Build(); err == nil {
+		t.Errorf("Building a denylist allowing the listed syscalls should fail")
+	}
+}
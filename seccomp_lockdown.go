@@ -0,0 +1,210 @@
+// +build linux
+
+// Staged lockdown support for libseccomp Go bindings
+// Applies progressively stricter filters at runtime checkpoints
+
+package seccomp
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Syscalls a stage must allow for the next stage to be loaded on top of it
+var lockdownLoadSyscalls = []string{"seccomp", "prctl"}
+
+// LockdownStage describes one stage of a staged lockdown: the syscalls
+// allowed while the stage is in effect, and the action taken on all others.
+//
+// Name:       name of the stage (e.g., "startup", "steady-state")
+// Allow:      names of the syscalls permitted by the stage
+// DenyAction: action taken on any other syscall
+//
+type LockdownStage struct {
+	Name       string     `json:"name"`
+	Allow      []string   `json:"allow"`
+	DenyAction ScmpAction `json:"deny_action"`
+}
+
+// Lockdown applies a sequence of increasingly restrictive filters to the
+// calling process. Since the kernel stacks filters and always honours the
+// most restrictive result, each stage may only remove syscalls from the
+// set allowed by the stage before it.
+// All stages are compiled when the Lockdown is created, so advancing to the
+// next stage only needs to load an already built filter.
+type Lockdown struct {
+	lock     sync.Mutex
+	stages   []LockdownStage
+	filters  []*ScmpFilter
+	next     int
+	released bool
+}
+
+// NewLockdown validates and compiles a staged lockdown.
+// Every stage must allow a strict subset of the syscalls allowed by the
+// previous stage, with a DenyAction at least as strict as the previous one
+// by the precedence the kernel gives to actions (e.g., ActKillProcess over
+// ActErrno over ActLog), and every stage but the last must allow the
+// syscalls needed to load the next one ("seccomp" and "prctl").
+// Returns an error if the stages are inconsistent or could not be compiled.
+func NewLockdown(stages ...LockdownStage) (*Lockdown, error) {
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("a lockdown needs at least one stage")
+	}
+
+	loadSyscalls, err := resolveSyscallSet(lockdownLoadSyscalls)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]map[ScmpSyscall]string, len(stages))
+	for i, stage := range stages {
+		if err := sanitizeAction(stage.DenyAction); err != nil {
+			return nil, fmt.Errorf("stage %q: %v", stage.Name, err)
+		}
+
+		allowed, err := resolveSyscallSet(stage.Allow)
+		if err != nil {
+			return nil, fmt.Errorf("stage %q: %v", stage.Name, err)
+		}
+		sets[i] = allowed
+
+		if i < len(stages)-1 {
+			for call, name := range loadSyscalls {
+				if _, ok := allowed[call]; !ok {
+					return nil, fmt.Errorf("stage %q must allow %q to load the stages after it", stage.Name, name)
+				}
+			}
+		}
+
+		if i == 0 {
+			continue
+		}
+		prev := sets[i-1]
+		for call, name := range allowed {
+			if _, ok := prev[call]; !ok {
+				return nil, fmt.Errorf("stage %q allows %q, which the previous stage does not", stage.Name, name)
+			}
+		}
+		if len(allowed) == len(prev) {
+			return nil, fmt.Errorf("stage %q does not restrict the previous stage", stage.Name)
+		}
+		if actionPrecedence(stage.DenyAction) > actionPrecedence(stages[i-1].DenyAction) {
+			return nil, fmt.Errorf("stage %q denies with %s, which is less strict than %s of the previous stage",
+				stage.Name, stage.DenyAction, stages[i-1].DenyAction)
+		}
+	}
+
+	l := &Lockdown{stages: stages}
+	for i, stage := range stages {
+		filter, err := buildLockdownFilter(stage.DenyAction, sets[i])
+		if err != nil {
+			l.Release()
+			return nil, fmt.Errorf("stage %q: %v", stage.Name, err)
+		}
+		l.filters = append(l.filters, filter)
+	}
+
+	return l, nil
+}
+
+// Advance loads the filter of the next stage into the kernel.
+// Returns the name of the stage now in effect, or an error if all stages
+// have already been applied or loading failed. A stage that failed to load
+// can be retried.
+func (l *Lockdown) Advance() (string, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.released {
+		return "", fmt.Errorf("lockdown has been released")
+	} else if l.next >= len(l.filters) {
+		return "", fmt.Errorf("all lockdown stages have been applied")
+	}
+
+	if err := l.filters[l.next].Load(); err != nil {
+		return "", fmt.Errorf("could not apply stage %q: %v", l.stages[l.next].Name, err)
+	}
+
+	name := l.stages[l.next].Name
+	l.filters[l.next].Release()
+	l.next++
+
+	return name, nil
+}
+
+// Current returns the name of the stage currently in effect, or an empty
+// string if no stage has been applied yet.
+func (l *Lockdown) Current() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.next == 0 {
+		return ""
+	}
+
+	return l.stages[l.next-1].Name
+}
+
+// Remaining returns the number of stages which have not been applied yet,
+// or 0 once the lockdown has been released.
+func (l *Lockdown) Remaining() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.released {
+		return 0
+	}
+
+	return len(l.stages) - l.next
+}
+
+// Release frees the filters of all stages which have not been applied yet.
+// The lockdown cannot be advanced afterwards. Stages already applied stay in
+// effect.
+func (l *Lockdown) Release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, filter := range l.filters {
+		filter.Release()
+	}
+	l.released = true
+}
+
+// Helper - Build the filter of a stage allowing the resolved syscalls
+func buildLockdownFilter(denyAction ScmpAction, allowed map[ScmpSyscall]string) (*ScmpFilter, error) {
+	filter, err := NewFilter(denyAction)
+	if err != nil {
+		return nil, err
+	}
+
+	for call, name := range allowed {
+		if err := filter.AddRule(call, ActAllow); err != nil {
+			filter.Release()
+			return nil, fmt.Errorf("could not allow %q: %v", name, err)
+		}
+	}
+
+	return filter, nil
+}
+
+// Helper - Resolve a list of syscall names into a set of native syscalls
+func resolveSyscallSet(names []string) (map[ScmpSyscall]string, error) {
+	set := make(map[ScmpSyscall]string, len(names))
+	for _, name := range names {
+		call, err := GetSyscallFromName(name)
+		if err != nil {
+			return nil, syscallNameError(err, name, ArchNative)
+		}
+		set[call] = name
+	}
+	return set, nil
+}
+
+// Helper - Rank an action by the precedence the kernel gives to it when
+// filters are stacked, lower ranks taking precedence
+func actionPrecedence(a ScmpAction) int32 {
+	// The kernel takes the lowest signed return value, without its data
+	return int32(actionToRet(a) & 0xffff0000)
+}
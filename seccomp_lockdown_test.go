@@ -0,0 +1,83 @@
+// +build linux
+
+// Tests for staged lockdown support of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"testing"
+)
+
+func TestNewLockdownValidation(t *testing.T) {
+	deny := ActErrno.SetReturnCode(1)
+	startup := LockdownStage{
+		Name:       "startup",
+		Allow:      []string{"read", "write", "openat", "seccomp", "prctl", "exit_group"},
+		DenyAction: deny,
+	}
+	steady := LockdownStage{
+		Name:       "steady-state",
+		Allow:      []string{"read", "write", "exit_group"},
+		DenyAction: deny,
+	}
+
+	_, err := NewLockdown()
+	if err == nil {
+		t.Errorf("Lockdown without stages should fail")
+	}
+
+	_, err = NewLockdown(steady, startup)
+	if err == nil {
+		t.Errorf("Lockdown widening the allowed set should fail")
+	}
+
+	_, err = NewLockdown(startup, startup)
+	if err == nil {
+		t.Errorf("Lockdown with a stage not restricting the previous one should fail")
+	}
+
+	noLoad := steady
+	noLoad.Name = "no-load"
+	noLoad.Allow = append([]string{"openat"}, steady.Allow...)
+	_, err = NewLockdown(noLoad, steady)
+	if err == nil {
+		t.Errorf("Lockdown with a stage unable to load the next one should fail")
+	}
+
+	lenient := steady
+	lenient.DenyAction = ActLog
+	_, err = NewLockdown(startup, lenient)
+	if err == nil {
+		t.Errorf("Lockdown with a less strict deny action should fail")
+	}
+
+	stricter := steady
+	stricter.DenyAction = ActKillProcess
+	lockdown, err := NewLockdown(startup, stricter)
+	if err != nil {
+		t.Errorf("Error creating lockdown with a stricter deny action: %s", err)
+	} else {
+		lockdown.Release()
+	}
+
+	bogus := steady
+	bogus.Allow = []string{"not_a_syscall"}
+	_, err = NewLockdown(startup, bogus)
+	if err == nil {
+		t.Errorf("Lockdown with an unknown syscall should fail")
+	}
+
+	lockdown, err = NewLockdown(startup, steady)
+	if err != nil {
+		t.Fatalf("Error creating lockdown: %s", err)
+	}
+
+	if lockdown.Current() != "" || lockdown.Remaining() != 2 {
+		t.Errorf("New lockdown should not have any stage applied")
+	}
+
+	lockdown.Release()
+	if _, err := lockdown.Advance(); err == nil {
+		t.Errorf("Released lockdown should not advance")
+	}
+}
@@ -0,0 +1,178 @@
+// +build linux
+
+// Filter minimization for libseccomp Go bindings
+// Removes redundant rules to reduce the size of generated filters
+
+package seccomp
+
+// Minimize removes the rules of the filter which do not change its policy,
+// reducing the size of the generated program, e.g. for large allowlists
+// generated from several sources. Rules taking the default action are
+// dropped, as are rules covered by another rule taking the same action on
+// the same syscall with a subset of their conditions, including duplicate
+// rules. Rules added with AddRuleForArch taking the same action with the
+// same conditions on all the architectures of the filter are collapsed into
+// a single rule for all architectures, which also applies to architectures
+// added to the filter later.
+// The filter is rebuilt from the remaining tracked rules, see AddRuleForArch.
+// Returns the number of rules removed, or an error if the filter is invalid
+// or could not be rebuilt.
+func (f *ScmpFilter) Minimize() (int, error) {
+	defaultAction, err := f.GetDefaultAction()
+	if err != nil {
+		return 0, err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return 0, ErrInvalidFilter
+	}
+
+	arches := f.filterArches()
+	rules := minimizeRules(f.rules, defaultAction, arches)
+	removed := len(f.rules) - len(rules)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	old := f.rules
+	f.rules = rules
+	if err := f.rebuild(arches); err != nil {
+		f.rules = old
+		return 0, err
+	}
+
+	return removed, nil
+}
+
+// Helper - Compute the minimal rules with the same policy as rules, for a
+// filter of the given architectures
+func minimizeRules(rules []ScmpRule, defaultAction ScmpAction, arches []ScmpArch) []ScmpRule {
+	rules = collapseArchRules(rules, arches)
+
+	var kept []ScmpRule
+	for i, rule := range rules {
+		if rule.Action == defaultAction {
+			continue
+		}
+
+		covered := false
+		for j, other := range rules {
+			if i == j || !coversRule(other, rule) {
+				continue
+			}
+			// Of identical rules, the first one is kept
+			if j < i || !coversRule(rule, other) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			kept = append(kept, rule)
+		}
+	}
+
+	return kept
+}
+
+// Helper - Replace the rules added for each architecture of the filter with
+// the same action and conditions by a single rule for all architectures
+func collapseArchRules(rules []ScmpRule, arches []ScmpArch) []ScmpRule {
+	collapsed := make([]bool, len(rules))
+	var result []ScmpRule
+
+	for i, rule := range rules {
+		if collapsed[i] {
+			continue
+		}
+		if rule.Arch == ArchInvalid {
+			result = append(result, rule)
+			continue
+		}
+
+		name, err := rule.Syscall.GetNameByArch(rule.Arch)
+		if err != nil {
+			result = append(result, rule)
+			continue
+		}
+
+		// Find the same rule on the other architectures
+		group := []int{i}
+		present := map[ScmpArch]bool{rule.Arch: true}
+		for j := i + 1; j < len(rules); j++ {
+			other := rules[j]
+			if other.Arch == ArchInvalid || other.Action != rule.Action ||
+				other.Exact != rule.Exact || !sameConditions(other.Conditions, rule.Conditions) {
+				continue
+			}
+			if otherName, err := other.Syscall.GetNameByArch(other.Arch); err != nil || otherName != name {
+				continue
+			}
+			group = append(group, j)
+			present[other.Arch] = true
+		}
+
+		call, err := GetSyscallFromName(name)
+		if err != nil || !allPresent(arches, present) {
+			result = append(result, rule)
+			continue
+		}
+
+		for _, j := range group {
+			collapsed[j] = true
+		}
+		rule.Arch = ArchInvalid
+		rule.Syscall = call
+		result = append(result, rule)
+	}
+
+	return result
+}
+
+// Helper - Check whether rule a matches whenever rule b does, taking the
+// same action
+func coversRule(a, b ScmpRule) bool {
+	if a.Action != b.Action || a.Exact != b.Exact {
+		return false
+	}
+
+	switch {
+	case a.Arch == b.Arch:
+		if a.Syscall != b.Syscall {
+			return false
+		}
+	case a.Arch == ArchInvalid:
+		call, err := TranslateSyscall(a.Syscall, ArchNative, b.Arch)
+		if err != nil || call != b.Syscall {
+			return false
+		}
+	default:
+		return false
+	}
+
+	// a matches if its conditions are a subset of those of b
+	count := make(map[ScmpCondition]int)
+	for _, cond := range b.Conditions {
+		count[cond]++
+	}
+	for _, cond := range a.Conditions {
+		if count[cond] == 0 {
+			return false
+		}
+		count[cond]--
+	}
+
+	return true
+}
+
+// Helper - Check whether all the architectures are in the set
+func allPresent(arches []ScmpArch, set map[ScmpArch]bool) bool {
+	for _, arch := range arches {
+		if !set[arch] {
+			return false
+		}
+	}
+	return true
+}
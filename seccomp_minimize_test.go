@@ -0,0 +1,96 @@
+// +build linux
+
+// Tests for the filter minimization of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"testing"
+)
+
+func TestMinimize(t *testing.T) {
+	filter, err := NewFilterWithArches(ActEPerm, ArchNative, ArchX86)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	native, err := GetNativeArch()
+	if err != nil {
+		t.Fatalf("Error getting native architecture: %s", err)
+	}
+	if native == ArchX86 {
+		t.Skipf("Skipping test: needs an architecture other than x86")
+	}
+
+	syscall := func(name string, arch ScmpArch) ScmpSyscall {
+		call, err := GetSyscallFromNameByArch(name, arch)
+		if err != nil {
+			t.Fatalf("Error getting syscall number of %q: %s", name, err)
+		}
+		return call
+	}
+	makeCondition := func(arg uint, op ScmpCompareOp, value uint64) ScmpCondition {
+		cond, err := MakeCondition(arg, op, value)
+		if err != nil {
+			t.Fatalf("Error making condition: %s", err)
+		}
+		return cond
+	}
+	a0 := makeCondition(0, CompareEqual, 1)
+	a1 := makeCondition(1, CompareEqual, 2)
+
+	for _, rule := range []ScmpRule{
+		{Syscall: syscall("read", native), Action: ActAllow},
+		{Syscall: syscall("read", native), Action: ActAllow},
+		{Syscall: syscall("write", native), Action: ActAllow, Conditions: []ScmpCondition{a0}},
+		{Syscall: syscall("write", native), Action: ActAllow},
+		{Syscall: syscall("ioctl", native), Action: ActLog, Conditions: []ScmpCondition{a0}},
+		{Syscall: syscall("ioctl", native), Action: ActLog, Conditions: []ScmpCondition{a1, a0}},
+		{Syscall: syscall("ioctl", native), Action: ActLog, Conditions: []ScmpCondition{a1}},
+		{Arch: ArchX86, Syscall: syscall("read", ArchX86), Action: ActAllow},
+		{Arch: native, Syscall: syscall("getpid", native), Action: ActAllow},
+		{Arch: ArchX86, Syscall: syscall("getpid", ArchX86), Action: ActAllow},
+		{Arch: ArchX86, Syscall: syscall("close", ArchX86), Action: ActAllow},
+	} {
+		if rule.Arch != ArchInvalid {
+			err = filter.AddRuleForArch(rule.Arch, rule.Syscall, rule.Action, rule.Conditions)
+		} else {
+			err = filter.AddRuleConditional(rule.Syscall, rule.Action, rule.Conditions)
+		}
+		if err != nil {
+			t.Fatalf("Error adding rule %+v: %s", rule, err)
+		}
+	}
+
+	removed, err := filter.Minimize()
+	if err != nil {
+		t.Fatalf("Error minimizing filter: %s", err)
+	}
+	if removed != 5 {
+		t.Errorf("Minimize removed %d rules, want 5", removed)
+	}
+
+	want := []ScmpRule{
+		{Syscall: syscall("read", native), Action: ActAllow},
+		{Syscall: syscall("write", native), Action: ActAllow},
+		{Syscall: syscall("ioctl", native), Action: ActLog, Conditions: []ScmpCondition{a0}},
+		{Syscall: syscall("ioctl", native), Action: ActLog, Conditions: []ScmpCondition{a1}},
+		{Syscall: syscall("getpid", native), Action: ActAllow},
+		{Arch: ArchX86, Syscall: syscall("close", ArchX86), Action: ActAllow},
+	}
+	rules := filter.ListRules()
+	if len(rules) != len(want) {
+		t.Fatalf("Got %d rules, want %d: %+v", len(rules), len(want), rules)
+	}
+	for i, rule := range rules {
+		if rule.Arch != want[i].Arch || rule.Syscall != want[i].Syscall ||
+			rule.Action != want[i].Action || !sameConditions(rule.Conditions, want[i].Conditions) {
+			t.Errorf("Rule %d: got %+v, want %+v", i, rule, want[i])
+		}
+	}
+
+	if removed, err := filter.Minimize(); err != nil || removed != 0 {
+		t.Errorf("Minimizing again removed %d rules, %v", removed, err)
+	}
+}
@@ -0,0 +1,128 @@
+// +build linux
+
+// Notification feature probing for libseccomp Go bindings
+// Detects the notification features supported by the running kernel
+
+package seccomp
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// NotifFeature represents a feature of seccomp userspace notifications which
+// the running kernel may or may not support
+type NotifFeature uint
+
+const (
+	// NotifFeatureContinue is support for responses with
+	// NotifRespFlagContinue, added in Linux 5.5
+	NotifFeatureContinue NotifFeature = iota + 1
+	// NotifFeatureAddFd is support for NotifAddFd(), added in Linux 5.9
+	NotifFeatureAddFd
+	// NotifFeatureAddFdSend is support for installing a file descriptor and
+	// responding to the notification at once, added in Linux 5.14
+	NotifFeatureAddFdSend
+	// NotifFeatureWaitKillable is support for LoadFlagWaitKillableRecv,
+	// added in Linux 5.19
+	NotifFeatureWaitKillable
+)
+
+var (
+	// Notification features supported by the running kernel, probed once
+	notifFeaturesOnce sync.Once
+	notifFeatures     map[NotifFeature]bool
+	notifFeaturesErr  error
+)
+
+// String returns a string representation of a notification feature
+func (f NotifFeature) String() string {
+	switch f {
+	case NotifFeatureContinue:
+		return "continue"
+	case NotifFeatureAddFd:
+		return "addfd"
+	case NotifFeatureAddFdSend:
+		return "addfd-send"
+	case NotifFeatureWaitKillable:
+		return "wait-killable"
+	default:
+		return fmt.Sprintf("Unknown notification feature %d", uint(f))
+	}
+}
+
+// NotifSupports reports whether the running kernel supports the notification
+// feature feature. Unlike the API level, which reflects the features known to
+// libseccomp, this probes the kernel itself, so supervisors can fall back on
+// older kernels.
+// The kernel is probed on the first call, by loading a filter which allows
+// all syscalls on a thread discarded afterwards.
+// Returns an error if the feature is unknown or the kernel could not be
+// probed.
+func NotifSupports(feature NotifFeature) (bool, error) {
+	if feature < NotifFeatureContinue || feature > NotifFeatureWaitKillable {
+		return false, fmt.Errorf("unknown notification feature %d", uint(feature))
+	}
+
+	notifFeaturesOnce.Do(func() {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			// The thread is never unlocked, so the runtime terminates it
+			// when the goroutine exits, along with the filter loaded on it
+			runtime.LockOSThread()
+			notifFeatures, notifFeaturesErr = probeNotifFeatures()
+		}()
+		<-done
+	})
+	if notifFeaturesErr != nil {
+		return false, notifFeaturesErr
+	}
+
+	return notifFeatures[feature], nil
+}
+
+// Helper - Probe the notification features of the kernel
+// Requires a locked OS thread which is never unlocked
+func probeNotifFeatures() (map[NotifFeature]bool, error) {
+	const seccompSetModeFilter = 1
+	features := make(map[NotifFeature]bool)
+
+	// Unknown flags are rejected with EINVAL, before the kernel fails to
+	// read the NULL program
+	_, _, errno := unix.Syscall(unix.SYS_SECCOMP, seccompSetModeFilter,
+		uintptr(LoadFlagNewListener|LoadFlagWaitKillableRecv), 0)
+	features[NotifFeatureWaitKillable] = errno == unix.EFAULT
+
+	// Only this thread gets the no new privileges bit and the filter
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return nil, fmt.Errorf("could not set no new privileges bit: %v", err)
+	}
+
+	prog := []unix.SockFilter{{Code: unix.BPF_RET | unix.BPF_K, K: uint32(ActAllow.toNative())}}
+	fprog := unix.SockFprog{Len: uint16(len(prog)), Filter: &prog[0]}
+	fd, _, errno := unix.Syscall(unix.SYS_SECCOMP, seccompSetModeFilter,
+		uintptr(LoadFlagNewListener), uintptr(unsafe.Pointer(&fprog)))
+	runtime.KeepAlive(prog)
+	if errno == unix.EINVAL {
+		// Notifications are not supported at all
+		return features, nil
+	} else if errno != 0 {
+		return nil, fmt.Errorf("could not load probe filter: %v", errno)
+	}
+	defer unix.Close(int(fd))
+
+	// Requests for a notification which does not exist fail with ENOENT
+	// once the kernel accepted them
+	features[NotifFeatureContinue] = notifProbeContinue(ScmpFd(fd)) == unix.ENOENT
+	_, err := notifAddFdRaw(ScmpFd(fd), &ScmpNotifAddFd{SrcFd: uint32(fd)})
+	features[NotifFeatureAddFd] = err == ErrNotifTargetGone
+	_, err = notifAddFdRaw(ScmpFd(fd), &ScmpNotifAddFd{Flags: NotifAddFdFlagSend, SrcFd: uint32(fd)})
+	features[NotifFeatureAddFdSend] = err == ErrNotifTargetGone
+
+	return features, nil
+}
@@ -0,0 +1,50 @@
+// +build linux
+
+// Tests for the notification feature probing of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNotifSupports(t *testing.T) {
+	execInSubprocess(t, subprocessNotifSupports)
+}
+func subprocessNotifSupports(t *testing.T) {
+	if _, err := NotifSupports(NotifFeature(0)); err == nil {
+		t.Errorf("Probing an unknown feature should fail")
+	}
+
+	// Each feature was added after the previous one
+	features := []NotifFeature{NotifFeatureContinue, NotifFeatureAddFd, NotifFeatureAddFdSend, NotifFeatureWaitKillable}
+	supported := make([]bool, len(features))
+	for i, feature := range features {
+		var err error
+		supported[i], err = NotifSupports(feature)
+		if err != nil {
+			t.Fatalf("Error probing feature %s: %s", feature, err)
+		}
+		t.Logf("Feature %s supported: %t", feature, supported[i])
+
+		if i > 0 && supported[i] && !supported[i-1] {
+			t.Errorf("Feature %s supported without feature %s", feature, features[i-1])
+		}
+	}
+
+	// The probe filter must not confine the calling threads
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	status, err := ioutil.ReadFile("/proc/thread-self/status")
+	if err != nil {
+		t.Fatalf("Error reading thread status: %s", err)
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if strings.HasPrefix(line, "Seccomp:") && strings.TrimSpace(strings.TrimPrefix(line, "Seccomp:")) != "0" {
+			t.Errorf("Probing confined the calling thread: %s", line)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// +build linux
+
+// Target memory access for libseccomp Go bindings
+// Reads and writes the memory of the process which triggered a notification
+
+package seccomp
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// ReadBytes reads length bytes at address addr from the memory of the process
+// which triggered the notification. The notification is checked to still be
+// valid before and after reading: the memory is only meaningful while the
+// target is blocked in its syscall, and its pid may be reused once it went
+// away.
+// Note that other threads of the target may still modify the memory after
+// it has been read; a notification must not be answered with
+// NotifRespFlagContinue after taking a security decision on its memory.
+// Returns an error if the notification was not retrieved via NotifReceive(),
+// the memory could not be read, or ErrNotifTargetGone if the notification is
+// no longer valid.
+func (r *ScmpNotifReq) ReadBytes(addr uint64, length int) ([]byte, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("invalid length %d", length)
+	}
+
+	mem, err := r.openMem(os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer mem.Close()
+
+	data := make([]byte, length)
+	if _, err := mem.ReadAt(data, int64(addr)); err != nil {
+		return nil, fmt.Errorf("could not read target memory at %#x: %v", addr, err)
+	}
+
+	if err := notifIDValid(r.fd, r.ID); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// ReadString reads the NUL-terminated string pointed to by the syscall
+// argument arg, such as a path, from the memory of the process which
+// triggered the notification. At most unix.PathMax bytes are read.
+// The notification is validated as by ReadBytes().
+// Returns an error if the notification was not retrieved via NotifReceive(),
+// the memory could not be read or the string is too long, or
+// ErrNotifTargetGone if the notification is no longer valid.
+func (r *ScmpNotifReq) ReadString(arg int) (string, error) {
+	if arg < 0 || arg >= len(r.Data.Args) {
+		return "", fmt.Errorf("invalid syscall argument %d", arg)
+	}
+	addr := r.Data.Args[arg]
+	if addr == 0 {
+		return "", fmt.Errorf("syscall argument %d is a NULL pointer", arg)
+	}
+
+	mem, err := r.openMem(os.O_RDONLY)
+	if err != nil {
+		return "", err
+	}
+	defer mem.Close()
+
+	str, err := readMemString(mem, addr, unix.PathMax)
+	if err != nil {
+		return "", fmt.Errorf("could not read target memory at %#x: %v", addr, err)
+	}
+
+	if err := notifIDValid(r.fd, r.ID); err != nil {
+		return "", err
+	}
+
+	return string(str), nil
+}
+
+// WriteBytes writes data at address addr into the memory of the process
+// which triggered the notification, e.g. to fill the output buffer of an
+// emulated syscall before responding with the number of bytes written.
+// The notification is checked to still be valid before writing, so that the
+// memory of a process reusing the target's pid is never modified.
+// Returns an error if the notification was not retrieved via NotifReceive(),
+// the memory could not be written, or ErrNotifTargetGone if the notification
+// is no longer valid.
+func (r *ScmpNotifReq) WriteBytes(addr uint64, data []byte) error {
+	mem, err := r.openMem(os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	defer mem.Close()
+
+	if _, err := mem.WriteAt(data, int64(addr)); err != nil {
+		return fmt.Errorf("could not write target memory at %#x: %v", addr, err)
+	}
+
+	return nil
+}
+
+// Helper - Open the memory of the target of a notification with the given
+// flags, checking that the notification is still valid once it is open
+func (r *ScmpNotifReq) openMem(flag int) (*os.File, error) {
+	if !r.hasFd {
+		return nil, fmt.Errorf("notification was not received on a notification fd")
+	}
+
+	mem, err := os.OpenFile(fmt.Sprintf("/proc/%d/mem", r.Pid), flag, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pid may have been reused before the file was opened
+	if err := notifIDValid(r.fd, r.ID); err != nil {
+		mem.Close()
+		return nil, err
+	}
+
+	return mem, nil
+}
+
+// Helper - Read a NUL-terminated string without crossing into pages past its
+// end, which may not be mapped
+func readMemString(mem *os.File, addr uint64, max int) ([]byte, error) {
+	pageSize := uint64(os.Getpagesize())
+	var str []byte
+
+	for len(str) < max {
+		chunk := pageSize - addr%pageSize
+		if rest := uint64(max - len(str)); chunk > rest {
+			chunk = rest
+		}
+
+		buf := make([]byte, chunk)
+		if _, err := mem.ReadAt(buf, int64(addr)); err != nil {
+			return nil, err
+		}
+		if i := bytes.IndexByte(buf, 0); i >= 0 {
+			return append(str, buf[:i]...), nil
+		}
+
+		str = append(str, buf...)
+		addr += chunk
+	}
+
+	return nil, fmt.Errorf("string is longer than %d bytes", max)
+}
@@ -0,0 +1,138 @@
+// +build linux
+
+// Tests for the target memory access of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestNotifReadMemory(t *testing.T) {
+	execInSubprocess(t, subprocessNotifReadMemory)
+}
+func subprocessNotifReadMemory(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	// Only the target goroutine uses chdir, see subprocessNotif
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_CHDIR), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- unix.Chdir("/non-existent-path")
+	}()
+
+	req, err := NotifReceive(fd)
+	if err != nil {
+		t.Fatalf("Error in NotifReceive(): %s", err)
+	}
+
+	if path, err := req.ReadString(0); err != nil {
+		t.Errorf("Error reading path argument: %s", err)
+	} else if path != "/non-existent-path" {
+		t.Errorf("Unexpected path argument: %q", path)
+	}
+	if data, err := req.ReadBytes(req.Data.Args[0], 4); err != nil {
+		t.Errorf("Error reading target memory: %s", err)
+	} else if string(data) != "/non" {
+		t.Errorf("Unexpected target memory: %q", data)
+	}
+	if _, err := req.ReadString(1); err == nil {
+		t.Errorf("Reading a NULL pointer argument should fail")
+	}
+
+	resp := &ScmpNotifResp{ID: req.ID}
+	resp.SetErrno(unix.ENOMEDIUM)
+	if err := NotifRespond(fd, resp); err != nil {
+		t.Fatalf("Error in NotifRespond(): %s", err)
+	}
+	if err := <-done; err != unix.ENOMEDIUM {
+		t.Errorf("Response not applied: got %v, want %v", err, unix.ENOMEDIUM)
+	}
+
+	if _, err := req.ReadString(0); err != ErrNotifTargetGone {
+		t.Errorf("Reading memory of an answered notification: got %v, want %v", err, ErrNotifTargetGone)
+	}
+
+	copied := ScmpNotifReq{ID: req.ID, Pid: req.Pid, Data: req.Data}
+	if _, err := copied.ReadString(0); err == nil {
+		t.Errorf("Reading memory of a notification without fd should fail")
+	}
+}
+
+func TestNotifWriteMemory(t *testing.T) {
+	execInSubprocess(t, subprocessNotifWriteMemory)
+}
+func subprocessNotifWriteMemory(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	// Only the target goroutine uses getcwd, see subprocessNotif
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETCWD), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	type result struct {
+		cwd string
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		buf := make([]byte, 64)
+		n, err := unix.Getcwd(buf)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{cwd: string(buf[:n])}
+	}()
+
+	req, err := NotifReceive(fd)
+	if err != nil {
+		t.Fatalf("Error in NotifReceive(): %s", err)
+	}
+
+	// Emulate getcwd, which returns the length of the path including its NUL
+	cwd := []byte("/emulated\x00")
+	if err := req.WriteBytes(req.Data.Args[0], cwd); err != nil {
+		t.Fatalf("Error writing target memory: %s", err)
+	}
+	if err := NotifRespond(fd, &ScmpNotifResp{ID: req.ID, Val: uint64(len(cwd))}); err != nil {
+		t.Fatalf("Error in NotifRespond(): %s", err)
+	}
+
+	if res := <-done; res.err != nil {
+		t.Errorf("Error in emulated syscall: %s", res.err)
+	} else if res.cwd != string(cwd) {
+		t.Errorf("Emulated syscall returned %q, want %q", res.cwd, cwd)
+	}
+
+	if err := req.WriteBytes(req.Data.Args[0], cwd); err != ErrNotifTargetGone {
+		t.Errorf("Writing memory of an answered notification: got %v, want %v", err, ErrNotifTargetGone)
+	}
+}
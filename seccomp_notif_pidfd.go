@@ -0,0 +1,99 @@
+// +build linux
+
+// pidfd support for libseccomp Go bindings
+// Refers to the process which triggered a notification by a file descriptor
+
+package seccomp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// OpenPidfd opens a pidfd referring to the process which triggered the
+// notification. Unlike its pid, the pidfd cannot be reused by another
+// process once the target exits, so it can be used to duplicate the target's
+// file descriptors with PidfdGetfd(), or to signal it with
+// PidfdSendSignal(), without races.
+// The pidfd refers to the whole thread group of the thread which triggered
+// the notification. It is close-on-exec, and owned by the caller.
+// Returns an error if the notification was not retrieved via NotifReceive(),
+// the pidfd could not be opened, e.g. ENOSYS before Linux 5.3, or
+// ErrNotifTargetGone if the notification is no longer valid.
+func (r *ScmpNotifReq) OpenPidfd() (int, error) {
+	if !r.hasFd {
+		return -1, fmt.Errorf("notification was not received on a notification fd")
+	}
+
+	// pidfds can only refer to thread group leaders
+	tgid, err := threadGroupID(int(r.Pid))
+	if err != nil {
+		return -1, err
+	}
+
+	pidfd, _, errno := unix.Syscall(unix.SYS_PIDFD_OPEN, uintptr(tgid), 0, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+
+	// The pid may have been reused before the pidfd was opened
+	if err := notifIDValid(r.fd, r.ID); err != nil {
+		unix.Close(int(pidfd))
+		return -1, err
+	}
+
+	return int(pidfd), nil
+}
+
+// PidfdGetfd duplicates the file descriptor targetFd of the process referred
+// to by pidfd, see OpenPidfd(). The duplicate is close-on-exec, and owned by
+// the caller.
+// Returns an error if the file descriptor could not be duplicated, e.g.
+// ENOSYS before Linux 5.6.
+func PidfdGetfd(pidfd int, targetFd int) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_PIDFD_GETFD, uintptr(pidfd), uintptr(targetFd), 0)
+	if errno != 0 {
+		return -1, errno
+	}
+
+	return int(fd), nil
+}
+
+// PidfdSendSignal sends the signal sig to the process referred to by pidfd,
+// see OpenPidfd().
+// Returns an error if the signal could not be sent, e.g. ESRCH if the
+// process exited.
+func PidfdSendSignal(pidfd int, sig unix.Signal) error {
+	_, _, errno := unix.Syscall6(unix.SYS_PIDFD_SEND_SIGNAL, uintptr(pidfd), uintptr(sig), 0, 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// Helper - Get the thread group ID of a thread
+func threadGroupID(tid int) (int, error) {
+	status, err := os.Open(fmt.Sprintf("/proc/%d/status", tid))
+	if err != nil {
+		return -1, err
+	}
+	defer status.Close()
+
+	scanner := bufio.NewScanner(status)
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), "Tgid:"); value != scanner.Text() {
+			return strconv.Atoi(strings.TrimSpace(value))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return -1, err
+	}
+
+	return -1, fmt.Errorf("thread %d has no thread group ID", tid)
+}
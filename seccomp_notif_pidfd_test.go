@@ -0,0 +1,87 @@
+// +build linux
+
+// Tests for the pidfd support of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestNotifPidfd(t *testing.T) {
+	execInSubprocess(t, subprocessNotifPidfd)
+}
+func subprocessNotifPidfd(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	// Only the target goroutine uses chdir, see subprocessNotif
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_CHDIR), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	file, err := os.Open("/dev/null")
+	if err != nil {
+		t.Fatalf("Error opening file: %s", err)
+	}
+	defer file.Close()
+
+	done := make(chan error)
+	go func() {
+		done <- unix.Chdir("/")
+	}()
+
+	req, err := NotifReceive(fd)
+	if err != nil {
+		t.Fatalf("Error in NotifReceive(): %s", err)
+	}
+	defer func() {
+		NotifRespond(fd, &ScmpNotifResp{ID: req.ID, Flags: NotifRespFlagContinue})
+		<-done
+	}()
+
+	pidfd, err := req.OpenPidfd()
+	if err == unix.ENOSYS {
+		t.Skipf("Skipping test: pidfd_open is not supported")
+	} else if err != nil {
+		t.Fatalf("Error opening pidfd: %s", err)
+	}
+	defer unix.Close(pidfd)
+
+	dup, err := PidfdGetfd(pidfd, int(file.Fd()))
+	if err == unix.ENOSYS {
+		t.Skipf("Skipping test: pidfd_getfd is not supported")
+	} else if err != nil {
+		t.Fatalf("Error duplicating target fd: %s", err)
+	}
+	defer unix.Close(dup)
+
+	var want, got unix.Stat_t
+	if err := unix.Fstat(int(file.Fd()), &want); err != nil {
+		t.Fatalf("Error in Fstat(): %s", err)
+	}
+	if err := unix.Fstat(dup, &got); err != nil {
+		t.Fatalf("Error in Fstat() of the duplicated fd: %s", err)
+	}
+	if got.Dev != want.Dev || got.Ino != want.Ino {
+		t.Errorf("Duplicated fd %d does not refer to the target's fd", dup)
+	}
+
+	// Signal 0 only checks that the process can be signaled
+	if err := PidfdSendSignal(pidfd, 0); err != nil {
+		t.Errorf("Error signaling target: %s", err)
+	}
+}
@@ -0,0 +1,78 @@
+// +build linux
+
+// Notification request accessors for libseccomp Go bindings
+// Resolves and formats the syscall context of a notification on demand
+
+package seccomp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyscallName resolves the name of the syscall which triggered the
+// notification, on the architecture it was made on.
+// Returns an error if the syscall is unknown on that architecture.
+func (r *ScmpNotifReq) SyscallName() (string, error) {
+	return r.Data.Syscall.GetNameByArch(r.Data.Arch)
+}
+
+// ArchName returns the name of the architecture the syscall which triggered
+// the notification was made on, e.g. "amd64".
+func (r *ScmpNotifReq) ArchName() string {
+	return r.Data.Arch.String()
+}
+
+// Arg returns the syscall argument i (0 to 5) as the raw 64-bit value passed
+// by the target, or 0 if the notification holds no such argument.
+func (r *ScmpNotifReq) Arg(i int) uint64 {
+	if i < 0 || i >= len(r.Data.Args) {
+		return 0
+	}
+
+	return r.Data.Args[i]
+}
+
+// ArgInt returns the syscall argument i as a signed 64-bit value, e.g. an
+// offset. See Arg().
+func (r *ScmpNotifReq) ArgInt(i int) int64 {
+	return int64(r.Arg(i))
+}
+
+// ArgInt32 returns the lower 32 bits of the syscall argument i as a signed
+// value, as the kernel reads C int arguments such as file descriptors
+// (e.g., AT_FDCWD). See Arg().
+func (r *ScmpNotifReq) ArgInt32(i int) int32 {
+	return int32(r.Arg(i))
+}
+
+// ArgUint32 returns the lower 32 bits of the syscall argument i, as the
+// kernel reads C unsigned int arguments such as flags and modes. See Arg().
+func (r *ScmpNotifReq) ArgUint32(i int) uint32 {
+	return uint32(r.Arg(i))
+}
+
+// SetFd records fd as the notification fd the notification was received on,
+// for notifications which were not retrieved via NotifReceive(), e.g. ones
+// handed over by another process, so that ReadBytes(), ReadString(),
+// WriteBytes() and OpenPidfd() can check that they are still valid.
+func (r *ScmpNotifReq) SetFd(fd ScmpFd) {
+	r.fd, r.hasFd = fd, true
+}
+
+// String returns a string representation of the notification, with the
+// syscall name if it is known, e.g.
+// "openat(0xffffff9c, 0x7ffc3c6e7d10, 0x0, 0x0, 0x0, 0x0) arch amd64 pid 42".
+func (r *ScmpNotifReq) String() string {
+	name, err := r.SyscallName()
+	if err != nil {
+		name = fmt.Sprintf("syscall %d", int32(r.Data.Syscall))
+	}
+
+	args := make([]string, len(r.Data.Args))
+	for i, arg := range r.Data.Args {
+		args[i] = fmt.Sprintf("%#x", arg)
+	}
+
+	return fmt.Sprintf("%s(%s) arch %s pid %d", name, strings.Join(args, ", "), r.ArchName(), r.Pid)
+}
@@ -0,0 +1,60 @@
+// +build linux
+
+// Tests for the notification request accessors of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestNotifReqAccessors(t *testing.T) {
+	openat, err := GetSyscallFromNameByArch("openat", ArchAMD64)
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+
+	req := &ScmpNotifReq{
+		Pid: 42,
+		Data: ScmpNotifData{
+			Syscall: openat,
+			Arch:    ArchAMD64,
+			Args:    []uint64{0xffffffffffffff9c, 0x1000, 0xffffffff00000241, 0x1a4, 0, 0},
+		},
+	}
+
+	if name, err := req.SyscallName(); err != nil || name != "openat" {
+		t.Errorf("SyscallName() = %q, %v, want %q", name, err, "openat")
+	}
+	if arch := req.ArchName(); arch != "amd64" {
+		t.Errorf("ArchName() = %q, want %q", arch, "amd64")
+	}
+
+	if fd := req.ArgInt32(0); fd != unix.AT_FDCWD {
+		t.Errorf("ArgInt32(0) = %d, want %d", fd, unix.AT_FDCWD)
+	}
+	if addr := req.Arg(1); addr != 0x1000 {
+		t.Errorf("Arg(1) = %#x, want %#x", addr, 0x1000)
+	}
+	if flags := req.ArgUint32(2); flags != 0x241 {
+		t.Errorf("ArgUint32(2) = %#x, want %#x", flags, 0x241)
+	}
+	if val := req.ArgInt(0); val != -100 {
+		t.Errorf("ArgInt(0) = %d, want %d", val, -100)
+	}
+	if val := req.Arg(6); val != 0 {
+		t.Errorf("Arg(6) = %#x, want 0", val)
+	}
+
+	want := "openat(0xffffffffffffff9c, 0x1000, 0xffffffff00000241, 0x1a4, 0x0, 0x0) arch amd64 pid 42"
+	if str := req.String(); str != want {
+		t.Errorf("String() = %q, want %q", str, want)
+	}
+
+	req.Data.Syscall = 0x7fff
+	if _, err := req.SyscallName(); err == nil {
+		t.Errorf("SyscallName() of an unknown syscall should fail")
+	}
+}
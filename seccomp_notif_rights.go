@@ -0,0 +1,83 @@
+// +build linux
+
+// Notification fd passing for libseccomp Go bindings
+// Sends notification fds to supervisors in other processes over Unix sockets
+
+package seccomp
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// SendNotifFd sends the notification fd fd over the Unix socket conn, to be
+// received with RecvNotifFd() by a supervisor in another process. This lets
+// a process load a filter using ActNotify and have its notifications handled
+// by an external supervisor, as container engines do.
+// The fd remains open in the sending process.
+// Returns an error if the fd could not be sent.
+func SendNotifFd(conn *net.UnixConn, fd ScmpFd) error {
+	// Stream sockets only pass control messages along with data
+	if _, _, err := conn.WriteMsgUnix([]byte{0}, unix.UnixRights(int(fd)), nil); err != nil {
+		return fmt.Errorf("could not send notification fd: %v", err)
+	}
+
+	return nil
+}
+
+// RecvNotifFd receives a notification fd sent with SendNotifFd() over the
+// Unix socket conn. The received fd is close-on-exec, and owned by the
+// caller.
+// Returns an error if no single fd could be received.
+func RecvNotifFd(conn *net.UnixConn) (ScmpFd, error) {
+	var data [1]byte
+	oob := make([]byte, unix.CmsgSpace(4))
+	_, oobn, flags, _, err := conn.ReadMsgUnix(data[:], oob)
+	if err != nil {
+		return -1, fmt.Errorf("could not receive notification fd: %v", err)
+	}
+
+	cmsgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return -1, fmt.Errorf("could not parse control message: %v", err)
+	}
+
+	if flags&unix.MSG_CTRUNC != 0 {
+		closeRights(cmsgs)
+		return -1, fmt.Errorf("control message was truncated")
+	}
+
+	var fds []int
+	for _, cmsg := range cmsgs {
+		rights, err := unix.ParseUnixRights(&cmsg)
+		if err != nil {
+			continue
+		}
+		fds = append(fds, rights...)
+	}
+
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+		return -1, fmt.Errorf("received %d file descriptors, expected 1", len(fds))
+	}
+	unix.CloseOnExec(fds[0])
+
+	return ScmpFd(fds[0]), nil
+}
+
+// Helper - Close the file descriptors passed in control messages
+func closeRights(cmsgs []unix.SocketControlMessage) {
+	for _, cmsg := range cmsgs {
+		rights, err := unix.ParseUnixRights(&cmsg)
+		if err != nil {
+			continue
+		}
+		for _, fd := range rights {
+			unix.Close(fd)
+		}
+	}
+}
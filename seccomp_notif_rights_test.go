@@ -0,0 +1,75 @@
+// +build linux
+
+// Tests for the notification fd passing of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// Helper - Wrap one end of a socket pair into a *net.UnixConn
+func unixConn(t *testing.T, fd int) *net.UnixConn {
+	file := os.NewFile(uintptr(fd), "socketpair")
+	defer file.Close()
+
+	conn, err := net.FileConn(file)
+	if err != nil {
+		t.Fatalf("Error wrapping socket: %s", err)
+	}
+	return conn.(*net.UnixConn)
+}
+
+func TestSendRecvNotifFd(t *testing.T) {
+	pair, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("Error creating socket pair: %s", err)
+	}
+	sender, receiver := unixConn(t, pair[0]), unixConn(t, pair[1])
+	defer sender.Close()
+	defer receiver.Close()
+
+	// Any fd can stand in for a notification fd
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Error creating pipe: %s", err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	if err := SendNotifFd(sender, ScmpFd(r.Fd())); err != nil {
+		t.Fatalf("Error sending fd: %s", err)
+	}
+	fd, err := RecvNotifFd(receiver)
+	if err != nil {
+		t.Fatalf("Error receiving fd: %s", err)
+	}
+	defer unix.Close(int(fd))
+
+	var want, got unix.Stat_t
+	if err := unix.Fstat(int(r.Fd()), &want); err != nil {
+		t.Fatalf("Error in Fstat(): %s", err)
+	}
+	if err := unix.Fstat(int(fd), &got); err != nil {
+		t.Fatalf("Error in Fstat() of the received fd: %s", err)
+	}
+	if got.Dev != want.Dev || got.Ino != want.Ino {
+		t.Errorf("Received fd %d does not refer to the sent fd", fd)
+	}
+	if flags, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0); err != nil {
+		t.Errorf("Error getting fd flags: %s", err)
+	} else if flags&unix.FD_CLOEXEC == 0 {
+		t.Errorf("Received fd is not close-on-exec")
+	}
+
+	if _, err := sender.Write([]byte{0}); err != nil {
+		t.Fatalf("Error writing to socket: %s", err)
+	}
+	if _, err := RecvNotifFd(receiver); err == nil {
+		t.Errorf("Receiving a message without fd should fail")
+	}
+}
@@ -0,0 +1,678 @@
+// +build linux
+
+// OCI runtime-spec support for libseccomp Go bindings
+// Translates the "linux.seccomp" section of an OCI bundle into a filter
+
+package seccomp
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/seccomp/libseccomp-golang/profile"
+	"golang.org/x/sys/unix"
+)
+
+// OCISeccomp represents the "linux.seccomp" section of an OCI runtime-spec
+// config.json.
+type OCISeccomp = profile.Seccomp
+
+// OCISyscall represents a single entry of the "syscalls" list of an OCI
+// seccomp profile.
+type OCISyscall = profile.Syscall
+
+// OCIArg represents an argument condition of an OCI seccomp syscall entry.
+type OCIArg = profile.Arg
+
+// OCIHookState is the container state an OCI runtime passes to hooks on
+// their standard input.
+type OCIHookState = profile.HookState
+
+// ReadOCIBundleProfile reads config.json from the OCI bundle directory bundle
+// and returns its "linux.seccomp" section.
+// Returns nil and no error if the bundle does not configure seccomp.
+func ReadOCIBundleProfile(bundle string) (*OCISeccomp, error) {
+	return profile.ReadBundle(bundle)
+}
+
+// RunOCIHook implements the body of an OCI createRuntime or prestart hook.
+// It reads the container state from r, extracts the seccomp section of the
+// bundle's config.json, compiles it and verifies that libseccomp is able to
+// generate a BPF program from it. The filter is not loaded.
+// Returns the compiled filter, which the caller must release, or nil and no
+// error if the bundle does not configure seccomp.
+func RunOCIHook(r io.Reader) (*ScmpFilter, error) {
+	state, err := profile.ReadHookState(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := profile.ReadBundle(state.Bundle)
+	if err != nil || p == nil {
+		return nil, err
+	}
+
+	filter, err := buildOCIFilter(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyFilter(filter); err != nil {
+		filter.Release()
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// NewFilterFromProfile compiles an OCI seccomp profile into a new filter,
+// following the semantics of runc: rules for syscalls unknown to libseccomp
+// and rules matching the default action are skipped.
+// Returns the filter, which is not loaded, or an error if the profile is
+// invalid.
+func NewFilterFromProfile(p *OCISeccomp) (*ScmpFilter, error) {
+	if p == nil {
+		return nil, fmt.Errorf("profile is nil")
+	}
+
+	return buildOCIFilter(p)
+}
+
+// BuildFilterFromOCIProfile compiles the JSON encoding of an OCI seccomp
+// profile, the "linux.seccomp" section of a config.json, into a new filter.
+// The profile is translated as runc does: defaultAction, defaultErrnoRet,
+// architectures, flags and the names, action, args and errnoRet of the
+// syscall entries are honored, rules for syscalls unknown to libseccomp are
+// skipped, and conditions comparing the same argument more than once are
+// expanded into one rule per condition.
+// Returns the filter, which is not loaded, or an error if the profile could
+// not be parsed or is invalid.
+func BuildFilterFromOCIProfile(jsonBytes []byte) (*ScmpFilter, error) {
+	p, err := profile.Parse(jsonBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildOCIFilter(p)
+}
+
+// ReadYAMLProfile reads the YAML seccomp profile at path, along with the
+// profiles it includes. See profile.ReadYAML for the format.
+// Returns an error if a profile could not be read or parsed.
+func ReadYAMLProfile(path string) (*OCISeccomp, error) {
+	return profile.ReadYAML(path)
+}
+
+// BuildFilterFromYAMLProfile compiles the YAML seccomp profile at path,
+// along with the profiles it includes, into a new filter. The profile is
+// translated as by BuildFilterFromOCIProfile.
+// Returns the filter, which is not loaded, or an error if a profile could not
+// be read or parsed, or is invalid.
+func BuildFilterFromYAMLProfile(path string) (*ScmpFilter, error) {
+	p, err := profile.ReadYAML(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildOCIFilter(p)
+}
+
+// ToOCIProfile describes the filter as an OCI seccomp profile, the
+// "linux.seccomp" section of a config.json, from the rules tracked by the
+// bindings, so that filters built with this package can be handed to OCI
+// runtimes. Rules sharing an action and conditions are grouped into a single
+// syscall entry.
+// Syscall priorities and attributes other than the log and SSB flags are
+// not represented.
+// Returns an error if the filter is invalid, or uses rules an OCI profile
+// cannot express: rules restricted to one architecture, or on syscalls
+// without a name.
+func (f *ScmpFilter) ToOCIProfile() (*OCISeccomp, error) {
+	state, err := f.MarshalState()
+	if err != nil {
+		return nil, err
+	}
+
+	p := new(OCISeccomp)
+	if p.DefaultAction, p.DefaultErrnoRet, err = ociActionToString(state.DefaultAction); err != nil {
+		return nil, err
+	}
+
+	for _, arch := range state.Arches {
+		name, err := ociArchToString(arch)
+		if err != nil {
+			return nil, err
+		}
+		p.Architectures = append(p.Architectures, name)
+	}
+
+	if state.Attrs[AttrLog] != 0 {
+		p.Flags = append(p.Flags, "SECCOMP_FILTER_FLAG_LOG")
+	}
+	if state.Attrs[AttrSSB] != 0 {
+		p.Flags = append(p.Flags, "SECCOMP_FILTER_FLAG_SPEC_ALLOW")
+	}
+
+	// Index of the syscall entry of each action and conditions, and the
+	// names added to each of them
+	entries := make(map[string]int)
+	named := make(map[string]bool)
+	for _, rule := range state.Rules {
+		if rule.Arch != ArchInvalid {
+			return nil, fmt.Errorf("rule for syscall %d is restricted to architecture %s", int32(rule.Syscall), rule.Arch)
+		}
+
+		name, err := rule.Syscall.GetName()
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve syscall %d: %v", int32(rule.Syscall), err)
+		}
+
+		call := OCISyscall{Names: []string{name}}
+		if call.Action, call.ErrnoRet, err = ociActionToString(rule.Action); err != nil {
+			return nil, err
+		}
+		for _, cond := range rule.Conditions {
+			arg, err := ociArgFromCondition(cond)
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+		}
+
+		key := fmt.Sprintf("%d %v", rule.Action, call.Args)
+		if named[key+" "+name] {
+			continue
+		}
+		named[key+" "+name] = true
+
+		if i, ok := entries[key]; ok {
+			p.Syscalls[i].Names = append(p.Syscalls[i].Names, name)
+			continue
+		}
+		entries[key] = len(p.Syscalls)
+		p.Syscalls = append(p.Syscalls, call)
+	}
+
+	return p, nil
+}
+
+// ToYAMLProfile describes the filter as a YAML seccomp profile, which
+// BuildFilterFromYAMLProfile reads back. See ToOCIProfile for the rules
+// which can be described.
+// Returns an error if the filter is invalid, or uses rules a profile cannot
+// express.
+func (f *ScmpFilter) ToYAMLProfile() ([]byte, error) {
+	p, err := f.ToOCIProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	return profile.MarshalYAML(p), nil
+}
+
+// Helper - Ensure libseccomp can generate a BPF program from a filter
+func verifyFilter(f *ScmpFilter) error {
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer null.Close()
+
+	if err := f.ExportBPF(null); err != nil {
+		return fmt.Errorf("could not generate filter program: %v", err)
+	}
+
+	return nil
+}
+
+// Helper - Build a filter from an OCI seccomp profile, the same way runc does
+func buildOCIFilter(p *OCISeccomp) (*ScmpFilter, error) {
+	defaultAction, err := ociActionFromString(p.DefaultAction, p.DefaultErrnoRet)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkOCINotify(p, defaultAction); err != nil {
+		return nil, err
+	}
+
+	filterAction := defaultAction
+	if p.UnknownSyscallsENOSYS && !allowsSyscalls(defaultAction) {
+		// Known syscalls get rules for the default action instead
+		filterAction = ActENoSys
+	}
+
+	filter, err := NewFilter(filterAction)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyOCIProfile(filter, p, defaultAction); err != nil {
+		filter.Release()
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// Helper - Reject the uses of SCMP_ACT_NOTIFY runc refuses: as default
+// action, and for write, which the supervisor may need to answer notifications
+func checkOCINotify(p *OCISeccomp, defaultAction ScmpAction) error {
+	if defaultAction == ActNotify {
+		return fmt.Errorf("SCMP_ACT_NOTIFY cannot be used as default action")
+	}
+
+	for _, call := range p.Syscalls {
+		if call.Action != "SCMP_ACT_NOTIFY" {
+			continue
+		}
+		for _, name := range call.Names {
+			if name == "write" {
+				return fmt.Errorf("SCMP_ACT_NOTIFY cannot be used for the write syscall")
+			}
+		}
+	}
+
+	return nil
+}
+
+// Helper - Check whether an action lets syscalls run
+func allowsSyscalls(action ScmpAction) bool {
+	return action == ActAllow || action == ActLog
+}
+
+func applyOCIProfile(filter *ScmpFilter, p *OCISeccomp, defaultAction ScmpAction) error {
+	for _, name := range p.Architectures {
+		arch, err := ociArchFromString(name)
+		if err != nil {
+			return err
+		}
+		if err := filter.AddArch(arch); err != nil {
+			return fmt.Errorf("could not add architecture %s: %v", name, err)
+		}
+	}
+
+	for _, flag := range p.Flags {
+		var err error
+		switch flag {
+		case "SECCOMP_FILTER_FLAG_TSYNC":
+			// Always set by NewFilter
+		case "SECCOMP_FILTER_FLAG_LOG":
+			err = filter.SetLogBit(true)
+		case "SECCOMP_FILTER_FLAG_SPEC_ALLOW":
+			err = filter.SetSSB(true)
+		default:
+			err = fmt.Errorf("unrecognized seccomp flag %q", flag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Differs from the profile's default action for UnknownSyscallsENOSYS
+	filterAction, err := filter.GetDefaultAction()
+	if err != nil {
+		return err
+	}
+
+	// Syscalls with rules on all their arguments, and the conditions of the
+	// rules on the others, whose remaining arguments get the default action
+	ruled := make(map[string]bool)
+	partial := make(map[string][][]ScmpCondition)
+	for _, call := range p.Syscalls {
+		action, err := ociActionFromString(call.Action, call.ErrnoRet)
+		if err != nil {
+			return err
+		}
+
+		// libseccomp refuses rules matching the default action, skip them
+		if action == defaultAction {
+			continue
+		}
+
+		conds, err := ociConditions(call.Args)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range call.Names {
+			if len(conds) == 0 {
+				ruled[name] = true
+			} else if hasRepeatedArgument(conds) {
+				// Expanded by addOCIRule
+				for _, cond := range conds {
+					partial[name] = append(partial[name], []ScmpCondition{cond})
+				}
+			} else {
+				partial[name] = append(partial[name], conds)
+			}
+
+			if action == filterAction {
+				continue
+			}
+			if err := addOCIRule(filter, name, action, conds); err != nil {
+				return err
+			}
+		}
+	}
+
+	if filterAction != defaultAction {
+		return addOCIKnownSyscalls(filter, p, defaultAction, ruled, partial)
+	}
+
+	return nil
+}
+
+// Helper - Apply the default action of a profile to the syscalls known to
+// libseccomp that have no rule, and to the arguments of those only having
+// conditional rules that the rules do not match, leaving the filter's
+// default action to the unknown ones
+func addOCIKnownSyscalls(filter *ScmpFilter, p *OCISeccomp, defaultAction ScmpAction,
+	ruled map[string]bool, partial map[string][][]ScmpCondition) error {
+	arches := []ScmpArch{ArchNative}
+	for _, name := range p.Architectures {
+		arch, err := ociArchFromString(name)
+		if err != nil {
+			return err
+		}
+		arches = append(arches, arch)
+	}
+
+	// Arguments are compared on 32 bits on 32-bit architectures, which need
+	// rules of their own if the filter also has 64-bit ones
+	var resolved []ScmpArch
+	limits := make(map[ScmpArch]uint64)
+	widths := make(map[uint64]bool)
+	for _, arch := range arches {
+		arch, err := resolveNativeArch(arch)
+		if err != nil {
+			return err
+		} else if _, ok := limits[arch]; ok {
+			continue
+		}
+		bits, err := arch.BitWidth()
+		if err != nil {
+			return err
+		}
+		limits[arch] = math.MaxUint64
+		if bits == 32 {
+			limits[arch] = math.MaxUint32
+		}
+		widths[limits[arch]] = true
+		resolved = append(resolved, arch)
+	}
+
+	var archRules []ScmpRule
+	for _, name := range knownSyscallNames(arches) {
+		// Syscalls only known from the supplemental tables cannot be
+		// translated to other architectures by libseccomp
+		if ruled[name] || !libseccompKnowsSyscall(name) {
+			continue
+		}
+
+		call, err := GetSyscallFromName(name)
+		if err != nil {
+			return err
+		}
+
+		alternatives, ok := partial[name]
+		if !ok {
+			if err := filter.AddRule(call, defaultAction); err != nil {
+				return fmt.Errorf("could not add rule for syscall %q: %v", name, err)
+			}
+			continue
+		}
+
+		if len(widths) == 1 {
+			for limit := range widths {
+				for _, conds := range complementConditions(alternatives, limit) {
+					if err := filter.AddRuleConditional(call, defaultAction, conds); err != nil {
+						return fmt.Errorf("could not add rule for syscall %q: %v", name, err)
+					}
+				}
+			}
+			continue
+		}
+
+		for _, arch := range resolved {
+			archCall, err := TranslateSyscall(call, ArchNative, arch)
+			if err != nil {
+				// Missing on the architecture
+				continue
+			}
+			for _, conds := range complementConditions(alternatives, limits[arch]) {
+				archRules = append(archRules, ScmpRule{Arch: arch, Syscall: archCall, Action: defaultAction, Conditions: conds})
+			}
+		}
+	}
+
+	if len(archRules) != 0 {
+		if err := filter.addArchRules(archRules); err != nil {
+			return fmt.Errorf("could not add rules for the default action: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Helper - Add the rules for one syscall name of an OCI profile entry
+func addOCIRule(filter *ScmpFilter, name string, action ScmpAction, conds []ScmpCondition) error {
+	call, err := GetSyscallFromName(name)
+	if err != nil {
+		// Unknown to this version of libseccomp, ignore it like runc does
+		return nil
+	}
+
+	// Syscalls only known from the supplemental tables cannot be translated
+	// to other architectures by libseccomp
+	addRule := filter.AddRuleConditional
+	if !libseccompKnowsSyscall(name) {
+		addRule = func(call ScmpSyscall, action ScmpAction, conds []ScmpCondition) error {
+			return filter.AddRuleConditionalRaw(ArchNative, call, action, conds)
+		}
+	}
+
+	// libseccomp cannot compare the same argument twice in a single rule,
+	// so such entries are expanded to one rule per condition (logical OR)
+	if !hasRepeatedArgument(conds) {
+		if err := addRule(call, action, conds); err != nil {
+			return fmt.Errorf("could not add rule for syscall %q: %v", name, err)
+		}
+		return nil
+	}
+
+	for _, cond := range conds {
+		if err := addRule(call, action, []ScmpCondition{cond}); err != nil {
+			return fmt.Errorf("could not add rule for syscall %q: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func hasRepeatedArgument(conds []ScmpCondition) bool {
+	seen := make(map[uint]bool)
+	for _, cond := range conds {
+		if seen[cond.Argument] {
+			return true
+		}
+		seen[cond.Argument] = true
+	}
+	return false
+}
+
+func ociConditions(args []OCIArg) ([]ScmpCondition, error) {
+	conds := make([]ScmpCondition, 0, len(args))
+	for _, arg := range args {
+		op, err := ociCompareOpFromString(arg.Op)
+		if err != nil {
+			return nil, err
+		}
+
+		var cond ScmpCondition
+		if op == CompareMaskedEqual {
+			cond, err = MakeCondition(arg.Index, op, arg.Value, arg.ValueTwo)
+		} else {
+			cond, err = MakeCondition(arg.Index, op, arg.Value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	return conds, nil
+}
+
+// Actions, comparison operators and architectures of OCI seccomp profiles
+var (
+	ociActions = []string{"SCMP_ACT_KILL", "SCMP_ACT_KILL_THREAD", "SCMP_ACT_KILL_PROCESS",
+		"SCMP_ACT_TRAP", "SCMP_ACT_ERRNO", "SCMP_ACT_TRACE", "SCMP_ACT_ALLOW", "SCMP_ACT_LOG",
+		"SCMP_ACT_NOTIFY"}
+	ociCompareOps = []string{"SCMP_CMP_NE", "SCMP_CMP_LT", "SCMP_CMP_LE", "SCMP_CMP_EQ",
+		"SCMP_CMP_GE", "SCMP_CMP_GT", "SCMP_CMP_MASKED_EQ"}
+	ociArches = []string{"SCMP_ARCH_X86", "SCMP_ARCH_X86_64", "SCMP_ARCH_X32",
+		"SCMP_ARCH_ARM", "SCMP_ARCH_AARCH64", "SCMP_ARCH_MIPS", "SCMP_ARCH_MIPS64",
+		"SCMP_ARCH_MIPS64N32", "SCMP_ARCH_MIPSEL", "SCMP_ARCH_MIPSEL64",
+		"SCMP_ARCH_MIPSEL64N32", "SCMP_ARCH_PPC", "SCMP_ARCH_PPC64", "SCMP_ARCH_PPC64LE",
+		"SCMP_ARCH_S390", "SCMP_ARCH_S390X", "SCMP_ARCH_PARISC", "SCMP_ARCH_PARISC64",
+		"SCMP_ARCH_LOONGARCH64", "SCMP_ARCH_RISCV64"}
+)
+
+func ociActionToString(action ScmpAction) (string, *uint, error) {
+	base := action & 0xFFFF
+	for _, name := range ociActions {
+		if a, _ := ociActionFromString(name, nil); a&0xFFFF != base {
+			continue
+		}
+
+		if base == ActErrno || base == ActTrace {
+			errnoRet := uint(uint16(action.GetReturnCode()))
+			return name, &errnoRet, nil
+		}
+		return name, nil, nil
+	}
+
+	return "", nil, fmt.Errorf("action %s has no OCI equivalent", action)
+}
+
+func ociActionFromString(action string, errnoRet *uint) (ScmpAction, error) {
+	code := int16(unix.EPERM)
+	if errnoRet != nil {
+		code = int16(*errnoRet)
+	}
+
+	switch action {
+	case "SCMP_ACT_KILL":
+		return ActKill, nil
+	case "SCMP_ACT_KILL_THREAD":
+		return ActKillThread, nil
+	case "SCMP_ACT_KILL_PROCESS":
+		return ActKillProcess, nil
+	case "SCMP_ACT_TRAP":
+		return ActTrap, nil
+	case "SCMP_ACT_ERRNO":
+		return ActErrno.SetReturnCode(code), nil
+	case "SCMP_ACT_TRACE":
+		return ActTrace.SetReturnCode(code), nil
+	case "SCMP_ACT_ALLOW":
+		return ActAllow, nil
+	case "SCMP_ACT_LOG":
+		return ActLog, nil
+	case "SCMP_ACT_NOTIFY":
+		return ActNotify, nil
+	default:
+		return ActInvalid, fmt.Errorf("unrecognized OCI seccomp action %q", action)
+	}
+}
+
+func ociCompareOpFromString(op string) (ScmpCompareOp, error) {
+	switch op {
+	case "SCMP_CMP_NE":
+		return CompareNotEqual, nil
+	case "SCMP_CMP_LT":
+		return CompareLess, nil
+	case "SCMP_CMP_LE":
+		return CompareLessOrEqual, nil
+	case "SCMP_CMP_EQ":
+		return CompareEqual, nil
+	case "SCMP_CMP_GE":
+		return CompareGreaterEqual, nil
+	case "SCMP_CMP_GT":
+		return CompareGreater, nil
+	case "SCMP_CMP_MASKED_EQ":
+		return CompareMaskedEqual, nil
+	default:
+		return CompareInvalid, fmt.Errorf("unrecognized OCI seccomp operator %q", op)
+	}
+}
+
+func ociArgFromCondition(cond ScmpCondition) (OCIArg, error) {
+	for _, name := range ociCompareOps {
+		if op, _ := ociCompareOpFromString(name); op == cond.Op {
+			return OCIArg{Index: cond.Argument, Value: cond.Operand1, ValueTwo: cond.Operand2, Op: name}, nil
+		}
+	}
+
+	return OCIArg{}, fmt.Errorf("comparison operator %s has no OCI equivalent", cond.Op)
+}
+
+func ociArchToString(arch ScmpArch) (string, error) {
+	for _, name := range ociArches {
+		if a, _ := ociArchFromString(name); a == arch {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("architecture %s has no OCI equivalent", arch)
+}
+
+func ociArchFromString(arch string) (ScmpArch, error) {
+	switch arch {
+	case "SCMP_ARCH_NATIVE":
+		return ArchNative, nil
+	case "SCMP_ARCH_X86":
+		return ArchX86, nil
+	case "SCMP_ARCH_X86_64":
+		return ArchAMD64, nil
+	case "SCMP_ARCH_X32":
+		return ArchX32, nil
+	case "SCMP_ARCH_ARM":
+		return ArchARM, nil
+	case "SCMP_ARCH_AARCH64":
+		return ArchARM64, nil
+	case "SCMP_ARCH_MIPS":
+		return ArchMIPS, nil
+	case "SCMP_ARCH_MIPS64":
+		return ArchMIPS64, nil
+	case "SCMP_ARCH_MIPS64N32":
+		return ArchMIPS64N32, nil
+	case "SCMP_ARCH_MIPSEL":
+		return ArchMIPSEL, nil
+	case "SCMP_ARCH_MIPSEL64":
+		return ArchMIPSEL64, nil
+	case "SCMP_ARCH_MIPSEL64N32":
+		return ArchMIPSEL64N32, nil
+	case "SCMP_ARCH_PPC":
+		return ArchPPC, nil
+	case "SCMP_ARCH_PPC64":
+		return ArchPPC64, nil
+	case "SCMP_ARCH_PPC64LE":
+		return ArchPPC64LE, nil
+	case "SCMP_ARCH_S390":
+		return ArchS390, nil
+	case "SCMP_ARCH_S390X":
+		return ArchS390X, nil
+	case "SCMP_ARCH_PARISC":
+		return ArchPARISC, nil
+	case "SCMP_ARCH_PARISC64":
+		return ArchPARISC64, nil
+	case "SCMP_ARCH_LOONGARCH64":
+		return ArchLOONGARCH64, nil
+	case "SCMP_ARCH_RISCV64":
+		return ArchRISCV64, nil
+	default:
+		return ArchInvalid, fmt.Errorf("unrecognized OCI seccomp architecture %q", arch)
+	}
+}
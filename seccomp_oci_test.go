@@ -0,0 +1,396 @@
+// +build linux
+
+// Tests for OCI runtime-spec support of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
+)
+
+const ociTestConfig = `{
+	"ociVersion": "1.0.2",
+	"linux": {
+		"seccomp": {
+			"defaultAction": "SCMP_ACT_ERRNO",
+			"architectures": ["SCMP_ARCH_X86_64", "SCMP_ARCH_X86", "SCMP_ARCH_X32"],
+			"syscalls": [
+				{
+					"names": ["read", "write", "exit_group", "not_a_real_syscall"],
+					"action": "SCMP_ACT_ALLOW"
+				},
+				{
+					"names": ["personality"],
+					"action": "SCMP_ACT_ALLOW",
+					"args": [
+						{"index": 0, "value": 0, "op": "SCMP_CMP_EQ"},
+						{"index": 0, "value": 8, "op": "SCMP_CMP_EQ"}
+					]
+				},
+				{
+					"names": ["ptrace"],
+					"action": "SCMP_ACT_ERRNO"
+				}
+			]
+		}
+	}
+}`
+
+func writeOCIBundle(t *testing.T, config string) string {
+	bundle, err := ioutil.TempDir("", "seccomp-oci-bundle")
+	if err != nil {
+		t.Fatalf("Error creating bundle directory: %s", err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(bundle, "config.json"), []byte(config), 0600)
+	if err != nil {
+		os.RemoveAll(bundle)
+		t.Fatalf("Error writing bundle config: %s", err)
+	}
+
+	return bundle
+}
+
+func TestRunOCIHook(t *testing.T) {
+	bundle := writeOCIBundle(t, ociTestConfig)
+	defer os.RemoveAll(bundle)
+
+	state := `{"ociVersion": "1.0.2", "id": "test", "status": "creating", "bundle": "` + bundle + `"}`
+
+	filter, err := RunOCIHook(strings.NewReader(state))
+	if err != nil {
+		t.Fatalf("Error running OCI hook: %s", err)
+	}
+	defer filter.Release()
+
+	action, err := filter.GetDefaultAction()
+	if err != nil {
+		t.Errorf("Error getting default action: %s", err)
+	} else if action != ActErrno.SetReturnCode(1) {
+		t.Errorf("Default action should be EPERM, got %s", action)
+	}
+
+	for _, arch := range []ScmpArch{ArchAMD64, ArchX86, ArchX32} {
+		present, err := filter.IsArchPresent(arch)
+		if err != nil {
+			t.Errorf("Error checking architecture %s: %s", arch, err)
+		} else if !present {
+			t.Errorf("Architecture %s is not present in the filter", arch)
+		}
+	}
+}
+
+func TestRunOCIHookNoSeccomp(t *testing.T) {
+	bundle := writeOCIBundle(t, `{"ociVersion": "1.0.2", "linux": {}}`)
+	defer os.RemoveAll(bundle)
+
+	filter, err := RunOCIHook(strings.NewReader(`{"bundle": "` + bundle + `"}`))
+	if err != nil {
+		t.Errorf("Error running OCI hook: %s", err)
+	} else if filter != nil {
+		t.Errorf("OCI hook should not return a filter without a seccomp section")
+	}
+
+	_, err = RunOCIHook(strings.NewReader(`{"id": "test"}`))
+	if err == nil {
+		t.Errorf("OCI hook should fail without a bundle")
+	}
+}
+
+func TestRunOCIHookBadProfile(t *testing.T) {
+	bundle := writeOCIBundle(t, `{"linux": {"seccomp": {"defaultAction": "SCMP_ACT_BOGUS"}}}`)
+	defer os.RemoveAll(bundle)
+
+	_, err := RunOCIHook(strings.NewReader(`{"bundle": "` + bundle + `"}`))
+	if err == nil {
+		t.Errorf("OCI hook should fail on an invalid default action")
+	}
+}
+
+func TestNewFilterFromProfileENOSYS(t *testing.T) {
+	auditArch := map[string]uint32{
+		"amd64": 0xc000003e, // AUDIT_ARCH_X86_64
+		"arm64": 0xc00000b7, // AUDIT_ARCH_AARCH64
+	}[runtime.GOARCH]
+	if auditArch == 0 {
+		t.Skipf("Skipping test: no audit architecture known for %s", runtime.GOARCH)
+	}
+
+	enosys := uint(unix.ENOSYS)
+	p := &OCISeccomp{
+		DefaultAction: "SCMP_ACT_ERRNO",
+		Syscalls: []OCISyscall{
+			{Names: []string{"read", "write"}, Action: "SCMP_ACT_ALLOW"},
+			{Names: []string{"clone3"}, Action: "SCMP_ACT_ERRNO", ErrnoRet: &enosys},
+			{Names: []string{"personality"}, Action: "SCMP_ACT_ALLOW", Args: []OCIArg{
+				{Index: 0, Value: 0, Op: "SCMP_CMP_EQ"},
+				{Index: 0, Value: 8, Op: "SCMP_CMP_EQ"},
+			}},
+		},
+		UnknownSyscallsENOSYS: true,
+	}
+	if runtime.GOARCH == "amd64" {
+		p.Architectures = []string{"SCMP_ARCH_X86_64", "SCMP_ARCH_X86"}
+	}
+
+	filter, err := NewFilterFromProfile(p)
+	if err != nil {
+		t.Fatalf("Error compiling profile: %s", err)
+	}
+	defer filter.Release()
+
+	if action, err := filter.GetDefaultAction(); err != nil || action != ActENoSys {
+		t.Errorf("Got default action %s (%v), expected %s", action, err, ActENoSys)
+	}
+
+	tests := []struct {
+		arch uint32
+		nr   int32
+		arg  uint64
+		want uint32
+	}{
+		{auditArch, unix.SYS_READ, 0, bpf.RetAllow},
+		{auditArch, unix.SYS_GETPID, 0, bpf.RetErrno | uint32(unix.EPERM)},
+		{auditArch, unix.SYS_CLONE3, 0, bpf.RetErrno | uint32(unix.ENOSYS)},
+		// Not a syscall as of yet
+		{auditArch, 1000, 0, bpf.RetErrno | uint32(unix.ENOSYS)},
+		// Arguments the conditional rules do not match get the default action
+		{auditArch, unix.SYS_PERSONALITY, 0, bpf.RetAllow},
+		{auditArch, unix.SYS_PERSONALITY, 8, bpf.RetAllow},
+		{auditArch, unix.SYS_PERSONALITY, 1, bpf.RetErrno | uint32(unix.EPERM)},
+		{auditArch, unix.SYS_PERSONALITY, 0x100000000, bpf.RetErrno | uint32(unix.EPERM)},
+	}
+	if runtime.GOARCH == "amd64" {
+		// Only the lower 32 bits of arguments are compared on x86
+		const auditArchI386 = 0x40000003
+		tests = append(tests, []struct {
+			arch uint32
+			nr   int32
+			arg  uint64
+			want uint32
+		}{
+			{auditArchI386, 136, 8, bpf.RetAllow},
+			{auditArchI386, 136, 0x100000000, bpf.RetAllow},
+			{auditArchI386, 136, 9, bpf.RetErrno | uint32(unix.EPERM)},
+		}...)
+	}
+
+	prog := exportProgram(t, filter)
+	for _, test := range tests {
+		ret, err := bpf.Run(prog, &bpf.Data{Nr: test.nr, Arch: test.arch, Args: [6]uint64{test.arg}})
+		if err != nil {
+			t.Fatalf("Error running exported filter: %s", err)
+		}
+		if ret != test.want {
+			t.Errorf("Syscall %d(%#x) of %#x: got %s, want %s", test.nr, test.arg, test.arch,
+				bpf.ActionString(ret), bpf.ActionString(test.want))
+		}
+	}
+}
+
+func TestBuildFilterFromOCIProfile(t *testing.T) {
+	auditArch := map[string]uint32{
+		"amd64": 0xc000003e, // AUDIT_ARCH_X86_64
+		"arm64": 0xc00000b7, // AUDIT_ARCH_AARCH64
+	}[runtime.GOARCH]
+	if auditArch == 0 {
+		t.Skipf("Skipping test: no audit architecture known for %s", runtime.GOARCH)
+	}
+
+	filter, err := BuildFilterFromOCIProfile([]byte(`{
+		"defaultAction": "SCMP_ACT_ERRNO",
+		"defaultErrnoRet": 38,
+		"syscalls": [
+			{"names": ["read", "not_a_real_syscall"], "action": "SCMP_ACT_ALLOW"},
+			{"names": ["getpid"], "action": "SCMP_ACT_ERRNO", "errnoRet": 13},
+			{"names": ["getppid"], "action": "SCMP_ACT_ERRNO"},
+			{
+				"names": ["personality"],
+				"action": "SCMP_ACT_ALLOW",
+				"args": [
+					{"index": 0, "value": 0, "op": "SCMP_CMP_EQ"},
+					{"index": 0, "value": 8, "op": "SCMP_CMP_EQ"}
+				]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Error compiling profile: %s", err)
+	}
+	defer filter.Release()
+
+	prog := exportProgram(t, filter)
+	for _, test := range []struct {
+		nr   int32
+		arg  uint64
+		want uint32
+	}{
+		{unix.SYS_READ, 0, bpf.RetAllow},
+		{unix.SYS_GETPID, 0, bpf.RetErrno | uint32(unix.EACCES)},
+		{unix.SYS_GETPPID, 0, bpf.RetErrno | uint32(unix.EPERM)},
+		{unix.SYS_PERSONALITY, 0, bpf.RetAllow},
+		{unix.SYS_PERSONALITY, 8, bpf.RetAllow},
+		{unix.SYS_PERSONALITY, 1, bpf.RetErrno | uint32(unix.ENOSYS)},
+		{unix.SYS_WRITE, 0, bpf.RetErrno | uint32(unix.ENOSYS)},
+	} {
+		ret, err := bpf.Run(prog, &bpf.Data{Nr: test.nr, Arch: auditArch, Args: [6]uint64{test.arg}})
+		if err != nil {
+			t.Fatalf("Error running exported filter: %s", err)
+		}
+		if ret != test.want {
+			t.Errorf("Syscall %d(%d): got %s, want %s", test.nr, test.arg,
+				bpf.ActionString(ret), bpf.ActionString(test.want))
+		}
+	}
+
+	for _, bad := range []string{
+		`{"defaultAction": "SCMP_ACT_ALLOW"`,
+		`{"defaultAction": "SCMP_ACT_NOTIFY"}`,
+		`{"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"names": ["write"], "action": "SCMP_ACT_NOTIFY"}]}`,
+		`{"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"names": ["read"], "action": "SCMP_ACT_BOGUS"}]}`,
+		`{"defaultAction": "SCMP_ACT_ALLOW", "architectures": ["SCMP_ARCH_BOGUS"]}`,
+	} {
+		if filter, err := BuildFilterFromOCIProfile([]byte(bad)); err == nil {
+			filter.Release()
+			t.Errorf("Compiling invalid profile %s should fail", bad)
+		}
+	}
+}
+
+func TestToOCIProfile(t *testing.T) {
+	filter, err := NewFilter(ActErrno.SetReturnCode(int16(unix.ENOSYS)))
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetLogBit(true); err != nil {
+		t.Fatalf("Error setting log bit: %s", err)
+	}
+
+	for _, name := range []string{"read", "write", "read"} {
+		call, err := GetSyscallFromName(name)
+		if err != nil {
+			t.Fatalf("Error getting syscall number: %s", err)
+		}
+		if err := filter.AddRule(call, ActAllow); err != nil {
+			t.Fatalf("Error adding rule: %s", err)
+		}
+	}
+	personality, err := GetSyscallFromName("personality")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	cond, err := MakeCondition(0, CompareMaskedEqual, 0xff, 8)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+	if err := filter.AddRuleConditional(personality, ActErrno.SetReturnCode(int16(unix.EACCES)), []ScmpCondition{cond}); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	p, err := filter.ToOCIProfile()
+	if err != nil {
+		t.Fatalf("Error exporting profile: %s", err)
+	}
+
+	if p.DefaultAction != "SCMP_ACT_ERRNO" || p.DefaultErrnoRet == nil || *p.DefaultErrnoRet != uint(unix.ENOSYS) {
+		t.Errorf("Got default action %s (%v), want SCMP_ACT_ERRNO (%d)", p.DefaultAction, p.DefaultErrnoRet, unix.ENOSYS)
+	}
+	if len(p.Architectures) != 1 {
+		t.Errorf("Got architectures %v, want only the native one", p.Architectures)
+	}
+	if len(p.Flags) != 1 || p.Flags[0] != "SECCOMP_FILTER_FLAG_LOG" {
+		t.Errorf("Got flags %v, want [SECCOMP_FILTER_FLAG_LOG]", p.Flags)
+	}
+	if len(p.Syscalls) != 2 {
+		t.Fatalf("Got %d syscall entries, want 2: %+v", len(p.Syscalls), p.Syscalls)
+	}
+	if call := p.Syscalls[0]; call.Action != "SCMP_ACT_ALLOW" || strings.Join(call.Names, ",") != "read,write" {
+		t.Errorf("Got first entry %+v, want read and write allowed", call)
+	}
+	want := OCIArg{Index: 0, Value: 0xff, ValueTwo: 8, Op: "SCMP_CMP_MASKED_EQ"}
+	if call := p.Syscalls[1]; call.Action != "SCMP_ACT_ERRNO" || call.ErrnoRet == nil ||
+		*call.ErrnoRet != uint(unix.EACCES) || len(call.Args) != 1 || call.Args[0] != want {
+		t.Errorf("Got second entry %+v, want personality denied with EACCES on %+v", call, want)
+	}
+
+	// The profile compiles back into an equivalent filter
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Error encoding profile: %s", err)
+	}
+	rebuilt, err := BuildFilterFromOCIProfile(data)
+	if err != nil {
+		t.Fatalf("Error compiling exported profile: %s", err)
+	}
+	defer rebuilt.Release()
+
+	if got, want := exportProgram(t, rebuilt), exportProgram(t, filter); len(got) != len(want) {
+		t.Errorf("Rebuilt filter has %d instructions, want %d", len(got), len(want))
+	}
+
+	if err := filter.AddRuleForArch(ArchNative, personality, ActAllow, nil); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+	if _, err := filter.ToOCIProfile(); err == nil {
+		t.Errorf("Exporting a rule restricted to an architecture should fail")
+	}
+}
+
+func TestYAMLProfile(t *testing.T) {
+	filter, err := Profile().
+		Allow("read", "write").
+		Errno(unix.EACCES, "chdir").
+		Build()
+	if err != nil {
+		t.Fatalf("Error building filter: %s", err)
+	}
+	defer filter.Release()
+
+	data, err := filter.ToYAMLProfile()
+	if err != nil {
+		t.Fatalf("Error exporting profile: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "seccomp-yaml")
+	if err != nil {
+		t.Fatalf("Error creating directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The exported profile is included by a hand-written one
+	if err := ioutil.WriteFile(filepath.Join(dir, "base.yaml"), data, 0600); err != nil {
+		t.Fatalf("Error writing profile: %s", err)
+	}
+	profile := "include: [base.yaml]\nsyscalls:\n  # Exits are fine\n  - names: [exit_group]\n    action: SCMP_ACT_ALLOW\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "profile.yaml"), []byte(profile), 0600); err != nil {
+		t.Fatalf("Error writing profile: %s", err)
+	}
+
+	p, err := ReadYAMLProfile(filepath.Join(dir, "profile.yaml"))
+	if err != nil {
+		t.Fatalf("Error reading profile: %s", err)
+	}
+	if len(p.Syscalls) != 3 || p.DefaultAction != "SCMP_ACT_ERRNO" {
+		t.Errorf("Got profile %+v", p)
+	}
+
+	rebuilt, err := BuildFilterFromYAMLProfile(filepath.Join(dir, "profile.yaml"))
+	if err != nil {
+		t.Fatalf("Error compiling profile: %s", err)
+	}
+	defer rebuilt.Release()
+
+	if rules := rebuilt.ListRules(); len(rules) != 4 {
+		t.Errorf("Got %d rules, want 4", len(rules))
+	}
+}
@@ -0,0 +1,84 @@
+// +build linux
+
+// Condition operand checks for libseccomp Go bindings
+// Refuses operands which 32-bit architectures would silently truncate
+
+package seccomp
+
+import (
+	"fmt"
+	"math"
+)
+
+// SetOperandTruncation sets whether rules may have conditions with operands
+// exceeding 32 bits while the filter has 32-bit architectures (see
+// BitWidth). Syscall arguments are only 32 bits wide on such architectures,
+// and libseccomp silently compares them to the lower 32 bits of operands,
+// so that e.g. "arg0 == 0x100000001" matches an argument of 1. Operands
+// which are the sign extension of a negative 32-bit value, e.g. -1, lose
+// nothing by the truncation and are always accepted.
+// By default, adding such a rule to a filter with 32-bit architectures, or a
+// 32-bit architecture to a filter with such rules, fails; with truncation
+// enabled, the operands are truncated on 32-bit architectures.
+// Defaults to off.
+// Returns an error if the filter is invalid, or if truncation is disabled
+// while rules of the filter rely on it.
+func (f *ScmpFilter) SetOperandTruncation(state bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return ErrInvalidFilter
+	}
+
+	if !state && f.truncateOperands {
+		f.truncateOperands = false
+		for _, rule := range f.rules {
+			arches := []ScmpArch{rule.Arch}
+			if rule.Arch == ArchInvalid {
+				arches = f.filterArches()
+			}
+			if err := f.checkOperands(arches, rule.Conditions); err != nil {
+				f.truncateOperands = true
+				return err
+			}
+		}
+	}
+	f.truncateOperands = state
+
+	return nil
+}
+
+// Helper - Check that conditions fit the 32-bit architectures among arches,
+// unless truncation was enabled
+// Requires the filter lock
+func (f *ScmpFilter) checkOperands(arches []ScmpArch, conds []ScmpCondition) error {
+	if f.truncateOperands {
+		return nil
+	}
+
+	for _, cond := range conds {
+		operand := cond.Operand1
+		if operandFits32(operand) && cond.Op == CompareMaskedEqual {
+			operand = cond.Operand2
+		}
+		if operandFits32(operand) {
+			continue
+		}
+
+		for _, arch := range arches {
+			if bits, err := arch.BitWidth(); err == nil && bits == 32 {
+				return fmt.Errorf("operand %#x of condition on argument %d exceeds the 32 bits of arguments on %s, see SetOperandTruncation",
+					operand, cond.Argument, arch)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Helper - Check whether an operand is unchanged by truncation to 32 bits,
+// up to sign extension
+func operandFits32(operand uint64) bool {
+	return operand <= math.MaxUint32 || int64(operand) == int64(int32(operand))
+}
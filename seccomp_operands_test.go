@@ -0,0 +1,101 @@
+// +build linux
+
+// Tests for the condition operand checks of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"testing"
+)
+
+func TestOperandFits32(t *testing.T) {
+	for operand, fits := range map[uint64]bool{
+		0:                  true,
+		0xffffffff:         true,
+		0x100000000:        false,
+		0x7fffffffffffffff: false,
+		0xffffffff80000000: true,
+		0xffffffff7fffffff: false,
+		0xffffffffffffffff: true,
+	} {
+		if operandFits32(operand) != fits {
+			t.Errorf("Expected %#x fitting 32 bits to be %t", operand, fits)
+		}
+	}
+}
+
+func TestOperandTruncation(t *testing.T) {
+	filter, err := NewFilterWithArches(ActAllow, ArchAMD64, ArchX86)
+	if err != nil {
+		t.Skipf("Error creating filter with x86: %s", err)
+	}
+	defer filter.Release()
+
+	call, err := GetSyscallFromName("dup3")
+	if err != nil {
+		t.Fatalf("Error getting syscall number of dup3: %s", err)
+	}
+	wide := []ScmpCondition{{Argument: 2, Op: CompareEqual, Operand1: 0x100000001}}
+	masked := []ScmpCondition{{Argument: 2, Op: CompareMaskedEqual, Operand1: 0xff, Operand2: 0x100000000}}
+	negative := []ScmpCondition{{Argument: 2, Op: CompareEqual, Operand1: 0xffffffffffffffff}}
+
+	if err := filter.AddRuleConditional(call, ActErrno, wide); err == nil {
+		t.Errorf("Expected an error adding a 64-bit operand to a filter with x86")
+	}
+	if err := filter.AddRuleConditional(call, ActErrno, masked); err == nil {
+		t.Errorf("Expected an error adding a 64-bit masked operand to a filter with x86")
+	}
+	if err := filter.AddRuleConditional(call, ActErrno, negative); err != nil {
+		t.Errorf("Error adding a sign-extended operand: %s", err)
+	}
+	if err := filter.AddRuleForArch(ArchAMD64, call, ActKillThread, wide); err != nil {
+		t.Errorf("Error adding a 64-bit operand for amd64 only: %s", err)
+	}
+
+	if err := filter.SetOperandTruncation(true); err != nil {
+		t.Fatalf("Error enabling operand truncation: %s", err)
+	}
+	wider := []ScmpCondition{{Argument: 1, Op: CompareGreater, Operand1: 0x100000000}}
+	if err := filter.AddRuleConditional(call, ActTrap, wider); err != nil {
+		t.Errorf("Error adding a 64-bit operand with truncation: %s", err)
+	}
+	if err := filter.SetOperandTruncation(false); err == nil {
+		t.Errorf("Expected an error disabling truncation relied upon")
+	}
+
+	clone, err := filter.Clone()
+	if err != nil {
+		t.Fatalf("Error cloning filter: %s", err)
+	}
+	defer clone.Release()
+	if !clone.truncateOperands {
+		t.Errorf("Operand truncation was not cloned")
+	}
+}
+
+func TestOperandTruncationAddArch(t *testing.T) {
+	filter, err := NewFilterWithArches(ActAllow, ArchAMD64)
+	if err != nil {
+		t.Skipf("Error creating filter for amd64: %s", err)
+	}
+	defer filter.Release()
+
+	call, err := GetSyscallFromName("dup3")
+	if err != nil {
+		t.Fatalf("Error getting syscall number of dup3: %s", err)
+	}
+	wide := []ScmpCondition{{Argument: 2, Op: CompareEqual, Operand1: 0x100000001}}
+	if err := filter.AddRuleConditional(call, ActErrno, wide); err != nil {
+		t.Fatalf("Error adding a 64-bit operand for amd64: %s", err)
+	}
+
+	if err := filter.AddArch(ArchX86); err == nil {
+		t.Errorf("Expected an error adding x86 to a filter with 64-bit operands")
+	}
+	if err := filter.SetOperandTruncation(true); err != nil {
+		t.Fatalf("Error enabling operand truncation: %s", err)
+	}
+	if err := filter.AddArch(ArchX86); err != nil {
+		t.Errorf("Error adding x86 with truncation: %s", err)
+	}
+}
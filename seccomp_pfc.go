@@ -0,0 +1,579 @@
+// +build linux
+
+// PFC parser for libseccomp Go bindings
+// Reads filters exported with ExportPFC back into rules
+
+package seccomp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+)
+
+// PFCFilter is the content of a filter, as read back from its PFC export by
+// ParsePFC.
+//
+// DefaultAction: action taken on syscalls which match no rule
+// BadArchAction: action taken on syscalls of architectures not in Arches
+// Arches:        architectures of the filter, in the order of the export
+// Rules:         rules of the filter, one per architecture, with the syscall
+//                numbers of their architecture
+//
+type PFCFilter struct {
+	DefaultAction ScmpAction
+	BadArchAction ScmpAction
+	Arches        []ScmpArch
+	Rules         []ScmpRule
+}
+
+// Branches of a PFC comparison, as part of a comparison of a whole argument
+const (
+	// The comparison of the argument continues in the branch
+	pfcContinue = iota
+	// The argument matches in the branch
+	pfcMatch
+	// The argument does not match in the branch
+	pfcMismatch
+)
+
+// Statement of PFC: a comparison with its branches, or an action
+type pfcStatement struct {
+	line   int
+	cond   *pfcCompare
+	action ScmpAction
+	then   []*pfcStatement
+	orElse []*pfcStatement
+}
+
+// Comparison of PFC, on $syscall, $arch or (a half of) a syscall argument
+type pfcCompare struct {
+	field string
+	arg   uint
+	half  string
+	op    string
+	mask  uint64
+	value uint64
+}
+
+// Comparison of a whole syscall argument a PFC comparison is part of
+type pfcArgCompare struct {
+	arg   uint
+	op    ScmpCompareOp
+	mask  uint64
+	value uint64
+}
+
+// Comparison of a whole syscall argument on the path to an action, and
+// whether it matched
+type pfcLiteral struct {
+	pfcArgCompare
+	match bool
+}
+
+// ParsePFC reads the PFC (pseudo filter code) output of ExportPFC back into
+// the rules of the filter, e.g. to edit an exported filter by hand and import
+// it again with Build, or to compare filters by their rules rather than by
+// their exact output. Comparisons of 64-bit arguments split by libseccomp
+// into their upper and lower halves are joined again, and rules are
+// returned sorted by architecture, syscall, action and conditions, without
+// duplicates. Rules added for all architectures are returned once for each
+// architecture, and rules libseccomp rewrote (e.g., for multiplexed socket
+// syscalls on x86) as they were rewritten.
+// Returns an error if the input is not valid PFC, or uses comparisons that
+// rules cannot express.
+func ParsePFC(r io.Reader) (*PFCFilter, error) {
+	stmts, err := parsePFCStatements(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := new(PFCFilter)
+	haveDefault, haveBadArch := false, false
+	for _, stmt := range stmts {
+		if stmt.cond == nil {
+			if haveBadArch {
+				return nil, fmt.Errorf("line %d: more than one invalid architecture action", stmt.line)
+			}
+			p.BadArchAction = stmt.action
+			haveBadArch = true
+			continue
+		} else if stmt.cond.field != "$arch" || stmt.cond.op != "==" || stmt.orElse != nil {
+			return nil, fmt.Errorf("line %d: expected an architecture filter", stmt.line)
+		}
+
+		arch, err := archFromAudit(uint32(stmt.cond.value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", stmt.line, err)
+		}
+		p.Arches = append(p.Arches, arch)
+
+		for _, archStmt := range stmt.then {
+			if archStmt.cond != nil {
+				continue
+			} else if haveDefault && archStmt.action != p.DefaultAction {
+				return nil, fmt.Errorf("line %d: default action differs between architectures", archStmt.line)
+			}
+			p.DefaultAction = archStmt.action
+			haveDefault = true
+		}
+
+		if err := p.addRules(arch, stmt.then); err != nil {
+			return nil, err
+		}
+	}
+
+	if !haveDefault || !haveBadArch {
+		return nil, fmt.Errorf("missing default or invalid architecture action")
+	}
+
+	p.sortRules()
+
+	return p, nil
+}
+
+// Build creates a new filter from the architectures, actions and rules of
+// the PFC filter. See AddRuleForArch for how rules are applied.
+// Returns the filter, which is not loaded, or an error if it could not be
+// created.
+func (p *PFCFilter) Build() (*ScmpFilter, error) {
+	filter, err := NewFilterWithArches(p.DefaultAction, p.Arches...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := filter.SetBadArchAction(p.BadArchAction); err != nil {
+		filter.Release()
+		return nil, fmt.Errorf("could not set bad arch action: %v", err)
+	}
+
+	for _, rule := range p.Rules {
+		if err := filter.AddRuleForArch(rule.Arch, rule.Syscall, rule.Action, rule.Conditions); err != nil {
+			filter.Release()
+			return nil, fmt.Errorf("could not add rule for syscall %d on %s: %v", rule.Syscall, rule.Arch, err)
+		}
+	}
+
+	return filter, nil
+}
+
+// Helper - Parse the statements of PFC, by their indentation
+func parsePFCStatements(r io.Reader) ([]*pfcStatement, error) {
+	type pfcLine struct {
+		num    int
+		indent int
+		text   string
+	}
+
+	var lines []pfcLine
+	scanner := bufio.NewScanner(r)
+	for num := 1; scanner.Scan(); num++ {
+		line := scanner.Text()
+		text := strings.TrimLeft(line, " ")
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent%2 != 0 {
+			return nil, fmt.Errorf("line %d: invalid indentation", num)
+		}
+		lines = append(lines, pfcLine{num, indent / 2, text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	pos := 0
+	var parseBlock func(indent int) ([]*pfcStatement, error)
+	parseBlock = func(indent int) ([]*pfcStatement, error) {
+		var stmts []*pfcStatement
+		for pos < len(lines) && lines[pos].indent >= indent {
+			line := lines[pos]
+			if line.indent > indent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+			}
+			pos++
+
+			stmt := &pfcStatement{line: line.num}
+			switch {
+			case strings.HasPrefix(line.text, "if (") && strings.HasSuffix(line.text, ")"):
+				cond, err := parsePFCCompare(line.text[len("if (") : len(line.text)-1])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", line.num, err)
+				}
+				stmt.cond = cond
+
+				if stmt.then, err = parseBlock(indent + 1); err != nil {
+					return nil, err
+				}
+				if pos < len(lines) && lines[pos].indent == indent && lines[pos].text == "else" {
+					pos++
+					if stmt.orElse, err = parseBlock(indent + 1); err != nil {
+						return nil, err
+					}
+				}
+			case strings.HasPrefix(line.text, "action ") && strings.HasSuffix(line.text, ";"):
+				action, err := parsePFCAction(line.text[len("action ") : len(line.text)-1])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", line.num, err)
+				}
+				stmt.action = action
+			default:
+				return nil, fmt.Errorf("line %d: unexpected %q", line.num, line.text)
+			}
+			stmts = append(stmts, stmt)
+		}
+		return stmts, nil
+	}
+
+	return parseBlock(0)
+}
+
+// Helper - Parse a comparison, e.g. "$a0.hi32 & 0x0000ffff == 0"
+func parsePFCCompare(text string) (*pfcCompare, error) {
+	fields := strings.Fields(text)
+	cond := &pfcCompare{mask: 0xffffffffffffffff}
+
+	if len(fields) == 5 && fields[1] == "&" {
+		mask, err := strconv.ParseUint(fields[2], 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mask %q", fields[2])
+		}
+		cond.mask = mask
+		fields = append(fields[:1], fields[3:]...)
+	}
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid comparison %q", text)
+	}
+
+	switch cond.op = fields[1]; cond.op {
+	case "==", ">", ">=":
+	default:
+		return nil, fmt.Errorf("invalid comparison operator %q", cond.op)
+	}
+
+	value, err := strconv.ParseUint(fields[2], 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", fields[2])
+	}
+	cond.value = value
+
+	cond.field = fields[0]
+	switch {
+	case cond.field == "$arch" || cond.field == "$syscall":
+	case strings.HasPrefix(cond.field, "$a"):
+		name := cond.field[len("$a"):]
+		if i := strings.Index(name, "."); i >= 0 {
+			name, cond.half = name[:i], name[i+1:]
+		}
+		arg, err := strconv.ParseUint(name, 10, 0)
+		if err != nil || arg > 5 || (cond.half != "" && cond.half != "hi32" && cond.half != "lo32") {
+			return nil, fmt.Errorf("invalid argument %q", cond.field)
+		}
+		cond.arg = uint(arg)
+		cond.field = "$a"
+	default:
+		return nil, fmt.Errorf("invalid field %q", cond.field)
+	}
+
+	return cond, nil
+}
+
+// Helper - Parse an action, e.g. "ERRNO(1)" or "0x7fc00000"
+func parsePFCAction(text string) (ScmpAction, error) {
+	var ret uint32
+	var data uint16
+	switch {
+	case text == "KILL_PROCESS":
+		ret = bpf.RetKillProcess
+	case text == "KILL":
+		ret = bpf.RetKillThread
+	case text == "TRAP":
+		ret = bpf.RetTrap
+	case text == "LOG":
+		ret = bpf.RetLog
+	case text == "ALLOW":
+		ret = bpf.RetAllow
+	case strings.HasPrefix(text, "ERRNO("):
+		ret = bpf.RetErrno
+		_, err := fmt.Sscanf(text, "ERRNO(%d)", &data)
+		if err != nil {
+			return 0, fmt.Errorf("invalid action %q", text)
+		}
+	case strings.HasPrefix(text, "TRACE("):
+		ret = bpf.RetTrace
+		_, err := fmt.Sscanf(text, "TRACE(%d)", &data)
+		if err != nil {
+			return 0, fmt.Errorf("invalid action %q", text)
+		}
+	default:
+		value, err := strconv.ParseUint(text, 0, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid action %q", text)
+		}
+		ret = uint32(value)
+	}
+
+	return actionFromRet(ret | uint32(data))
+}
+
+// Helper - Collect the rules of the statements of an architecture
+func (p *PFCFilter) addRules(arch ScmpArch, stmts []*pfcStatement) error {
+	for _, stmt := range stmts {
+		switch {
+		case stmt.cond == nil:
+			// Default action
+		case stmt.cond.field == "$syscall" && stmt.cond.op == ">":
+			// Binary search tree over the syscalls
+			if err := p.addRules(arch, stmt.then); err != nil {
+				return err
+			}
+			if err := p.addRules(arch, stmt.orElse); err != nil {
+				return err
+			}
+		case stmt.cond.field == "$syscall" && stmt.cond.op == "==" && stmt.orElse == nil:
+			call := ScmpSyscall(int32(uint32(stmt.cond.value)))
+			err := walkPFCArgs(stmt.then, nil, nil, func(action ScmpAction, path []pfcLiteral) error {
+				conds, err := pfcConditions(path)
+				if err != nil {
+					return fmt.Errorf("line %d: %v", stmt.line, err)
+				}
+				p.Rules = append(p.Rules, ScmpRule{
+					Arch:       arch,
+					Syscall:    call,
+					Action:     action,
+					Conditions: conds,
+				})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("line %d: expected a syscall filter", stmt.line)
+		}
+	}
+
+	return nil
+}
+
+// Helper - Walk the argument comparisons of a syscall, calling leaf with the
+// comparisons leading to each action
+// upper holds the upper halves of the arguments whose comparison continues
+// with their lower half
+func walkPFCArgs(stmts []*pfcStatement, path []pfcLiteral, upper map[uint]*pfcCompare,
+	leaf func(action ScmpAction, path []pfcLiteral) error) error {
+	for _, stmt := range stmts {
+		if stmt.cond == nil {
+			if err := leaf(stmt.action, path); err != nil {
+				return err
+			}
+			continue
+		} else if stmt.cond.field != "$a" {
+			return fmt.Errorf("line %d: expected an argument comparison", stmt.line)
+		}
+
+		cmp, onTrue, onFalse, err := pfcArgComparison(stmt, upper)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", stmt.line, err)
+		}
+
+		for _, branch := range []struct {
+			stmts  []*pfcStatement
+			result int
+		}{{stmt.then, onTrue}, {stmt.orElse, onFalse}} {
+			if len(branch.stmts) == 0 {
+				continue
+			}
+
+			branchPath := path
+			branchUpper := upper
+			if branch.result == pfcContinue {
+				branchUpper = make(map[uint]*pfcCompare, len(upper)+1)
+				for arg, cond := range upper {
+					branchUpper[arg] = cond
+				}
+				branchUpper[cmp.arg] = stmt.cond
+			} else {
+				literal := pfcLiteral{cmp, branch.result == pfcMatch}
+				branchPath = append(append([]pfcLiteral(nil), path...), literal)
+			}
+
+			if err := walkPFCArgs(branch.stmts, branchPath, branchUpper, leaf); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Helper - Get the comparison of a whole argument a PFC comparison is part
+// of, and the outcome of each of its branches
+// 64-bit arguments are compared by libseccomp as follows:
+// ==, masked ==: upper == H, then lower == L
+// >, >=:         upper > H, else upper == H, then lower > or >= L
+func pfcArgComparison(stmt *pfcStatement, upper map[uint]*pfcCompare) (pfcArgCompare, int, int, error) {
+	cond := stmt.cond
+	cmp := pfcArgCompare{arg: cond.arg}
+
+	// Find the comparison of the lower half following an upper half
+	lowerOf := func(stmts []*pfcStatement) *pfcCompare {
+		for _, s := range stmts {
+			if s.cond != nil && s.cond.field == "$a" && s.cond.arg == cond.arg && s.cond.half == "lo32" {
+				return s.cond
+			}
+		}
+		return nil
+	}
+	setOp := func(op string, masked bool) {
+		switch {
+		case op == ">":
+			cmp.op = CompareGreater
+		case op == ">=":
+			cmp.op = CompareGreaterEqual
+		case masked:
+			cmp.op = CompareMaskedEqual
+		default:
+			cmp.op = CompareEqual
+		}
+	}
+
+	switch cond.half {
+	case "":
+		setOp(cond.op, cond.mask != 0xffffffffffffffff)
+		cmp.mask, cmp.value = cond.mask, cond.value
+		return cmp, pfcMatch, pfcMismatch, nil
+	case "lo32":
+		hi, ok := upper[cond.arg]
+		if !ok {
+			return cmp, 0, 0, fmt.Errorf("comparison of the lower half of argument %d without its upper half", cond.arg)
+		}
+		setOp(cond.op, hi.mask != 0xffffffffffffffff || cond.mask != 0xffffffffffffffff)
+		cmp.mask = (hi.mask&0xffffffff)<<32 | cond.mask&0xffffffff
+		cmp.value = hi.value<<32 | cond.value
+		return cmp, pfcMatch, pfcMismatch, nil
+	}
+
+	if cond.op == ">" {
+		// upper > H, else upper == H, then lower > or >= L
+		for _, s := range stmt.orElse {
+			if s.cond == nil || s.cond.field != "$a" || s.cond.arg != cond.arg ||
+				s.cond.half != "hi32" || s.cond.op != "==" || s.cond.value != cond.value {
+				continue
+			}
+			if lo := lowerOf(s.then); lo != nil && lo.op != "==" {
+				setOp(lo.op, false)
+				cmp.mask, cmp.value = 0xffffffffffffffff, cond.value<<32|lo.value
+				return cmp, pfcMatch, pfcContinue, nil
+			}
+		}
+		return cmp, 0, 0, fmt.Errorf("comparison of the upper half of argument %d without its lower half", cond.arg)
+	}
+
+	lo := lowerOf(stmt.then)
+	if lo == nil || cond.op != "==" {
+		return cmp, 0, 0, fmt.Errorf("comparison of the upper half of argument %d without its lower half", cond.arg)
+	}
+	setOp(lo.op, cond.mask != 0xffffffffffffffff || lo.mask != 0xffffffffffffffff)
+	cmp.mask = (cond.mask&0xffffffff)<<32 | lo.mask&0xffffffff
+	cmp.value = cond.value<<32 | lo.value
+
+	return cmp, pfcContinue, pfcMismatch, nil
+}
+
+// Helper - Convert the argument comparisons leading to an action into the
+// conditions of a rule, sorted and without duplicates
+func pfcConditions(path []pfcLiteral) ([]ScmpCondition, error) {
+	var conds []ScmpCondition
+	for _, literal := range path {
+		cond := ScmpCondition{Argument: literal.arg, Op: literal.op, Operand1: literal.value}
+		if literal.op == CompareMaskedEqual {
+			cond.Operand1, cond.Operand2 = literal.mask, literal.value
+		}
+
+		if !literal.match {
+			switch literal.op {
+			case CompareEqual:
+				cond.Op = CompareNotEqual
+			case CompareGreater:
+				cond.Op = CompareLessOrEqual
+			case CompareGreaterEqual:
+				cond.Op = CompareLess
+			default:
+				return nil, fmt.Errorf("negated masked comparison of argument %d cannot be expressed as a condition", literal.arg)
+			}
+		}
+
+		dup := false
+		for _, c := range conds {
+			dup = dup || c == cond
+		}
+		if !dup {
+			conds = append(conds, cond)
+		}
+	}
+
+	sort.Slice(conds, func(i, j int) bool { return lessCondition(conds[i], conds[j]) })
+
+	return conds, nil
+}
+
+// Helper - Sort the rules and remove duplicates
+func (p *PFCFilter) sortRules() {
+	archIndex := make(map[ScmpArch]int, len(p.Arches))
+	for i, arch := range p.Arches {
+		archIndex[arch] = i
+	}
+
+	sort.SliceStable(p.Rules, func(i, j int) bool {
+		a, b := p.Rules[i], p.Rules[j]
+		switch {
+		case a.Arch != b.Arch:
+			return archIndex[a.Arch] < archIndex[b.Arch]
+		case a.Syscall != b.Syscall:
+			return a.Syscall < b.Syscall
+		case a.Action != b.Action:
+			return a.Action < b.Action
+		}
+		for k := 0; k < len(a.Conditions) && k < len(b.Conditions); k++ {
+			if a.Conditions[k] != b.Conditions[k] {
+				return lessCondition(a.Conditions[k], b.Conditions[k])
+			}
+		}
+		return len(a.Conditions) < len(b.Conditions)
+	})
+
+	rules := p.Rules[:0]
+	for _, rule := range p.Rules {
+		if n := len(rules); n > 0 && rules[n-1].Arch == rule.Arch && rules[n-1].Syscall == rule.Syscall &&
+			rules[n-1].Action == rule.Action && sameConditions(rules[n-1].Conditions, rule.Conditions) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	p.Rules = rules
+}
+
+// Helper - Order conditions by argument, operator and operands
+func lessCondition(a, b ScmpCondition) bool {
+	switch {
+	case a.Argument != b.Argument:
+		return a.Argument < b.Argument
+	case a.Op != b.Op:
+		return a.Op < b.Op
+	case a.Operand1 != b.Operand1:
+		return a.Operand1 < b.Operand1
+	}
+	return a.Operand2 < b.Operand2
+}
@@ -0,0 +1,160 @@
+// +build linux
+
+// Tests for the PFC parser of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"bytes"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Helper - Parse the PFC export of a filter
+func parseFilterPFC(t *testing.T, filter *ScmpFilter) *PFCFilter {
+	var buf bytes.Buffer
+	if err := filter.ExportPFC(&buf); err != nil {
+		t.Fatalf("Error exporting PFC: %s", err)
+	}
+
+	p, err := ParsePFC(&buf)
+	if err != nil {
+		t.Fatalf("Error parsing PFC: %s\n%s", err, buf.String())
+	}
+
+	return p
+}
+
+func TestParsePFC(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: syscall numbers are checked for amd64")
+	}
+
+	filter, err := NewFilterWithArches(ActErrno.SetReturnCode(1), ArchAMD64, ArchX86)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.SetBadArchAction(ActKillProcess); err != nil {
+		t.Fatalf("Error setting bad arch action: %s", err)
+	}
+	// The 64-bit operands are truncated on x86
+	if err := filter.SetOperandTruncation(true); err != nil {
+		t.Fatalf("Error enabling operand truncation: %s", err)
+	}
+
+	cond := func(arg uint, op ScmpCompareOp, values ...uint64) ScmpCondition {
+		c, err := MakeCondition(arg, op, values...)
+		if err != nil {
+			t.Fatalf("Error making condition: %s", err)
+		}
+		return c
+	}
+
+	// Syscall numbers on amd64
+	rules := []ScmpRule{
+		{Syscall: 1, Action: ActAllow},
+		{Syscall: 60, Action: ActNotify},
+		{Syscall: 0, Action: ActTrace.SetReturnCode(7), Conditions: []ScmpCondition{
+			cond(0, CompareMaskedEqual, 0xff, 3), cond(1, CompareGreater, 5)}},
+		{Syscall: 41, Action: ActAllow, Conditions: []ScmpCondition{cond(0, CompareNotEqual, 40)}},
+		{Syscall: 42, Action: ActLog, Conditions: []ScmpCondition{
+			cond(1, CompareLessOrEqual, 7), cond(2, CompareGreaterEqual, 0x200000000)}},
+		{Syscall: 43, Action: ActAllow, Conditions: []ScmpCondition{cond(0, CompareLess, 0x100000005)}},
+		{Syscall: 135, Action: ActAllow, Conditions: []ScmpCondition{cond(0, CompareEqual, 0)}},
+		{Syscall: 135, Action: ActAllow, Conditions: []ScmpCondition{cond(0, CompareEqual, 0xffffffff)}},
+	}
+	for _, rule := range rules {
+		if err := filter.AddRuleConditional(rule.Syscall, rule.Action, rule.Conditions); err != nil {
+			t.Fatalf("Error adding rule: %s", err)
+		}
+	}
+
+	p := parseFilterPFC(t, filter)
+	if p.DefaultAction != ActErrno.SetReturnCode(1) || p.BadArchAction != ActKillProcess {
+		t.Errorf("Got actions %s and %s", p.DefaultAction, p.BadArchAction)
+	}
+	if !reflect.DeepEqual(p.Arches, []ScmpArch{ArchAMD64, ArchX86}) {
+		t.Errorf("Got architectures %v", p.Arches)
+	}
+
+	var got []ScmpRule
+	for _, rule := range p.Rules {
+		if rule.Arch == ArchAMD64 {
+			rule.Arch = ArchInvalid
+			got = append(got, rule)
+		}
+	}
+	if len(got) != len(rules) {
+		t.Fatalf("Got %d rules for amd64, want %d: %+v", len(got), len(rules), got)
+	}
+	for _, want := range rules {
+		found := false
+		for _, rule := range got {
+			found = found || (rule.Syscall == want.Syscall && rule.Action == want.Action &&
+				sameConditions(rule.Conditions, want.Conditions))
+		}
+		if !found {
+			t.Errorf("Rule %+v not found in %+v", want, got)
+		}
+	}
+
+	// Importing the rules again gives the same filter
+	rebuilt, err := p.Build()
+	if err != nil {
+		t.Fatalf("Error building filter: %s", err)
+	}
+	defer rebuilt.Release()
+
+	if again := parseFilterPFC(t, rebuilt); !reflect.DeepEqual(again, p) {
+		t.Errorf("Rebuilt filter differs:\n%+v\n%+v", again, p)
+	}
+}
+
+func TestParsePFCOptimized(t *testing.T) {
+	filter, err := NewFilter(ActErrno.SetReturnCode(1))
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.SetOptimizeLevel(2); err != nil {
+		t.Skipf("Skipping test: binary tree optimization not supported: %s", err)
+	}
+	for call := ScmpSyscall(0); call < 16; call++ {
+		if err := filter.AddRuleExact(call, ActAllow); err != nil {
+			t.Fatalf("Error adding rule: %s", err)
+		}
+	}
+
+	p := parseFilterPFC(t, filter)
+	if len(p.Rules) != 16 {
+		t.Fatalf("Got %d rules, want 16", len(p.Rules))
+	}
+	for i, rule := range p.Rules {
+		if rule.Syscall != ScmpSyscall(i) || rule.Action != ActAllow || len(rule.Conditions) != 0 {
+			t.Errorf("Unexpected rule %+v", rule)
+		}
+	}
+}
+
+func TestParsePFCInvalid(t *testing.T) {
+	for _, pfc := range []string{
+		"",
+		"action ALLOW;\n",
+		"if ($arch == 3221225534)\n  action ALLOW;\n",
+		"if ($arch == 3221225534)\n  action BOGUS;\naction KILL;\n",
+		"if ($arch == 3221225534)\n    action ALLOW;\naction KILL;\n",
+		"if ($arch == 1)\n  action ALLOW;\naction KILL;\n",
+		"if ($arch == 3221225534)\n  if ($syscall == 0)\n    if ($a0.lo32 == 1)\n      action ALLOW;\n  action ERRNO(1);\naction KILL;\n",
+		"if ($arch == 3221225534)\n  if ($syscall == 0)\n    if ($a6 == 1)\n      action ALLOW;\n  action ERRNO(1);\naction KILL;\n",
+		"if ($arch == 3221225534)\n  if ($syscall == 0)\n    if ($a0 & 0xff == 1)\n    else\n      action ALLOW;\n  action ERRNO(1);\naction KILL;\n",
+	} {
+		if _, err := ParsePFC(strings.NewReader(pfc)); err == nil {
+			t.Errorf("Parsing invalid PFC should fail:\n%s", pfc)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+// +build linux
+
+// Non-blocking notification receive for libseccomp Go bindings
+// Waits for notifications with the Go runtime poller instead of OS threads
+
+package seccomp
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// NotifPoller receives seccomp userspace notifications without blocking an
+// OS thread while waiting for them. The notification fd is registered with
+// the Go runtime poller, so any number of goroutines waiting in Next only
+// park themselves, and a notification is only retrieved from the kernel once
+// it is ready.
+// Responses are sent with NotifRespond on the fd returned by Fd, or with
+// Respond.
+// A NotifPoller is safe for concurrent use.
+type NotifPoller struct {
+	fd   ScmpFd
+	file *os.File
+	// Token held by the goroutine receiving a notification, since the
+	// kernel blocks receives even on non-blocking fds
+	recv chan struct{}
+}
+
+// NewNotifPoller creates a poller for the notification fd fd, as returned by
+// GetNotifFd(). The poller works on a non-blocking duplicate of fd, and does
+// not take ownership of fd; it must be closed with Close.
+// Returns an error if fd could not be duplicated.
+func NewNotifPoller(fd ScmpFd) (*NotifPoller, error) {
+	dup, err := unix.FcntlInt(uintptr(fd), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// os.NewFile only registers non-blocking fds with the runtime poller
+	if err := unix.SetNonblock(dup, true); err != nil {
+		unix.Close(dup)
+		return nil, err
+	}
+
+	p := &NotifPoller{
+		fd:   ScmpFd(dup),
+		file: os.NewFile(uintptr(dup), "seccomp-notify"),
+		recv: make(chan struct{}, 1),
+	}
+	p.recv <- struct{}{}
+
+	return p, nil
+}
+
+// Fd returns the notification fd of the poller, which is valid until the
+// poller is closed.
+func (p *NotifPoller) Fd() ScmpFd {
+	// Not p.file.Fd(), which would make the fd blocking again
+	return p.fd
+}
+
+// Next retrieves the next notification, waiting until one is available or
+// ctx is done. Each notification is returned to a single caller.
+// Returns the error of ctx once it is done, io.EOF once no process uses the
+// filter anymore, ErrNotifTargetGone if the notification went away before it
+// could be retrieved, or an error if receiving failed.
+func (p *NotifPoller) Next(ctx context.Context) (*ScmpNotifReq, error) {
+	select {
+	case <-p.recv:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() {
+		p.recv <- struct{}{}
+	}()
+
+	conn, err := p.file.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.file.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	if ctx.Done() != nil {
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-ctx.Done():
+				// Wake up the goroutine waiting in the runtime poller
+				p.file.SetReadDeadline(time.Unix(1, 0))
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-stopped
+		}()
+	}
+
+	var req *ScmpNotifReq
+	var recvErr error
+	err = conn.Read(func(fd uintptr) bool {
+		var ready bool
+		ready, recvErr = notifReady(ScmpFd(fd))
+		if recvErr != nil {
+			return true
+		} else if !ready {
+			return false
+		}
+
+		req, recvErr = notifReceive(ScmpFd(fd))
+		return true
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	return req, recvErr
+}
+
+// Respond responds to a notification retrieved via Next. See NotifRespond().
+func (p *NotifPoller) Respond(resp *ScmpNotifResp) error {
+	return notifRespond(p.Fd(), resp)
+}
+
+// Close closes the poller's notification fd. Goroutines waiting in Next
+// return an error.
+func (p *NotifPoller) Close() error {
+	return p.file.Close()
+}
+
+// Helper - Check without blocking whether a notification can be received
+// Returns ErrNotifClosed if the notification fd is closed, and io.EOF once it
+// hung up
+func notifReady(fd ScmpFd) (bool, error) {
+	for {
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, 0)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return false, err
+		} else if n == 0 {
+			return false, nil
+		}
+
+		switch {
+		case fds[0].Revents&unix.POLLIN != 0:
+			return true, nil
+		case fds[0].Revents&unix.POLLNVAL != 0:
+			return false, ErrNotifClosed
+		case fds[0].Revents&unix.POLLHUP != 0:
+			return false, io.EOF
+		}
+
+		return false, nil
+	}
+}
+
+// Wakeups of the notification fds used by receives, which NotifClose wakes
+// up, and only closes once the last of them is done, so that they never use
+// another file reusing the fd number. Wakeups are kept until NotifClose, so
+// that receiving does not allocate.
+var notifWakers = struct {
+	lock sync.Mutex
+	byFd map[ScmpFd]*notifWaker
+}{
+	byFd: make(map[ScmpFd]*notifWaker),
+}
+
+// Wakeup of the receives using a notification fd
+type notifWaker struct {
+	// eventfd made readable by NotifClose
+	efd    int
+	users  int
+	closed bool
+}
+
+// NotifClose closes the notification fd fd, as returned by GetNotifFd().
+// NotifReceive, NotifReceiveBatch and NotifWait calls waiting on fd return
+// ErrNotifClosed, as do those made afterwards, so that a receive loop can be
+// stopped from another goroutine. A receive already retrieving a
+// notification from the kernel is not interrupted; fd is only closed once the
+// last receive using it returned.
+// Returns an error if fd could not be closed.
+func NotifClose(fd ScmpFd) error {
+	notifWakers.lock.Lock()
+	defer notifWakers.lock.Unlock()
+
+	w, ok := notifWakers.byFd[fd]
+	if !ok {
+		return unix.Close(int(fd))
+	} else if w.closed {
+		return ErrNotifClosed
+	} else if w.users == 0 {
+		delete(notifWakers.byFd, fd)
+		unix.Close(w.efd)
+		return unix.Close(int(fd))
+	}
+	w.closed = true
+
+	return signalEventfd(w.efd)
+}
+
+// NotifWait waits until a notification can be received from the notification
+// fd fd, without retrieving it, e.g. to check for other events between
+// notifications.
+// Returns the error of ctx once it is done, ErrNotifClosed once fd is closed
+// with NotifClose, io.EOF once no process uses the filter anymore, or an
+// error if waiting failed.
+func NotifWait(ctx context.Context, fd ScmpFd) error {
+	w, err := notifAcquire(fd)
+	if err != nil {
+		return err
+	}
+	defer notifRelease(fd, w)
+
+	return w.wait(ctx, fd)
+}
+
+// Helper - Register a receive using a notification fd
+// Returns ErrNotifClosed if fd is being closed
+func notifAcquire(fd ScmpFd) (*notifWaker, error) {
+	notifWakers.lock.Lock()
+	defer notifWakers.lock.Unlock()
+
+	w, ok := notifWakers.byFd[fd]
+	if !ok {
+		efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC)
+		if err != nil {
+			return nil, err
+		}
+		w = &notifWaker{efd: efd}
+		notifWakers.byFd[fd] = w
+	} else if w.closed {
+		return nil, ErrNotifClosed
+	}
+	w.users++
+
+	return w, nil
+}
+
+// Helper - Unregister a receive using a notification fd, closing fd if it is
+// the last receive since NotifClose
+func notifRelease(fd ScmpFd, w *notifWaker) {
+	notifWakers.lock.Lock()
+	defer notifWakers.lock.Unlock()
+
+	if w.users--; w.users > 0 || !w.closed {
+		return
+	}
+	delete(notifWakers.byFd, fd)
+	unix.Close(w.efd)
+	unix.Close(int(fd))
+}
+
+// Helper - Wait until a notification can be received from fd, fd is closed
+// with NotifClose or ctx is done
+func (w *notifWaker) wait(ctx context.Context, fd ScmpFd) error {
+	fds := []unix.PollFd{
+		{Fd: int32(fd), Events: unix.POLLIN},
+		{Fd: int32(w.efd), Events: unix.POLLIN},
+	}
+
+	if done := ctx.Done(); done != nil {
+		efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC)
+		if err != nil {
+			return err
+		}
+		defer unix.Close(efd)
+		fds = append(fds, unix.PollFd{Fd: int32(efd), Events: unix.POLLIN})
+
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-done:
+				signalEventfd(efd)
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-stopped
+		}()
+	}
+
+	for {
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		switch {
+		case fds[1].Revents != 0:
+			return ErrNotifClosed
+		case len(fds) > 2 && fds[2].Revents != 0:
+			return ctx.Err()
+		case fds[0].Revents&unix.POLLIN != 0:
+			return nil
+		case fds[0].Revents&unix.POLLNVAL != 0:
+			return ErrNotifClosed
+		case fds[0].Revents&unix.POLLHUP != 0:
+			return io.EOF
+		}
+	}
+}
+
+// Helper - Make an eventfd readable
+func signalEventfd(efd int) error {
+	// Any non-zero count
+	var count [8]byte
+	count[0] = 1
+	_, err := unix.Write(efd, count[:])
+
+	return err
+}
@@ -0,0 +1,75 @@
+// +build linux
+
+// Tests for the notification poller of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestNotifPoller(t *testing.T) {
+	execInSubprocess(t, subprocessNotifPoller)
+}
+func subprocessNotifPoller(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	// Only the target goroutine uses getppid, see subprocessNotif
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	poller, err := NewNotifPoller(fd)
+	if err != nil {
+		t.Fatalf("Error creating poller: %s", err)
+	}
+	defer poller.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := poller.Next(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Next() without notification: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	type result struct {
+		val   uintptr
+		errno unix.Errno
+	}
+	results := make(chan result, 1)
+	go func() {
+		r1, _, errno := unix.Syscall(unix.SYS_GETPPID, 0, 0, 0)
+		results <- result{r1, errno}
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := poller.Next(ctx)
+	if err != nil {
+		t.Fatalf("Error in Next(): %s", err)
+	}
+	if req.Data.Syscall != unix.SYS_GETPPID {
+		t.Errorf("Notification for syscall %d, want %d", req.Data.Syscall, unix.SYS_GETPPID)
+	}
+	if err := poller.Respond(&ScmpNotifResp{ID: req.ID, Val: 42}); err != nil {
+		t.Fatalf("Error in Respond(): %s", err)
+	}
+
+	if res := <-results; res.errno != 0 || res.val != 42 {
+		t.Errorf("Emulated syscall returned %d (%v), want 42", res.val, res.errno)
+	}
+}
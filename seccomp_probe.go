@@ -0,0 +1,107 @@
+// +build linux
+
+// Kernel syscall probing for libseccomp Go bindings
+// Finds out whether the running kernel implements a syscall by calling it
+
+package seccomp
+
+import (
+	"fmt"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// Syscalls which fail without effect when called with all bits set in their
+// arguments, either on a bad file descriptor, a bad pointer, or invalid
+// flags checked before anything else. Any other syscall may have effects
+// outliving the probing thread, e.g. msgget creating a message queue, and is
+// not probed
+var probeSafe = map[string]bool{
+	// Bad file descriptors
+	"close": true, "dup": true, "dup2": true, "dup3": true, "fcntl": true,
+	"fstat": true, "ioctl": true, "lseek": true, "pread64": true,
+	"preadv": true, "pwrite64": true, "pwritev": true, "read": true,
+	"readv": true, "write": true, "writev": true,
+	// Bad pointers or invalid arguments
+	"clock_gettime": true, "getcwd": true, "gettimeofday": true,
+	"mmap": true, "mprotect": true, "munmap": true, "nanosleep": true,
+	"newfstatat": true, "openat": true, "sysinfo": true, "tgkill": true,
+	"uname": true,
+	// No arguments, nothing changed
+	"getegid": true, "geteuid": true, "getgid": true, "getpid": true,
+	"getppid": true, "gettid": true, "getuid": true,
+	// Invalid flags
+	"cachestat": true, "close_range": true, "copy_file_range": true,
+	"epoll_create1": true, "eventfd2": true, "faccessat2": true,
+	"fchmodat2": true, "fsmount": true, "fsopen": true, "fspick": true,
+	"futex_waitv": true, "getrandom": true, "inotify_init1": true,
+	"landlock_create_ruleset": true, "listmount": true,
+	"lsm_get_self_attr": true, "lsm_list_modules": true,
+	"lsm_set_self_attr": true, "membarrier": true, "memfd_create": true,
+	"memfd_secret": true, "mount_setattr": true, "move_mount": true,
+	"mseal": true, "open_tree": true, "openat2": true,
+	"pidfd_getfd": true, "pidfd_open": true, "pidfd_send_signal": true,
+	"pipe2": true, "pkey_alloc": true, "preadv2": true,
+	"process_madvise": true, "process_mrelease": true, "pwritev2": true,
+	"rseq": true, "seccomp": true, "set_mempolicy_home_node": true,
+	"statmount": true, "statx": true,
+	// Never implemented
+	"afs_syscall": true, "getpmsg": true, "putpmsg": true,
+	"security": true, "tuxcall": true, "vserver": true,
+}
+
+// Arguments probing syscalls which report unknown operations with ENOSYS,
+// such that they fail with another error or have no effect
+var probeArgs = map[string][6]uintptr{
+	// FUTEX_WAIT on NULL
+	"futex": {0, 0, 0, 0, 0, 0},
+	// Read 0 bytes of the LDT
+	"modify_ldt": {0, 0, 0, 0, 0, 0},
+	// SEMCTL on an invalid semaphore set
+	"ipc": {3, ^uintptr(0), 0, 0, 0, 0},
+}
+
+// KernelSupportsSyscall probes the running kernel for a syscall of the native
+// architecture, as libseccomp knowing a syscall does not mean the kernel
+// implements it, e.g. because it is too old or was built without it. The
+// syscall is called with invalid arguments, all bits set, so that it fails
+// without effect, on a thread which is discarded afterwards; only a failure
+// with ENOSYS means that the kernel lacks it. Only syscalls known to fail
+// without effect this way are probed, as discarding the thread does not undo
+// changes to global kernel state.
+// As filters loaded into the process apply to the probe, syscalls a filter
+// denies with ENOSYS are reported as unsupported, and probing syscalls a
+// filter kills the process for kills the process.
+// Returns whether the kernel implements the syscall, or an error if the
+// syscall is unknown, is a pseudo-syscall, or cannot be probed safely.
+func KernelSupportsSyscall(call ScmpSyscall) (bool, error) {
+	if int32(call) < 0 {
+		return false, fmt.Errorf("syscall %d is a pseudo-syscall, not a syscall of the kernel", int32(call))
+	}
+
+	name, err := call.GetName()
+	if err != nil {
+		return false, fmt.Errorf("cannot probe unknown syscall %d: %v", int32(call), err)
+	}
+
+	args, ok := probeArgs[name]
+	if !ok && !probeSafe[name] {
+		return false, fmt.Errorf("syscall %s cannot be probed safely", name)
+	} else if !ok {
+		for i := range args {
+			args[i] = ^uintptr(0)
+		}
+	}
+
+	done := make(chan unix.Errno)
+	go func() {
+		// The thread is discarded when the goroutine exits locked to it,
+		// along with any state the probe changed
+		runtime.LockOSThread()
+		_, _, errno := unix.Syscall6(uintptr(call), args[0], args[1], args[2], args[3], args[4], args[5])
+		done <- errno
+	}()
+
+	return <-done != unix.ENOSYS, nil
+}
@@ -0,0 +1,47 @@
+// +build linux
+
+// Tests for the kernel syscall probing of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestKernelSupportsSyscall(t *testing.T) {
+	for _, name := range []string{"getpid", "read", "futex", "openat", "tgkill", "mmap"} {
+		call, err := GetSyscallFromName(name)
+		if err != nil {
+			t.Fatalf("Error getting syscall number of %s: %s", name, err)
+		}
+		if supported, err := KernelSupportsSyscall(call); err != nil || !supported {
+			t.Errorf("Got %s supported %t, %v", name, supported, err)
+		}
+	}
+
+	for _, name := range []string{"exit_group", "fork", "rt_sigreturn", "msgget", "unlink", "setsid"} {
+		call, err := GetSyscallFromName(name)
+		if err != nil || call.IsPseudo() {
+			continue
+		}
+		if _, err := KernelSupportsSyscall(call); err == nil {
+			t.Errorf("Probing %s should fail", name)
+		}
+	}
+
+	if _, err := KernelSupportsSyscall(PseudoSocket); err == nil {
+		t.Errorf("Probing a pseudo-syscall should fail")
+	}
+	if _, err := KernelSupportsSyscall(4095); err == nil {
+		t.Errorf("Probing an unknown syscall should fail")
+	}
+
+	if runtime.GOARCH != "amd64" {
+		return
+	}
+	// afs_syscall was never implemented
+	if supported, err := KernelSupportsSyscall(183); err != nil || supported {
+		t.Errorf("Got afs_syscall supported %t, %v", supported, err)
+	}
+}
@@ -0,0 +1,130 @@
+// +build linux
+
+// Profile management for libseccomp Go bindings
+// Compiles profiles managed by package profile into filters
+
+package seccomp
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/seccomp/libseccomp-golang/profile"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// ErrProfileSignatureInvalid represents an error condition where the
+	// signature over a profile could not be verified against any trusted key
+	ErrProfileSignatureInvalid = profile.ErrSignatureInvalid
+)
+
+// ProfileFetcher retrieves seccomp profiles from http(s) URLs or OCI
+// registries. See profile.Fetcher.
+type ProfileFetcher = profile.Fetcher
+
+// ProfileVerifier verifies a detached signature over the raw bytes of a
+// profile. See profile.Verifier.
+type ProfileVerifier = profile.Verifier
+
+// Ed25519Verifier verifies raw Ed25519 signatures against a set of trusted
+// public keys. See profile.Ed25519Verifier.
+type Ed25519Verifier = profile.Ed25519Verifier
+
+// ECDSAVerifier verifies ASN.1-encoded ECDSA signatures against a set of
+// trusted public keys. See profile.ECDSAVerifier.
+type ECDSAVerifier = profile.ECDSAVerifier
+
+// ProfileStats describes a profile held by a Registry and its usage.
+// See profile.Stats.
+type ProfileStats = profile.Stats
+
+// Registry manages a set of named OCI seccomp profiles and compiles them
+// into filters. See profile.Registry for the management of the profiles.
+type Registry struct {
+	*profile.Registry
+}
+
+// DefaultProfileOptions selects the capability, architecture and kernel
+// dependent rules of the default container profile. See
+// profile.DefaultOptions.
+type DefaultProfileOptions = profile.DefaultOptions
+
+// NewProfileFetcher creates a ProfileFetcher caching profiles in cacheDir.
+func NewProfileFetcher(cacheDir string) *ProfileFetcher {
+	return profile.NewFetcher(cacheDir)
+}
+
+// NewVerifierFromPEM creates a ProfileVerifier trusting the PEM-encoded PKIX
+// public keys in data. See profile.NewVerifierFromPEM.
+func NewVerifierFromPEM(data []byte) (ProfileVerifier, error) {
+	return profile.NewVerifierFromPEM(data)
+}
+
+// VerifyProfile verifies signature over the raw OCI seccomp profile data
+// using verifier, and parses the profile only once it has been verified.
+// Returns the parsed profile, or an error if verification or parsing failed.
+func VerifyProfile(data, signature []byte, verifier ProfileVerifier) (*OCISeccomp, error) {
+	return profile.Verify(data, signature, verifier)
+}
+
+// NewFilterFromSignedProfile verifies signature over the raw OCI seccomp
+// profile data using verifier, then compiles the profile into a filter.
+// Returns the filter, or an error if verification or compilation failed. The
+// filter is not loaded.
+func NewFilterFromSignedProfile(data, signature []byte, verifier ProfileVerifier) (*ScmpFilter, error) {
+	p, err := profile.Verify(data, signature, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildOCIFilter(p)
+}
+
+// NewRegistry creates an empty profile registry.
+func NewRegistry() *Registry {
+	return &Registry{profile.NewRegistry()}
+}
+
+// NewFilter compiles the profile registered under name into a new filter.
+// Returns an error if no such profile exists or it could not be compiled.
+func (r *Registry) NewFilter(name string) (*ScmpFilter, error) {
+	p, ok := r.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("no profile registered as %q", name)
+	}
+
+	return buildOCIFilter(p)
+}
+
+// DefaultProfile returns the default seccomp profile of Docker and
+// containerd, resolved for a container with the capabilities and
+// architecture in opts. If opts does not set a kernel version, the profile
+// is resolved for the running kernel.
+// Returns an error if the kernel version is invalid.
+func DefaultProfile(opts DefaultProfileOptions) (*OCISeccomp, error) {
+	if opts.KernelVersion == "" {
+		var uts unix.Utsname
+		if err := unix.Uname(&uts); err != nil {
+			return nil, err
+		}
+		opts.KernelVersion = string(bytes.TrimRight(uts.Release[:], "\x00"))
+	}
+
+	return profile.Default(opts)
+}
+
+// DefaultProfileFilter compiles the default seccomp profile of Docker and
+// containerd, resolved as by DefaultProfile, into a new filter, so that
+// programs can confine themselves as a default container would be.
+// Syscalls the profile does not allow fail with EPERM.
+// Returns the filter, which is not loaded, or an error if the profile could
+// not be compiled.
+func DefaultProfileFilter(opts DefaultProfileOptions) (*ScmpFilter, error) {
+	p, err := DefaultProfile(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildOCIFilter(p)
+}
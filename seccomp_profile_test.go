@@ -0,0 +1,109 @@
+// +build linux
+
+// Tests for profile compilation of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"runtime"
+	"testing"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestRegistryNewFilter(t *testing.T) {
+	reg := NewRegistry()
+
+	if _, err := reg.NewFilter("missing"); err == nil {
+		t.Errorf("Compiling an unregistered profile should fail")
+	}
+
+	profile := &OCISeccomp{
+		DefaultAction: "SCMP_ACT_ERRNO",
+		Syscalls:      []OCISyscall{{Names: []string{"read"}, Action: "SCMP_ACT_ALLOW"}},
+	}
+	if err := reg.Register("default", profile); err != nil {
+		t.Fatalf("Error registering profile: %s", err)
+	}
+
+	filter, err := reg.NewFilter("default")
+	if err != nil {
+		t.Fatalf("Error compiling registered profile: %s", err)
+	}
+	filter.Release()
+
+	if stats := reg.Stats(); len(stats) != 1 || stats[0].Lookups != 1 {
+		t.Errorf("Compiling a profile should count as a lookup: %+v", stats)
+	}
+}
+
+func TestNewFilterFromSignedProfile(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+
+	data := []byte(`{"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"names": ["ptrace"], "action": "SCMP_ACT_ERRNO"}]}`)
+	verifier := &Ed25519Verifier{Keys: []ed25519.PublicKey{pub}}
+
+	filter, err := NewFilterFromSignedProfile(data, ed25519.Sign(priv, data), verifier)
+	if err != nil {
+		t.Fatalf("Error building filter from signed profile: %s", err)
+	}
+	filter.Release()
+
+	if _, err := NewFilterFromSignedProfile(data, make([]byte, ed25519.SignatureSize), verifier); err != ErrProfileSignatureInvalid {
+		t.Errorf("Invalid signature should fail verification, got %v", err)
+	}
+}
+
+func TestDefaultProfileFilter(t *testing.T) {
+	auditArch := map[string]uint32{
+		"amd64": 0xc000003e, // AUDIT_ARCH_X86_64
+		"arm64": 0xc00000b7, // AUDIT_ARCH_AARCH64
+	}[runtime.GOARCH]
+	if auditArch == 0 {
+		t.Skipf("Skipping test: no audit architecture known for %s", runtime.GOARCH)
+	}
+
+	eperm := bpf.RetErrno | uint32(unix.EPERM)
+	for _, test := range []struct {
+		caps []string
+		nr   int32
+		arg  uint64
+		want uint32
+	}{
+		{nil, unix.SYS_GETPID, 0, bpf.RetAllow},
+		{nil, unix.SYS_CHROOT, 0, bpf.RetAllow},
+		{nil, unix.SYS_MOUNT, 0, eperm},
+		{nil, unix.SYS_PERSONALITY, 0x8, bpf.RetAllow},
+		{nil, unix.SYS_PERSONALITY, 0x1, eperm},
+		{nil, unix.SYS_SOCKET, unix.AF_INET, bpf.RetAllow},
+		{nil, unix.SYS_SOCKET, unix.AF_VSOCK, eperm},
+		{nil, unix.SYS_CLONE, uint64(unix.SIGCHLD), bpf.RetAllow},
+		{nil, unix.SYS_CLONE, unix.CLONE_NEWUSER | uint64(unix.SIGCHLD), eperm},
+		{nil, unix.SYS_CLONE3, 0, bpf.RetErrno | uint32(unix.ENOSYS)},
+		{[]string{"SYS_ADMIN"}, unix.SYS_MOUNT, 0, bpf.RetAllow},
+		{[]string{"SYS_ADMIN"}, unix.SYS_CLONE, unix.CLONE_NEWUSER, bpf.RetAllow},
+		{[]string{"SYS_ADMIN"}, unix.SYS_CHROOT, 0, eperm},
+	} {
+		filter, err := DefaultProfileFilter(DefaultProfileOptions{Capabilities: test.caps})
+		if err != nil {
+			t.Fatalf("Error compiling default profile: %s", err)
+		}
+		prog := exportProgram(t, filter)
+		filter.Release()
+
+		ret, err := bpf.Run(prog, &bpf.Data{Nr: test.nr, Arch: auditArch, Args: [6]uint64{test.arg}})
+		if err != nil {
+			t.Fatalf("Error running exported filter: %s", err)
+		}
+		if ret != test.want {
+			t.Errorf("Syscall %d(%#x) with %v: got %s, want %s", test.nr, test.arg, test.caps,
+				bpf.ActionString(ret), bpf.ActionString(test.want))
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// +build linux
+
+// Pseudo-syscalls for libseccomp Go bindings
+// Names the syscalls multiplexed through socketcall and ipc on some architectures
+
+package seccomp
+
+import (
+	"fmt"
+)
+
+// Pseudo-syscall numbers libseccomp uses for the socket and IPC syscalls of
+// architectures which multiplex them through socketcall and ipc, such as
+// ArchX86, ArchS390 and ArchPPC. Rules on these numbers match both the
+// multiplexed calls, by their call argument, and the direct syscalls of
+// kernels having them. Syscall lookups on these architectures return them,
+// e.g. GetSyscallFromNameByArch("socket", ArchX86) returns PseudoSocket.
+// As libseccomp translates rules between architectures from the native
+// architecture, filters can only be given rules on pseudo-syscalls when the
+// native architecture multiplexes the syscall; on other kernels, rules on
+// the native syscall are translated to the pseudo-syscalls of the
+// multiplexing architectures of the filter.
+const (
+	// PseudoSocket is the pseudo-syscall of socket
+	PseudoSocket ScmpSyscall = -101
+	// PseudoBind is the pseudo-syscall of bind
+	PseudoBind ScmpSyscall = -102
+	// PseudoConnect is the pseudo-syscall of connect
+	PseudoConnect ScmpSyscall = -103
+	// PseudoListen is the pseudo-syscall of listen
+	PseudoListen ScmpSyscall = -104
+	// PseudoAccept is the pseudo-syscall of accept
+	PseudoAccept ScmpSyscall = -105
+	// PseudoGetsockname is the pseudo-syscall of getsockname
+	PseudoGetsockname ScmpSyscall = -106
+	// PseudoGetpeername is the pseudo-syscall of getpeername
+	PseudoGetpeername ScmpSyscall = -107
+	// PseudoSocketpair is the pseudo-syscall of socketpair
+	PseudoSocketpair ScmpSyscall = -108
+	// PseudoSend is the pseudo-syscall of send
+	PseudoSend ScmpSyscall = -109
+	// PseudoRecv is the pseudo-syscall of recv
+	PseudoRecv ScmpSyscall = -110
+	// PseudoSendto is the pseudo-syscall of sendto
+	PseudoSendto ScmpSyscall = -111
+	// PseudoRecvfrom is the pseudo-syscall of recvfrom
+	PseudoRecvfrom ScmpSyscall = -112
+	// PseudoShutdown is the pseudo-syscall of shutdown
+	PseudoShutdown ScmpSyscall = -113
+	// PseudoSetsockopt is the pseudo-syscall of setsockopt
+	PseudoSetsockopt ScmpSyscall = -114
+	// PseudoGetsockopt is the pseudo-syscall of getsockopt
+	PseudoGetsockopt ScmpSyscall = -115
+	// PseudoSendmsg is the pseudo-syscall of sendmsg
+	PseudoSendmsg ScmpSyscall = -116
+	// PseudoRecvmsg is the pseudo-syscall of recvmsg
+	PseudoRecvmsg ScmpSyscall = -117
+	// PseudoAccept4 is the pseudo-syscall of accept4
+	PseudoAccept4 ScmpSyscall = -118
+	// PseudoRecvmmsg is the pseudo-syscall of recvmmsg
+	PseudoRecvmmsg ScmpSyscall = -119
+	// PseudoSendmmsg is the pseudo-syscall of sendmmsg
+	PseudoSendmmsg ScmpSyscall = -120
+
+	// PseudoSemop is the pseudo-syscall of semop
+	PseudoSemop ScmpSyscall = -201
+	// PseudoSemget is the pseudo-syscall of semget
+	PseudoSemget ScmpSyscall = -202
+	// PseudoSemctl is the pseudo-syscall of semctl
+	PseudoSemctl ScmpSyscall = -203
+	// PseudoSemtimedop is the pseudo-syscall of semtimedop
+	PseudoSemtimedop ScmpSyscall = -204
+	// PseudoMsgsnd is the pseudo-syscall of msgsnd
+	PseudoMsgsnd ScmpSyscall = -211
+	// PseudoMsgrcv is the pseudo-syscall of msgrcv
+	PseudoMsgrcv ScmpSyscall = -212
+	// PseudoMsgget is the pseudo-syscall of msgget
+	PseudoMsgget ScmpSyscall = -213
+	// PseudoMsgctl is the pseudo-syscall of msgctl
+	PseudoMsgctl ScmpSyscall = -214
+	// PseudoShmat is the pseudo-syscall of shmat
+	PseudoShmat ScmpSyscall = -221
+	// PseudoShmdt is the pseudo-syscall of shmdt
+	PseudoShmdt ScmpSyscall = -222
+	// PseudoShmget is the pseudo-syscall of shmget
+	PseudoShmget ScmpSyscall = -223
+	// PseudoShmctl is the pseudo-syscall of shmctl
+	PseudoShmctl ScmpSyscall = -224
+)
+
+// Names of the multiplexed syscalls, by pseudo-syscall number
+var pseudoSyscalls = map[ScmpSyscall]string{
+	PseudoSocket:      "socket",
+	PseudoBind:        "bind",
+	PseudoConnect:     "connect",
+	PseudoListen:      "listen",
+	PseudoAccept:      "accept",
+	PseudoGetsockname: "getsockname",
+	PseudoGetpeername: "getpeername",
+	PseudoSocketpair:  "socketpair",
+	PseudoSend:        "send",
+	PseudoRecv:        "recv",
+	PseudoSendto:      "sendto",
+	PseudoRecvfrom:    "recvfrom",
+	PseudoShutdown:    "shutdown",
+	PseudoSetsockopt:  "setsockopt",
+	PseudoGetsockopt:  "getsockopt",
+	PseudoSendmsg:     "sendmsg",
+	PseudoRecvmsg:     "recvmsg",
+	PseudoAccept4:     "accept4",
+	PseudoRecvmmsg:    "recvmmsg",
+	PseudoSendmmsg:    "sendmmsg",
+	PseudoSemop:       "semop",
+	PseudoSemget:      "semget",
+	PseudoSemctl:      "semctl",
+	PseudoSemtimedop:  "semtimedop",
+	PseudoMsgsnd:      "msgsnd",
+	PseudoMsgrcv:      "msgrcv",
+	PseudoMsgget:      "msgget",
+	PseudoMsgctl:      "msgctl",
+	PseudoShmat:       "shmat",
+	PseudoShmdt:       "shmdt",
+	PseudoShmget:      "shmget",
+	PseudoShmctl:      "shmctl",
+}
+
+// libseccomp numbers the syscalls missing on an architecture from here down
+const pseudoMissingBase ScmpSyscall = -10000
+
+// GetPseudoSyscallFromName returns the pseudo-syscall number of a socket or
+// IPC syscall by name, which is the same on every architecture.
+// Returns an error if the syscall is not multiplexed by socketcall or ipc.
+func GetPseudoSyscallFromName(name string) (ScmpSyscall, error) {
+	for call, callName := range pseudoSyscalls {
+		if callName == name {
+			return call, nil
+		}
+	}
+
+	return 0, fmt.Errorf("syscall %q has no pseudo-syscall number", name)
+}
+
+// IsPseudo checks whether the syscall number is a pseudo-syscall number of
+// libseccomp: either the number of a syscall multiplexed by socketcall or
+// ipc, or the number libseccomp resolves a syscall missing on an
+// architecture to, e.g. arch_prctl on ArchARM.
+func (s ScmpSyscall) IsPseudo() bool {
+	_, ok := pseudoSyscalls[s]
+	return ok || s < pseudoMissingBase
+}
+
+// Multiplexer returns the name of the syscall multiplexing a pseudo-syscall
+// on the architectures which have one, "socketcall" or "ipc".
+// Returns an error if the syscall is not a socket or IPC pseudo-syscall.
+func (s ScmpSyscall) Multiplexer() (string, error) {
+	if _, ok := pseudoSyscalls[s]; !ok {
+		return "", fmt.Errorf("syscall %d is not multiplexed", int32(s))
+	} else if s <= PseudoSemop {
+		return "ipc", nil
+	}
+
+	return "socketcall", nil
+}
+
+// Helper - Check that a syscall number of a rule is either a real syscall
+// number, a pseudo-syscall libseccomp can translate from the native
+// architecture, or -1 when rules on it are allowed
+func checkRuleSyscall(call ScmpSyscall, tskip bool) error {
+	if call >= 0 || (call == -1 && tskip) {
+		return nil
+	} else if call == -1 {
+		return fmt.Errorf("rules on syscall -1 must be allowed with SetTSkip")
+	} else if !call.IsPseudo() {
+		return fmt.Errorf("invalid syscall number %d", int32(call))
+	}
+
+	if _, err := call.GetNameByArch(ArchNative); err != nil {
+		if name, ok := pseudoSyscalls[call]; ok {
+			return fmt.Errorf("pseudo-syscall %d of %s is not used by the native architecture, use the native number of %s instead",
+				int32(call), name, name)
+		}
+		return fmt.Errorf("pseudo-syscall %d is not used by the native architecture", int32(call))
+	}
+
+	return nil
+}
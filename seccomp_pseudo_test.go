@@ -0,0 +1,90 @@
+// +build linux
+
+// Tests for the pseudo-syscalls of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestPseudoSyscalls(t *testing.T) {
+	// The pseudo-syscalls are those libseccomp resolves on ArchX86
+	for call, name := range pseudoSyscalls {
+		if got, err := GetSyscallFromNameByArch(name, ArchX86); err != nil || got != call {
+			t.Errorf("Got pseudo-syscall %d, %v for %s on x86, want %d", got, err, name, call)
+		}
+
+		if got, err := GetPseudoSyscallFromName(name); err != nil || got != call {
+			t.Errorf("Got pseudo-syscall %d, %v for %s", got, err, name)
+		}
+		if !call.IsPseudo() {
+			t.Errorf("Syscall %d should be a pseudo-syscall", call)
+		}
+	}
+
+	if _, err := GetPseudoSyscallFromName("read"); err == nil {
+		t.Errorf("read should have no pseudo-syscall number")
+	}
+
+	for _, test := range []struct {
+		call   ScmpSyscall
+		pseudo bool
+		mux    string
+	}{
+		{PseudoSocket, true, "socketcall"},
+		{PseudoSendmmsg, true, "socketcall"},
+		{PseudoSemop, true, "ipc"},
+		{PseudoShmctl, true, "ipc"},
+		{-10001, true, ""},
+		{-150, false, ""},
+		{-5, false, ""},
+		{1, false, ""},
+	} {
+		if got := test.call.IsPseudo(); got != test.pseudo {
+			t.Errorf("Syscall %d: got pseudo %t, want %t", test.call, got, test.pseudo)
+		}
+		mux, err := test.call.Multiplexer()
+		if test.mux == "" && err == nil {
+			t.Errorf("Syscall %d should not be multiplexed", test.call)
+		} else if mux != test.mux {
+			t.Errorf("Syscall %d: got multiplexer %q, want %q", test.call, mux, test.mux)
+		}
+	}
+}
+
+func TestPseudoSyscallRules(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: native architecture is not amd64")
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.AddArch(ArchX86); err != nil {
+		t.Fatalf("Error adding architecture: %s", err)
+	}
+
+	if err := filter.AddRule(-5, ActKillThread); err == nil {
+		t.Errorf("Adding a rule on an invalid syscall number should fail")
+	}
+	// amd64 does not multiplex socket syscalls
+	if err := filter.AddRule(PseudoSocket, ActKillThread); err == nil {
+		t.Errorf("Adding a rule on a pseudo-syscall unused natively should fail")
+	}
+
+	// The native number is translated to the pseudo-syscall on x86
+	call, err := GetSyscallFromName("socket")
+	if err != nil {
+		t.Fatalf("Error getting syscall number of socket: %s", err)
+	}
+	if err := filter.AddRule(call, ActKillThread); err != nil {
+		t.Errorf("Error adding rule on socket: %s", err)
+	}
+	if rules := filter.ListRules(); len(rules) != 1 {
+		t.Errorf("Got %d rules, want 1", len(rules))
+	}
+}
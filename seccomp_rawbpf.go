@@ -0,0 +1,45 @@
+// +build linux
+
+// Raw BPF loader for libseccomp Go bindings
+// Installs pre-compiled seccomp programs without building a filter
+
+package seccomp
+
+import (
+	"fmt"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// LoadRawBPF installs the pre-compiled seccomp program prog, as written by
+// ExportBPF, directly with seccomp(2), or prctl(2) on kernels without it,
+// bypassing libseccomp. This lets programs compiled once be deployed as
+// bytes. The no new privileges bit is set first, as for filters with the
+// default attributes.
+// Like LoadWithFlags, LoadRawBPF only applies the program to the calling
+// thread, unless LoadFlagTsync is set. LoadFlagNewListener is rejected, as
+// the notification fd could not be returned; see bpf.Load, which also works
+// without libseccomp.
+// Returns an error if prog is not a valid program, or could not be
+// installed.
+func LoadRawBPF(prog []byte, flags LoadFlag) error {
+	if flags&LoadFlagNewListener != 0 {
+		return fmt.Errorf("raw programs cannot be loaded with a notification fd")
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("could not set no new privileges bit: %v", err)
+	}
+
+	ret, err := bpf.Load(prog, uint(flags))
+	if flags&LoadFlagTsync != 0 && err == unix.ESRCH {
+		return &TsyncError{}
+	} else if err != nil {
+		return err
+	} else if flags&LoadFlagTsync != 0 && flags&LoadFlagTsyncESRCH == 0 && ret != 0 {
+		return &TsyncError{ThreadID: ret}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+// +build linux
+
+// Tests for the raw BPF loader of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLoadRawBPF(t *testing.T) {
+	execInSubprocess(t, subprocessLoadRawBPF)
+}
+func subprocessLoadRawBPF(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActErrno.SetErrno(unix.EACCES)); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	var prog bytes.Buffer
+	if err := filter.ExportBPF(&prog); err != nil {
+		t.Fatalf("Error exporting filter: %s", err)
+	}
+
+	for _, bad := range [][]byte{nil, prog.Bytes()[:prog.Len()-1], make([]byte, 8)} {
+		if err := LoadRawBPF(bad, 0); err == nil {
+			t.Fatalf("Loading invalid program %x should fail", bad)
+		}
+	}
+	if err := LoadRawBPF(prog.Bytes(), LoadFlagNewListener); err == nil {
+		t.Errorf("Loading a raw program with a listener should fail")
+	}
+
+	if err := LoadRawBPF(prog.Bytes(), LoadFlagTsync); err != nil {
+		t.Fatalf("Error loading program: %s", err)
+	}
+
+	if _, _, errno := unix.RawSyscall(unix.SYS_GETPPID, 0, 0, 0); errno != unix.EACCES {
+		t.Errorf("Syscall returned %v, expected %v", errno, unix.EACCES)
+	}
+}
@@ -0,0 +1,207 @@
+// +build linux
+
+// Filter reports for libseccomp Go bindings
+// Summarizes the policy of filters for operators
+
+package seccomp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+)
+
+// ScmpFilterReport summarizes the policy of a filter, as returned by Report.
+//
+// DefaultAction: action taken on syscalls which match no rule
+// BadArchAction: action taken on syscalls of architectures not in Arches
+// Arches:        architectures of the filter
+// Attrs:         raw values of the other filter attributes, see GetAttr
+// Groups:        rules grouped by action, in order of decreasing precedence
+//
+type ScmpFilterReport struct {
+	DefaultAction ScmpAction
+	BadArchAction ScmpAction
+	Arches        []ScmpArch
+	Attrs         map[ScmpFilterAttr]uint
+	Groups        []ScmpReportGroup
+}
+
+// ScmpReportGroup holds the rules of a filter taking the same action.
+//
+// Action: action taken by the rules
+// Rules:  rules taking the action, sorted by syscall name
+//
+type ScmpReportGroup struct {
+	Action ScmpAction
+	Rules  []ScmpReportRule
+}
+
+// ScmpReportRule is a rule of a filter, as summarized in a report.
+//
+// Syscall:    name of the syscall, or its number if it has no name
+// Arch:       architecture the rule is restricted to, ArchInvalid for all
+// Conditions: conditions which must all match for the rule to match
+// Comment:    label of the rule, see AddRuleWithComment
+//
+type ScmpReportRule struct {
+	Syscall    string
+	Arch       ScmpArch
+	Conditions []ScmpCondition
+	Comment    string
+}
+
+// Report summarizes the policy of the filter from the rules tracked by the
+// bindings, e.g. to log the policy in effect when a container starts.
+// Returns an error if the filter is invalid or its state could not be read.
+func (f *ScmpFilter) Report() (*ScmpFilterReport, error) {
+	state, err := f.MarshalState()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ScmpFilterReport{
+		DefaultAction: state.DefaultAction,
+		Arches:        state.Arches,
+		Attrs:         state.Attrs,
+	}
+	if value, ok := r.Attrs[AttrActBadArch]; ok {
+		if r.BadArchAction, err = actionFromRet(uint32(value)); err != nil {
+			return nil, err
+		}
+		delete(r.Attrs, AttrActBadArch)
+	}
+
+	groups := make(map[ScmpAction]int)
+	for _, rule := range state.Rules {
+		arch := rule.Arch
+		if arch == ArchInvalid {
+			arch = ArchNative
+		}
+		name, err := rule.Syscall.GetNameByArch(arch)
+		if err != nil {
+			name = fmt.Sprintf("%d", int32(rule.Syscall))
+		}
+
+		i, ok := groups[rule.Action]
+		if !ok {
+			i = len(r.Groups)
+			groups[rule.Action] = i
+			r.Groups = append(r.Groups, ScmpReportGroup{Action: rule.Action})
+		}
+		r.Groups[i].Rules = append(r.Groups[i].Rules, ScmpReportRule{
+			Syscall:    name,
+			Arch:       rule.Arch,
+			Conditions: rule.Conditions,
+			Comment:    rule.Comment,
+		})
+	}
+
+	// The kernel gives precedence to the lowest signed return value
+	sort.SliceStable(r.Groups, func(i, j int) bool {
+		return int32(actionToRet(r.Groups[i].Action)) < int32(actionToRet(r.Groups[j].Action))
+	})
+	for _, group := range r.Groups {
+		rules := group.Rules
+		sort.SliceStable(rules, func(i, j int) bool { return rules[i].Syscall < rules[j].Syscall })
+	}
+
+	return r, nil
+}
+
+// Describe returns a human-readable summary of the filter's policy: its
+// actions, architectures, attributes and rules grouped by action. See
+// Report.
+func (f *ScmpFilter) Describe() string {
+	r, err := f.Report()
+	if err != nil {
+		return fmt.Sprintf("could not describe filter: %v", err)
+	}
+
+	return r.String()
+}
+
+// String formats the report over several lines, e.g.
+//
+//	default action: ERRNO(1)
+//	bad architecture action: KILL
+//	architectures: amd64, x86
+//	attributes: No New Privileges bit=1
+//	ALLOW: personality(a0 == 0x8), read, write
+//	ERRNO(13): ptrace
+func (r *ScmpFilterReport) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "default action: %s\n", bpf.ActionString(actionToRet(r.DefaultAction)))
+	fmt.Fprintf(&b, "bad architecture action: %s\n", bpf.ActionString(actionToRet(r.BadArchAction)))
+
+	arches := make([]string, len(r.Arches))
+	for i, arch := range r.Arches {
+		arches[i] = arch.String()
+	}
+	fmt.Fprintf(&b, "architectures: %s\n", strings.Join(arches, ", "))
+
+	var attrs []string
+	for _, attr := range stateAttrs {
+		if value := r.Attrs[attr]; value != 0 {
+			attrs = append(attrs, fmt.Sprintf("%s=%d", attr, value))
+		}
+	}
+	if len(attrs) > 0 {
+		fmt.Fprintf(&b, "attributes: %s\n", strings.Join(attrs, ", "))
+	}
+
+	for _, group := range r.Groups {
+		rules := make([]string, len(group.Rules))
+		for i, rule := range group.Rules {
+			rules[i] = rule.String()
+		}
+		fmt.Fprintf(&b, "%s: %s\n", bpf.ActionString(actionToRet(group.Action)), strings.Join(rules, ", "))
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// String formats the rule as its syscall, the architecture it is restricted
+// to, and its conditions, e.g. "socket[x86](a0 != 0x28)".
+func (r ScmpReportRule) String() string {
+	s := r.Syscall
+	if r.Arch != ArchInvalid {
+		s += "[" + r.Arch.String() + "]"
+	}
+	if len(r.Conditions) == 0 {
+		return s
+	}
+
+	conds := make([]string, len(r.Conditions))
+	for i, cond := range r.Conditions {
+		conds[i] = conditionString(cond)
+	}
+
+	return s + "(" + strings.Join(conds, ", ") + ")"
+}
+
+// Helper - Format a condition as a comparison, e.g. "a0 != 0x28"
+func conditionString(cond ScmpCondition) string {
+	arg := fmt.Sprintf("a%d", cond.Argument)
+	switch cond.Op {
+	case CompareNotEqual:
+		return fmt.Sprintf("%s != %#x", arg, cond.Operand1)
+	case CompareLess:
+		return fmt.Sprintf("%s < %#x", arg, cond.Operand1)
+	case CompareLessOrEqual:
+		return fmt.Sprintf("%s <= %#x", arg, cond.Operand1)
+	case CompareEqual:
+		return fmt.Sprintf("%s == %#x", arg, cond.Operand1)
+	case CompareGreaterEqual:
+		return fmt.Sprintf("%s >= %#x", arg, cond.Operand1)
+	case CompareGreater:
+		return fmt.Sprintf("%s > %#x", arg, cond.Operand1)
+	case CompareMaskedEqual:
+		return fmt.Sprintf("%s & %#x == %#x", arg, cond.Operand1, cond.Operand2)
+	default:
+		return fmt.Sprintf("%s %s %#x", arg, cond.Op, cond.Operand1)
+	}
+}
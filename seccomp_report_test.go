@@ -0,0 +1,78 @@
+// +build linux
+
+// Tests for the filter reports of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestDescribe(t *testing.T) {
+	personality, err := MakeCondition(0, CompareEqual, 8)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+	masked, err := MakeCondition(1, CompareMaskedEqual, 0xff, 3)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+
+	filter, err := Profile().
+		Allow("write", "read").
+		OnIf(ActAllow, "personality", personality).
+		Errno(unix.EACCES, "ptrace").
+		OnIf(ActLog, "ioctl", masked).
+		Kill("kexec_load").
+		Build()
+	if err != nil {
+		t.Fatalf("Error building profile: %s", err)
+	}
+	defer filter.Release()
+
+	r, err := filter.Report()
+	if err != nil {
+		t.Fatalf("Error reporting filter: %s", err)
+	}
+	if r.DefaultAction != ActEPerm || r.BadArchAction != ActKill {
+		t.Errorf("Got actions %s and %s", r.DefaultAction, r.BadArchAction)
+	}
+	if len(r.Groups) != 4 || r.Groups[0].Action != ActKillProcess || r.Groups[3].Action != ActAllow {
+		t.Fatalf("Rules should be grouped by action, by precedence: %+v", r.Groups)
+	}
+
+	arch, err := GetNativeArch()
+	if err != nil {
+		t.Fatalf("Error getting native architecture: %s", err)
+	}
+	want := []string{
+		"default action: ERRNO(1)",
+		"bad architecture action: KILL",
+		"architectures: " + arch.String(),
+		"attributes: ",
+		"KILL_PROCESS: kexec_load",
+		"ERRNO(13): ptrace",
+		"LOG: ioctl(a1 & 0xff == 0x3)",
+		"ALLOW: personality(a0 == 0x8), read, write",
+	}
+	lines := strings.Split(filter.Describe(), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("Got description:\n%s", filter.Describe())
+	}
+	for i := range want {
+		if !strings.HasPrefix(lines[i], want[i]) {
+			t.Errorf("Got line %q, want %q", lines[i], want[i])
+		}
+	}
+	if !strings.Contains(lines[3], AttrNNP.String()+"=1") {
+		t.Errorf("Attributes should include the no new privileges bit: %q", lines[3])
+	}
+
+	filter.Release()
+	if desc := filter.Describe(); !strings.Contains(desc, ErrInvalidFilter.Error()) {
+		t.Errorf("Describing a released filter should fail: %q", desc)
+	}
+}
@@ -0,0 +1,232 @@
+// +build linux
+
+// Tracked rule model for libseccomp Go bindings
+// Records the rules of filters so they can be rebuilt from scratch
+
+package seccomp
+
+import (
+	"fmt"
+)
+
+var (
+	// ErrRuleNotFound is returned when removing a rule the filter does not
+	// contain
+	ErrRuleNotFound = fmt.Errorf("rule not found")
+)
+
+// ScmpRule is a rule of a filter, as tracked by the bindings.
+//
+// Arch:       architecture the rule is restricted to, ArchInvalid for all
+// Syscall:    syscall number on Arch if set, or as passed to AddRule otherwise
+// Action:     action taken when the rule matches
+// Conditions: conditions which must all match for the rule to match
+// Exact:      whether the rule must be added without modification
+// Comment:    label of the rule, see AddRuleWithComment
+//
+type ScmpRule struct {
+	Arch       ScmpArch        `json:"arch,omitempty"`
+	Syscall    ScmpSyscall     `json:"syscall"`
+	Action     ScmpAction      `json:"action"`
+	Conditions []ScmpCondition `json:"conditions,omitempty"`
+	Exact      bool            `json:"exact,omitempty"`
+	Comment    string          `json:"comment,omitempty"`
+}
+
+// AddRuleForArch adds a single rule for a conditional action on a syscall,
+// which only applies to architecture arch. This lets a filter with several
+// architectures carry differences between them, such as syscalls only found
+// on one of them, different syscall numbers or different conditions.
+// Accepts the number of the syscall on arch, which must be present in the
+// filter.
+// As libseccomp applies rules to all the architectures of a filter, the
+// filter is rebuilt from its tracked rules, with the rules added for all
+// architectures applied to all the architectures present, including those
+// added after the rules.
+// Returns an error if an issue was encountered adding the rule.
+func (f *ScmpFilter) AddRuleForArch(arch ScmpArch, call ScmpSyscall, action ScmpAction, conds []ScmpCondition) error {
+	if err := sanitizeArch(arch); err != nil {
+		return err
+	}
+	arch, err := resolveNativeArch(arch)
+	if err != nil {
+		return err
+	}
+
+	return f.addArchRules([]ScmpRule{{
+		Arch:       arch,
+		Syscall:    call,
+		Action:     action,
+		Conditions: append([]ScmpCondition(nil), conds...),
+	}})
+}
+
+// Helper - Add rules restricted to architectures, with a single rebuild of
+// the filter
+func (f *ScmpFilter) addArchRules(rules []ScmpRule) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return ErrInvalidFilter
+	}
+
+	for _, rule := range rules {
+		if !f.archPresent(rule.Arch) {
+			return fmt.Errorf("architecture %s is not present in the filter", rule.Arch)
+		}
+
+		if feature, level := actionAPILevel(rule.Action); level != 0 {
+			if err := f.useAPI(feature, level); err != nil {
+				return err
+			}
+		}
+	}
+
+	n := len(f.rules)
+	f.rules = append(f.rules, rules...)
+
+	if err := f.rebuild(f.filterArches()); err != nil {
+		f.rules = f.rules[:n]
+		return err
+	}
+
+	return nil
+}
+
+// ListRules returns the rules of the filter, in the order they were added,
+// e.g. to audit a generated filter. Rules merged from other filters are
+// restricted to the architectures of their original filter.
+// Returns nil if the filter is invalid or has no rules.
+func (f *ScmpFilter) ListRules() []ScmpRule {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid || len(f.rules) == 0 {
+		return nil
+	}
+
+	rules := make([]ScmpRule, len(f.rules))
+	for i, rule := range f.rules {
+		rules[i] = rule
+		rules[i].Conditions = append([]ScmpCondition(nil), rule.Conditions...)
+	}
+
+	return rules
+}
+
+// RemoveRule removes the unconditional rules on a syscall added with AddRule
+// or AddRuleExact. Rules added with AddRuleForArch are not affected.
+// As libseccomp cannot remove rules, the filter is rebuilt from its tracked
+// rules, see AddRuleForArch.
+// Returns ErrRuleNotFound if the filter has no such rule, or an error if the
+// filter could not be rebuilt.
+func (f *ScmpFilter) RemoveRule(call ScmpSyscall) error {
+	return f.removeRules(func(rule ScmpRule) bool {
+		return rule.Syscall == call && len(rule.Conditions) == 0
+	})
+}
+
+// RemoveRuleConditional removes the rules on a syscall added with
+// AddRuleConditional or AddRuleConditionalExact with the same conditions, in
+// any order. Rules added with AddRuleForArch are not affected.
+// As libseccomp cannot remove rules, the filter is rebuilt from its tracked
+// rules, see AddRuleForArch.
+// Returns ErrRuleNotFound if the filter has no such rule, or an error if the
+// filter could not be rebuilt.
+func (f *ScmpFilter) RemoveRuleConditional(call ScmpSyscall, conds []ScmpCondition) error {
+	return f.removeRules(func(rule ScmpRule) bool {
+		return rule.Syscall == call && sameConditions(rule.Conditions, conds)
+	})
+}
+
+// Helper - Remove the rules added to all the architectures of the filter
+// which match, and rebuild the filter
+func (f *ScmpFilter) removeRules(match func(rule ScmpRule) bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return ErrInvalidFilter
+	}
+
+	var rules []ScmpRule
+	for _, rule := range f.rules {
+		if rule.Arch == ArchInvalid && match(rule) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == len(f.rules) {
+		return ErrRuleNotFound
+	}
+
+	old := f.rules
+	f.rules = rules
+	if err := f.rebuild(f.filterArches()); err != nil {
+		f.rules = old
+		return err
+	}
+
+	return nil
+}
+
+// Helper - Check whether two lists of conditions hold the same conditions,
+// regardless of their order
+func sameConditions(a, b []ScmpCondition) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	count := make(map[ScmpCondition]int)
+	for _, cond := range a {
+		count[cond]++
+	}
+	for _, cond := range b {
+		if count[cond] == 0 {
+			return false
+		}
+		count[cond]--
+	}
+
+	return true
+}
+
+// Helper - Record a rule added to all the architectures of the filter
+// Requires the filter lock
+func (f *ScmpFilter) trackRule(call ScmpSyscall, action ScmpAction, exact bool, conds []ScmpCondition) {
+	f.rules = append(f.rules, ScmpRule{
+		Syscall:    call,
+		Action:     action,
+		Conditions: append([]ScmpCondition(nil), conds...),
+		Exact:      exact,
+	})
+}
+
+// Helper - Restrict the rules of a filter to the given architectures, so
+// they keep applying to the same architectures once merged into another
+// filter
+func restrictRules(rules []ScmpRule, arches []ScmpArch) []ScmpRule {
+	var restricted []ScmpRule
+
+	for _, rule := range rules {
+		if rule.Arch != ArchInvalid {
+			restricted = append(restricted, rule)
+			continue
+		}
+
+		for _, arch := range arches {
+			call, err := TranslateSyscall(rule.Syscall, ArchNative, arch)
+			if err != nil {
+				// The syscall does not exist on the architecture
+				continue
+			}
+			r := rule
+			r.Arch = arch
+			r.Syscall = call
+			restricted = append(restricted, r)
+		}
+	}
+
+	return restricted
+}
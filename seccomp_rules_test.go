@@ -0,0 +1,268 @@
+// +build linux
+
+// Tests for the tracked rule model of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"bytes"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// Helper - Export the BPF program of a filter and decode it
+func exportProgram(t *testing.T, filter *ScmpFilter) []bpf.Instruction {
+	var data bytes.Buffer
+	if err := filter.ExportBPF(&data); err != nil {
+		t.Fatalf("Error exporting filter: %s", err)
+	}
+	prog, err := bpf.Decode(data.Bytes())
+	if err != nil {
+		t.Fatalf("Error decoding exported filter: %s", err)
+	}
+
+	return prog
+}
+
+func TestAddRuleForArch(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: syscall numbers are specific to amd64")
+	}
+	const (
+		auditArchX86_64 = 0xc000003e
+		auditArchI386   = 0x40000003
+	)
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.AddRuleForArch(ArchX86, 102, ActEPerm, nil); err == nil {
+		t.Errorf("Added rule for an architecture missing from the filter")
+	}
+
+	if err := filter.AddArch(ArchX86); err != nil {
+		t.Fatalf("Error adding architecture: %s", err)
+	}
+
+	getppid, err := GetSyscallFromName("getppid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	if err := filter.AddRule(getppid, ActErrno.SetErrno(unix.EACCES)); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	// socketcall() only exists on x86
+	if err := filter.AddRuleForArch(ArchX86, 102, ActEPerm, nil); err != nil {
+		t.Fatalf("Error adding rule for x86: %s", err)
+	}
+	cond, err := MakeCondition(0, CompareEqual, 1)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+	if err := filter.AddRuleForArch(ArchAMD64, unix.SYS_CLOSE, ActENoSys, []ScmpCondition{cond}); err != nil {
+		t.Fatalf("Error adding rule for x86_64: %s", err)
+	}
+
+	prog := exportProgram(t, filter)
+	for _, test := range []struct {
+		data bpf.Data
+		want uint32
+	}{
+		{bpf.Data{Nr: 102, Arch: auditArchI386}, bpf.RetErrno | uint32(unix.EPERM)},
+		{bpf.Data{Nr: 64, Arch: auditArchI386}, bpf.RetErrno | uint32(unix.EACCES)},
+		{bpf.Data{Nr: unix.SYS_GETPPID, Arch: auditArchX86_64}, bpf.RetErrno | uint32(unix.EACCES)},
+		{bpf.Data{Nr: unix.SYS_CLOSE, Arch: auditArchX86_64, Args: [6]uint64{1}}, bpf.RetErrno | uint32(unix.ENOSYS)},
+		{bpf.Data{Nr: unix.SYS_CLOSE, Arch: auditArchX86_64, Args: [6]uint64{2}}, bpf.RetAllow},
+		// close() on x86
+		{bpf.Data{Nr: 6, Arch: auditArchI386, Args: [6]uint64{1}}, bpf.RetAllow},
+	} {
+		ret, err := bpf.Run(prog, &test.data)
+		if err != nil {
+			t.Fatalf("Error running exported filter: %s", err)
+		}
+		if ret != test.want {
+			t.Errorf("Syscall %d on %#x: got %s, want %s", test.data.Nr, test.data.Arch,
+				bpf.ActionString(ret), bpf.ActionString(test.want))
+		}
+	}
+
+	if err := filter.RemoveArch(ArchX86); err != nil {
+		t.Fatalf("Error removing architecture: %s", err)
+	}
+	for _, rule := range filter.rules {
+		if rule.Arch == ArchX86 {
+			t.Errorf("Rule for removed architecture still tracked")
+		}
+	}
+}
+
+func TestRemoveRule(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: filter program is checked for amd64")
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	cond1, err := MakeCondition(0, CompareEqual, 1)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+	cond2, err := MakeCondition(1, CompareEqual, 2)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+
+	if err := filter.AddRule(unix.SYS_GETPID, ActEPerm); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+	if err := filter.AddRule(unix.SYS_GETPPID, ActEPerm); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+	if err := filter.AddRuleConditional(unix.SYS_CLOSE, ActEPerm, []ScmpCondition{cond1, cond2}); err != nil {
+		t.Fatalf("Error adding conditional rule: %s", err)
+	}
+
+	if err := filter.RemoveRule(unix.SYS_CLOSE); err != ErrRuleNotFound {
+		t.Errorf("Removing a missing unconditional rule returned %v, expected ErrRuleNotFound", err)
+	}
+	if err := filter.RemoveRuleConditional(unix.SYS_CLOSE, []ScmpCondition{cond1}); err != ErrRuleNotFound {
+		t.Errorf("Removing a rule with different conditions returned %v, expected ErrRuleNotFound", err)
+	}
+
+	if err := filter.RemoveRule(unix.SYS_GETPID); err != nil {
+		t.Fatalf("Error removing rule: %s", err)
+	}
+	if err := filter.RemoveRuleConditional(unix.SYS_CLOSE, []ScmpCondition{cond2, cond1}); err != nil {
+		t.Fatalf("Error removing conditional rule: %s", err)
+	}
+
+	if ret := runAMD64(t, filter, unix.SYS_GETPID); ret != bpf.RetAllow {
+		t.Errorf("Removed rule still applies: got %s", bpf.ActionString(ret))
+	}
+	if ret := runAMD64(t, filter, unix.SYS_GETPPID); ret != bpf.RetErrno|uint32(unix.EPERM) {
+		t.Errorf("Remaining rule does not apply: got %s", bpf.ActionString(ret))
+	}
+	prog := exportProgram(t, filter)
+	data := bpf.Data{Nr: unix.SYS_CLOSE, Arch: 0xc000003e, Args: [6]uint64{1, 2}}
+	if ret, err := bpf.Run(prog, &data); err != nil {
+		t.Fatalf("Error running exported filter: %s", err)
+	} else if ret != bpf.RetAllow {
+		t.Errorf("Removed conditional rule still applies: got %s", bpf.ActionString(ret))
+	}
+}
+
+func TestListRules(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+
+	if rules := filter.ListRules(); rules != nil {
+		t.Errorf("Expected no rules, got %v", rules)
+	}
+
+	getpid, err := GetSyscallFromName("getpid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	cond, err := MakeCondition(0, CompareEqual, 1)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+
+	if err := filter.AddRule(getpid, ActEPerm); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+	if err := filter.AddRuleConditionalExact(getpid, ActENoSys, []ScmpCondition{cond}); err != nil {
+		t.Fatalf("Error adding conditional rule: %s", err)
+	}
+
+	rules := filter.ListRules()
+	want := []ScmpRule{
+		{Syscall: getpid, Action: ActEPerm},
+		{Syscall: getpid, Action: ActENoSys, Conditions: []ScmpCondition{cond}, Exact: true},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("Expected rules %v, got %v", want, rules)
+	}
+
+	rules[1].Conditions[0].Operand1 = 2
+	if filter.ListRules()[1].Conditions[0].Operand1 != 1 {
+		t.Errorf("Listed rules share their conditions with the filter")
+	}
+
+	filter.Release()
+	if rules := filter.ListRules(); rules != nil {
+		t.Errorf("Expected no rules for an invalid filter, got %v", rules)
+	}
+}
+
+func TestMergeMultiplexedRules(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: syscall numbers are specific to amd64")
+	}
+	const auditArchI386 = 0x40000003
+
+	src, err := NewFilterWithArches(ActErrno.SetReturnCode(int16(unix.EPERM)), ArchAMD64, ArchX86)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer src.Release()
+	dst, err := NewFilterWithArches(ActErrno.SetReturnCode(int16(unix.EPERM)), ArchARM64)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer dst.Release()
+
+	socket, err := GetSyscallFromName("socket")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	if err := src.AddRule(socket, ActAllow); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+	if err := dst.Merge(src); err != nil {
+		t.Fatalf("Error merging filters: %s", err)
+	}
+
+	found := false
+	for _, rule := range dst.ListRules() {
+		found = found || (rule.Arch == ArchX86 && rule.Syscall == PseudoSocket)
+	}
+	if !found {
+		t.Fatalf("Rule for socket on x86 not tracked after merge: %+v", dst.ListRules())
+	}
+
+	clone, err := dst.Clone()
+	if err != nil {
+		t.Fatalf("Error cloning filter: %s", err)
+	}
+	defer clone.Release()
+
+	prog := exportProgram(t, clone)
+	for _, data := range []bpf.Data{
+		// socket() and socketcall(SYS_SOCKET) on x86
+		{Nr: 359, Arch: auditArchI386},
+		{Nr: 102, Arch: auditArchI386, Args: [6]uint64{1}},
+	} {
+		ret, err := bpf.Run(prog, &data)
+		if err != nil {
+			t.Fatalf("Error running exported filter: %s", err)
+		}
+		if ret != bpf.RetAllow {
+			t.Errorf("Syscall %d on x86: got %s, want allow", data.Nr, bpf.ActionString(ret))
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// +build linux
+
+// Runnable architectures for libseccomp Go bindings
+// Detects the compat architectures whose programs the running kernel can execute
+
+package seccomp
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Kernel configuration options enabling the compat architectures, any of
+// which is enough
+var compatConfigs = map[ScmpArch][]string{
+	ArchX86:         {"CONFIG_IA32_EMULATION"},
+	ArchX32:         {"CONFIG_X86_X32_ABI", "CONFIG_X86_X32"},
+	ArchARM:         {"CONFIG_COMPAT"},
+	ArchMIPS64N32:   {"CONFIG_MIPS32_N32"},
+	ArchMIPSEL64N32: {"CONFIG_MIPS32_N32"},
+	ArchMIPS:        {"CONFIG_MIPS32_O32"},
+	ArchMIPSEL:      {"CONFIG_MIPS32_O32"},
+	ArchPPC:         {"CONFIG_COMPAT"},
+	ArchS390:        {"CONFIG_COMPAT"},
+	ArchPARISC:      {"CONFIG_COMPAT"},
+}
+
+// KernelRunnableArches returns the architectures whose syscalls programs can
+// make on the running kernel: the native architecture, followed by those of
+// its compat architectures (see AddArchFamily) the kernel was built with and
+// has not been told to disable on its command line, as read from
+// /proc/config.gz or the /boot/config file of the kernel. Filters loaded on
+// this kernel only need to handle these architectures.
+// As leaving out a runnable architecture could make filters bypassable,
+// compat architectures are assumed runnable when the configuration of the
+// kernel cannot be read.
+// Returns nil if the native architecture could not be determined.
+func KernelRunnableArches() []ScmpArch {
+	native, err := GetNativeArch()
+	if err != nil {
+		return nil
+	}
+
+	var cmdline []string
+	if data, err := ioutil.ReadFile("/proc/cmdline"); err == nil {
+		cmdline = strings.Fields(string(data))
+	}
+
+	return runnableArches(native, readKernelConfig(), cmdline)
+}
+
+// Helper - Filter the compat architectures of the native architecture by the
+// configuration and command line of the kernel, a nil configuration meaning
+// an unknown one
+func runnableArches(native ScmpArch, config map[string]string, cmdline []string) []ScmpArch {
+	arches := []ScmpArch{native}
+
+	for _, arch := range compatArches[native] {
+		if config != nil {
+			enabled := false
+			for _, option := range compatConfigs[arch] {
+				if config[option] == "y" {
+					enabled = true
+				}
+			}
+			if !enabled {
+				continue
+			}
+		}
+
+		// IA32 emulation can be turned off at boot since Linux 6.7
+		if arch == ArchX86 {
+			enabled := config["CONFIG_IA32_EMULATION_DEFAULT_DISABLED"] != "y"
+			for _, param := range cmdline {
+				if !strings.HasPrefix(param, "ia32_emulation=") {
+					continue
+				}
+				// The kernel ignores values it does not understand, but
+				// assume a runnable architecture rather than guess
+				value, ok := parseKernelBool(strings.TrimPrefix(param, "ia32_emulation="))
+				enabled = value || !ok
+			}
+			if !enabled {
+				continue
+			}
+		}
+
+		arches = append(arches, arch)
+	}
+
+	return arches
+}
+
+// Helper - Parse a boolean kernel parameter the way kstrtobool does, from
+// its first characters only
+// Returns the value and whether it was recognised
+func parseKernelBool(s string) (bool, bool) {
+	if s == "" {
+		return false, false
+	}
+
+	switch s[0] {
+	case 'y', 'Y', 't', 'T', '1':
+		return true, true
+	case 'n', 'N', 'f', 'F', '0':
+		return false, true
+	case 'o', 'O':
+		if len(s) > 1 {
+			switch s[1] {
+			case 'n', 'N':
+				return true, true
+			case 'f', 'F':
+				return false, true
+			}
+		}
+	}
+
+	return false, false
+}
+
+// Helper - Read the configuration of the running kernel into a map of options
+// to their values
+// Returns nil if the configuration could not be found
+func readKernelConfig() map[string]string {
+	var r io.Reader
+	if f, err := os.Open("/proc/config.gz"); err == nil {
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		var uname unix.Utsname
+		if err := unix.Uname(&uname); err != nil {
+			return nil
+		}
+		f, err := os.Open("/boot/config-" + unix.ByteSliceToString(uname.Release[:]))
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		r = f
+	}
+
+	config := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "="); i > 0 && !strings.HasPrefix(line, "#") {
+			config[line[:i]] = line[i+1:]
+		}
+	}
+	if scanner.Err() != nil {
+		return nil
+	}
+
+	return config
+}
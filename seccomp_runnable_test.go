@@ -0,0 +1,59 @@
+// +build linux
+
+// Tests for the runnable architectures of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKernelRunnableArches(t *testing.T) {
+	native, err := GetNativeArch()
+	if err != nil {
+		t.Fatalf("Error getting native arch: %s", err)
+	}
+
+	arches := KernelRunnableArches()
+	if len(arches) == 0 || arches[0] != native {
+		t.Fatalf("Runnable architectures %v should start with the native architecture", arches)
+	}
+	for _, arch := range arches[1:] {
+		found := false
+		for _, compat := range compatArches[native] {
+			found = found || compat == arch
+		}
+		if !found {
+			t.Errorf("Architecture %s is not a compat architecture of %s", arch, native)
+		}
+	}
+	t.Logf("Runnable architectures: %v", arches)
+
+	for _, test := range []struct {
+		native  ScmpArch
+		config  map[string]string
+		cmdline []string
+		want    []ScmpArch
+	}{
+		{ArchAMD64, nil, nil, []ScmpArch{ArchAMD64, ArchX86, ArchX32}},
+		{ArchAMD64, map[string]string{"CONFIG_IA32_EMULATION": "y"}, nil, []ScmpArch{ArchAMD64, ArchX86}},
+		{ArchAMD64, map[string]string{"CONFIG_IA32_EMULATION": "y"}, []string{"quiet", "ia32_emulation=0"}, []ScmpArch{ArchAMD64}},
+		{ArchAMD64, map[string]string{"CONFIG_IA32_EMULATION": "y", "CONFIG_IA32_EMULATION_DEFAULT_DISABLED": "y"}, nil, []ScmpArch{ArchAMD64}},
+		{ArchAMD64, map[string]string{"CONFIG_IA32_EMULATION": "y", "CONFIG_IA32_EMULATION_DEFAULT_DISABLED": "y"}, []string{"ia32_emulation=1"}, []ScmpArch{ArchAMD64, ArchX86}},
+		{ArchAMD64, map[string]string{"CONFIG_IA32_EMULATION": "y", "CONFIG_IA32_EMULATION_DEFAULT_DISABLED": "y"}, []string{"ia32_emulation=on"}, []ScmpArch{ArchAMD64, ArchX86}},
+		{ArchAMD64, map[string]string{"CONFIG_IA32_EMULATION": "y", "CONFIG_IA32_EMULATION_DEFAULT_DISABLED": "y"}, []string{"ia32_emulation=Y"}, []ScmpArch{ArchAMD64, ArchX86}},
+		{ArchAMD64, map[string]string{"CONFIG_IA32_EMULATION": "y", "CONFIG_IA32_EMULATION_DEFAULT_DISABLED": "y"}, []string{"ia32_emulation=maybe"}, []ScmpArch{ArchAMD64, ArchX86}},
+		{ArchAMD64, map[string]string{"CONFIG_IA32_EMULATION": "y"}, []string{"ia32_emulation=off"}, []ScmpArch{ArchAMD64}},
+		{ArchAMD64, map[string]string{"CONFIG_IA32_EMULATION": "y"}, []string{"ia32_emulation=N"}, []ScmpArch{ArchAMD64}},
+		{ArchAMD64, map[string]string{"CONFIG_X86_X32": "y"}, nil, []ScmpArch{ArchAMD64, ArchX32}},
+		{ArchARM64, map[string]string{}, nil, []ScmpArch{ArchARM64}},
+		{ArchARM64, map[string]string{"CONFIG_COMPAT": "y"}, nil, []ScmpArch{ArchARM64, ArchARM}},
+		{ArchMIPS64, map[string]string{"CONFIG_MIPS32_O32": "y"}, nil, []ScmpArch{ArchMIPS64, ArchMIPS}},
+		{ArchRISCV64, map[string]string{"CONFIG_COMPAT": "y"}, nil, []ScmpArch{ArchRISCV64}},
+	} {
+		if got := runnableArches(test.native, test.config, test.cmdline); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Got runnable architectures %v for %s with %v and %v, want %v", got, test.native, test.config, test.cmdline, test.want)
+		}
+	}
+}
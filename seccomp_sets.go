@@ -0,0 +1,341 @@
+// +build linux
+
+// Syscall sets for libseccomp Go bindings
+// Provides the syscall groups of systemd's SystemCallFilter= setting
+
+package seccomp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SyscallSet is a named group of syscalls, using the names of the groups
+// of systemd's SystemCallFilter= unit setting (e.g., "@system-service"), so
+// that filters can be written in the vocabulary used in unit files.
+// Sets may include other sets.
+type SyscallSet string
+
+const (
+	// SetDefault are the syscalls always permitted by systemd
+	SetDefault SyscallSet = "@default"
+	// SetAIO are the asynchronous I/O syscalls
+	SetAIO SyscallSet = "@aio"
+	// SetBasicIO are the syscalls reading and writing file descriptors
+	SetBasicIO SyscallSet = "@basic-io"
+	// SetChown are the syscalls changing file ownership
+	SetChown SyscallSet = "@chown"
+	// SetClock are the syscalls changing the system clock
+	SetClock SyscallSet = "@clock"
+	// SetClone are the syscalls creating processes and namespaces
+	SetClone SyscallSet = "@clone"
+	// SetCPUEmulation are the syscalls emulating CPUs
+	SetCPUEmulation SyscallSet = "@cpu-emulation"
+	// SetDebug are the syscalls debugging, tracing and profiling
+	SetDebug SyscallSet = "@debug"
+	// SetFileSystem are the syscalls accessing the file system
+	SetFileSystem SyscallSet = "@file-system"
+	// SetIOEvent are the syscalls polling for events
+	SetIOEvent SyscallSet = "@io-event"
+	// SetIPC are the syscalls of inter-process communication
+	SetIPC SyscallSet = "@ipc"
+	// SetKeyring are the syscalls accessing the kernel keyring
+	SetKeyring SyscallSet = "@keyring"
+	// SetMemlock are the syscalls locking memory
+	SetMemlock SyscallSet = "@memlock"
+	// SetModule are the syscalls loading and unloading kernel modules
+	SetModule SyscallSet = "@module"
+	// SetMount are the syscalls mounting and unmounting file systems
+	SetMount SyscallSet = "@mount"
+	// SetNetworkIO are the syscalls of socket I/O
+	SetNetworkIO SyscallSet = "@network-io"
+	// SetObsolete are the unusual, obsolete or unimplemented syscalls
+	SetObsolete SyscallSet = "@obsolete"
+	// SetPkey are the syscalls managing memory protection keys
+	SetPkey SyscallSet = "@pkey"
+	// SetPrivileged are the syscalls requiring superuser capabilities
+	SetPrivileged SyscallSet = "@privileged"
+	// SetProcess are the syscalls managing processes
+	SetProcess SyscallSet = "@process"
+	// SetRawIO are the syscalls accessing raw I/O ports
+	SetRawIO SyscallSet = "@raw-io"
+	// SetReboot are the syscalls rebooting and preparing reboots
+	SetReboot SyscallSet = "@reboot"
+	// SetResources are the syscalls changing resource limits and scheduling
+	SetResources SyscallSet = "@resources"
+	// SetSandbox are the syscalls sandboxing processes
+	SetSandbox SyscallSet = "@sandbox"
+	// SetSetUID are the syscalls changing user and group credentials
+	SetSetUID SyscallSet = "@setuid"
+	// SetSignal are the syscalls managing signals
+	SetSignal SyscallSet = "@signal"
+	// SetSwap are the syscalls enabling and disabling swap devices
+	SetSwap SyscallSet = "@swap"
+	// SetSync are the syscalls synchronizing files and memory to disk
+	SetSync SyscallSet = "@sync"
+	// SetSystemService are the syscalls commonly needed by system services
+	SetSystemService SyscallSet = "@system-service"
+	// SetTimer are the syscalls managing timers
+	SetTimer SyscallSet = "@timer"
+)
+
+// Members of the syscall sets, including other sets by their name, as
+// defined by systemd
+var syscallSets = map[SyscallSet][]string{
+	SetDefault: {
+		"arch_prctl", "brk", "cacheflush", "clock_getres", "clock_getres_time64",
+		"clock_gettime", "clock_gettime64", "clock_nanosleep",
+		"clock_nanosleep_time64", "execve", "exit", "exit_group", "futex",
+		"futex_time64", "futex_waitv", "get_robust_list", "get_thread_area",
+		"getegid", "getegid32", "geteuid", "geteuid32", "getgid", "getgid32",
+		"getgroups", "getgroups32", "getpgid", "getpgrp", "getpid", "getppid",
+		"getrandom", "getresgid", "getresgid32", "getresuid", "getresuid32",
+		"getrlimit", "getsid", "gettid", "gettimeofday", "getuid", "getuid32",
+		"membarrier", "mmap", "mmap2", "mprotect", "munmap", "nanosleep",
+		"pause", "prlimit64", "restart_syscall", "riscv_flush_icache", "rseq",
+		"rt_sigreturn", "sched_getaffinity", "sched_yield", "set_robust_list",
+		"set_thread_area", "set_tid_address", "set_tls", "sigreturn", "time",
+		"ugetrlimit",
+	},
+	SetAIO: {
+		"io_cancel", "io_destroy", "io_getevents", "io_pgetevents",
+		"io_pgetevents_time64", "io_setup", "io_submit", "io_uring_enter",
+		"io_uring_register", "io_uring_setup",
+	},
+	SetBasicIO: {
+		"_llseek", "close", "close_range", "dup", "dup2", "dup3", "lseek",
+		"pread64", "preadv", "preadv2", "pwrite64", "pwritev", "pwritev2",
+		"read", "readv", "write", "writev",
+	},
+	SetChown: {
+		"chown", "chown32", "fchown", "fchown32", "fchownat", "lchown", "lchown32",
+	},
+	SetClock: {
+		"adjtimex", "clock_adjtime", "clock_adjtime64", "clock_settime",
+		"clock_settime64", "settimeofday",
+	},
+	SetClone: {
+		"clone", "clone3", "fork", "unshare", "vfork",
+	},
+	SetCPUEmulation: {
+		"modify_ldt", "subpage_prot", "switch_endian", "vm86", "vm86old",
+	},
+	SetDebug: {
+		"lookup_dcookie", "perf_event_open", "pidfd_getfd", "ptrace", "rtas",
+		"s390_runtime_instr", "sys_debug_setcontext",
+	},
+	SetFileSystem: {
+		"access", "chdir", "chmod", "close", "creat", "faccessat", "faccessat2",
+		"fallocate", "fchdir", "fchmod", "fchmodat", "fcntl", "fcntl64",
+		"fgetxattr", "flistxattr", "fremovexattr", "fsetxattr", "fstat",
+		"fstat64", "fstatat64", "fstatfs", "fstatfs64", "ftruncate",
+		"ftruncate64", "futimesat", "getcwd", "getdents", "getdents64",
+		"getxattr", "inotify_add_watch", "inotify_init", "inotify_init1",
+		"inotify_rm_watch", "lgetxattr", "link", "linkat", "listxattr",
+		"llistxattr", "lremovexattr", "lsetxattr", "lstat", "lstat64", "mkdir",
+		"mkdirat", "mknod", "mknodat", "newfstatat", "oldfstat", "oldlstat",
+		"oldstat", "open", "openat", "openat2", "readlink", "readlinkat",
+		"removexattr", "rename", "renameat", "renameat2", "rmdir", "setxattr",
+		"stat", "stat64", "statfs", "statfs64", "statx", "symlink",
+		"symlinkat", "truncate", "truncate64", "unlink", "unlinkat", "utime",
+		"utimensat", "utimensat_time64", "utimes",
+	},
+	SetIOEvent: {
+		"_newselect", "epoll_create", "epoll_create1", "epoll_ctl",
+		"epoll_ctl_old", "epoll_pwait", "epoll_pwait2", "epoll_wait",
+		"epoll_wait_old", "eventfd", "eventfd2", "poll", "ppoll",
+		"ppoll_time64", "pselect6", "pselect6_time64", "select",
+	},
+	SetIPC: {
+		"ipc", "memfd_create", "mq_getsetattr", "mq_notify", "mq_open",
+		"mq_timedreceive", "mq_timedreceive_time64", "mq_timedsend",
+		"mq_timedsend_time64", "mq_unlink", "msgctl", "msgget", "msgrcv",
+		"msgsnd", "pipe", "pipe2", "process_madvise", "process_vm_readv",
+		"process_vm_writev", "semctl", "semget", "semop", "semtimedop",
+		"semtimedop_time64", "shmat", "shmctl", "shmdt", "shmget",
+	},
+	SetKeyring: {
+		"add_key", "keyctl", "request_key",
+	},
+	SetMemlock: {
+		"mlock", "mlock2", "mlockall", "munlock", "munlockall",
+	},
+	SetModule: {
+		"delete_module", "finit_module", "init_module",
+	},
+	SetMount: {
+		"chroot", "fsconfig", "fsmount", "fsopen", "fspick", "mount",
+		"mount_setattr", "move_mount", "open_tree", "pivot_root", "umount",
+		"umount2",
+	},
+	SetNetworkIO: {
+		"accept", "accept4", "bind", "connect", "getpeername", "getsockname",
+		"getsockopt", "listen", "recv", "recvfrom", "recvmmsg",
+		"recvmmsg_time64", "recvmsg", "send", "sendmmsg", "sendmsg", "sendto",
+		"setsockopt", "shutdown", "socket", "socketcall", "socketpair",
+	},
+	SetObsolete: {
+		"_sysctl", "afs_syscall", "bdflush", "break", "create_module", "ftime",
+		"get_kernel_syms", "getpmsg", "gtty", "idle", "lock", "mpx", "prof",
+		"profil", "putpmsg", "query_module", "security", "sgetmask",
+		"ssetmask", "stime", "stty", "sysfs", "tuxcall", "ulimit", "uselib",
+		"ustat", "vserver",
+	},
+	SetPkey: {
+		"pkey_alloc", "pkey_free", "pkey_mprotect",
+	},
+	SetPrivileged: {
+		string(SetChown), string(SetClock), string(SetModule), string(SetRawIO),
+		string(SetReboot), string(SetSwap), "_sysctl", "acct", "bpf", "capset",
+		"chroot", "fanotify_init", "fanotify_mark", "nfsservctl",
+		"open_by_handle_at", "pivot_root", "quotactl", "quotactl_fd",
+		"setdomainname", "setfsuid", "setfsuid32", "setgroups", "setgroups32",
+		"sethostname", "setresuid", "setresuid32", "setreuid", "setreuid32",
+		"setuid", "setuid32", "vhangup",
+	},
+	SetProcess: {
+		"capget", "clone", "clone3", "execveat", "fork", "getrusage", "kill",
+		"pidfd_open", "pidfd_send_signal", "prctl", "rt_sigqueueinfo",
+		"rt_tgsigqueueinfo", "setns", "swapcontext", "tgkill", "times",
+		"tkill", "unshare", "vfork", "wait4", "waitid", "waitpid",
+	},
+	SetRawIO: {
+		"ioperm", "iopl", "pciconfig_iobase", "pciconfig_read",
+		"pciconfig_write", "s390_pci_mmio_read", "s390_pci_mmio_write",
+	},
+	SetReboot: {
+		"kexec_file_load", "kexec_load", "reboot",
+	},
+	SetResources: {
+		"ioprio_set", "mbind", "migrate_pages", "move_pages", "nice",
+		"sched_setaffinity", "sched_setattr", "sched_setparam",
+		"sched_setscheduler", "set_mempolicy", "set_mempolicy_home_node",
+		"setpriority", "setrlimit",
+	},
+	SetSandbox: {
+		"landlock_add_rule", "landlock_create_ruleset",
+		"landlock_restrict_self", "seccomp",
+	},
+	SetSetUID: {
+		"setgid", "setgid32", "setgroups", "setgroups32", "setregid",
+		"setregid32", "setresgid", "setresgid32", "setresuid", "setresuid32",
+		"setreuid", "setreuid32", "setuid", "setuid32",
+	},
+	SetSignal: {
+		"rt_sigaction", "rt_sigpending", "rt_sigprocmask", "rt_sigsuspend",
+		"rt_sigtimedwait", "rt_sigtimedwait_time64", "sigaction",
+		"sigaltstack", "signal", "signalfd", "signalfd4", "sigpending",
+		"sigprocmask", "sigsuspend",
+	},
+	SetSwap: {
+		"swapoff", "swapon",
+	},
+	SetSync: {
+		"fdatasync", "fsync", "msync", "sync", "sync_file_range",
+		"sync_file_range2", "syncfs",
+	},
+	SetSystemService: {
+		string(SetAIO), string(SetBasicIO), string(SetChown), string(SetDefault),
+		string(SetFileSystem), string(SetIOEvent), string(SetIPC),
+		string(SetKeyring), string(SetMemlock), string(SetNetworkIO),
+		string(SetProcess), string(SetResources), string(SetSetUID),
+		string(SetSignal), string(SetSync), string(SetTimer),
+		"arm_fadvise64_64", "capget", "capset", "copy_file_range",
+		"fadvise64", "fadvise64_64", "flock", "get_mempolicy", "getcpu",
+		"getpriority", "ioctl", "ioprio_get", "kcmp", "madvise", "mremap",
+		"name_to_handle_at", "oldolduname", "olduname", "personality",
+		"readahead", "readdir", "remap_file_pages", "sched_get_priority_max",
+		"sched_get_priority_min", "sched_getattr", "sched_getparam",
+		"sched_getscheduler", "sched_rr_get_interval",
+		"sched_rr_get_interval_time64", "sched_yield", "sendfile",
+		"sendfile64", "setfsgid", "setfsgid32", "setfsuid", "setfsuid32",
+		"setpgid", "setsid", "splice", "sysinfo", "tee", "umask", "uname",
+		"userfaultfd", "vmsplice",
+	},
+	SetTimer: {
+		"alarm", "getitimer", "setitimer", "timer_create", "timer_delete",
+		"timer_getoverrun", "timer_gettime", "timer_gettime64",
+		"timer_settime", "timer_settime64", "timerfd_create",
+		"timerfd_gettime", "timerfd_gettime64", "timerfd_settime",
+		"timerfd_settime64", "times",
+	},
+}
+
+// SyscallSets returns all the known syscall sets, sorted by name.
+func SyscallSets() []SyscallSet {
+	sets := make([]SyscallSet, 0, len(syscallSets))
+	for set := range syscallSets {
+		sets = append(sets, set)
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i] < sets[j] })
+
+	return sets
+}
+
+// Syscalls returns the names of the syscalls in the set, including those of
+// the sets it includes, sorted and without duplicates. Names may include
+// syscalls which do not exist on every architecture, or are unknown to
+// libseccomp.
+// Returns an error if the set is unknown.
+func (s SyscallSet) Syscalls() ([]string, error) {
+	seen := make(map[string]bool)
+	if err := expandSyscallSet(s, seen); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// AddRuleSet adds a single rule taking action on each syscall of the set,
+// as AddRule does. Like systemd, syscalls of the set which are unknown to
+// libseccomp are skipped.
+// Returns an error if the set is unknown, or a rule could not be added.
+func (f *ScmpFilter) AddRuleSet(set SyscallSet, action ScmpAction) error {
+	names, err := set.Syscalls()
+	if err != nil {
+		return err
+	}
+
+	return f.addRuleNames(names, action, string(set))
+}
+
+// Helper - Add a rule taking action on each syscall of a list of names,
+// skipping those unknown to libseccomp, what naming the list in errors
+func (f *ScmpFilter) addRuleNames(names []string, action ScmpAction, what string) error {
+	for _, name := range names {
+		call, err := GetSyscallFromName(name)
+		if err != nil {
+			continue
+		}
+		if err := f.AddRule(call, action); err != nil {
+			return fmt.Errorf("could not add rule for %q of %s: %v", name, what, err)
+		}
+	}
+
+	return nil
+}
+
+// Helper - Collect the syscall names of a set and its included sets
+func expandSyscallSet(s SyscallSet, seen map[string]bool) error {
+	members, ok := syscallSets[s]
+	if !ok {
+		return fmt.Errorf("unknown syscall set %q", string(s))
+	}
+
+	for _, member := range members {
+		if !strings.HasPrefix(member, "@") {
+			seen[member] = true
+		} else if err := expandSyscallSet(SyscallSet(member), seen); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
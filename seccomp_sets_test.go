@@ -0,0 +1,110 @@
+// +build linux
+
+// Tests for the syscall sets of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestSyscallSets(t *testing.T) {
+	sets := SyscallSets()
+	if len(sets) == 0 || !sort.SliceIsSorted(sets, func(i, j int) bool { return sets[i] < sets[j] }) {
+		t.Fatalf("Syscall sets should be listed sorted: %v", sets)
+	}
+
+	for _, set := range sets {
+		names, err := set.Syscalls()
+		if err != nil {
+			t.Fatalf("Error expanding %s: %s", set, err)
+		}
+		if len(names) == 0 || !sort.StringsAreSorted(names) {
+			t.Errorf("Syscalls of %s should be listed sorted", set)
+		}
+
+		filter, err := NewFilterWithArches(ActErrno, ArchNative, ArchX86)
+		if err != nil {
+			t.Fatalf("Error creating filter: %s", err)
+		}
+		if err := filter.AddRuleSet(set, ActAllow); err != nil {
+			t.Errorf("Error adding rules for %s: %s", set, err)
+		}
+		filter.Release()
+	}
+
+	names, err := SetSystemService.Syscalls()
+	if err != nil {
+		t.Fatalf("Error expanding %s: %s", SetSystemService, err)
+	}
+	for _, want := range []string{"getpid", "read", "openat", "socket", "setuid"} {
+		if i := sort.SearchStrings(names, want); i == len(names) || names[i] != want {
+			t.Errorf("%s should include %s", SetSystemService, want)
+		}
+	}
+
+	names, err = SetPrivileged.Syscalls()
+	if err != nil {
+		t.Fatalf("Error expanding %s: %s", SetPrivileged, err)
+	}
+	for name, want := range map[string]bool{
+		"settimeofday": true, "clock_settime": true, "setuid": true,
+		"init_module": true, "fork": false, "clone": false,
+	} {
+		i := sort.SearchStrings(names, name)
+		if got := i < len(names) && names[i] == name; got != want {
+			t.Errorf("%s including %s should be %t", SetPrivileged, name, want)
+		}
+	}
+
+	if _, err := SyscallSet("@bogus").Syscalls(); err == nil {
+		t.Errorf("Expanding an unknown set should fail")
+	}
+}
+
+func TestAddRuleSet(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: filter program is checked for amd64")
+	}
+	const auditArchX8664 = 0xc000003e
+
+	filter, err := NewFilter(ActErrno.SetReturnCode(int16(unix.EPERM)))
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.AddRuleSet(SetSystemService, ActAllow); err != nil {
+		t.Fatalf("Error adding rules for %s: %s", SetSystemService, err)
+	}
+	if err := filter.AddRuleSet(SetReboot, ActKillProcess); err != nil {
+		t.Fatalf("Error adding rules for %s: %s", SetReboot, err)
+	}
+	if err := filter.AddRuleSet("@bogus", ActAllow); err == nil {
+		t.Errorf("Adding rules for an unknown set should fail")
+	}
+
+	prog := exportProgram(t, filter)
+	for _, test := range []struct {
+		nr   int32
+		want uint32
+	}{
+		{unix.SYS_READ, bpf.RetAllow},
+		{unix.SYS_GETPID, bpf.RetAllow},
+		{unix.SYS_REBOOT, bpf.RetKillProcess},
+		{unix.SYS_MOUNT, bpf.RetErrno | uint32(unix.EPERM)},
+	} {
+		ret, err := bpf.Run(prog, &bpf.Data{Nr: test.nr, Arch: auditArchX8664})
+		if err != nil {
+			t.Fatalf("Error running exported filter: %s", err)
+		}
+		if ret != test.want {
+			t.Errorf("Syscall %d: got %s, want %s", test.nr, bpf.ActionString(ret), bpf.ActionString(test.want))
+		}
+	}
+}
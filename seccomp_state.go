@@ -0,0 +1,172 @@
+// +build linux
+
+// Serializable filter state for libseccomp Go bindings
+// Captures filters so they can be stored and built again elsewhere
+
+package seccomp
+
+import (
+	"fmt"
+)
+
+// Attributes captured in the state of a filter
+var stateAttrs = []ScmpFilterAttr{AttrActBadArch, AttrNNP, AttrTsync, AttrTSkip,
+	AttrLog, AttrSSB, AttrOptimize, AttrRawRC, AttrWaitKill}
+
+// ScmpFilterState is a serializable representation of a filter, which can be
+// encoded with encoding/json or encoding/gob, and turned back into a filter
+// with RestoreFilter.
+//
+// DefaultAction: action taken for syscalls which match no rule
+// Attrs:         raw values of the filter attributes, see GetAttr
+// NativeArch:    native architecture the syscall numbers of Rules refer to
+// Arches:        architectures of the filter
+// Rules:         rules of the filter, see ListRules
+// Priorities:    syscall priorities, see SetSyscallPriority
+// RawPseudo:     whether raw rules may use pseudo-syscalls
+// Truncate:      whether operands are truncated on 32-bit architectures
+// AutoAPI:       whether the API level is raised automatically
+//
+type ScmpFilterState struct {
+	DefaultAction ScmpAction              `json:"defaultAction"`
+	Attrs         map[ScmpFilterAttr]uint `json:"attributes,omitempty"`
+	NativeArch    ScmpArch                `json:"nativeArch"`
+	Arches        []ScmpArch              `json:"architectures"`
+	Rules         []ScmpRule              `json:"rules,omitempty"`
+	Priorities    map[ScmpSyscall]uint8   `json:"priorities,omitempty"`
+	RawPseudo     bool                    `json:"rawPseudo,omitempty"`
+	Truncate      bool                    `json:"truncateOperands,omitempty"`
+	AutoAPI       bool                    `json:"autoAPI,omitempty"`
+}
+
+// MarshalState captures the state of the filter in a serializable
+// representation, from which RestoreFilter builds an identical filter, e.g.
+// in another process.
+// Returns an error if the filter is invalid or its state could not be read.
+func (f *ScmpFilter) MarshalState() (*ScmpFilterState, error) {
+	defaultAction, err := f.GetDefaultAction()
+	if err != nil {
+		return nil, err
+	}
+	native, err := GetNativeArch()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &ScmpFilterState{
+		DefaultAction: defaultAction,
+		Attrs:         make(map[ScmpFilterAttr]uint),
+		NativeArch:    native,
+		Rules:         f.ListRules(),
+	}
+	for _, attr := range stateAttrs {
+		// Attributes unknown to the linked libseccomp are left out
+		if value, err := f.GetAttr(attr); err == nil {
+			state.Attrs[attr] = value
+		}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return nil, ErrInvalidFilter
+	}
+
+	state.Arches = f.filterArches()
+	state.RawPseudo = f.rawPseudo
+	state.Truncate = f.truncateOperands
+	state.AutoAPI = f.autoAPI
+	if len(f.priorities) > 0 {
+		state.Priorities = make(map[ScmpSyscall]uint8, len(f.priorities))
+		for call, priority := range f.priorities {
+			state.Priorities[call] = priority
+		}
+	}
+
+	return state, nil
+}
+
+// RestoreFilter creates a new filter from a state captured by MarshalState.
+// If the state was captured on another native architecture, the syscall
+// numbers of the rules applying to all architectures are translated by name.
+// Returns an error if the state is invalid, or the filter could not be built
+// from it, e.g. because the linked libseccomp lacks an attribute it sets.
+func RestoreFilter(state *ScmpFilterState) (*ScmpFilter, error) {
+	native, err := GetNativeArch()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]ScmpRule, 0, len(state.Rules))
+	for _, rule := range state.Rules {
+		if rule.Arch == ArchInvalid {
+			call, err := restoreSyscall(rule.Syscall, state.NativeArch, native)
+			if err != nil {
+				return nil, err
+			}
+			rule.Syscall = call
+		}
+		rule.Conditions = append([]ScmpCondition(nil), rule.Conditions...)
+		rules = append(rules, rule)
+	}
+	priorities := make(map[ScmpSyscall]uint8, len(state.Priorities))
+	for call, priority := range state.Priorities {
+		call, err := restoreSyscall(call, state.NativeArch, native)
+		if err != nil {
+			return nil, err
+		}
+		priorities[call] = priority
+	}
+
+	filter, err := NewFilterWithArches(state.DefaultAction, state.Arches...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attr := range stateAttrs {
+		value, ok := state.Attrs[attr]
+		if !ok {
+			continue
+		}
+		if err := filter.SetAttr(attr, value); err != nil && value != 0 {
+			filter.Release()
+			return nil, fmt.Errorf("could not restore %s: %v", attr, err)
+		}
+	}
+
+	filter.lock.Lock()
+	filter.rawPseudo = state.RawPseudo
+	filter.truncateOperands = state.Truncate
+	filter.autoAPI = state.AutoAPI
+	filter.rules = rules
+	filter.priorities = priorities
+
+	err = filter.rebuild(state.Arches)
+	filter.lock.Unlock()
+	if err != nil {
+		filter.Release()
+		return nil, fmt.Errorf("could not restore rules: %v", err)
+	}
+
+	return filter, nil
+}
+
+// Helper - Translate a syscall number from the native architecture of a
+// captured state to the current one
+func restoreSyscall(call ScmpSyscall, from, to ScmpArch) (ScmpSyscall, error) {
+	if from == to || from == ArchInvalid || int32(call) < 0 {
+		return call, nil
+	}
+
+	name, err := call.GetNameByArch(from)
+	if err != nil {
+		return 0, fmt.Errorf("could not resolve syscall %d on %s: %v", int32(call), from, err)
+	}
+	translated, err := GetSyscallFromNameByArch(name, to)
+	if err != nil {
+		return 0, fmt.Errorf("could not resolve syscall %q on %s: %v", name, to, err)
+	}
+
+	return translated, nil
+}
@@ -0,0 +1,117 @@
+// +build linux
+
+// Tests for the serializable filter state of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestFilterState(t *testing.T) {
+	native, err := GetNativeArch()
+	if err != nil {
+		t.Fatalf("Error getting native arch: %s", err)
+	}
+	other := ArchX86
+	if native == ArchX86 {
+		other = ArchAMD64
+	}
+
+	filter, err := NewFilterWithArches(ActENoSys, native, other)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.SetBadArchAction(ActTrap); err != nil {
+		t.Fatalf("Error setting bad arch action: %s", err)
+	}
+	if err := filter.SetNoNewPrivsBit(false); err != nil {
+		t.Fatalf("Error setting no new privileges bit: %s", err)
+	}
+	getpid, err := GetSyscallFromName("getpid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	cond, err := MakeCondition(0, CompareEqual, 1)
+	if err != nil {
+		t.Fatalf("Error making condition: %s", err)
+	}
+	if err := filter.AddRule(getpid, ActAllow); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+	if err := filter.AddRuleConditional(ScmpSyscall(unix.SYS_CLOSE), ActEPerm, []ScmpCondition{cond}); err != nil {
+		t.Fatalf("Error adding conditional rule: %s", err)
+	}
+	if err := filter.SetSyscallPriority(getpid, 200); err != nil {
+		t.Fatalf("Error setting syscall priority: %s", err)
+	}
+
+	state, err := filter.MarshalState()
+	if err != nil {
+		t.Fatalf("Error capturing filter state: %s", err)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("Error encoding filter state: %s", err)
+	}
+	var decoded ScmpFilterState
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Error decoding filter state: %s", err)
+	}
+	if !reflect.DeepEqual(state, &decoded) {
+		t.Errorf("Filter state changed through JSON: %+v, expected %+v", decoded, *state)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(state); err != nil {
+		t.Fatalf("Error encoding filter state with gob: %s", err)
+	}
+	var gobDecoded ScmpFilterState
+	if err := gob.NewDecoder(&buf).Decode(&gobDecoded); err != nil {
+		t.Fatalf("Error decoding filter state with gob: %s", err)
+	}
+	if !reflect.DeepEqual(state, &gobDecoded) {
+		t.Errorf("Filter state changed through gob: %+v, expected %+v", gobDecoded, *state)
+	}
+
+	restored, err := RestoreFilter(&decoded)
+	if err != nil {
+		t.Fatalf("Error restoring filter: %s", err)
+	}
+	defer restored.Release()
+
+	if act, err := restored.GetBadArchAction(); err != nil || act != ActTrap {
+		t.Errorf("Bad arch action was not restored: %v, %v", act, err)
+	}
+	if !reflect.DeepEqual(restored.ListRules(), filter.ListRules()) {
+		t.Errorf("Rules were not restored: %v, expected %v", restored.ListRules(), filter.ListRules())
+	}
+
+	// Compare with a filter built the same way from the tracked rules
+	clone, err := filter.Clone()
+	if err != nil {
+		t.Fatalf("Error cloning filter: %s", err)
+	}
+	defer clone.Release()
+
+	want, err := clone.ExportBPFMem()
+	if err != nil {
+		t.Fatalf("Error exporting filter: %s", err)
+	}
+	got, err := restored.ExportBPFMem()
+	if err != nil {
+		t.Fatalf("Error exporting restored filter: %s", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Restored filter generates a different program")
+	}
+}
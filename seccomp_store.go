@@ -0,0 +1,257 @@
+// +build linux
+
+// Profile store for libseccomp Go bindings
+// Serves filters compiled from a directory of profiles, reloaded on changes
+
+package seccomp
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/seccomp/libseccomp-golang/profile"
+)
+
+// ProfileStore serves filters compiled from the OCI seccomp profiles of a
+// directory, loaded into a profile.Registry: see profile.Registry.LoadDir for
+// the layout of the directory and the names of its profiles, such as
+// "profiles/audit.json".
+// Profiles are validated by compiling them when they are loaded, and the
+// compiled filters are kept as templates from which Filter clones filters.
+// A ProfileStore is safe for concurrent use.
+type ProfileStore struct {
+	dir      string
+	registry *profile.Registry
+	// Serializes compilations
+	compileLock sync.Mutex
+	lock        sync.RWMutex
+	entries     map[string]*storeEntry
+	reloads     uint64
+	lastErr     error
+	// Errors of the last reload
+	reloadErr error
+	closed    bool
+	// Serializes Watch and Close, apart from the lock which reloads take
+	watchLock sync.Mutex
+	// Stops watching the directory, nil if it is not watched
+	stop func()
+}
+
+// Profile of a ProfileStore, compiled into a filter
+type storeEntry struct {
+	// Digest of the profile the filter was compiled from
+	digest string
+	filter *ScmpFilter
+}
+
+// NewProfileStore creates a store serving the profiles of dir, and loads
+// them. See Reload.
+// Returns the store, or an error if the directory could not be read. Errors
+// of individual profiles are reported by Reloads.
+func NewProfileStore(dir string) (*ProfileStore, error) {
+	s := &ProfileStore{
+		dir:      filepath.Clean(dir),
+		registry: profile.NewRegistry(),
+		entries:  make(map[string]*storeEntry),
+	}
+
+	s.registry.OnReload(s.compile)
+	if err := s.registry.LoadDir(s.dir); err != nil {
+		if _, ok := err.(*profile.LoadError); !ok {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Reload reloads the profiles of the directory. Profiles whose content did
+// not change are not recompiled, and profiles whose file has been removed
+// are dropped. A profile that fails to load or compile keeps its previously
+// loaded version, if any.
+// Returns an error if the directory could not be read, or a
+// *profile.LoadError describing every profile that could not be loaded or
+// compiled.
+func (s *ProfileStore) Reload() error {
+	if err := s.registry.LoadDir(s.dir); err != nil {
+		if _, ok := err.(*profile.LoadError); !ok {
+			return err
+		}
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.reloadErr
+}
+
+// Registry returns the registry holding the profiles of the store, e.g. to
+// query their usage. Profiles must not be registered into it.
+func (s *ProfileStore) Registry() *profile.Registry {
+	return s.registry
+}
+
+// Filter returns a new filter cloned from the template compiled from the
+// profile named name. The filter is not loaded, and must be released by the
+// caller.
+// Returns an error if no such profile exists or the filter could not be
+// cloned.
+func (s *ProfileStore) Filter(name string) (*ScmpFilter, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	entry, ok := s.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("no profile %q in %s", name, s.dir)
+	}
+
+	// Record the use of the profile
+	s.registry.Lookup(name)
+
+	return entry.filter.Clone()
+}
+
+// Names returns the sorted names of the profiles of the store.
+func (s *ProfileStore) Names() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Reloads returns the number of reloads of the profiles, and the error of
+// the last reload that failed, if any.
+func (s *ProfileStore) Reloads() (uint64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.reloads, s.lastErr
+}
+
+// Watch watches the directory with inotify, reloading the profiles whenever
+// a file of the directory changes. See profile.Registry.WatchDirInotify.
+// Errors of these reloads are reported by Reloads. Watching stops when the
+// store is closed.
+// Returns an error if the directory could not be watched, or is already
+// watched.
+func (s *ProfileStore) Watch() error {
+	s.watchLock.Lock()
+	defer s.watchLock.Unlock()
+
+	s.lock.RLock()
+	closed := s.closed
+	s.lock.RUnlock()
+	if closed {
+		return fmt.Errorf("profile store of %s is closed", s.dir)
+	} else if s.stop != nil {
+		return fmt.Errorf("profile directory %s is already watched", s.dir)
+	}
+
+	stop, err := s.registry.WatchDirInotify(s.dir)
+	if err != nil {
+		return err
+	}
+	s.stop = stop
+
+	return nil
+}
+
+// Close stops watching the directory and releases the templates of the
+// store. Filters returned by Filter are not affected.
+func (s *ProfileStore) Close() {
+	s.watchLock.Lock()
+	defer s.watchLock.Unlock()
+
+	if s.stop != nil {
+		s.stop()
+		s.stop = nil
+	}
+
+	// Wait for a compilation in progress
+	s.compileLock.Lock()
+	defer s.compileLock.Unlock()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	for name, entry := range s.entries {
+		entry.filter.Release()
+		delete(s.entries, name)
+	}
+}
+
+// Helper - Compile the profiles of the registry which changed since they
+// were last compiled, after a reload which failed with loadErr
+func (s *ProfileStore) compile(loadErr error) {
+	s.compileLock.Lock()
+	defer s.compileLock.Unlock()
+
+	s.lock.RLock()
+	closed := s.closed
+	current := make(map[string]string, len(s.entries))
+	for name, entry := range s.entries {
+		current[name] = entry.digest
+	}
+	s.lock.RUnlock()
+	if closed {
+		return
+	}
+
+	var errs []string
+	if e, ok := loadErr.(*profile.LoadError); ok {
+		errs = append(errs, e.Errors...)
+	} else if loadErr != nil {
+		errs = append(errs, loadErr.Error())
+	}
+	names := s.registry.Names()
+	seen := make(map[string]bool, len(names))
+	updated := make(map[string]*storeEntry)
+
+	for _, name := range names {
+		seen[name] = true
+
+		p, digest, ok := s.registry.Profile(name)
+		if !ok || current[name] == digest {
+			continue
+		}
+
+		filter, err := buildOCIFilter(p)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid profile: %v", filepath.Join(s.dir, name), err))
+			continue
+		}
+		updated[name] = &storeEntry{digest: digest, filter: filter}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for name, entry := range updated {
+		if old, ok := s.entries[name]; ok {
+			old.filter.Release()
+		}
+		s.entries[name] = entry
+	}
+	for name, entry := range s.entries {
+		if !seen[name] {
+			entry.filter.Release()
+			delete(s.entries, name)
+		}
+	}
+
+	s.reloads++
+	s.reloadErr = nil
+	if len(errs) > 0 {
+		s.reloadErr = &profile.LoadError{Errors: errs}
+		s.lastErr = s.reloadErr
+	}
+}
@@ -0,0 +1,110 @@
+// +build linux
+
+// Tests for the profile store of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProfileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seccomp-store")
+	if err != nil {
+		t.Fatalf("Error creating directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Error creating directory: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Error writing profile: %s", err)
+		}
+	}
+	writeFile("audit.json", `{"defaultAction": "SCMP_ACT_LOG"}`)
+	writeFile("profiles/deny.yaml", "defaultAction: SCMP_ACT_ERRNO\nsyscalls:\n  - names: [read]\n    action: SCMP_ACT_ALLOW\n")
+	writeFile("invalid.json", `{"defaultAction": "SCMP_ACT_BOGUS"}`)
+	writeFile(".hidden.json", `{"defaultAction": "SCMP_ACT_LOG"}`)
+	writeFile("README", "not a profile")
+
+	store, err := NewProfileStore(dir)
+	if err != nil {
+		t.Fatalf("Error creating profile store: %s", err)
+	}
+	defer store.Close()
+
+	if names := store.Names(); !reflect.DeepEqual(names, []string{"audit.json", "profiles/deny.yaml"}) {
+		t.Errorf("Got profiles %v", names)
+	}
+	if _, err := store.Reloads(); err == nil {
+		t.Errorf("Invalid profile should be reported")
+	}
+	if err := store.Reload(); err == nil {
+		t.Errorf("Reload should report the invalid profile")
+	}
+	if _, err := store.Filter("invalid.json"); err == nil {
+		t.Errorf("Invalid profile should not be served")
+	}
+
+	checkDefault := func(name string, want ScmpAction) {
+		filter, err := store.Filter(name)
+		if err != nil {
+			t.Fatalf("Error getting filter %q: %s", name, err)
+		}
+		defer filter.Release()
+
+		if action, err := filter.GetDefaultAction(); err != nil || action != want {
+			t.Errorf("Filter %q: got default action %s, want %s", name, action, want)
+		}
+	}
+	checkDefault("audit.json", ActLog)
+	checkDefault("profiles/deny.yaml", ActEPerm)
+
+	if err := store.Watch(); err != nil {
+		t.Fatalf("Error watching profile directory: %s", err)
+	}
+	if err := store.Watch(); err == nil {
+		t.Errorf("Watching twice should fail")
+	}
+
+	writeFile("audit.json", `{"defaultAction": "SCMP_ACT_TRAP"}`)
+	writeFile("new/nested.json", `{"defaultAction": "SCMP_ACT_LOG"}`)
+	if err := os.Remove(filepath.Join(dir, "profiles/deny.yaml")); err != nil {
+		t.Fatalf("Error removing profile: %s", err)
+	}
+
+	want := []string{"audit.json", "new/nested.json"}
+	deadline := time.Now().Add(5 * time.Second)
+	for !reflect.DeepEqual(store.Names(), want) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if names := store.Names(); !reflect.DeepEqual(names, want) {
+		t.Fatalf("Got profiles %v after changes, want %v", names, want)
+	}
+	for time.Now().Before(deadline) {
+		filter, err := store.Filter("audit.json")
+		if err != nil {
+			t.Fatalf("Error getting filter: %s", err)
+		}
+		action, _ := filter.GetDefaultAction()
+		filter.Release()
+		if action == ActTrap {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	checkDefault("audit.json", ActTrap)
+
+	store.Close()
+	if names := store.Names(); len(names) != 0 {
+		t.Errorf("Closed store should serve no profiles, got %v", names)
+	}
+}
@@ -0,0 +1,153 @@
+// +build linux
+
+// Syscall name suggestions for libseccomp Go bindings
+// Suggests known syscall names for misspelled ones
+
+package seccomp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Maximum number of suggestions of SuggestSyscalls
+const maxSyscallSuggestions = 3
+
+// UnknownSyscallError denotes a syscall name of a filter description which
+// could not be resolved on an architecture. It wraps ErrSyscallDoesNotExist,
+// and suggests the known syscall names closest to the name, so that typos in
+// hand-written profiles can be diagnosed, e.g. "openast" for "openat".
+// Syscall lookups such as GetSyscallFromName return ErrSyscallDoesNotExist
+// itself; see SuggestSyscalls.
+//
+// Name: the name which could not be resolved
+// Arch: the architecture the name was resolved on
+//
+type UnknownSyscallError struct {
+	Name string
+	Arch ScmpArch
+
+	once        sync.Once
+	suggestions []string
+}
+
+func (e *UnknownSyscallError) Error() string {
+	suggestions := e.Suggestions()
+	if len(suggestions) == 0 {
+		return fmt.Sprintf("%s %q", ErrSyscallDoesNotExist, e.Name)
+	}
+
+	quoted := make([]string, len(suggestions))
+	for i, name := range suggestions {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return fmt.Sprintf("%s %q, did you mean %s?", ErrSyscallDoesNotExist, e.Name,
+		strings.Join(quoted, " or "))
+}
+
+// Unwrap returns ErrSyscallDoesNotExist.
+func (e *UnknownSyscallError) Unwrap() error {
+	return ErrSyscallDoesNotExist
+}
+
+// Suggestions returns the suggestions of SuggestSyscalls for the unresolved
+// name on the architecture. Suggestions are computed on first use.
+func (e *UnknownSyscallError) Suggestions() []string {
+	e.once.Do(func() {
+		e.suggestions = SuggestSyscalls(e.Name, e.Arch)
+	})
+
+	return e.suggestions
+}
+
+// SuggestSyscalls returns the names of the syscalls known on an architecture
+// which are closest to name by edit distance, closest first, e.g. to suggest
+// "openat" when resolving "openast" failed with ErrSyscallDoesNotExist.
+// Returns nil if no known name is close, or if the architecture is invalid.
+func SuggestSyscalls(name string, arch ScmpArch) []string {
+	return suggestSyscalls(name, knownSyscallNames([]ScmpArch{arch}))
+}
+
+// Helper - Turn the error of resolving a syscall name of a filter description
+// on an architecture into an UnknownSyscallError if the name is unknown
+func syscallNameError(err error, name string, arch ScmpArch) error {
+	if err == ErrSyscallDoesNotExist {
+		return &UnknownSyscallError{Name: name, Arch: arch}
+	}
+	return fmt.Errorf("%v: %q", err, name)
+}
+
+// Helper - Pick the names closest to name by edit distance, allowing about
+// one edit per three characters
+func suggestSyscalls(name string, known []string) []string {
+	maxDistance := len(name) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for _, k := range known {
+		if d := editDistance(name, k); d <= maxDistance {
+			candidates = append(candidates, candidate{k, d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	var names []string
+	for i := 0; i < len(candidates) && i < maxSyscallSuggestions; i++ {
+		names = append(names, candidates[i].name)
+	}
+
+	return names
+}
+
+// Helper - Compute the edit distance between two strings, counting
+// insertions, deletions, substitutions and transpositions of adjacent bytes
+func editDistance(a, b string) int {
+	// Rows i-2, i-1 and i of the distance matrix
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && prev2[j-2]+1 < cur[j] {
+				cur[j] = prev2[j-2] + 1
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(b)]
+}
+
+// Helper - Get the smallest of three integers
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
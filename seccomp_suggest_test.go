@@ -0,0 +1,83 @@
+// +build linux
+
+// Tests for the syscall name suggestions of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"read", "read", 0},
+		{"", "read", 4},
+		{"openast", "openat", 1},
+		{"opne", "open", 1},
+		{"wirte", "write", 1},
+		{"mmap", "munmap", 2},
+		{"kitten", "sitting", 3},
+	} {
+		if got := editDistance(test.a, test.b); got != test.want {
+			t.Errorf("Edit distance of %q and %q: got %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := editDistance(test.b, test.a); got != test.want {
+			t.Errorf("Edit distance of %q and %q: got %d, want %d", test.b, test.a, got, test.want)
+		}
+	}
+}
+
+func TestSuggestSyscalls(t *testing.T) {
+	if _, err := GetSyscallFromNameByArch("openast", ArchAMD64); err != ErrSyscallDoesNotExist {
+		t.Errorf("Got error %v, want %v", err, ErrSyscallDoesNotExist)
+	}
+	if suggestions := SuggestSyscalls("openast", ArchAMD64); len(suggestions) == 0 || suggestions[0] != "openat" {
+		t.Errorf("Got suggestions %v, want openat first", suggestions)
+	}
+	if suggestions := SuggestSyscalls("NOTASYSCALL", ArchAMD64); suggestions != nil {
+		t.Errorf("Got suggestions %v for NOTASYSCALL", suggestions)
+	}
+
+	known := []string{"read", "readv", "pread64", "write"}
+	if got := suggestSyscalls("raed", known); !reflect.DeepEqual(got, []string{"read"}) {
+		t.Errorf("Got suggestions %v for raed", got)
+	}
+	if got := suggestSyscalls("readw", known); !reflect.DeepEqual(got, []string{"read", "readv"}) {
+		t.Errorf("Got suggestions %v for readw", got)
+	}
+}
+
+func TestUnknownSyscallError(t *testing.T) {
+	var err error = &UnknownSyscallError{Name: "openast", Arch: ArchAMD64}
+	if !errors.Is(err, ErrSyscallDoesNotExist) {
+		t.Errorf("Error should wrap ErrSyscallDoesNotExist")
+	}
+	if msg := err.Error(); !strings.Contains(msg, `did you mean "openat"`) {
+		t.Errorf("Got error message %q", msg)
+	}
+
+	err = &UnknownSyscallError{Name: "NOTASYSCALL", Arch: ArchAMD64}
+	if msg := err.Error(); msg != `could not resolve syscall name "NOTASYSCALL"` {
+		t.Errorf("Got error message %q", msg)
+	}
+
+	// Filter descriptions report their unknown syscall names once
+	_, err = (&Allowlist{Syscalls: []string{"read", "wirte"}}).Build()
+	var unknown *UnknownSyscallError
+	if !errors.As(err, &unknown) || unknown.Name != "wirte" {
+		t.Fatalf("Got error %v, want an UnknownSyscallError for wirte", err)
+	}
+	if suggestions := unknown.Suggestions(); len(suggestions) == 0 || suggestions[0] != "write" {
+		t.Errorf("Got suggestions %v, want write first", suggestions)
+	}
+	if strings.Count(err.Error(), "wirte") != 1 {
+		t.Errorf("Got error message %q", err)
+	}
+}
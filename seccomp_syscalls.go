@@ -0,0 +1,217 @@
+// +build linux
+
+// Supplemental syscall tables for libseccomp Go bindings
+// Resolves syscalls too recent for the linked libseccomp to know
+
+package seccomp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Supplemental syscall tables, per architecture
+var supplemental = struct {
+	lock   sync.RWMutex
+	byName map[ScmpArch]map[string]ScmpSyscall
+	byNum  map[ScmpArch]map[ScmpSyscall]string
+}{
+	byName: make(map[ScmpArch]map[string]ScmpSyscall),
+	byNum:  make(map[ScmpArch]map[ScmpSyscall]string),
+}
+
+// RegisterSyscall adds a supplemental mapping between the name of a syscall
+// and its number on architecture arch, e.g. for a syscall added to the kernel
+// after the release of the linked libseccomp. GetSyscallFromName,
+// GetSyscallFromNameByArch and the GetName functions fall back to the
+// supplemental mappings for syscalls libseccomp does not know; libseccomp's
+// own tables always take precedence.
+// As libseccomp translates rules between architectures by syscall name, rules
+// on syscalls only known through supplemental mappings can only apply to the
+// native architecture; see AddRuleRaw.
+// Returns an error if the name or number is invalid, or if the name or
+// number is already registered differently for the architecture.
+func RegisterSyscall(arch ScmpArch, name string, call ScmpSyscall) error {
+	if err := sanitizeArch(arch); err != nil {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("syscall name must not be empty")
+	}
+	if int32(call) < 0 {
+		return fmt.Errorf("invalid syscall number %d", int32(call))
+	}
+
+	arch, err := resolveNativeArch(arch)
+	if err != nil {
+		return err
+	}
+
+	supplemental.lock.Lock()
+	defer supplemental.lock.Unlock()
+
+	if supplemental.byName[arch] == nil {
+		supplemental.byName[arch] = make(map[string]ScmpSyscall)
+		supplemental.byNum[arch] = make(map[ScmpSyscall]string)
+	}
+
+	if old, ok := supplemental.byName[arch][name]; ok && old != call {
+		return fmt.Errorf("syscall %q is already registered as %d on %s", name, int32(old), arch)
+	}
+	if old, ok := supplemental.byNum[arch][call]; ok && old != name {
+		return fmt.Errorf("syscall number %d is already registered as %q on %s", int32(call), old, arch)
+	}
+
+	supplemental.byName[arch][name] = call
+	supplemental.byNum[arch][call] = name
+	// Lookups may have failed before the registration
+	clearSyscallCache()
+
+	return nil
+}
+
+// Helper - Remove a supplemental mapping added by RegisterSyscall, e.g. to
+// undo the registrations of tests
+func unregisterSyscall(arch ScmpArch, name string) {
+	arch, err := resolveNativeArch(arch)
+	if err != nil {
+		return
+	}
+
+	supplemental.lock.Lock()
+	defer supplemental.lock.Unlock()
+
+	if call, ok := supplemental.byName[arch][name]; ok {
+		delete(supplemental.byName[arch], name)
+		delete(supplemental.byNum[arch], call)
+	}
+	// Lookups may have succeeded through the mapping
+	clearSyscallCache()
+}
+
+// Helper - Get the syscall number ranges of an architecture, as [first, last]
+// pairs
+func syscallRanges(arch ScmpArch) [][2]ScmpSyscall {
+	const count = 1024
+
+	switch arch {
+	case ArchX32:
+		// __X32_SYSCALL_BIT
+		return [][2]ScmpSyscall{{0x40000000, 0x40000000 + count - 1}}
+	case ArchARM:
+		// Private ARM syscalls, such as cacheflush
+		return [][2]ScmpSyscall{{0, count - 1}, {0xf0000, 0xf00ff}}
+	case ArchMIPS, ArchMIPSEL:
+		return [][2]ScmpSyscall{{4000, 4000 + count - 1}}
+	case ArchMIPS64, ArchMIPSEL64:
+		return [][2]ScmpSyscall{{5000, 5000 + count - 1}}
+	case ArchMIPS64N32, ArchMIPSEL64N32:
+		return [][2]ScmpSyscall{{6000, 6000 + count - 1}}
+	default:
+		return [][2]ScmpSyscall{{0, count - 1}}
+	}
+}
+
+// ScmpSyscallInfo is a syscall known on an architecture.
+// Name: the name of the syscall
+// Syscall: the number of the syscall on the architecture
+//
+type ScmpSyscallInfo struct {
+	Name    string      `json:"name"`
+	Syscall ScmpSyscall `json:"syscall"`
+}
+
+// SyscallsForArch lists the syscalls libseccomp knows on an architecture,
+// along with those registered with RegisterSyscall, sorted by number. The
+// socket and IPC syscalls of architectures multiplexing them are listed with
+// their pseudo-syscall numbers, and also with their own numbers on the
+// architectures which have both (e.g., socket on ArchX86). ArchNative is
+// resolved to the native architecture of the kernel.
+// Returns an error if the architecture is invalid or unsupported.
+func SyscallsForArch(arch ScmpArch) ([]ScmpSyscallInfo, error) {
+	arch, err := resolveNativeArch(arch)
+	if err != nil {
+		return nil, err
+	}
+	if err := sanitizeArch(arch); err != nil {
+		return nil, err
+	}
+
+	var infos []ScmpSyscallInfo
+	for call := range pseudoSyscalls {
+		if name, err := call.GetNameByArch(arch); err == nil {
+			infos = append(infos, ScmpSyscallInfo{Name: name, Syscall: call})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Syscall < infos[j].Syscall })
+
+	for _, r := range syscallRanges(arch) {
+		for call := r[0]; call <= r[1]; call++ {
+			if name, err := call.GetNameByArch(arch); err == nil {
+				infos = append(infos, ScmpSyscallInfo{Name: name, Syscall: call})
+			}
+		}
+	}
+
+	return infos, nil
+}
+
+// Helper - List the names of the syscalls known on any of the given
+// architectures, in no particular order
+func knownSyscallNames(arches []ScmpArch) []string {
+	seen := make(map[string]bool)
+	var names []string
+
+	for _, arch := range arches {
+		infos, err := SyscallsForArch(arch)
+		if err != nil {
+			continue
+		}
+
+		for _, info := range infos {
+			if !seen[info.Name] {
+				seen[info.Name] = true
+				names = append(names, info.Name)
+			}
+		}
+	}
+
+	return names
+}
+
+// Helper - Resolve ArchNative to the actual native architecture
+func resolveNativeArch(arch ScmpArch) (ScmpArch, error) {
+	if arch != ArchNative {
+		return arch, nil
+	}
+	return GetNativeArch()
+}
+
+// Helper - Look a syscall name up in the supplemental tables
+func supplementalSyscall(arch ScmpArch, name string) (ScmpSyscall, bool) {
+	arch, err := resolveNativeArch(arch)
+	if err != nil {
+		return 0, false
+	}
+
+	supplemental.lock.RLock()
+	defer supplemental.lock.RUnlock()
+
+	call, ok := supplemental.byName[arch][name]
+	return call, ok
+}
+
+// Helper - Look a syscall number up in the supplemental tables
+func supplementalSyscallName(arch ScmpArch, call ScmpSyscall) (string, bool) {
+	arch, err := resolveNativeArch(arch)
+	if err != nil {
+		return "", false
+	}
+
+	supplemental.lock.RLock()
+	defer supplemental.lock.RUnlock()
+
+	name, ok := supplemental.byNum[arch][call]
+	return name, ok
+}
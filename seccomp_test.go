@@ -5,14 +5,22 @@
 package seccomp
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
-	"syscall"
 	"testing"
 	"time"
 	"unsafe"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
 )
 
 // execInSubprocess calls the go test binary again for the same test.
@@ -165,6 +173,81 @@ func subprocessSetAPILevel(t *testing.T) {
 	}
 }
 
+func TestAutoAPI(t *testing.T) {
+	execInSubprocess(t, subprocessAutoAPI)
+}
+func subprocessAutoAPI(t *testing.T) {
+	if hostAPI < 6 {
+		t.Skipf("Skipping test: host API level %d is less than 6", hostAPI)
+	}
+	if err := SetAPI(1); err != nil {
+		t.Fatalf("Error setting API level: %s", err)
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	if err := filter.SetLogBit(true); err != nil {
+		t.Errorf("Error setting log bit: %s", err)
+	}
+	if api, _ := GetAPI(); api != 3 {
+		t.Errorf("Got API level %d after setting the log bit, expected 3", api)
+	}
+	if api, err := filter.GetRequiredAPI(); err != nil || api != 3 {
+		t.Errorf("Got required API level %d (%v) with the log bit, expected 3", api, err)
+	}
+
+	// Clearing the log bit lowers the requirement
+	if err := filter.SetLogBit(false); err != nil {
+		t.Errorf("Error clearing log bit: %s", err)
+	}
+	if api, err := filter.GetRequiredAPI(); err != nil || api != 0 {
+		t.Errorf("Got required API level %d (%v) without the log bit, expected 0", api, err)
+	}
+
+	call, err := GetSyscallFromName("getppid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	if err := filter.AddRule(call, ActNotify); err != nil {
+		t.Errorf("Error adding notify rule: %s", err)
+	}
+	if api, _ := GetAPI(); api != 6 {
+		t.Errorf("Got API level %d after adding a notify rule, expected 6", api)
+	}
+	if api, err := filter.GetRequiredAPI(); err != nil || api != 6 {
+		t.Errorf("Got required API level %d (%v), expected 6", api, err)
+	}
+
+	// Pretend the host only supports level 3, features which could not be
+	// used are not required
+	hostAPI = 3
+	if err := SetAPI(3); err != nil {
+		t.Fatalf("Error setting API level: %s", err)
+	}
+	if err := filter.SetSSB(true); err == nil {
+		t.Errorf("SSB bit set on a host not supporting it")
+	}
+	if err := filter.SetLogBit(true); err != nil {
+		t.Errorf("Error setting log bit: %s", err)
+	}
+
+	err = filter.SetAutoAPI(true)
+	if err == nil {
+		t.Errorf("Automatic API level succeeded on a host not supporting it")
+	} else if msg := err.Error(); !strings.Contains(msg, "notify action (6)") || strings.Contains(msg, "SSB bit") ||
+		strings.Contains(msg, "log bit") {
+		t.Errorf("Error does not list exactly the unsupported features: %s", msg)
+	}
+}
+
 func TestActionSetReturnCode(t *testing.T) {
 	if ActInvalid.SetReturnCode(0x0010) != ActInvalid {
 		t.Errorf("Able to set a return code on invalid action!")
@@ -174,6 +257,68 @@ func TestActionSetReturnCode(t *testing.T) {
 	if codeSet == ActErrno || codeSet.GetReturnCode() != 0x0001 {
 		t.Errorf("Could not set return code on ActErrno")
 	}
+
+	errnoSet := ActErrno.SetErrno(unix.ENOSYS)
+	if errnoSet.GetErrno() != unix.ENOSYS || errnoSet.GetReturnCode() != int16(unix.ENOSYS) {
+		t.Errorf("Could not set errno on ActErrno")
+	}
+
+	if ActEPerm != ActErrno.SetErrno(unix.EPERM) || ActENoSys.GetErrno() != unix.ENOSYS {
+		t.Errorf("Preset errno actions do not match SetErrno")
+	}
+
+	var resp ScmpNotifResp
+	resp.SetErrno(unix.EPERM)
+	if resp.Error != int32(unix.EPERM) || resp.GetErrno() != unix.EPERM {
+		t.Errorf("Could not set errno on notification response")
+	}
+}
+
+func TestNotifTargetGone(t *testing.T) {
+	if ErrNotifTargetGone != unix.ENOENT {
+		t.Errorf("ErrNotifTargetGone is not ENOENT")
+	}
+
+	if err := NotifIDValid(ScmpFd(-1), 0); errors.Is(err, ErrNotifTargetGone) {
+		t.Errorf("Checking an invalid fd should not report a gone target: %v", err)
+	}
+}
+
+func TestNotifRespValidate(t *testing.T) {
+	valid := []ScmpNotifResp{
+		{Val: 3},
+		{Error: int32(unix.EPERM)},
+		{Flags: NotifRespFlagContinue},
+	}
+	for _, resp := range valid {
+		if err := resp.Validate(); err != nil {
+			t.Errorf("Valid response %+v rejected: %v", resp, err)
+		}
+	}
+
+	invalid := []ScmpNotifResp{
+		{Error: -1},
+		{Error: maxErrno + 1},
+		{Flags: NotifRespFlagContinue, Error: int32(unix.EPERM)},
+		{Flags: NotifRespFlagContinue, Val: 1},
+	}
+	for _, resp := range invalid {
+		if err := resp.Validate(); err == nil {
+			t.Errorf("Invalid response %+v accepted", resp)
+		}
+	}
+
+	future := ScmpNotifResp{Flags: 1 << 31}
+	if err := future.Validate(); err == nil {
+		t.Errorf("Response with unknown flag accepted")
+	}
+	future.AllowedFlags = 1 << 31
+	if err := future.Validate(); err != nil {
+		t.Errorf("Response with allowed flag rejected: %v", err)
+	}
+	if err := (ScmpNotifResp{Flags: 1 << 30, AllowedFlags: 1 << 31}).Validate(); err == nil {
+		t.Errorf("Response with unknown flag outside of allowed flags accepted")
+	}
 }
 
 func TestSyscallGetName(t *testing.T) {
@@ -223,6 +368,38 @@ func TestSyscallGetNameByArch(t *testing.T) {
 	}
 }
 
+func TestSyscallExistsOnArch(t *testing.T) {
+	for _, test := range []struct {
+		call   ScmpSyscall
+		arch   ScmpArch
+		exists bool
+	}{
+		// write
+		{1, ArchAMD64, true},
+		{4, ArchX86, true},
+		// arch_prctl
+		{158, ArchAMD64, true},
+		{-10001, ArchARM, false},
+		{PseudoSocket, ArchX86, true},
+		{PseudoSocket, ArchAMD64, false},
+		{4095, ArchAMD64, false},
+		{1, ArchInvalid, false},
+	} {
+		if got := test.call.ExistsOnArch(test.arch); got != test.exists {
+			t.Errorf("Syscall %d on %s: got %t, want %t", test.call, test.arch, got, test.exists)
+		}
+	}
+
+	call, err := GetSyscallFromName("arch_prctl")
+	if err != nil {
+		t.Fatalf("Error getting syscall number of arch_prctl: %s", err)
+	}
+	// arch_prctl is missing on some architectures
+	if call.ExistsOnArch(ArchNative) == call.IsPseudo() {
+		t.Errorf("Native arch_prctl %d should exist unless it is a pseudo-syscall", call)
+	}
+}
+
 func TestGetSyscallFromName(t *testing.T) {
 	name1 := "write"
 	nameInval := "NOTASYSCALL"
@@ -267,6 +444,188 @@ func TestGetSyscallFromNameByArch(t *testing.T) {
 	}
 }
 
+func TestGetSyscallFromNameRewrite(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		arch ScmpArch
+		want ScmpSyscall
+	}{
+		{"socket", ArchX86, 102},
+		{"semop", ArchX86, 117},
+		{"socket", ArchAMD64, 41},
+		{"write", ArchX86, 4},
+	} {
+		if got, err := GetSyscallFromNameRewrite(test.name, test.arch); err != nil || got != test.want {
+			t.Errorf("Got %s rewritten to %d, %v on %s, want %d", test.name, got, err, test.arch, test.want)
+		}
+	}
+
+	if _, err := GetSyscallFromNameRewrite("NOTASYSCALL", ArchX86); err == nil {
+		t.Errorf("Getting invalid syscall should error")
+	}
+	if _, err := GetSyscallFromNameRewrite("socket", ArchInvalid); err == nil {
+		t.Errorf("Getting valid syscall for invalid arch should error")
+	}
+}
+
+func TestTranslateSyscall(t *testing.T) {
+	for _, test := range []struct {
+		call     ScmpSyscall
+		from, to ScmpArch
+		want     ScmpSyscall
+	}{
+		// write
+		{1, ArchAMD64, ArchX86, 4},
+		{4, ArchX86, ArchARM64, 64},
+		{64, ArchARM64, ArchAMD64, 1},
+		// openat
+		{257, ArchAMD64, ArchARM, 322},
+		{257, ArchAMD64, ArchAMD64, 257},
+		// socket, multiplexed by socketcall on x86
+		{41, ArchAMD64, ArchX86, PseudoSocket},
+		{PseudoSocket, ArchX86, ArchAMD64, 41},
+	} {
+		got, err := TranslateSyscall(test.call, test.from, test.to)
+		if err != nil {
+			t.Errorf("Error translating syscall %d from %s to %s: %s", test.call, test.from, test.to, err)
+		} else if got != test.want {
+			t.Errorf("Syscall %d on %s translated to %d on %s, want %d", test.call, test.from, got, test.to, test.want)
+		}
+	}
+
+	// arch_prctl does not exist on ARM
+	if _, err := TranslateSyscall(158, ArchAMD64, ArchARM); err != ErrSyscallDoesNotExist {
+		t.Errorf("Translating a syscall missing on the target architecture should fail: %v", err)
+	}
+	if _, err := TranslateSyscall(4095, ArchAMD64, ArchX86); err == nil {
+		t.Errorf("Translating an unknown syscall should fail")
+	}
+	if _, err := TranslateSyscall(1, ArchAMD64, ArchInvalid); err == nil {
+		t.Errorf("Translating to an invalid architecture should fail")
+	}
+	if _, err := TranslateSyscall(1, ArchInvalid, ArchAMD64); err == nil {
+		t.Errorf("Translating from an invalid architecture should fail")
+	}
+
+	call, err := GetSyscallFromName("write")
+	if err != nil {
+		t.Fatalf("Error getting syscall number of write: %s", err)
+	}
+	if got, err := TranslateSyscall(call, ArchNative, ArchAMD64); err != nil || got != 1 {
+		t.Errorf("Got native write translated to %d, %v on AMD64", got, err)
+	}
+}
+
+func TestSyscallsForArch(t *testing.T) {
+	for _, arch := range []ScmpArch{ArchAMD64, ArchX86, ArchARM, ArchX32, ArchMIPSEL64N32} {
+		infos, err := SyscallsForArch(arch)
+		if err != nil {
+			t.Errorf("Error listing syscalls of %s: %s", arch, err)
+			continue
+		}
+		if len(infos) < 200 {
+			t.Errorf("Got only %d syscalls on %s", len(infos), arch)
+		}
+
+		names := make(map[string]bool)
+		for i, info := range infos {
+			if i > 0 && info.Syscall <= infos[i-1].Syscall {
+				t.Errorf("Syscalls of %s are not sorted at %d", arch, info.Syscall)
+			}
+			if call, err := GetSyscallFromNameByArch(info.Name, arch); err != nil {
+				t.Errorf("Error resolving %s on %s: %s", info.Name, arch, err)
+			} else if call != info.Syscall && !call.IsPseudo() && !info.Syscall.IsPseudo() {
+				t.Errorf("Got %s as %d on %s, resolved as %d", info.Name, info.Syscall, arch, call)
+			}
+			names[info.Name] = true
+		}
+		if !names["read"] || !names["exit_group"] {
+			t.Errorf("Syscalls of %s lack read or exit_group", arch)
+		}
+	}
+
+	infos, err := SyscallsForArch(ArchX86)
+	if err != nil {
+		t.Fatalf("Error listing syscalls of x86: %s", err)
+	}
+	if infos[0] != (ScmpSyscallInfo{Name: "shmctl", Syscall: PseudoShmctl}) {
+		t.Errorf("Got first syscall %v on x86, want the pseudo-syscall of shmctl", infos[0])
+	}
+
+	if _, err := SyscallsForArch(ArchInvalid); err == nil {
+		t.Errorf("Listing the syscalls of an invalid architecture should fail")
+	}
+}
+
+func TestRegisterSyscall(t *testing.T) {
+	native, err := GetNativeArch()
+	if err != nil {
+		t.Fatalf("Error getting native arch: %s", err)
+	}
+
+	// No syscall has this number or name
+	const call = ScmpSyscall(4001)
+	const name = "supplemental_test_syscall"
+
+	if err := RegisterSyscall(ArchNative, name, call); err != nil {
+		t.Fatalf("Error registering syscall: %s", err)
+	}
+	defer unregisterSyscall(native, name)
+	if err := RegisterSyscall(native, name, call); err != nil {
+		t.Errorf("Error registering the same syscall again: %s", err)
+	}
+	if err := RegisterSyscall(native, name, call+1); err == nil {
+		t.Errorf("Registered syscall under a second number")
+	}
+	if err := RegisterSyscall(native, "", call+1); err == nil {
+		t.Errorf("Registered syscall without name")
+	}
+
+	if got, err := GetSyscallFromName(name); err != nil || got != call {
+		t.Errorf("Got %d (%v) for supplemental syscall, expected %d", got, err, call)
+	}
+	if got, err := GetSyscallFromNameByArch(name, native); err != nil || got != call {
+		t.Errorf("Got %d (%v) for supplemental syscall on %s, expected %d", got, err, native, call)
+	}
+	if got, err := call.GetName(); err != nil || got != name {
+		t.Errorf("Got %q (%v) for supplemental syscall number, expected %q", got, err, name)
+	}
+
+	other := ArchX86
+	if native == ArchX86 {
+		other = ArchAMD64
+	}
+	if _, err := GetSyscallFromNameByArch(name, other); err == nil {
+		t.Errorf("Supplemental syscall resolved on %s", other)
+	}
+
+	// libseccomp's own tables take precedence
+	if err := RegisterSyscall(native, "getpid", call+2); err != nil {
+		t.Errorf("Error registering known syscall: %s", err)
+	}
+	defer unregisterSyscall(native, "getpid")
+	if got, _ := GetSyscallFromName("getpid"); got == call+2 {
+		t.Errorf("Supplemental mapping overrides libseccomp")
+	}
+
+	p := &OCISeccomp{
+		DefaultAction: "SCMP_ACT_ALLOW",
+		Syscalls:      []OCISyscall{{Names: []string{name}, Action: "SCMP_ACT_ERRNO"}},
+	}
+	filter, err := buildOCIFilter(p)
+	if err != nil {
+		t.Errorf("Error building profile using supplemental syscall: %s", err)
+	} else {
+		filter.Release()
+	}
+
+	p.Architectures = []string{"SCMP_ARCH_X86", "SCMP_ARCH_X86_64"}
+	if filter, err := buildOCIFilter(p); err == nil {
+		filter.Release()
+		t.Errorf("Built multi-arch profile using supplemental syscall")
+	}
+}
+
 func TestMakeCondition(t *testing.T) {
 	condition, err := MakeCondition(3, CompareNotEqual, 0x10)
 	if err != nil {
@@ -339,6 +698,35 @@ func TestFilterCreateRelease(t *testing.T) {
 	}
 }
 
+func TestFilterClose(t *testing.T) {
+	filter, err := NewFilter(ActKill)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	filter.DisableFinalizer()
+
+	var closer io.Closer = filter
+	if err := closer.Close(); err != nil {
+		t.Errorf("Error closing filter: %s", err)
+	}
+	if err := closer.Close(); err != nil {
+		t.Errorf("Error closing filter twice: %s", err)
+	}
+
+	if filter.IsValid() {
+		t.Errorf("Filter is valid after being closed")
+	}
+	if err := filter.AddRule(0, ActAllow); err != ErrInvalidFilter {
+		t.Errorf("Adding a rule to a closed filter returned %v, expected ErrInvalidFilter", err)
+	}
+	if _, err := filter.GetDefaultAction(); err != ErrInvalidFilter {
+		t.Errorf("Getting the default action of a closed filter returned %v, expected ErrInvalidFilter", err)
+	}
+	if err := filter.Load(); err != ErrInvalidFilter {
+		t.Errorf("Loading a closed filter returned %v, expected ErrInvalidFilter", err)
+	}
+}
+
 func TestFilterReset(t *testing.T) {
 	filter, err := NewFilter(ActKill)
 	if err != nil {
@@ -374,6 +762,46 @@ func TestFilterReset(t *testing.T) {
 	}
 }
 
+func TestFilterResetKeepArches(t *testing.T) {
+	native, err := GetNativeArch()
+	if err != nil {
+		t.Fatalf("Error getting native arch: %s", err)
+	}
+	other := ArchX86
+	if native == ArchX86 {
+		other = ArchAMD64
+	}
+
+	filter, err := NewFilterWithArches(ActKillThread, other)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.AddRule(0, ActAllow); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	if err := filter.ResetKeepArches(ActTrap); err != nil {
+		t.Fatalf("Error resetting filter: %s", err)
+	}
+
+	if act, err := filter.GetDefaultAction(); err != nil {
+		t.Errorf("Error getting default action: %s", err)
+	} else if act != ActTrap {
+		t.Errorf("Default action was not reset")
+	}
+	if present, err := filter.IsArchPresent(other); err != nil || !present {
+		t.Errorf("Architecture was not kept: %v, %v", present, err)
+	}
+	if present, err := filter.IsArchPresent(native); err != nil || present {
+		t.Errorf("Native architecture was added: %v, %v", present, err)
+	}
+	if rules := filter.ListRules(); rules != nil {
+		t.Errorf("Rules were not reset: %v", rules)
+	}
+}
+
 func TestFilterArchFunctions(t *testing.T) {
 	filter, err := NewFilter(ActKill)
 	if err != nil {
@@ -450,6 +878,52 @@ func TestFilterArchFunctions(t *testing.T) {
 	}
 }
 
+func TestNewFilterWithArches(t *testing.T) {
+	native, err := GetNativeArch()
+	if err != nil {
+		t.Fatalf("Error getting native arch: %s", err)
+	}
+	other := ArchX86
+	if native == ArchX86 {
+		other = ArchAMD64
+	}
+
+	if _, err := NewFilterWithArches(ActAllow); err == nil {
+		t.Errorf("Creating a filter without architectures should fail")
+	}
+	if _, err := NewFilterWithArches(ActAllow, other, ArchInvalid); err == nil {
+		t.Errorf("Creating a filter with an invalid architecture should fail")
+	}
+
+	for _, test := range []struct {
+		arches []ScmpArch
+		native bool
+		other  bool
+	}{
+		{[]ScmpArch{other}, false, true},
+		{[]ScmpArch{ArchNative}, true, false},
+		{[]ScmpArch{native, other}, true, true},
+	} {
+		filter, err := NewFilterWithArches(ActAllow, test.arches...)
+		if err != nil {
+			t.Fatalf("Error creating filter for %v: %s", test.arches, err)
+		}
+
+		if present, err := filter.IsArchPresent(native); err != nil {
+			t.Errorf("Error checking native architecture: %s", err)
+		} else if present != test.native {
+			t.Errorf("Filter for %v: native architecture present is %v", test.arches, present)
+		}
+		if present, err := filter.IsArchPresent(other); err != nil {
+			t.Errorf("Error checking architecture: %s", err)
+		} else if present != test.other {
+			t.Errorf("Filter for %v: %s present is %v", test.arches, other, present)
+		}
+
+		filter.Release()
+	}
+}
+
 func TestFilterAttributeGettersAndSetters(t *testing.T) {
 	filter, err := NewFilter(ActKill)
 	if err != nil {
@@ -488,6 +962,49 @@ func TestFilterAttributeGettersAndSetters(t *testing.T) {
 		t.Errorf("No new privileges bit was not set correctly")
 	}
 
+	if err := filter.SetTsync(false); err != nil {
+		t.Errorf("Error clearing TSYNC: %s", err)
+	}
+	if tsync, err := filter.GetTsync(); err != nil {
+		t.Errorf("Error getting TSYNC: %s", err)
+	} else if tsync {
+		t.Errorf("TSYNC was not cleared")
+	}
+
+	if err := filter.SetTsync(true); err != nil {
+		t.Logf("Ignoring failure: %s\n", err)
+	} else if tsync, err := filter.GetTsync(); err != nil {
+		t.Errorf("Error getting TSYNC: %s", err)
+	} else if !tsync {
+		t.Errorf("TSYNC was not set correctly")
+	}
+
+	if checkVersionAbove(2, 5, 0) {
+		if err := filter.SetRawRC(true); err != nil {
+			t.Errorf("Error setting raw return codes: %s", err)
+		}
+		if rawrc, err := filter.GetRawRC(); err != nil {
+			t.Errorf("Error getting raw return codes: %s", err)
+		} else if !rawrc {
+			t.Errorf("Raw return codes were not set correctly")
+		}
+	} else if err := filter.SetRawRC(true); err == nil {
+		t.Errorf("Setting raw return codes should fail before libseccomp v2.5.0")
+	}
+
+	if checkVersionAbove(2, 6, 0) {
+		if err := filter.SetWaitKill(false); err != nil {
+			t.Errorf("Error clearing wait killable notifications: %s", err)
+		}
+		if waitKill, err := filter.GetWaitKill(); err != nil {
+			t.Errorf("Error getting wait killable notifications: %s", err)
+		} else if waitKill {
+			t.Errorf("Wait killable notifications were not cleared")
+		}
+	} else if _, err := filter.GetWaitKill(); err == nil {
+		t.Errorf("Getting wait killable notifications should fail before libseccomp v2.6.0")
+	}
+
 	if APILevelIsSupported() {
 		api, err := GetAPI()
 		if err != nil {
@@ -558,6 +1075,64 @@ func TestFilterAttributeGettersAndSetters(t *testing.T) {
 	}
 }
 
+func TestFilterGenericAttributes(t *testing.T) {
+	for _, test := range []struct {
+		attr     ScmpFilterAttr
+		internal scmpFilterAttr
+	}{
+		{AttrActDefault, filterAttrActDefault},
+		{AttrActBadArch, filterAttrActBadArch},
+		{AttrNNP, filterAttrNNP},
+		{AttrTsync, filterAttrTsync},
+		{AttrLog, filterAttrLog},
+		{AttrSSB, filterAttrSSB},
+		{AttrOptimize, filterAttrOptimize},
+		{AttrRawRC, filterAttrRawRC},
+		{AttrWaitKill, filterAttrWaitKill},
+	} {
+		// Attributes missing from the linked libseccomp have no value
+		if native := test.internal.toNative(); native != 0 && native != uint32(test.attr) {
+			t.Errorf("%s has value %d, libseccomp uses %d", test.attr, test.attr, native)
+		}
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if value, err := filter.GetAttr(AttrActDefault); err != nil {
+		t.Errorf("Error getting default action: %s", err)
+	} else if value != uint(ActAllow.toNative()) {
+		t.Errorf("Default action was returned incorrectly: %#x", value)
+	}
+	if err := filter.SetAttr(AttrActDefault, 0); err == nil {
+		t.Errorf("Setting the read-only default action should fail")
+	}
+
+	if err := filter.SetAttr(AttrNNP, 0); err != nil {
+		t.Errorf("Error setting no new privileges bit: %s", err)
+	}
+	if privs, err := filter.GetNoNewPrivsBit(); err != nil {
+		t.Errorf("Error getting no new privileges bit: %s", err)
+	} else if privs {
+		t.Errorf("No new privileges bit was not set correctly")
+	}
+	if value, err := filter.GetAttr(AttrNNP); err != nil {
+		t.Errorf("Error getting no new privileges bit: %s", err)
+	} else if value != 0 {
+		t.Errorf("No new privileges bit was returned incorrectly")
+	}
+
+	if _, err := filter.GetAttr(AttrInvalid); err == nil {
+		t.Errorf("Getting an invalid attribute should fail")
+	}
+	if _, err := filter.GetAttr(ScmpFilterAttr(1000)); err == nil {
+		t.Errorf("Getting an unknown attribute should fail")
+	}
+}
+
 func TestMergeFilters(t *testing.T) {
 	filter1, err := NewFilter(ActAllow)
 	if err != nil {
@@ -609,6 +1184,102 @@ func TestMergeFilters(t *testing.T) {
 	err = filter1.Merge(filter3)
 	if err == nil {
 		t.Errorf("Attributes should have to match to merge filters")
+	} else if mergeErr, ok := err.(*MergeError); !ok || mergeErr.Attr != AttrActDefault {
+		t.Errorf("Expected a MergeError naming the default action, got %v", err)
+	}
+
+	filter4, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Errorf("Error creating filter: %s", err)
+	}
+	defer filter4.Release()
+
+	err = filter1.Merge(filter4)
+	if mergeErr, ok := err.(*MergeError); !ok || mergeErr.Arch != nativeArch {
+		t.Errorf("Expected a MergeError naming architecture %s, got %v", nativeArch, err)
+	}
+	if !filter4.IsValid() {
+		t.Errorf("Source filter should stay valid after a failed merge")
+	}
+}
+
+func TestFilterClone(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: filter program is checked for amd64")
+	}
+	const (
+		auditArchX86_64 = 0xc000003e
+		auditArchI386   = 0x40000003
+	)
+
+	filter, err := NewFilter(ActENoSys)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.AddArch(ArchX86); err != nil {
+		t.Fatalf("Error adding architecture: %s", err)
+	}
+	if err := filter.SetBadArchAction(ActTrap); err != nil {
+		t.Fatalf("Error setting bad arch action: %s", err)
+	}
+	if err := filter.SetNoNewPrivsBit(false); err != nil {
+		t.Fatalf("Error setting no new privileges bit: %s", err)
+	}
+	if err := filter.AddRule(unix.SYS_GETPID, ActAllow); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+	// socketcall() only exists on x86
+	if err := filter.AddRuleForArch(ArchX86, 102, ActEPerm, nil); err != nil {
+		t.Fatalf("Error adding rule for x86: %s", err)
+	}
+
+	clone, err := filter.Clone()
+	if err != nil {
+		t.Fatalf("Error cloning filter: %s", err)
+	}
+	defer clone.Release()
+
+	if err := clone.AddRule(unix.SYS_GETPPID, ActAllow); err != nil {
+		t.Fatalf("Error adding rule to clone: %s", err)
+	}
+
+	if act, err := clone.GetBadArchAction(); err != nil || act != ActTrap {
+		t.Errorf("Bad arch action was not cloned: %v, %v", act, err)
+	}
+	if privs, err := clone.GetNoNewPrivsBit(); err != nil || privs {
+		t.Errorf("No new privileges bit was not cloned: %v, %v", privs, err)
+	}
+	if present, err := clone.IsArchPresent(ArchX86); err != nil || !present {
+		t.Errorf("Architecture was not cloned: %v, %v", present, err)
+	}
+
+	enosys := bpf.RetErrno | uint32(unix.ENOSYS)
+	for _, test := range []struct {
+		data  bpf.Data
+		want  uint32
+		clone uint32
+	}{
+		{bpf.Data{Nr: unix.SYS_GETPID, Arch: auditArchX86_64}, bpf.RetAllow, bpf.RetAllow},
+		{bpf.Data{Nr: unix.SYS_GETPPID, Arch: auditArchX86_64}, enosys, bpf.RetAllow},
+		{bpf.Data{Nr: 102, Arch: auditArchI386}, bpf.RetErrno | uint32(unix.EPERM), bpf.RetErrno | uint32(unix.EPERM)},
+		{bpf.Data{Nr: 102, Arch: auditArchX86_64}, enosys, enosys},
+		{bpf.Data{Nr: 0, Arch: 0xc00000b7}, bpf.RetTrap, bpf.RetTrap},
+	} {
+		for _, f := range []struct {
+			filter *ScmpFilter
+			want   uint32
+		}{{filter, test.want}, {clone, test.clone}} {
+			ret, err := bpf.Run(exportProgram(t, f.filter), &test.data)
+			if err != nil {
+				t.Fatalf("Error running exported filter: %s", err)
+			}
+			if ret != f.want {
+				t.Errorf("Syscall %d on %#x: got %s, want %s", test.data.Nr, test.data.Arch,
+					bpf.ActionString(ret), bpf.ActionString(f.want))
+			}
+		}
 	}
 }
 
@@ -638,8 +1309,8 @@ func subprocessRuleAddAndLoad(t *testing.T) {
 		t.Errorf("Error getting syscall number of setreuid32: %s", err)
 	}
 
-	uid := syscall.Getuid()
-	euid := syscall.Geteuid()
+	uid := unix.Getuid()
+	euid := unix.Geteuid()
 
 	err = filter1.AddRule(call, ActErrno.SetReturnCode(0x1))
 	if err != nil {
@@ -674,29 +1345,532 @@ func subprocessRuleAddAndLoad(t *testing.T) {
 	}
 
 	// Try making a simple syscall, it should error
-	pid := syscall.Getpid()
+	pid := unix.Getpid()
 	if pid != -1 {
 		t.Errorf("Syscall should have returned error code!")
 	}
 
 	// Try making a Geteuid syscall that should normally succeed
-	err = syscall.Setreuid(uid, euid)
+	err = unix.Setreuid(uid, euid)
 	if err == nil {
 		t.Errorf("Syscall should have returned error code!")
-	} else if err != syscall.Errno(2) && err != syscall.Errno(3) {
+	} else if err != unix.Errno(2) && err != unix.Errno(3) {
 		t.Errorf("Syscall returned incorrect error code - likely not blocked by Seccomp!")
 	}
 }
 
-func TestLogAct(t *testing.T) {
-	execInSubprocess(t, subprocessLogAct)
+func TestRuleAddRaw(t *testing.T) {
+	execInSubprocess(t, subprocessRuleAddRaw)
 }
-func subprocessLogAct(t *testing.T) {
-	expectedPid := syscall.Getpid()
-
-	api, err := GetAPI()
+func subprocessRuleAddRaw(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
 	if err != nil {
-		if !APILevelIsSupported() {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	// No syscall has this number, so it cannot be resolved by name
+	const unknown = ScmpSyscall(4000)
+
+	if err := filter.AddRuleRaw(ArchNative, -1, ActErrno); err == nil {
+		t.Errorf("Raw rule on pseudo-syscall added without opt-in")
+	}
+
+	native, err := GetNativeArch()
+	if err != nil {
+		t.Fatalf("Error getting native arch: %s", err)
+	}
+	other := ArchX86
+	if native == ArchX86 {
+		other = ArchAMD64
+	}
+
+	if err := filter.AddRuleRaw(other, unknown, ActErrno); err == nil {
+		t.Errorf("Raw rule for non-native architecture added")
+	}
+
+	// The rule only applies to the native architecture of filters with
+	// several architectures
+	if err := filter.AddArch(other); err != nil {
+		t.Fatalf("Error adding architecture: %s", err)
+	}
+	if err := filter.AddRuleRaw(native, unknown, ActErrno.SetErrno(unix.EACCES)); err != nil {
+		t.Fatalf("Error adding raw rule: %s", err)
+	}
+	rules := filter.ListRules()
+	if len(rules) != 1 || rules[0].Arch != native || rules[0].Syscall != unknown {
+		t.Errorf("Raw rule tracked as %+v, expected a rule on syscall %d of %s", rules, unknown, native)
+	}
+
+	if err := filter.Load(); err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	if _, _, errno := unix.RawSyscall(uintptr(unknown), 0, 0, 0); errno != unix.EACCES {
+		t.Errorf("Raw syscall returned %v, expected %v", errno, unix.EACCES)
+	}
+}
+
+func TestLoadWithFlags(t *testing.T) {
+	execInSubprocess(t, subprocessLoadWithFlags)
+}
+func subprocessLoadWithFlags(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActErrno.SetErrno(unix.EACCES)); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	if err := filter.LoadWithFlags(LoadFlagTsync | LoadFlagNewListener); err != unix.EINVAL {
+		t.Errorf("Loading with TSYNC and a listener but without ESRCH returned %v, expected %v",
+			err, unix.EINVAL)
+	}
+
+	if err := filter.LoadWithFlags(LoadFlagTsync | LoadFlagTsyncESRCH | LoadFlagNewListener); err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	if _, _, errno := unix.RawSyscall(unix.SYS_GETPPID, 0, 0, 0); errno != unix.EACCES {
+		t.Errorf("Syscall returned %v, expected %v", errno, unix.EACCES)
+	}
+
+	if !APILevelIsSupported() {
+		return
+	}
+	if api, err := GetAPI(); err != nil || api < 6 {
+		return
+	}
+	fd, err := filter.GetNotifFd()
+	if err != nil {
+		t.Fatalf("Error getting notification fd: %s", err)
+	}
+	defer unix.Close(int(fd))
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0); err != nil {
+		t.Errorf("Notification fd %d is invalid: %s", fd, err)
+	}
+
+	// The caller owns the fd, which outlives the filter
+	filter.Release()
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0); err != nil {
+		t.Errorf("Releasing the filter closed its notification fd: %s", err)
+	}
+}
+
+func TestLoadTsyncError(t *testing.T) {
+	execInSubprocess(t, subprocessLoadTsyncError)
+}
+func subprocessLoadTsyncError(t *testing.T) {
+	// Load a filter on a single thread, which then cannot be synchronized
+	tids := make(chan int)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		filter, err := NewFilter(ActAllow)
+		if err != nil {
+			t.Errorf("Error creating filter: %s", err)
+			close(tids)
+			return
+		}
+		defer filter.Release()
+		if err := filter.LoadWithFlags(0); err != nil {
+			t.Errorf("Error loading filter on a single thread: %s", err)
+			close(tids)
+			return
+		}
+
+		tids <- unix.Gettid()
+		<-done
+	}()
+	tid, ok := <-tids
+	if !ok {
+		t.FailNow()
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetTsync(true); err != nil {
+		t.Skipf("Skipping test: %s", err)
+	}
+
+	err = filter.Load()
+	if tsyncErr, ok := err.(*TsyncError); !ok {
+		t.Errorf("Load returned %v, expected a TsyncError", err)
+	} else if tsyncErr.ThreadID != tid {
+		t.Errorf("Load reported thread %d, expected %d", tsyncErr.ThreadID, tid)
+	}
+}
+
+func TestLoadOnCurrentThread(t *testing.T) {
+	execInSubprocess(t, subprocessLoadOnCurrentThread)
+}
+func subprocessLoadOnCurrentThread(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActErrno.SetErrno(unix.EACCES)); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	tsync, err := filter.GetTsync()
+	if err != nil {
+		t.Fatalf("Error getting TSYNC: %s", err)
+	}
+
+	confined := make(chan error)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		if err := filter.LoadOnCurrentThread(); err != nil {
+			confined <- err
+			return
+		}
+		_, _, errno := unix.RawSyscall(unix.SYS_GETPPID, 0, 0, 0)
+		confined <- errno
+		<-done
+	}()
+	if err := <-confined; err != unix.EACCES {
+		t.Errorf("Syscall on confined thread returned %v, expected %v", err, unix.EACCES)
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if _, _, errno := unix.RawSyscall(unix.SYS_GETPPID, 0, 0, 0); errno != 0 {
+		t.Errorf("Syscall on another thread returned %v", errno)
+	}
+
+	if restored, err := filter.GetTsync(); err != nil {
+		t.Errorf("Error getting TSYNC: %s", err)
+	} else if restored != tsync {
+		t.Errorf("TSYNC was not restored after loading")
+	}
+}
+
+func TestLoadAndGetNotifFd(t *testing.T) {
+	execInSubprocess(t, subprocessLoadAndGetNotifFd)
+}
+func subprocessLoadAndGetNotifFd(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	if _, err := filter.LoadAndGetNotifFd(); err == nil {
+		t.Errorf("Loading a filter without ActNotify rules returned a notification fd")
+	}
+
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+	defer unix.Close(int(fd))
+
+	if getFd, err := filter.GetNotifFd(); err != nil {
+		t.Errorf("Error getting notification fd: %s", err)
+	} else if getFd != fd {
+		t.Errorf("GetNotifFd returned %d, expected %d", getFd, fd)
+	}
+
+	file, err := filter.GetNotifFile()
+	if err != nil {
+		t.Fatalf("Error getting notification file: %s", err)
+	}
+	if int(file.Fd()) == int(fd) {
+		t.Errorf("GetNotifFile returned the filter's fd instead of a duplicate")
+	}
+	if flags, err := unix.FcntlInt(file.Fd(), unix.F_GETFD, 0); err != nil {
+		t.Errorf("Error getting fd flags: %s", err)
+	} else if flags&unix.FD_CLOEXEC == 0 {
+		t.Errorf("Notification file is not close-on-exec")
+	}
+	if err := file.Close(); err != nil {
+		t.Errorf("Error closing notification file: %s", err)
+	}
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0); err != nil {
+		t.Errorf("Closing the notification file closed the filter's fd: %s", err)
+	}
+}
+
+func TestExportPFC(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	call, err := GetSyscallFromName("getpid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	if err := filter.AddRule(call, ActEPerm); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	var pfc bytes.Buffer
+	if err := filter.ExportPFC(&pfc); err != nil {
+		t.Fatalf("Error exporting filter: %s", err)
+	}
+
+	for _, want := range []string{`filter for syscall "getpid"`, "action ERRNO(1);", "action ALLOW;"} {
+		if !strings.Contains(pfc.String(), want) {
+			t.Errorf("Exported filter does not contain %q:\n%s", want, pfc.String())
+		}
+	}
+}
+
+func TestExportBPF(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	call, err := GetSyscallFromName("getpid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	if err := filter.AddRule(call, ActEPerm); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	var prog bytes.Buffer
+	if err := filter.ExportBPF(&prog); err != nil {
+		t.Fatalf("Error exporting filter: %s", err)
+	}
+	if prog.Len() == 0 || prog.Len()%8 != 0 {
+		t.Errorf("Exported program has invalid length %d", prog.Len())
+	}
+
+	file, err := ioutil.TempFile("", "seccomp-bpf")
+	if err != nil {
+		t.Fatalf("Error creating temporary file: %s", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := filter.ExportBPF(file); err != nil {
+		t.Fatalf("Error exporting filter to a file: %s", err)
+	}
+	data, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatalf("Error reading exported filter: %s", err)
+	}
+	if !bytes.Equal(data, prog.Bytes()) {
+		t.Errorf("Program exported to a file differs from the one exported to a buffer")
+	}
+
+	mem, err := filter.ExportBPFMem()
+	if err != nil {
+		t.Fatalf("Error exporting filter to memory: %s", err)
+	}
+	if !bytes.Equal(mem, prog.Bytes()) {
+		t.Errorf("Program exported to memory differs from the one exported to a buffer")
+	}
+}
+
+func TestPrecompute(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	call, err := GetSyscallFromName("getpid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	if err := filter.AddRule(call, ActEPerm); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	err = filter.Precompute()
+	if !checkVersionAbove(2, 6, 0) {
+		if _, ok := err.(VersionError); !ok {
+			t.Errorf("Precompute should fail with a VersionError, got %v", err)
+		}
+	} else if err != nil {
+		t.Errorf("Error precomputing filter: %s", err)
+	}
+}
+
+func TestTSkip(t *testing.T) {
+	filter, err := NewFilter(ActKillThread)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if err := filter.AddRule(-1, ActAllow); err == nil {
+		t.Errorf("Rule on syscall -1 added without TSKIP")
+	}
+
+	if !checkVersionAbove(2, 3, 0) {
+		if err := filter.SetTSkip(true); err == nil {
+			t.Errorf("Setting TSKIP should fail before libseccomp v2.3.0")
+		}
+		return
+	}
+	if err := filter.SetTSkip(true); err != nil {
+		t.Fatalf("Error setting TSKIP: %s", err)
+	}
+	if tskip, err := filter.GetTSkip(); err != nil || !tskip {
+		t.Errorf("Got TSKIP %t, %v", tskip, err)
+	}
+	if err := filter.AddRule(-1, ActAllow); err != nil {
+		t.Fatalf("Error adding rule on syscall -1: %s", err)
+	}
+	if err := filter.AddRule(-2, ActAllow); err == nil {
+		t.Errorf("Rule on invalid syscall number added")
+	}
+
+	// The attribute and the rule survive rebuilding the filter
+	if err := filter.AddArch(ArchX86); err != nil {
+		t.Fatalf("Error adding architecture: %s", err)
+	}
+	if tskip, err := filter.GetTSkip(); err != nil || !tskip {
+		t.Errorf("Got TSKIP %t, %v after adding an architecture", tskip, err)
+	}
+
+	if runtime.GOARCH != "amd64" {
+		return
+	}
+	const auditArchX86_64 = 0xc000003e
+	prog := exportProgram(t, filter)
+	if ret, err := bpf.Run(prog, &bpf.Data{Nr: -1, Arch: auditArchX86_64}); err != nil || ret != bpf.RetAllow {
+		t.Errorf("Skipped syscall got %#x, %v, want allowed", ret, err)
+	}
+	if ret, err := bpf.Run(prog, &bpf.Data{Nr: unix.SYS_GETPID, Arch: auditArchX86_64}); err != nil || ret == bpf.RetAllow {
+		t.Errorf("getpid got %#x, %v, want killed", ret, err)
+	}
+}
+
+func TestSyscallPriority(t *testing.T) {
+	getpid, err := GetSyscallFromName("getpid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	getppid, err := GetSyscallFromName("getppid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+
+	// Helper - Get the position of the first comparison with a syscall number
+	position := func(prog []bpf.Instruction, call ScmpSyscall) int {
+		for i, ins := range prog {
+			if ins.Op == bpf.ClassJmp|bpf.JmpJeq|bpf.SrcK && ins.K == uint32(call) {
+				return i
+			}
+		}
+		t.Fatalf("Syscall %d not found in the filter program", call)
+		return -1
+	}
+
+	for _, calls := range [][2]ScmpSyscall{{getpid, getppid}, {getppid, getpid}} {
+		filter, err := NewFilter(ActAllow)
+		if err != nil {
+			t.Fatalf("Error creating filter: %s", err)
+		}
+		defer filter.Release()
+
+		for _, call := range calls {
+			if err := filter.AddRule(call, ActEPerm); err != nil {
+				t.Fatalf("Error adding rule: %s", err)
+			}
+		}
+		if err := filter.SetSyscallPriority(calls[1], 255); err != nil {
+			t.Fatalf("Error setting syscall priority: %s", err)
+		}
+
+		prog := exportProgram(t, filter)
+		if position(prog, calls[1]) > position(prog, calls[0]) {
+			t.Errorf("Syscall %d with the highest priority is not checked first", calls[1])
+		}
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	filter.Release()
+	if err := filter.SetSyscallPriority(getpid, 255); err != ErrInvalidFilter {
+		t.Errorf("Setting syscall priority on an invalid filter should fail with ErrInvalidFilter, got %v", err)
+	}
+}
+
+func TestOptimizeLevel(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	if !checkVersionAbove(2, 5, 0) {
+		if err := filter.SetOptimizeLevel(2); err == nil {
+			t.Errorf("Setting the optimization level should fail before libseccomp v2.5.0")
+		}
+		t.Skipf("Skipping test: optimization levels are not supported")
+	}
+
+	if level, err := filter.GetOptimizeLevel(); err != nil {
+		t.Errorf("Error getting optimization level: %s", err)
+	} else if level != 1 {
+		t.Errorf("Expected default optimization level 1, got %d", level)
+	}
+
+	if err := filter.SetOptimizeLevel(3); err == nil {
+		t.Errorf("Setting an invalid optimization level should fail")
+	}
+	if err := filter.SetOptimizeLevel(2); err != nil {
+		t.Fatalf("Error setting optimization level: %s", err)
+	}
+	if level, err := filter.GetOptimizeLevel(); err != nil {
+		t.Errorf("Error getting optimization level: %s", err)
+	} else if level != 2 {
+		t.Errorf("Optimization level was not set correctly")
+	}
+
+	for _, name := range []string{"getpid", "getppid", "getuid", "getgid"} {
+		call, err := GetSyscallFromName(name)
+		if err != nil {
+			t.Fatalf("Error getting syscall number: %s", err)
+		}
+		if err := filter.AddRule(call, ActEPerm); err != nil {
+			t.Fatalf("Error adding rule: %s", err)
+		}
+	}
+	if err := bpf.Validate(exportProgram(t, filter)); err != nil {
+		t.Errorf("Optimized filter program is invalid: %s", err)
+	}
+}
+
+func TestLogAct(t *testing.T) {
+	execInSubprocess(t, subprocessLogAct)
+}
+func subprocessLogAct(t *testing.T) {
+	expectedPid := unix.Getpid()
+
+	api, err := GetAPI()
+	if err != nil {
+		if !APILevelIsSupported() {
 			t.Skipf("Skipping test: %s", err)
 		}
 
@@ -727,7 +1901,7 @@ func subprocessLogAct(t *testing.T) {
 	}
 
 	// Try making a simple syscall, it should succeed
-	pid := syscall.Getpid()
+	pid := unix.Getpid()
 	if pid != expectedPid {
 		t.Errorf("Syscall should have returned expected pid (%d != %d)", pid, expectedPid)
 	}
@@ -742,33 +1916,80 @@ func subprocessCreateActKillThreadFilter(t *testing.T) {
 		t.Errorf("Error creating filter: %s", err)
 	}
 
-	if !filter.IsValid() {
-		t.Errorf("Filter created by NewFilter was not valid")
+	if !filter.IsValid() {
+		t.Errorf("Filter created by NewFilter was not valid")
+	}
+}
+
+func TestCreateActKillProcessFilter(t *testing.T) {
+	execInSubprocess(t, subprocessCreateActKillProcessFilter)
+}
+func subprocessCreateActKillProcessFilter(t *testing.T) {
+	api, err := GetAPI()
+	if err != nil {
+		if !APILevelIsSupported() {
+			t.Skipf("Skipping test: %s", err)
+		}
+
+		t.Errorf("Error getting API level: %s", err)
+	} else if api < 3 {
+		t.Skipf("Skipping test: API level %d is less than 3", api)
+	}
+
+	filter, err := NewFilter(ActKillThread)
+	if err != nil {
+		t.Errorf("Error creating filter: %s", err)
+	}
+
+	if !filter.IsValid() {
+		t.Errorf("Filter created by NewFilter was not valid")
+	}
+}
+
+func TestConfineSelf(t *testing.T) {
+	execInSubprocess(t, subprocessConfineSelf)
+}
+func subprocessConfineSelf(t *testing.T) {
+	if err := ConfineSelf(ConfineOptions{}); err == nil {
+		t.Errorf("ConfineSelf without a filter should fail")
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	call, err := GetSyscallFromName("getpid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number of getpid: %s", err)
+	}
+
+	if err := filter.AddRule(call, ActErrno.SetReturnCode(0x1)); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
 	}
-}
 
-func TestCreateActKillProcessFilter(t *testing.T) {
-	execInSubprocess(t, subprocessCreateActKillProcessFilter)
-}
-func subprocessCreateActKillProcessFilter(t *testing.T) {
-	api, err := GetAPI()
+	err = ConfineSelf(ConfineOptions{Filter: filter, ClearAmbientCaps: true})
 	if err != nil {
-		if !APILevelIsSupported() {
-			t.Skipf("Skipping test: %s", err)
-		}
+		t.Fatalf("Error confining process: %s", err)
+	}
 
-		t.Errorf("Error getting API level: %s", err)
-	} else if api < 3 {
-		t.Skipf("Skipping test: API level %d is less than 3", api)
+	if pid := unix.Getpid(); pid != -1 {
+		t.Errorf("Syscall should have returned error code!")
 	}
 
-	filter, err := NewFilter(ActKillThread)
-	if err != nil {
-		t.Errorf("Error creating filter: %s", err)
+	nnp, err := unix.PrctlRetInt(unix.PR_GET_NO_NEW_PRIVS, 0, 0, 0, 0)
+	if err != nil || nnp != 1 {
+		t.Errorf("No New Privileges bit is not set after ConfineSelf")
 	}
 
-	if !filter.IsValid() {
-		t.Errorf("Filter created by NewFilter was not valid")
+	// A second filter must not be stacked without consent
+	if err := ConfineSelf(ConfineOptions{Filter: filter}); err == nil {
+		t.Errorf("ConfineSelf should refuse to stack filters by default")
+	}
+
+	if err := ConfineSelf(ConfineOptions{Filter: filter, AllowStacking: true}); err != nil {
+		t.Errorf("Error stacking filter: %s", err)
 	}
 }
 
@@ -821,6 +2042,13 @@ func notifHandler(ch chan error, fd ScmpFd, tests []notifTest) {
 			ch <- fmt.Errorf("TOCTOU check failed: req.ID is no longer valid: %s", err)
 			return
 		}
+		if valid, err := NotifIDsValid(fd, []uint64{req.ID, req.ID + 1}); err != nil {
+			ch <- fmt.Errorf("Error in NotifIDsValid(): %s", err)
+			return
+		} else if !valid[0] || valid[1] {
+			ch <- fmt.Errorf("Error in NotifIDsValid(): got %v, want [true false]", valid)
+			return
+		}
 
 		resp := &ScmpNotifResp{
 			ID:    req.ID,
@@ -893,11 +2121,11 @@ func subprocessNotif(t *testing.T) {
 		t.Errorf("Error adding rule to log syscall: %s", err)
 	}
 
-	nonExistentPath, err := syscall.BytePtrFromString("/non-existent-path")
+	nonExistentPath, err := unix.BytePtrFromString("/non-existent-path")
 	if err != nil {
 		t.Errorf("Error converting string: %s", err)
 	}
-	currentWorkingDirectory, err := syscall.BytePtrFromString(cwd)
+	currentWorkingDirectory, err := unix.BytePtrFromString(cwd)
 	if err != nil {
 		t.Errorf("Error converting string: %s", err)
 	}
@@ -910,7 +2138,7 @@ func subprocessNotif(t *testing.T) {
 			respErr:     0,
 			respVal:     0,
 			respFlags:   NotifRespFlagContinue,
-			expectedErr: syscall.ENOENT,
+			expectedErr: unix.ENOENT,
 			expectedVal: ^uint64(0), // -1
 		},
 		{
@@ -920,27 +2148,27 @@ func subprocessNotif(t *testing.T) {
 			respErr:     0,
 			respVal:     0,
 			respFlags:   NotifRespFlagContinue,
-			expectedErr: syscall.Errno(0),
+			expectedErr: unix.Errno(0),
 			expectedVal: 0,
 		},
 		{
 			syscall:     call,
 			args:        [6]uintptr{uintptr(unsafe.Pointer(nonExistentPath)), 0, 0, 0, 0, 0},
 			arch:        arch,
-			respErr:     int32(syscall.ENOMEDIUM),
+			respErr:     int32(unix.ENOMEDIUM),
 			respVal:     ^uint64(0), // -1
 			respFlags:   0,
-			expectedErr: syscall.ENOMEDIUM,
+			expectedErr: unix.ENOMEDIUM,
 			expectedVal: ^uint64(0), // -1
 		},
 		{
 			syscall:     call,
 			args:        [6]uintptr{uintptr(unsafe.Pointer(currentWorkingDirectory)), 0, 0, 0, 0, 0},
 			arch:        arch,
-			respErr:     int32(syscall.EPIPE),
+			respErr:     int32(unix.EPIPE),
 			respVal:     ^uint64(0), // -1
 			respFlags:   0,
-			expectedErr: syscall.EPIPE,
+			expectedErr: unix.EPIPE,
 			expectedVal: ^uint64(0), // -1
 		},
 	}
@@ -968,7 +2196,7 @@ func subprocessNotif(t *testing.T) {
 
 		for i, test := range tests {
 			infoChan <- fmt.Sprintf("Starting test %d", i)
-			r1, r2, err := syscall.Syscall6(syscall.SYS_CHDIR,
+			r1, r2, err := unix.Syscall6(unix.SYS_CHDIR,
 				test.args[0], test.args[1], test.args[2], test.args[3], test.args[4], test.args[5])
 			if err != test.expectedErr || uint64(r1) != test.expectedVal {
 				errorChan <- fmt.Errorf("test #%d: error in syscall: want \"%s\", got \"%s\" (want %v, got r1=%v, r2=%v)",
@@ -1002,6 +2230,538 @@ L:
 	}
 }
 
+func TestNotifAddFd(t *testing.T) {
+	execInSubprocess(t, subprocessNotifAddFd)
+}
+func subprocessNotifAddFd(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	// Only the target goroutine uses getppid, see subprocessNotif
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	file, err := os.Open("/dev/null")
+	if err != nil {
+		t.Fatalf("Error opening file: %s", err)
+	}
+	defer file.Close()
+
+	errorChan := make(chan error, 1)
+	go func() {
+		req, err := NotifReceive(fd)
+		if err != nil {
+			errorChan <- fmt.Errorf("Error in NotifReceive(): %s", err)
+			return
+		}
+
+		addFd := &ScmpNotifAddFd{
+			ID:         req.ID,
+			SrcFd:      uint32(file.Fd()),
+			NewFdFlags: unix.O_CLOEXEC,
+		}
+		newFd, err := NotifAddFd(fd, addFd)
+		if err == unix.ENOTTY {
+			NotifRespond(fd, &ScmpNotifResp{ID: req.ID, Flags: NotifRespFlagContinue})
+			errorChan <- err
+			return
+		} else if err != nil {
+			errorChan <- fmt.Errorf("Error in NotifAddFd(): %s", err)
+			return
+		}
+
+		errorChan <- NotifRespond(fd, &ScmpNotifResp{ID: req.ID, Val: uint64(newFd)})
+	}()
+
+	r1, _, errno := unix.Syscall(unix.SYS_GETPPID, 0, 0, 0)
+	if err := <-errorChan; err == unix.ENOTTY {
+		t.Skipf("Skipping test: SECCOMP_IOCTL_NOTIF_ADDFD is not supported")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if errno != 0 {
+		t.Fatalf("Error in emulated syscall: %s", errno)
+	}
+	defer unix.Close(int(r1))
+
+	var want, got unix.Stat_t
+	if err := unix.Fstat(int(file.Fd()), &want); err != nil {
+		t.Fatalf("Error in Fstat(): %s", err)
+	}
+	if err := unix.Fstat(int(r1), &got); err != nil {
+		t.Fatalf("Error in Fstat() of the installed fd: %s", err)
+	}
+	if got.Dev != want.Dev || got.Ino != want.Ino {
+		t.Errorf("Installed fd %d does not refer to the source file", r1)
+	}
+
+	if _, err := NotifAddFd(fd, &ScmpNotifAddFd{ID: ^uint64(0), SrcFd: uint32(file.Fd())}); err != ErrNotifTargetGone {
+		t.Errorf("NotifAddFd() with an invalid ID: got %v, want %v", err, ErrNotifTargetGone)
+	}
+
+	// Install the fd and respond at once, natively and with the fallback for
+	// older kernels
+	for i, addFd := range []func(*ScmpNotifAddFd) (int, error){
+		func(req *ScmpNotifAddFd) (int, error) { return NotifAddFd(fd, req) },
+		func(req *ScmpNotifAddFd) (int, error) { return notifAddFdAndRespond(fd, req) },
+	} {
+		results := make(chan uintptr, 1)
+		go func() {
+			r1, _, _ := unix.Syscall(unix.SYS_GETPPID, 0, 0, 0)
+			results <- r1
+		}()
+
+		req, err := NotifReceive(fd)
+		if err != nil {
+			t.Fatalf("Error in NotifReceive(): %s", err)
+		}
+		newFd, err := addFd(&ScmpNotifAddFd{
+			ID:         req.ID,
+			Flags:      NotifAddFdFlagSend,
+			SrcFd:      uint32(file.Fd()),
+			NewFdFlags: unix.O_CLOEXEC,
+		})
+		if err != nil {
+			t.Fatalf("Test %d: error installing fd and responding: %s", i, err)
+		}
+
+		if r1 := <-results; r1 != uintptr(newFd) {
+			t.Errorf("Test %d: syscall returned %d, want installed fd %d", i, r1, newFd)
+		}
+		unix.Close(newFd)
+	}
+}
+
+func TestNotifSetFlags(t *testing.T) {
+	execInSubprocess(t, subprocessNotifSetFlags)
+}
+func subprocessNotifSetFlags(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	if err := NotifSetFlags(fd, NotifFdFlagSyncWakeUp); err == unix.ENOTTY {
+		t.Skipf("Skipping test: SECCOMP_IOCTL_NOTIF_SET_FLAGS is not supported")
+	} else if err != nil {
+		t.Errorf("Error setting notification fd flags: %s", err)
+	}
+	if err := NotifSetFlags(fd, 1<<63); err != unix.EINVAL {
+		t.Errorf("Setting unknown notification fd flags: got %v, want %v", err, unix.EINVAL)
+	}
+}
+
+func TestGetNotifSizes(t *testing.T) {
+	sizes, err := GetNotifSizes()
+	if err == unix.EINVAL {
+		t.Skipf("Skipping test: seccomp notification is not supported")
+	} else if err != nil {
+		t.Fatalf("Error getting notification sizes: %s", err)
+	}
+
+	// The kernel structures only ever grow from their Linux 5.0 layout
+	if want := uint16(64); sizes.Data < want {
+		t.Errorf("seccomp_data size %d, want at least %d", sizes.Data, want)
+	}
+	if want := uint16(80); sizes.Notif < want {
+		t.Errorf("seccomp_notif size %d, want at least %d", sizes.Notif, want)
+	}
+	if want := uint16(24); sizes.NotifResp < want {
+		t.Errorf("seccomp_notif_resp size %d, want at least %d", sizes.NotifResp, want)
+	}
+}
+
+func TestNotifReceiveBatch(t *testing.T) {
+	execInSubprocess(t, subprocessNotifReceiveBatch)
+}
+func subprocessNotifReceiveBatch(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	// Only the target goroutines use chdir, see subprocessNotif
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_CHDIR), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	if _, err := NotifReceiveBatch(fd, 0); err == nil {
+		t.Errorf("Receiving an empty batch should fail")
+	}
+
+	const targets = 3
+	done := make(chan error, targets)
+	for i := 0; i < targets; i++ {
+		go func() {
+			done <- unix.Chdir("/")
+		}()
+	}
+	// Let all the targets block in their syscall
+	time.Sleep(100 * time.Millisecond)
+
+	first, err := NotifReceiveBatch(fd, targets-1)
+	if err != nil {
+		t.Fatalf("Error in NotifReceiveBatch(): %s", err)
+	} else if len(first) != targets-1 {
+		t.Errorf("First batch holds %d notifications, want %d", len(first), targets-1)
+	}
+	rest, err := NotifReceiveBatch(fd, targets)
+	if err != nil {
+		t.Fatalf("Error in NotifReceiveBatch(): %s", err)
+	}
+
+	reqs := append(first, rest...)
+	if len(reqs) != targets {
+		t.Fatalf("Received %d notifications, want %d", len(reqs), targets)
+	}
+	for _, req := range reqs {
+		if req.Data.Syscall != unix.SYS_CHDIR {
+			t.Errorf("Notification for syscall %d, want %d", req.Data.Syscall, unix.SYS_CHDIR)
+		}
+		resp := &ScmpNotifResp{ID: req.ID}
+		resp.SetErrno(unix.ENOMEDIUM)
+		if err := NotifRespond(fd, resp); err != nil {
+			t.Errorf("Error in NotifRespond(): %s", err)
+		}
+	}
+
+	for i := 0; i < targets; i++ {
+		if err := <-done; err != unix.ENOMEDIUM {
+			t.Errorf("Response not applied: got %v, want %v", err, unix.ENOMEDIUM)
+		}
+	}
+}
+
+func TestNotifExtraFields(t *testing.T) {
+	execInSubprocess(t, subprocessNotifExtraFields)
+}
+func subprocessNotifExtraFields(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	// Only the target goroutine uses getppid, see subprocessNotif
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+	sizes, err := GetNotifSizes()
+	if err != nil {
+		t.Fatalf("Error getting notification sizes: %s", err)
+	}
+
+	results := make(chan uintptr, 1)
+	go func() {
+		r1, _, _ := unix.Syscall(unix.SYS_GETPPID, 0, 0, 0)
+		results <- r1
+	}()
+
+	req, err := NotifReceive(fd)
+	if err != nil {
+		t.Fatalf("Error in NotifReceive(): %s", err)
+	}
+	// The bindings know the 80 bytes of the Linux 5.0 structure
+	if want := int(sizes.Notif) - 80; len(req.Extra) != want {
+		t.Errorf("Notification has %d bytes of extra fields, want %d", len(req.Extra), want)
+	}
+
+	// Extra fields the kernel does not know about can't be sent
+	extra := make([]byte, int(sizes.NotifResp)-24+1)
+	if err := NotifRespond(fd, &ScmpNotifResp{ID: req.ID, Val: 1, Extra: extra}); err == nil {
+		t.Errorf("Responding with too many extra fields should fail")
+	}
+	extra = extra[:len(extra)-1]
+	if err := NotifRespond(fd, &ScmpNotifResp{ID: req.ID, Val: 42, Extra: extra}); err != nil {
+		t.Fatalf("Error in NotifRespond(): %s", err)
+	}
+
+	if r1 := <-results; r1 != 42 {
+		t.Errorf("Emulated syscall returned %d, want 42", r1)
+	}
+}
+
+func TestNotifReceiveInto(t *testing.T) {
+	execInSubprocess(t, subprocessNotifReceiveInto)
+}
+func subprocessNotifReceiveInto(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	// Only the target goroutine uses getppid, see subprocessNotif
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	const runs = 100
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// AllocsPerRun() runs the function once more to warm up
+		for i := 0; i < runs+1; i++ {
+			unix.Syscall(unix.SYS_GETPPID, 0, 0, 0)
+		}
+	}()
+
+	var req ScmpNotifReq
+	var args *uint64
+	allocs := testing.AllocsPerRun(runs, func() {
+		if err := NotifReceiveInto(fd, &req); err != nil {
+			t.Fatalf("Error in NotifReceiveInto(): %s", err)
+		}
+		if args == nil {
+			args = &req.Data.Args[0]
+		} else if &req.Data.Args[0] != args {
+			t.Errorf("Syscall arguments were not reused")
+		}
+		if req.Data.Syscall != unix.SYS_GETPPID {
+			t.Errorf("Notification for syscall %d, want %d", req.Data.Syscall, unix.SYS_GETPPID)
+		}
+		if err := NotifRespondContinue(fd, req.ID); err != nil {
+			t.Fatalf("Error in NotifRespondContinue(): %s", err)
+		}
+	})
+	<-done
+
+	if allocs > 0 {
+		t.Errorf("Receiving and responding allocated %v times per notification", allocs)
+	}
+}
+
+func TestNotifClosed(t *testing.T) {
+	execInSubprocess(t, subprocessNotifClosed)
+}
+func subprocessNotifClosed(t *testing.T) {
+	if ErrNotifClosed != unix.EBADF {
+		t.Errorf("ErrNotifClosed is not EBADF")
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := NotifWait(ctx, fd); err != context.DeadlineExceeded {
+		t.Errorf("NotifWait() without notification: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// Keep fd in use, so that the receive gets woken up whether it is
+	// already waiting or not when fd is closed
+	w, err := notifAcquire(fd)
+	if err != nil {
+		t.Fatalf("Error using notification fd: %s", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := NotifReceive(fd)
+		done <- err
+	}()
+	if err := NotifClose(fd); err != nil {
+		t.Fatalf("Error closing notification fd: %s", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != ErrNotifClosed {
+			t.Errorf("NotifReceive() on a closed fd: got %v, want %v", err, ErrNotifClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("NotifReceive() still waiting after its fd was closed")
+	}
+
+	// fd is closed once no receive uses it anymore
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0); err != nil {
+		t.Errorf("Notification fd closed while in use: %s", err)
+	}
+	notifRelease(fd, w)
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0); err != unix.EBADF {
+		t.Errorf("Notification fd still open after closing: %v", err)
+	}
+}
+
+func TestNotifRespondTargetGone(t *testing.T) {
+	// The target process only makes the notified syscall
+	if os.Getenv("GO_NOTIF_TARGET") == "1" {
+		unix.Getppid()
+		return
+	}
+	execInSubprocess(t, subprocessNotifRespondTargetGone)
+}
+func subprocessNotifRespondTargetGone(t *testing.T) {
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	// The target inherits the filter
+	cmd := exec.Command(os.Args[0], "-test.run=^"+strings.TrimSuffix(t.Name(), "/subprocess")+"$")
+	cmd.Env = []string{"GO_NOTIF_TARGET=1"}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Error starting target: %s", err)
+	}
+
+	req, err := NotifReceive(fd)
+	if err != nil {
+		t.Fatalf("Error in NotifReceive(): %s", err)
+	}
+	if req.Pid != uint32(cmd.Process.Pid) {
+		t.Fatalf("Notification from pid %d, want %d", req.Pid, cmd.Process.Pid)
+	}
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatalf("Error killing target: %s", err)
+	}
+	cmd.Wait()
+
+	start := time.Now()
+	if err := NotifRespond(fd, &ScmpNotifResp{ID: req.ID}); err != ErrNotifTargetGone {
+		t.Errorf("Responding to a killed target: got %v, want %v", err, ErrNotifTargetGone)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Responding to a killed target took %s", elapsed)
+	}
+}
+
+func TestNotifRespondHelpers(t *testing.T) {
+	execInSubprocess(t, subprocessNotifRespondHelpers)
+}
+func subprocessNotifRespondHelpers(t *testing.T) {
+	// getppid is notified on once the filter is loaded
+	ppid := uintptr(os.Getppid())
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+	if err := filter.SetAutoAPI(true); err != nil {
+		t.Fatalf("Error enabling automatic API level: %s", err)
+	}
+
+	// Only the target goroutine uses getppid, see subprocessNotif
+	if err := filter.AddRule(ScmpSyscall(unix.SYS_GETPPID), ActNotify); err != nil {
+		t.Skipf("Skipping test: seccomp notification is not supported: %s", err)
+	}
+	fd, err := filter.LoadAndGetNotifFd()
+	if err != nil {
+		t.Fatalf("Error loading filter: %s", err)
+	}
+
+	tests := []struct {
+		respond func(id uint64) error
+		val     uintptr
+		errno   unix.Errno
+	}{
+		{func(id uint64) error { return NotifRespondErrno(fd, id, unix.EPERM) }, ^uintptr(0), unix.EPERM},
+		{func(id uint64) error { return NotifRespondSuccess(fd, id, 42) }, 42, 0},
+		{func(id uint64) error { return NotifRespondContinue(fd, id) }, ppid, 0},
+	}
+
+	for i, test := range tests {
+		type result struct {
+			val   uintptr
+			errno unix.Errno
+		}
+		results := make(chan result, 1)
+		go func() {
+			r1, _, errno := unix.Syscall(unix.SYS_GETPPID, 0, 0, 0)
+			results <- result{r1, errno}
+		}()
+
+		req, err := NotifReceive(fd)
+		if err != nil {
+			t.Fatalf("Error in NotifReceive(): %s", err)
+		}
+		if err := NotifRespondErrno(fd, req.ID, 0); err == nil {
+			t.Errorf("Responding with error 0 should fail")
+		}
+		if err := test.respond(req.ID); err != nil {
+			t.Fatalf("Test %d: error responding: %s", i, err)
+		}
+		// The notification was answered already
+		if err := NotifRespond(fd, &ScmpNotifResp{ID: req.ID}); err != ErrNotifTargetGone {
+			t.Errorf("Responding to an answered notification: got %v, want %v", err, ErrNotifTargetGone)
+		}
+
+		if res := <-results; res.val != test.val || res.errno != test.errno {
+			t.Errorf("Test %d: syscall returned %d (%v), want %d (%v)", i, res.val, res.errno, test.val, test.errno)
+		}
+	}
+}
+
 // TestNotifUnsupported is checking that the user notify API correctly returns
 // an error when we don't have the proper api level, for example when linking
 // with libseccomp < 2.5.0.
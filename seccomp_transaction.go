@@ -0,0 +1,132 @@
+// +build linux
+
+// Filter transactions for libseccomp Go bindings
+// Applies or discards batches of filter changes atomically
+
+package seccomp
+
+import (
+	"fmt"
+)
+
+var (
+	// ErrTransactionDone is returned when committing or rejecting a
+	// transaction which already ended, or which is not the innermost
+	// transaction of its filter
+	ErrTransactionDone = fmt.Errorf("transaction is not active")
+)
+
+// ScmpTransaction is a batch of changes to the rules and architectures of a
+// filter, which are either all applied or all discarded. Filter attributes
+// are not covered by transactions.
+// Transactions can be nested; the innermost transaction must end first.
+type ScmpTransaction struct {
+	filter *ScmpFilter
+	// Whether a libseccomp transaction backs this transaction
+	native bool
+	// Tracked state of the filter when the transaction began
+	arches     []ScmpArch
+	rules      []ScmpRule
+	priorities map[ScmpSyscall]uint8
+}
+
+// BeginTransaction starts a transaction on the filter. The changes made to
+// the filter until the transaction ends are applied by Commit, or discarded
+// by Reject.
+// With libseccomp versions below v2.6.0, which lack transactions, Reject
+// rebuilds the filter from the rules tracked by the bindings instead, see
+// AddRuleForArch.
+// Returns an error if the filter is invalid or the transaction could not be
+// started.
+func (f *ScmpFilter) BeginTransaction() (*ScmpTransaction, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.valid {
+		return nil, ErrInvalidFilter
+	}
+
+	native, err := f.transactionStart()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &ScmpTransaction{
+		filter:     f,
+		native:     native,
+		arches:     f.filterArches(),
+		rules:      append([]ScmpRule(nil), f.rules...),
+		priorities: make(map[ScmpSyscall]uint8, len(f.priorities)),
+	}
+	for call, priority := range f.priorities {
+		tx.priorities[call] = priority
+	}
+	f.transactions = append(f.transactions, tx)
+
+	return tx, nil
+}
+
+// Commit applies the changes made during the transaction.
+// Returns ErrTransactionDone if the transaction is not the innermost active
+// transaction of its filter, or an error if it could not be committed.
+func (tx *ScmpTransaction) Commit() error {
+	f := tx.filter
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	if tx.native {
+		if err := f.transactionCommit(); err != nil {
+			return err
+		}
+	}
+	f.transactions = f.transactions[:len(f.transactions)-1]
+
+	return nil
+}
+
+// Reject discards the changes made during the transaction, restoring the
+// rules and architectures the filter had when it began.
+// Returns ErrTransactionDone if the transaction is not the innermost active
+// transaction of its filter, or an error if the filter could not be
+// restored.
+func (tx *ScmpTransaction) Reject() error {
+	f := tx.filter
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	rules, priorities := f.rules, f.priorities
+	f.rules, f.priorities = tx.rules, tx.priorities
+
+	if tx.native {
+		f.transactionReject()
+	} else if err := f.rebuild(tx.arches); err != nil {
+		f.rules, f.priorities = rules, priorities
+		return fmt.Errorf("could not restore filter: %v", err)
+	}
+	f.transactions = f.transactions[:len(f.transactions)-1]
+
+	return nil
+}
+
+// Helper - Check that the transaction is the innermost active one
+// Requires the filter lock
+func (tx *ScmpTransaction) check() error {
+	f := tx.filter
+	if !f.valid {
+		return ErrInvalidFilter
+	}
+
+	if len(f.transactions) == 0 || f.transactions[len(f.transactions)-1] != tx {
+		return ErrTransactionDone
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+// +build linux
+
+// Tests for filter transactions of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/seccomp/libseccomp-golang/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// Helper - Run the exported program of a filter on an x86_64 syscall
+func runAMD64(t *testing.T, filter *ScmpFilter, call ScmpSyscall) uint32 {
+	const auditArchX86_64 = 0xc000003e
+
+	ret, err := bpf.Run(exportProgram(t, filter), &bpf.Data{Nr: int32(call), Arch: auditArchX86_64})
+	if err != nil {
+		t.Fatalf("Error running exported filter: %s", err)
+	}
+
+	return ret
+}
+
+func TestTransaction(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("Skipping test: filter program is checked for amd64")
+	}
+
+	filter, err := NewFilter(ActAllow)
+	if err != nil {
+		t.Fatalf("Error creating filter: %s", err)
+	}
+	defer filter.Release()
+
+	getppid, err := GetSyscallFromName("getppid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+	getpid, err := GetSyscallFromName("getpid")
+	if err != nil {
+		t.Fatalf("Error getting syscall number: %s", err)
+	}
+
+	if err := filter.AddRule(getpid, ActEPerm); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+
+	tx, err := filter.BeginTransaction()
+	if err != nil {
+		t.Fatalf("Error beginning transaction: %s", err)
+	}
+	if err := filter.AddRule(getppid, ActEPerm); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+	inner, err := filter.BeginTransaction()
+	if err != nil {
+		t.Fatalf("Error beginning nested transaction: %s", err)
+	}
+	if err := tx.Commit(); err != ErrTransactionDone {
+		t.Errorf("Committed outer transaction before the nested one: %v", err)
+	}
+	if err := inner.Commit(); err != nil {
+		t.Fatalf("Error committing nested transaction: %s", err)
+	}
+	if err := tx.Reject(); err != nil {
+		t.Fatalf("Error rejecting transaction: %s", err)
+	}
+	if err := tx.Reject(); err != ErrTransactionDone {
+		t.Errorf("Rejected transaction twice: %v", err)
+	}
+
+	if ret := runAMD64(t, filter, getppid); ret != bpf.RetAllow {
+		t.Errorf("Rule of rejected transaction applied: got %s", bpf.ActionString(ret))
+	}
+	if ret := runAMD64(t, filter, getpid); ret != bpf.RetErrno|uint32(unix.EPERM) {
+		t.Errorf("Rule added before the transaction lost: got %s", bpf.ActionString(ret))
+	}
+	if len(filter.rules) != 1 {
+		t.Errorf("Expected 1 tracked rule after rejecting, got %d", len(filter.rules))
+	}
+
+	tx, err = filter.BeginTransaction()
+	if err != nil {
+		t.Fatalf("Error beginning transaction: %s", err)
+	}
+	if err := filter.AddRule(getppid, ActEPerm); err != nil {
+		t.Fatalf("Error adding rule: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Error committing transaction: %s", err)
+	}
+
+	if ret := runAMD64(t, filter, getppid); ret != bpf.RetErrno|uint32(unix.EPERM) {
+		t.Errorf("Rule of committed transaction not applied: got %s", bpf.ActionString(ret))
+	}
+
+	tx, err = filter.BeginTransaction()
+	if err != nil {
+		t.Fatalf("Error beginning transaction: %s", err)
+	}
+	if err := filter.Reset(ActAllow); err != nil {
+		t.Fatalf("Error resetting filter: %s", err)
+	}
+	if err := tx.Commit(); err != ErrTransactionDone {
+		t.Errorf("Committed transaction across a reset: %v", err)
+	}
+}
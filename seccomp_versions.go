@@ -0,0 +1,51 @@
+// +build linux
+
+// Syscall kernel versions for libseccomp Go bindings
+// Relates syscalls to the version of Linux which introduced them
+
+package seccomp
+
+import (
+	"fmt"
+)
+
+//go:generate go run ./internal/mksyscallversions -names $LIBSECCOMP/src/syscalls.csv -kernel 6.13 -o seccomp_versions_table.go $LINUX_HISTORY $LINUX
+
+// SyscallVersionsKernel is the version of Linux whose syscalls the kernel
+// versions of SyscallKernelVersion are up to date with.
+const SyscallVersionsKernel = "6.13"
+
+// Kernel versions of the syscalls of syscallVersions, by name
+var syscallVersionByName = func() map[string]string {
+	byName := make(map[string]string)
+	for version, names := range syscallVersions {
+		for _, name := range names {
+			byName[name] = version
+		}
+	}
+	return byName
+}()
+
+// SyscallKernelVersion returns the version of Linux which introduced a
+// syscall of the native architecture, e.g. "3.17" for seccomp, so that
+// profiles can be checked against the kernels they are deployed on.
+// Syscalls are dated by their first implementation on any architecture, as
+// read from the syscall tables of each release, and may have been added to
+// some architectures later. Syscalls which predate Linux 2.6.0 are reported
+// as "2.6.0".
+// Returns the version, or an error if the syscall is unknown, was never
+// implemented, is private to an architecture, or is newer than
+// SyscallVersionsKernel.
+func SyscallKernelVersion(call ScmpSyscall) (string, error) {
+	name, err := call.GetName()
+	if err != nil {
+		return "", err
+	}
+
+	version, ok := syscallVersionByName[name]
+	if !ok {
+		return "", fmt.Errorf("no kernel version known for syscall %s", name)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,57 @@
+// +build linux
+
+// Tests for the syscall kernel versions of libseccomp Go bindings
+
+package seccomp
+
+import (
+	"testing"
+)
+
+func TestSyscallKernelVersion(t *testing.T) {
+	for name, version := range map[string]string{
+		"read":             "2.6.0",
+		"mbind":            "2.6.7",
+		"kexec_load":       "2.6.13",
+		"openat":           "2.6.16",
+		"accept4":          "2.6.28",
+		"process_vm_readv": "3.2",
+		"seccomp":          "3.17",
+		"statx":            "4.11",
+	} {
+		call, err := GetSyscallFromName(name)
+		if err != nil {
+			t.Fatalf("Error getting syscall number of %s: %s", name, err)
+		}
+		if got, err := SyscallKernelVersion(call); err != nil || got != version {
+			t.Errorf("Got %s introduced in %q, %v, expected %q", name, got, err, version)
+		}
+	}
+
+	if _, err := SyscallKernelVersion(4095); err == nil {
+		t.Errorf("Expected an error for an unknown syscall")
+	}
+
+	// Syscalls libseccomp knows but no kernel implements are not dated
+	for _, name := range []string{"tuxcall", "afs_syscall", "multiplexer"} {
+		call, err := GetSyscallFromName(name)
+		if err != nil {
+			continue
+		}
+		if version, err := SyscallKernelVersion(call); err == nil {
+			t.Errorf("Got %s introduced in %q, expected an error", name, version)
+		}
+	}
+}
+
+func TestSyscallVersionsUnique(t *testing.T) {
+	seen := make(map[string]string)
+	for version, names := range syscallVersions {
+		for _, name := range names {
+			if other, ok := seen[name]; ok {
+				t.Errorf("Syscall %s listed for both %s and %s", name, version, other)
+			}
+			seen[name] = version
+		}
+	}
+}